@@ -2,19 +2,17 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"fmt"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
-	"time"
 
 	"github.com/Azure/open-service-broker-azure/pkg/api/authenticator/basic"
 	"github.com/Azure/open-service-broker-azure/pkg/broker"
-	"github.com/Azure/open-service-broker-azure/pkg/crypto/aes256"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
 	log "github.com/Sirupsen/logrus"
-	"github.com/go-redis/redis"
+	"github.com/urfave/cli"
 )
 
 func init() {
@@ -49,37 +47,80 @@ func init() {
 }
 
 func main() {
-	// Redis client
-	redisConfig, err := getRedisConfig()
-	if err != nil {
-		log.Fatal(err)
-	}
-	redisOpts := &redis.Options{
-		Addr:       fmt.Sprintf("%s:%d", redisConfig.Host, redisConfig.Port),
-		Password:   redisConfig.Password,
-		DB:         redisConfig.DB,
-		MaxRetries: 5,
+	app := cli.NewApp()
+	app.Name = "broker"
+	app.Usage = "Open Service Broker for Azure"
+	app.Action = runBroker
+	app.Commands = []cli.Command{
+		{
+			Name: "migrate",
+			Usage: "upgrade all instances and bindings to the current schema " +
+				"versions of their module-specific contexts",
+			Action: migrateSchemas,
+		},
+		{
+			Name: "reencrypt",
+			Usage: "re-encrypt all instances and bindings using the active " +
+				"encryption key, binding each value to the record it belongs to; " +
+				"run this before upgrading from a broker that didn't bind values",
+			Action: reencrypt,
+		},
+		{
+			Name:  "state",
+			Usage: "export or import instances and bindings",
+			Subcommands: []cli.Command{
+				{
+					Name: "export",
+					Usage: "write all instances and bindings to an archive; encrypted " +
+						"fields remain encrypted",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  flagFile,
+							Usage: "specify the archive `<path>`; defaults to stdout",
+						},
+					},
+					Action: exportState,
+				},
+				{
+					Name: "import",
+					Usage: "restore instances and bindings from an archive; the " +
+						"broker must be configured with the same encryption key the " +
+						"archive was exported with",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  flagFile,
+							Usage: "specify the archive `<path>`; defaults to stdin",
+						},
+					},
+					Action: importState,
+				},
+			},
+		},
 	}
-	if redisConfig.EnableTLS {
-		redisOpts.TLSConfig = &tls.Config{
-			ServerName: redisConfig.Host,
-		}
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
 	}
-	redisClient := redis.NewClient(redisOpts)
+}
 
-	// Crypto
-	cryptoConfig, err := getCryptoConfig()
+func runBroker(*cli.Context) error {
+	// Storage and async engine
+	store, asyncEngine, err := getStoreAndAsyncEngine()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	codec, err := aes256.NewCodec([]byte(cryptoConfig.AES256Key))
+
+	// Crypto
+	codec, err := getCodec()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
+	// Upgrade instances and bindings to current schema versions as they're read
+	store = storage.NewMigratingStore(store, getMigrationRegistry(), codec)
+
 	basicAuthConfig, err := getBasicAuthConfig()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	authenticator := basic.NewAuthenticator(
 		basicAuthConfig.Username,
@@ -88,17 +129,24 @@ func main() {
 
 	modulesConfig, err := getModulesConfig()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	azureConfig, err := getAzureConfig()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	asyncConfig, err := getAsyncConfig()
+	if err != nil {
+		return err
 	}
 
 	// Create broker
 	broker, err := broker.NewBroker(
-		redisClient,
+		store,
+		asyncEngine,
+		asyncConfig.JobsMaxConcurrency,
 		codec,
 		authenticator,
 		modules,
@@ -107,7 +155,7 @@ func main() {
 		azureConfig.DefaultResourceGroup,
 	)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -125,12 +173,22 @@ func main() {
 	}()
 
 	// Run broker
-	if err := broker.Start(ctx); err != nil {
-		if err == ctx.Err() {
-			// Allow some time for goroutines to shut down
-			time.Sleep(time.Second * 3)
-		} else {
-			log.Fatal(err)
+	// Start blocks until every component, including the async engine, which
+	// drains in-flight tasks, has shut down
+	if err := broker.Start(ctx); err != nil && err != ctx.Err() {
+		return err
+	}
+	return nil
+}
+
+// getMigrationRegistry returns a registry of schema migrations for the
+// module-specific contexts of all modules
+func getMigrationRegistry() service.MigrationRegistry {
+	registry := service.NewMigrationRegistry()
+	for _, module := range modules {
+		if migrator, ok := module.(service.SchemaMigrator); ok {
+			migrator.RegisterMigrations(registry)
 		}
 	}
+	return registry
 }
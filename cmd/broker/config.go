@@ -1,14 +1,27 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 	log "github.com/Sirupsen/logrus"
 	"github.com/kelseyhightower/envconfig"
 )
 
+const (
+	keyEncrypterTypeAzureKeyVault = "AZURE_KEY_VAULT"
+	keyEncrypterTypeFile          = "FILE"
+)
+
+const (
+	storageTypeRedis      = "REDIS"
+	storageTypePostgreSQL = "POSTGRESQL"
+	storageTypeBoltDB     = "BOLTDB"
+)
+
 // logConfig represents configuration options for the broker's leveled logging
 type logConfig struct {
 	LevelStr string `envconfig:"LOG_LEVEL" default:"INFO"`
@@ -17,19 +30,86 @@ type logConfig struct {
 
 // redisConfig represents details for connecting to the Redis instance that
 // the broker itself relies on for storing state and orchestrating asynchronous
-// processes
+// processes. All keys the broker uses are namespaced by the key prefix so that
+// multiple brokers may share a single Redis database. The broker refuses to
+// start if it finds instances or bindings stored under the unprefixed keys used
+// by older versions of the broker, unless it is permitted to migrate them.
 type redisConfig struct {
-	Host      string `envconfig:"REDIS_HOST" required:"true"`
-	Port      int    `envconfig:"REDIS_PORT" default:"6379"`
-	Password  string `envconfig:"REDIS_PASSWORD" default:""`
-	DB        int    `envconfig:"REDIS_DB" default:"0"`
-	EnableTLS bool   `envconfig:"REDIS_ENABLE_TLS" default:"false"`
+	Host              string `envconfig:"REDIS_HOST" required:"true"`
+	Port              int    `envconfig:"REDIS_PORT" default:"6379"`
+	Password          string `envconfig:"REDIS_PASSWORD" default:""`
+	DB                int    `envconfig:"REDIS_DB" default:"0"`
+	EnableTLS         bool   `envconfig:"REDIS_ENABLE_TLS" default:"false"`
+	KeyPrefix         string `envconfig:"REDIS_KEY_PREFIX" default:"osba"`
+	MigrateLegacyKeys bool   `envconfig:"REDIS_MIGRATE_LEGACY_KEYS" default:"false"` // nolint: lll
+}
+
+// asyncConfig represents tunable behavior of the async engine. Concurrency
+// limits for individual jobs are specified as comma-delimited job:limit pairs
+// (e.g. "deprovisionStep:5"). ASYNC_MAX_WORKER_REJECTIONS applies only to the
+// Redis-based async engine. All other options apply to both async engines.
+type asyncConfig struct {
+	MaxWorkerRejections int            `envconfig:"ASYNC_MAX_WORKER_REJECTIONS" default:"10"` // nolint: lll
+	WorkerPoolSize      int            `envconfig:"ASYNC_WORKER_POOL_SIZE" default:"5"`       // nolint: lll
+	DefaultTaskTimeout  time.Duration  `envconfig:"ASYNC_DEFAULT_TASK_TIMEOUT" default:"0"`   // nolint: lll
+	DrainTimeout        time.Duration  `envconfig:"ASYNC_DRAIN_TIMEOUT" default:"30s"`        // nolint: lll
+	JobsMaxConcurrency  map[string]int `envconfig:"ASYNC_JOBS_MAX_CONCURRENCY"`
+}
+
+// storageConfig represents configuration options for selecting the type of
+// storage the broker uses for persisting instances and bindings
+type storageConfig struct {
+	TypeStr string `envconfig:"STORAGE_TYPE" default:"REDIS"`
+}
+
+// postgresqlConfig represents details for connecting to the PostgreSQL database
+// that the broker relies on for storing state when STORAGE_TYPE is POSTGRESQL
+type postgresqlConfig struct {
+	Host      string `envconfig:"POSTGRESQL_HOST" required:"true"`
+	Port      int    `envconfig:"POSTGRESQL_PORT" default:"5432"`
+	Database  string `envconfig:"POSTGRESQL_DATABASE" default:"postgres"`
+	Username  string `envconfig:"POSTGRESQL_USERNAME" required:"true"`
+	Password  string `envconfig:"POSTGRESQL_PASSWORD" default:""`
+	EnableTLS bool   `envconfig:"POSTGRESQL_ENABLE_TLS" default:"true"`
+}
+
+// boltdbConfig represents details for locating the file that the broker relies
+// on for storing state and orchestrating asynchronous processes when
+// STORAGE_TYPE is BOLTDB
+type boltdbConfig struct {
+	Path string `envconfig:"BOLTDB_PATH" required:"true"`
 }
 
 // cryptoConfig represents details (e.g. key) for encrypting and decrypting any
-// (potentially) sensitive information
+// (potentially) sensitive information. If AES256_KEYS is set, values are
+// encrypted using the key identified by AES256_ACTIVE_KEY_ID and can be
+// decrypted using any of the keys. In that case, AES256_KEY is optional and,
+// if set, is used only to decrypt values encrypted before AES256_KEYS was set.
+// If KEY_ENCRYPTER is set, values are instead encrypted using envelope
+// encryption and the AES256 keys, if any, are used only for decryption.
 type cryptoConfig struct {
-	AES256Key string `envconfig:"AES256_KEY" required:"true"`
+	AES256Key string `envconfig:"AES256_KEY"`
+	// AES256Keys maps key IDs to keys. It is specified as a comma-delimited
+	// list of id:key pairs, so neither key IDs nor keys may contain commas or
+	// colons.
+	AES256Keys        map[string]string `envconfig:"AES256_KEYS"`
+	AES256ActiveKeyID string            `envconfig:"AES256_ACTIVE_KEY_ID"`
+	// KeyEncrypterType, if set, identifies the provider used to wrap the data
+	// keys used for envelope encryption
+	KeyEncrypterType   string `envconfig:"KEY_ENCRYPTER"`
+	KeyVaultURL        string `envconfig:"KEY_ENCRYPTER_KEY_VAULT_URL"`
+	KeyVaultKeyName    string `envconfig:"KEY_ENCRYPTER_KEY_VAULT_KEY_NAME"`
+	KeyVaultKeyVersion string `envconfig:"KEY_ENCRYPTER_KEY_VAULT_KEY_VERSION"` // nolint: lll
+	KeyFilePath        string `envconfig:"KEY_ENCRYPTER_FILE_PATH"`
+	// AllowUnboundCiphertexts permits decryption of values that were encrypted
+	// before ciphertexts were bound to the records and fields they belong to.
+	// Such ciphertexts can be copied between records undetected, so they are
+	// refused by default. Operators upgrading from a version of the broker that
+	// didn't bind ciphertexts should run the reencrypt command before starting
+	// the upgraded broker. Enabling this is a temporary measure only for
+	// deployments that cannot do that and should be reverted once reencrypt has
+	// been run.
+	AllowUnboundCiphertexts bool `envconfig:"ALLOW_UNBOUND_CIPHERTEXTS" default:"false"` // nolint: lll
 }
 
 type basicAuthConfig struct {
@@ -63,10 +143,111 @@ func getRedisConfig() (redisConfig, error) {
 	return rc, err
 }
 
+func getAsyncConfig() (asyncConfig, error) {
+	ac := asyncConfig{}
+	err := envconfig.Process("", &ac)
+	if err != nil {
+		return ac, err
+	}
+	if ac.WorkerPoolSize < 1 {
+		return ac, errors.New("ASYNC_WORKER_POOL_SIZE must be at least 1")
+	}
+	if ac.DefaultTaskTimeout < 0 {
+		return ac, errors.New("ASYNC_DEFAULT_TASK_TIMEOUT must not be negative")
+	}
+	if ac.DrainTimeout < 0 {
+		return ac, errors.New("ASYNC_DRAIN_TIMEOUT must not be negative")
+	}
+	for jobName, maxConcurrency := range ac.JobsMaxConcurrency {
+		if maxConcurrency < 0 {
+			return ac, fmt.Errorf(
+				`invalid max concurrency %d for job "%s" in `+
+					"ASYNC_JOBS_MAX_CONCURRENCY",
+				maxConcurrency,
+				jobName,
+			)
+		}
+	}
+	return ac, nil
+}
+
+func getStorageConfig() (storageConfig, error) {
+	sc := storageConfig{}
+	err := envconfig.Process("", &sc)
+	if err != nil {
+		return sc, err
+	}
+	sc.TypeStr = strings.ToUpper(sc.TypeStr)
+	switch sc.TypeStr {
+	case storageTypeRedis, storageTypePostgreSQL, storageTypeBoltDB:
+	default:
+		return sc, fmt.Errorf(`unrecognized storage type "%s"`, sc.TypeStr)
+	}
+	return sc, nil
+}
+
+func getPostgreSQLConfig() (postgresqlConfig, error) {
+	pc := postgresqlConfig{}
+	err := envconfig.Process("", &pc)
+	return pc, err
+}
+
+func getBoltDBConfig() (boltdbConfig, error) {
+	bc := boltdbConfig{}
+	err := envconfig.Process("", &bc)
+	return bc, err
+}
+
 func getCryptoConfig() (cryptoConfig, error) {
 	cc := cryptoConfig{}
 	err := envconfig.Process("", &cc)
-	return cc, err
+	if err != nil {
+		return cc, err
+	}
+	cc.KeyEncrypterType = strings.ToUpper(cc.KeyEncrypterType)
+	switch cc.KeyEncrypterType {
+	case "":
+	case keyEncrypterTypeAzureKeyVault:
+		if cc.KeyVaultURL == "" || cc.KeyVaultKeyName == "" {
+			return cc, errors.New(
+				"KEY_ENCRYPTER_KEY_VAULT_URL and KEY_ENCRYPTER_KEY_VAULT_KEY_NAME " +
+					"must be specified",
+			)
+		}
+	case keyEncrypterTypeFile:
+		if cc.KeyFilePath == "" {
+			return cc, errors.New("KEY_ENCRYPTER_FILE_PATH must be specified")
+		}
+	default:
+		return cc, fmt.Errorf(
+			`unrecognized key encrypter type "%s"`,
+			cc.KeyEncrypterType,
+		)
+	}
+	if cc.KeyEncrypterType != "" {
+		if _, ok := cc.AES256Keys[envelopeKeyID]; ok {
+			return cc, fmt.Errorf(
+				`key ID "%s" in AES256_KEYS is reserved`,
+				envelopeKeyID,
+			)
+		}
+		return cc, nil
+	}
+	if len(cc.AES256Keys) == 0 {
+		if cc.AES256Key == "" {
+			return cc, errors.New(
+				"either AES256_KEY or AES256_KEYS must be specified",
+			)
+		}
+		return cc, nil
+	}
+	if _, ok := cc.AES256Keys[cc.AES256ActiveKeyID]; !ok {
+		return cc, fmt.Errorf(
+			`AES256_ACTIVE_KEY_ID "%s" does not identify a key in AES256_KEYS`,
+			cc.AES256ActiveKeyID,
+		)
+	}
+	return cc, nil
 }
 
 func getBasicAuthConfig() (basicAuthConfig, error) {
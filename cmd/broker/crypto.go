@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/aes256"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/envelope"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/envelope/keyvault"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/envelope/local"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/keyring"
+)
+
+// envelopeKeyID is the key ID that values encrypted using envelope encryption
+// are tagged with
+const envelopeKeyID = "envelope"
+
+// getCodec returns the codec used for encrypting and decrypting all sensitive
+// values
+func getCodec() (crypto.Codec, error) {
+	cryptoConfig, err := getCryptoConfig()
+	if err != nil {
+		return nil, err
+	}
+	codec, err := getStrictCodec(cryptoConfig)
+	if err != nil || !cryptoConfig.AllowUnboundCiphertexts {
+		return codec, err
+	}
+	return crypto.NewUnboundCompatibleCodec(codec), nil
+}
+
+// getStrictCodec returns a codec that, regardless of configuration, does not
+// decrypt ciphertexts that aren't bound to associated data
+func getStrictCodec(cryptoConfig cryptoConfig) (crypto.Codec, error) {
+	var err error
+	var legacyCodec crypto.Codec
+	if cryptoConfig.AES256Key != "" {
+		legacyCodec, err = aes256.NewCodec([]byte(cryptoConfig.AES256Key))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(cryptoConfig.AES256Keys) == 0 &&
+		cryptoConfig.KeyEncrypterType == "" {
+		return legacyCodec, nil
+	}
+	codecs := map[string]crypto.Codec{}
+	for keyID, key := range cryptoConfig.AES256Keys {
+		if codecs[keyID], err = aes256.NewCodec([]byte(key)); err != nil {
+			return nil, fmt.Errorf(
+				`error creating codec for key "%s": %s`,
+				keyID,
+				err,
+			)
+		}
+	}
+	activeKeyID := cryptoConfig.AES256ActiveKeyID
+	if cryptoConfig.KeyEncrypterType != "" {
+		var keyEncrypter envelope.KeyEncrypter
+		if keyEncrypter, err = getKeyEncrypter(cryptoConfig); err != nil {
+			return nil, err
+		}
+		codecs[envelopeKeyID] = envelope.NewCodec(keyEncrypter)
+		activeKeyID = envelopeKeyID
+	}
+	return keyring.NewCodec(activeKeyID, codecs, legacyCodec)
+}
+
+func getKeyEncrypter(
+	cryptoConfig cryptoConfig,
+) (envelope.KeyEncrypter, error) {
+	switch cryptoConfig.KeyEncrypterType {
+	case keyEncrypterTypeAzureKeyVault:
+		return keyvault.NewKeyEncrypter(
+			cryptoConfig.KeyVaultURL,
+			cryptoConfig.KeyVaultKeyName,
+			cryptoConfig.KeyVaultKeyVersion,
+		)
+	case keyEncrypterTypeFile:
+		return local.NewKeyEncrypter(cryptoConfig.KeyFilePath)
+	default:
+		return nil, fmt.Errorf(
+			`unrecognized key encrypter type "%s"`,
+			cryptoConfig.KeyEncrypterType,
+		)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-redis/redis"
+	"github.com/urfave/cli"
+)
+
+// migrateLegacyKeys moves broker state stored under the unprefixed keys used by
+// older versions of the broker into the namespace identified by the given key
+// prefix
+func migrateLegacyKeys(redisClient *redis.Client, keyPrefix string) error {
+	log.WithField("keyPrefix", keyPrefix).Info("migrating legacy redis keys")
+	records, err := storage.MigrateLegacyKeys(redisClient, keyPrefix)
+	if err != nil {
+		return fmt.Errorf("error migrating legacy storage keys: %s", err)
+	}
+	tasks, err := async.MigrateLegacyKeys(redisClient, keyPrefix)
+	if err != nil {
+		return fmt.Errorf("error migrating legacy async engine keys: %s", err)
+	}
+	log.WithFields(log.Fields{
+		"keyPrefix": keyPrefix,
+		"records":   records,
+		"tasks":     tasks,
+	}).Info("migrated legacy redis keys")
+	return nil
+}
+
+// checkForLegacyKeys returns an error if Redis holds instances or bindings that
+// were stored under unprefixed keys by an older version of the broker and have
+// not been migrated into the namespace identified by the given key prefix.
+// Starting without them would make the broker lose track of those instances
+// and bindings.
+func checkForLegacyKeys(redisClient *redis.Client, keyPrefix string) error {
+	found, err := storage.HasLegacyKeys(redisClient, keyPrefix)
+	if err != nil {
+		return fmt.Errorf("error checking for legacy storage keys: %s", err)
+	}
+	if found {
+		return fmt.Errorf(
+			"found instances or bindings stored under legacy, unprefixed keys; "+
+				"set REDIS_MIGRATE_LEGACY_KEYS=true to migrate them into the "+
+				`keyspace with prefix "%s"`,
+			keyPrefix,
+		)
+	}
+	return nil
+}
+
+// rebuildIndices indexes any instances and bindings that were written by older
+// versions of the broker before the Redis-based store maintained indices for
+// listing them, unless that has already been done
+func rebuildIndices(redisClient *redis.Client, keyPrefix string) error {
+	built, err := storage.AreIndicesBuilt(redisClient, keyPrefix)
+	if err != nil {
+		return err
+	}
+	if built {
+		return nil
+	}
+	instances, bindings, err := storage.RebuildIndices(redisClient, keyPrefix)
+	if err != nil {
+		return fmt.Errorf("error rebuilding storage indices: %s", err)
+	}
+	log.WithFields(log.Fields{
+		"keyPrefix": keyPrefix,
+		"instances": instances,
+		"bindings":  bindings,
+	}).Info("rebuilt storage indices")
+	return nil
+}
+
+// migrateSchemas upgrades every instance and binding in storage to the current
+// schema versions of their module-specific contexts. Brokers also do this
+// lazily as instances and bindings are read, so running this is only necessary
+// before deploying a version of the broker that drops support for
+// older schema versions.
+func migrateSchemas(*cli.Context) error {
+	store, err := getStore()
+	if err != nil {
+		return err
+	}
+	codec, err := getCodec()
+	if err != nil {
+		return err
+	}
+	log.Info("migrating instances and bindings to current schema versions")
+	instances, bindings, err := storage.MigrateAll(
+		store,
+		getMigrationRegistry(),
+		codec,
+	)
+	if err != nil {
+		return fmt.Errorf("error migrating schemas: %s", err)
+	}
+	log.WithFields(log.Fields{
+		"instances": instances,
+		"bindings":  bindings,
+	}).Info("migrated instances and bindings to current schema versions")
+	return nil
+}
+
+// reencrypt re-encrypts every instance and binding in storage using the
+// active encryption key. It must be run before upgrading from a version of the
+// broker that didn't bind ciphertexts to the records they belong to, since
+// the upgraded broker refuses such ciphertexts unless
+// ALLOW_UNBOUND_CIPHERTEXTS is explicitly enabled. After this completes, keys
+// that are no longer active can be removed from AES256_KEYS and
+// ALLOW_UNBOUND_CIPHERTEXTS, if it was enabled, can be disabled.
+func reencrypt(*cli.Context) error {
+	store, err := getStore()
+	if err != nil {
+		return err
+	}
+	cryptoConfig, err := getCryptoConfig()
+	if err != nil {
+		return err
+	}
+	// Re-encryption must be able to tell which ciphertexts aren't yet bound to
+	// associated data, so it handles unbound ciphertexts itself
+	codec, err := getStrictCodec(cryptoConfig)
+	if err != nil {
+		return err
+	}
+	log.Info("re-encrypting instances and bindings")
+	instances, bindings, err := storage.Reencrypt(store, codec)
+	if err != nil {
+		return fmt.Errorf("error re-encrypting: %s", err)
+	}
+	log.WithFields(log.Fields{
+		"instances": instances,
+		"bindings":  bindings,
+	}).Info("re-encrypted instances and bindings")
+	return nil
+}
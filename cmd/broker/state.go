@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
+	log "github.com/Sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const flagFile = "file"
+
+// exportState writes every instance and binding in storage to an archive.
+// Encrypted fields remain encrypted in the archive.
+func exportState(c *cli.Context) error {
+	store, err := getStore()
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if path := c.String(flagFile); path != "" {
+		var file *os.File
+		if file, err = os.Create(path); err != nil {
+			return fmt.Errorf(`error creating archive "%s": %s`, path, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	instances, bindings, err := storage.Export(store, w)
+	if err != nil {
+		return fmt.Errorf("error exporting state: %s", err)
+	}
+	log.WithFields(log.Fields{
+		"instances": instances,
+		"bindings":  bindings,
+	}).Info("exported state")
+	return nil
+}
+
+// importState restores instances and bindings from an archive produced by
+// exportState into storage
+func importState(c *cli.Context) error {
+	store, err := getStore()
+	if err != nil {
+		return err
+	}
+	var r io.Reader = os.Stdin
+	if path := c.String(flagFile); path != "" {
+		var file *os.File
+		if file, err = os.Open(path); err != nil {
+			return fmt.Errorf(`error opening archive "%s": %s`, path, err)
+		}
+		defer file.Close()
+		r = file
+	}
+	instances, bindings, err := storage.Import(store, r)
+	log.WithFields(log.Fields{
+		"instances": instances,
+		"bindings":  bindings,
+	}).Info("imported state")
+	if err != nil {
+		return fmt.Errorf("error importing state: %s", err)
+	}
+	return nil
+}
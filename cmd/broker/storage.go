@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async"
+	boltAsync "github.com/Azure/open-service-broker-azure/pkg/async/boltdb"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
+	boltStorage "github.com/Azure/open-service-broker-azure/pkg/storage/boltdb"
+	"github.com/Azure/open-service-broker-azure/pkg/storage/postgresql"
+	"github.com/boltdb/bolt"
+	"github.com/go-redis/redis"
+)
+
+// getStoreAndAsyncEngine returns an implementation of storage.Store of the type
+// selected by the STORAGE_TYPE environment variable, along with a compatible
+// implementation of async.Engine
+func getStoreAndAsyncEngine() (storage.Store, async.Engine, error) {
+	storageConfig, err := getStorageConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	// BoltDB is used for both storage and the async engine, so Redis isn't
+	// needed at all
+	if storageConfig.TypeStr == storageTypeBoltDB {
+		return getBoltDBStoreAndAsyncEngine()
+	}
+	// Every other type of storage relies on Redis for the async engine
+	redisClient, redisConfig, err := getMigratedRedisClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	asyncEngineConfig, err := getAsyncEngineConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	asyncEngine := async.NewEngine(
+		redisClient,
+		redisConfig.KeyPrefix,
+		asyncEngineConfig,
+	)
+	if storageConfig.TypeStr == storageTypePostgreSQL {
+		store, err := getPostgreSQLStore()
+		return store, asyncEngine, err
+	}
+	store, err := getRedisStore(redisClient, redisConfig.KeyPrefix)
+	return store, asyncEngine, err
+}
+
+// getStore returns an implementation of storage.Store of the type selected by
+// the STORAGE_TYPE environment variable. Unlike getStoreAndAsyncEngine, it
+// doesn't build an async engine, so it suits commands that operate on storage
+// alone.
+func getStore() (storage.Store, error) {
+	storageConfig, err := getStorageConfig()
+	if err != nil {
+		return nil, err
+	}
+	switch storageConfig.TypeStr {
+	case storageTypeBoltDB:
+		db, err := openBoltDB()
+		if err != nil {
+			return nil, err
+		}
+		return boltStorage.NewStore(db)
+	case storageTypePostgreSQL:
+		return getPostgreSQLStore()
+	}
+	redisClient, redisConfig, err := getMigratedRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	return getRedisStore(redisClient, redisConfig.KeyPrefix)
+}
+
+// getMigratedRedisClient returns a Redis client, along with the configuration
+// it was built from, after ensuring that no broker state remains under the
+// legacy, unprefixed keys used by older versions of the broker-- either by
+// migrating it, if REDIS_MIGRATE_LEGACY_KEYS is enabled, or by returning an
+// error
+func getMigratedRedisClient() (*redis.Client, redisConfig, error) {
+	redisConfig, err := getRedisConfig()
+	if err != nil {
+		return nil, redisConfig, err
+	}
+	redisClient := getRedisClient(redisConfig)
+	if redisConfig.MigrateLegacyKeys {
+		err = migrateLegacyKeys(redisClient, redisConfig.KeyPrefix)
+	} else {
+		err = checkForLegacyKeys(redisClient, redisConfig.KeyPrefix)
+	}
+	return redisClient, redisConfig, err
+}
+
+// getRedisStore returns a Redis-based implementation of storage.Store whose
+// keys have the given prefix
+func getRedisStore(
+	redisClient *redis.Client,
+	keyPrefix string,
+) (storage.Store, error) {
+	// Records written before the store maintained indices are indexed on the
+	// first start of a broker that does; later starts skip this
+	if err := rebuildIndices(redisClient, keyPrefix); err != nil {
+		return nil, err
+	}
+	return storage.NewStore(redisClient, keyPrefix), nil
+}
+
+// getAsyncEngineConfig returns the async.Config shared by every implementation
+// of async.Engine, as specified by the ASYNC_* environment variables
+func getAsyncEngineConfig() (async.Config, error) {
+	asyncConfig, err := getAsyncConfig()
+	if err != nil {
+		return async.Config{}, err
+	}
+	return async.Config{
+		MaxWorkerRejections: asyncConfig.MaxWorkerRejections,
+		WorkerPoolSize:      asyncConfig.WorkerPoolSize,
+		DefaultTaskTimeout:  asyncConfig.DefaultTaskTimeout,
+		DrainTimeout:        asyncConfig.DrainTimeout,
+	}, nil
+}
+
+func getRedisClient(redisConfig redisConfig) *redis.Client {
+	redisOpts := &redis.Options{
+		Addr:       fmt.Sprintf("%s:%d", redisConfig.Host, redisConfig.Port),
+		Password:   redisConfig.Password,
+		DB:         redisConfig.DB,
+		MaxRetries: 5,
+	}
+	if redisConfig.EnableTLS {
+		redisOpts.TLSConfig = &tls.Config{
+			ServerName: redisConfig.Host,
+		}
+	}
+	return redis.NewClient(redisOpts)
+}
+
+func getPostgreSQLStore() (storage.Store, error) {
+	postgresqlConfig, err := getPostgreSQLConfig()
+	if err != nil {
+		return nil, err
+	}
+	sslMode := "disable"
+	if postgresqlConfig.EnableTLS {
+		sslMode = "require"
+	}
+	connURL := url.URL{
+		Scheme: "postgres",
+		User: url.UserPassword(
+			postgresqlConfig.Username,
+			postgresqlConfig.Password,
+		),
+		Host: fmt.Sprintf(
+			"%s:%d",
+			postgresqlConfig.Host,
+			postgresqlConfig.Port,
+		),
+		Path:     postgresqlConfig.Database,
+		RawQuery: fmt.Sprintf("sslmode=%s", sslMode),
+	}
+	db, err := sql.Open("postgres", connURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the database: %s", err)
+	}
+	return postgresql.NewStore(db)
+}
+
+func getBoltDBStoreAndAsyncEngine() (storage.Store, async.Engine, error) {
+	db, err := openBoltDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := boltStorage.NewStore(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	asyncEngineConfig, err := getAsyncEngineConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	asyncEngine, err := boltAsync.NewEngine(db, asyncEngineConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, asyncEngine, nil
+}
+
+func openBoltDB() (*bolt.DB, error) {
+	boltdbConfig, err := getBoltDBConfig()
+	if err != nil {
+		return nil, err
+	}
+	// Fail fast if another process (e.g. another replica sharing the same
+	// persistent volume) already has the file open
+	db, err := bolt.Open(
+		boltdbConfig.Path,
+		0600,
+		&bolt.Options{Timeout: time.Second * 10},
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			`error opening database file "%s": %s`,
+			boltdbConfig.Path,
+			err,
+		)
+	}
+	return db, nil
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -8,9 +9,11 @@ import (
 	"time"
 
 	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	"github.com/mitchellh/mapstructure"
+	"github.com/satori/uuid"
 )
 
 func (s *server) bind(w http.ResponseWriter, r *http.Request) {
@@ -24,6 +27,22 @@ func (s *server) bind(w http.ResponseWriter, r *http.Request) {
 
 	log.WithFields(logFields).Debug("received binding request")
 
+	// Binding is carried out asynchronously only if the client explicitly
+	// indicates that they will accept an incomplete result. Otherwise, the
+	// binding steps are executed before responding.
+	acceptsIncomplete, err := getAcceptsIncomplete(r)
+	if err != nil {
+		logFields["accepts_incomplete"] = r.URL.Query().Get("accepts_incomplete")
+		log.WithFields(logFields).Debug(
+			"bad binding request: query parameter has invalid value",
+		)
+		s.writeResponse(w, http.StatusBadRequest, responseEmptyJSON)
+		return
+	}
+	// Clients that predate asynchronous binding cannot poll for its outcome, so
+	// for them, binding is always carried out synchronously.
+	acceptsIncomplete = acceptsIncomplete && supportsAsyncBindings(r)
+
 	instance, ok, err := s.store.GetInstance(instanceID)
 	if err != nil {
 		logFields["error"] = err
@@ -218,6 +237,19 @@ func (s *server) bind(w http.ResponseWriter, r *http.Request) {
 				// for an existing binding.
 				s.writeResponse(w, http.StatusOK, bindingResponseJSON)
 				return
+			case service.BindingStateBinding:
+				// Per the spec, if binding is still in progress, respond with a 202.
+				// Only clients that accept an incomplete result can be told this.
+				if !acceptsIncomplete {
+					s.writeResponse(
+						w,
+						http.StatusUnprocessableEntity,
+						responseAsyncRequired,
+					)
+					return
+				}
+				s.writeResponse(w, http.StatusAccepted, responseBindingAccepted)
+				return
 			default:
 				// TODO: Write a more detailed response
 				s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
@@ -233,8 +265,24 @@ func (s *server) bind(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If we get to here, we need to create a new binding.
-	// Start by carrying out service-specific request validation
-	err = serviceManager.ValidateBindingParameters(bindingRequest.Parameters)
+	// Start by validating the request parameters against the schema derived from
+	// the service's binding parameters, then carry out service-specific request
+	// validation
+	schemas, err := svc.GetPlanSchemas()
+	if err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"pre-binding error: error retrieving parameter schemas",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	err = schemas.ServiceBinding.Create.Parameters.Validate(
+		bindingRequest.Parameters,
+	)
+	if err == nil {
+		err = serviceManager.ValidateBindingParameters(bindingRequest.Parameters)
+	}
 	if err != nil {
 		validationErr, ok := err.(*service.ValidationError)
 		if ok {
@@ -251,6 +299,40 @@ func (s *server) bind(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	plan, ok := svc.GetPlan(instance.PlanID)
+	if !ok {
+		// If we don't find the Plan, something is really wrong. (It should exist,
+		// because an instance with this planID exists.)
+		logFields["serviceID"] = instance.ServiceID
+		logFields["planID"] = instance.PlanID
+		log.WithFields(logFields).Error(
+			"pre-binding error: no Plan found for planID",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	binder, err := serviceManager.GetBinder(plan)
+	if err != nil {
+		logFields["serviceID"] = instance.ServiceID
+		logFields["planID"] = instance.PlanID
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"pre-binding error: error retrieving binder for service and plan",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	firstStepNames := binder.GetFirstStepNames()
+	if len(firstStepNames) == 0 {
+		logFields["serviceID"] = instance.ServiceID
+		logFields["planID"] = instance.PlanID
+		log.WithFields(logFields).Error(
+			"pre-binding error: no steps found for binding service and plan",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+
 	provisioningContext := serviceManager.GetEmptyProvisioningContext()
 	err = instance.GetProvisioningContext(provisioningContext, s.codec)
 	if err != nil {
@@ -267,14 +349,70 @@ func (s *server) bind(w http.ResponseWriter, r *http.Request) {
 		BindingID:  bindingID,
 		Created:    time.Now(),
 	}
+	if err = binding.SetBindingParameters(bindingParameters, s.codec); err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"binding error: error encoding bindingParameters",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+
+	if acceptsIncomplete {
+		binding.Status = service.BindingStateBinding
+		binding.OperationID = uuid.NewV4().String()
+		if err = s.store.WriteBinding(binding); err != nil {
+			if _, ok := err.(*storage.ConflictError); ok {
+				log.WithFields(logFields).Debug(
+					"binding conflict: binding was created by a concurrent request",
+				)
+				s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
+				return
+			}
+			logFields["error"] = err
+			log.WithFields(logFields).Error(
+				"binding error: error persisting new binding",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			return
+		}
+		for _, firstStepName := range firstStepNames {
+			task := NewBindingStepTask(
+				"bindStep",
+				OperationBinding,
+				bindingID,
+				binding.OperationID,
+				firstStepName,
+			)
+			if err = s.asyncEngine.SubmitTask(task); err != nil {
+				s.handleBindingError(
+					binding,
+					err,
+					fmt.Sprintf(`error submitting binding step "%s"`, firstStepName),
+					w,
+				)
+				return
+			}
+		}
+		s.writeResponse(w, http.StatusAccepted, responseBindingAccepted)
+		log.WithFields(logFields).Debug("asynchronous binding initiated")
+		return
+	}
 
 	// Starting here, if something goes wrong, we don't know what state service-
 	// specific code has left us in, so we'll attempt to record the error in
-	// the datastore.
-	bindingContext, credentials, err := serviceManager.Bind(
+	// the datastore. The binding logic is deliberately not bound to the
+	// request's context, since a client that disconnects mid-way would otherwise
+	// abandon it in an indeterminate state.
+	bindingContext, credentials, err := service.BindSynchronously(
+		context.Background(),
+		binder,
+		bindingID,
+		plan,
 		instance.StandardProvisioningContext,
 		provisioningContext,
-		bindingRequest.Parameters,
+		serviceManager.GetEmptyBindingContext(),
+		bindingParameters,
 	)
 	if err != nil {
 		s.handleBindingError(
@@ -366,6 +504,16 @@ func (s *server) handleBindingError(
 		"status":     binding.Status,
 	}
 	if err := s.store.WriteBinding(binding); err != nil {
+		if _, ok := err.(*storage.ConflictError); ok {
+			// Another request modified the binding concurrently. Whatever it
+			// recorded takes precedence over this failure, which we only log.
+			logFields["error"] = e
+			log.WithFields(logFields).Error(
+				fmt.Sprintf(`binding conflict: %s`, msg),
+			)
+			s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
+			return
+		}
 		logFields["originalError"] = binding.StatusReason
 		logFields["persistenceError"] = err
 		log.WithFields(logFields).Fatal(
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	fakeAsync "github.com/Azure/open-service-broker-azure/pkg/async/fake"
 	"github.com/Azure/open-service-broker-azure/pkg/crypto/noop"
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 	"github.com/Azure/open-service-broker-azure/pkg/services/fake"
@@ -308,6 +309,137 @@ func TestBrandNewBinding(t *testing.T) {
 	// TODO: Test the response body
 }
 
+func TestBindingWithInvalidAcceptsIncomplete(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	req, err := getBindingRequest(
+		getDisposableInstanceID(),
+		getDisposableBindingID(),
+		&BindingRequest{},
+	)
+	assert.Nil(t, err)
+	setAcceptsIncomplete(req, "bogus")
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, responseEmptyJSON, rr.Body.Bytes())
+}
+
+func TestBindingWithExistingBindingInProgressWithSameAttributes(
+	t *testing.T,
+) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	instanceID := getDisposableInstanceID()
+	err = s.store.WriteInstance(&service.Instance{
+		InstanceID: instanceID,
+		ServiceID:  fake.ServiceID,
+		PlanID:     fake.StandardPlanID,
+		Status:     service.InstanceStateProvisioned,
+	})
+	assert.Nil(t, err)
+	bindingID := getDisposableBindingID()
+	err = s.store.WriteBinding(&service.Binding{
+		InstanceID: instanceID,
+		BindingID:  bindingID,
+		Status:     service.BindingStateBinding,
+	})
+	assert.Nil(t, err)
+	testCases := []struct {
+		name              string
+		acceptsIncomplete string
+		apiVersion        string
+		expectedCode      int
+		expectedBody      []byte
+	}{
+		{
+			name:              "synchronous",
+			acceptsIncomplete: "false",
+			apiVersion:        "2.14",
+			expectedCode:      http.StatusUnprocessableEntity,
+			expectedBody:      responseAsyncRequired,
+		},
+		{
+			name:              "asynchronous",
+			acceptsIncomplete: "true",
+			apiVersion:        "2.14",
+			expectedCode:      http.StatusAccepted,
+			expectedBody:      responseBindingAccepted,
+		},
+		{
+			name:              "asynchronous with API version before 2.14",
+			acceptsIncomplete: "true",
+			apiVersion:        "2.13",
+			expectedCode:      http.StatusUnprocessableEntity,
+			expectedBody:      responseAsyncRequired,
+		},
+		{
+			name:              "asynchronous without API version",
+			acceptsIncomplete: "true",
+			expectedCode:      http.StatusUnprocessableEntity,
+			expectedBody:      responseAsyncRequired,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req, err := getBindingRequest(
+				instanceID,
+				bindingID,
+				&BindingRequest{},
+			)
+			assert.Nil(t, err)
+			setAcceptsIncomplete(req, testCase.acceptsIncomplete)
+			setBrokerAPIVersion(req, testCase.apiVersion)
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+			assert.Equal(t, testCase.expectedCode, rr.Code)
+			assert.Equal(t, testCase.expectedBody, rr.Body.Bytes())
+		})
+	}
+}
+
+func TestBrandNewAsyncBinding(t *testing.T) {
+	s, m, err := getTestServer("", "")
+	assert.Nil(t, err)
+	bindCalled := false
+	m.ServiceManager.BindBehavior = func(
+		service.StandardProvisioningContext,
+		service.ProvisioningContext,
+		service.BindingParameters,
+	) (service.BindingContext, service.Credentials, error) {
+		bindCalled = true
+		return nil, nil, nil
+	}
+	instanceID := getDisposableInstanceID()
+	err = s.store.WriteInstance(&service.Instance{
+		InstanceID: instanceID,
+		ServiceID:  fake.ServiceID,
+		PlanID:     fake.StandardPlanID,
+		Status:     service.InstanceStateProvisioned,
+	})
+	assert.Nil(t, err)
+	bindingID := getDisposableBindingID()
+	req, err := getBindingRequest(
+		instanceID,
+		bindingID,
+		&BindingRequest{},
+	)
+	assert.Nil(t, err)
+	setAcceptsIncomplete(req, "true")
+	setBrokerAPIVersion(req, "2.14")
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, responseBindingAccepted, rr.Body.Bytes())
+	// Binding steps are left to the async engine
+	assert.False(t, bindCalled)
+	assert.Equal(t, 1, len(s.asyncEngine.(*fakeAsync.Engine).SubmittedTasks))
+	binding, ok, err := s.store.GetBinding(bindingID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, service.BindingStateBinding, binding.Status)
+}
+
 func getBindingRequest(
 	instanceID string,
 	bindingID string,
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGettingCatalogIncludesPlanSchemas(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	req, err := http.NewRequest(http.MethodGet, "/v2/catalog", nil)
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	catalog, err := service.NewCatalogFromJSON(rr.Body.Bytes())
+	assert.Nil(t, err)
+	for _, svc := range catalog.GetServices() {
+		for _, plan := range svc.GetPlans() {
+			schemas := plan.GetProperties().Schemas
+			if !assert.NotNil(t, schemas) {
+				continue
+			}
+			provisioningSchema := schemas.ServiceInstance.Create.Parameters
+			// Schemas for provisioning parameters include both service-specific
+			// and standard parameters
+			assert.Contains(t, provisioningSchema.Properties, "someParameter")
+			assert.Contains(t, provisioningSchema.Properties, "location")
+			assert.Contains(
+				t,
+				schemas.ServiceBinding.Create.Parameters.Properties,
+				"someParameter",
+			)
+		}
+	}
+}
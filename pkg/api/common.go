@@ -2,10 +2,16 @@ package api
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 )
 
+// maxWriteAttempts bounds the number of times a request handler will attempt
+// to persist an instance or binding that is being concurrently modified
+const maxWriteAttempts = 5
+
 func (s *server) writeResponse(
 	w http.ResponseWriter,
 	statusCode int,
@@ -19,3 +25,37 @@ func (s *server) writeResponse(
 		)
 	}
 }
+
+// getAcceptsIncomplete returns a bool indicating whether the given request's
+// accepts_incomplete query parameter indicates that the client will accept an
+// incomplete (i.e. asynchronous) result. A missing parameter is equivalent to
+// false.
+func getAcceptsIncomplete(r *http.Request) (bool, error) {
+	acceptsIncompleteStr := r.URL.Query().Get("accepts_incomplete")
+	if acceptsIncompleteStr == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(acceptsIncompleteStr)
+}
+
+// supportsAsyncBindings returns a bool indicating whether the given request's
+// X-Broker-API-Version header indicates a client that implements version 2.14
+// or later of the OSB API. Earlier versions of the spec have no notion of
+// asynchronous binding or unbinding, so such clients cannot poll for the
+// outcome of either. A missing or malformed header is treated as an earlier
+// version.
+func supportsAsyncBindings(r *http.Request) bool {
+	versionTokens := strings.SplitN(r.Header.Get("X-Broker-API-Version"), ".", 2)
+	if len(versionTokens) != 2 {
+		return false
+	}
+	major, err := strconv.Atoi(versionTokens[0])
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(versionTokens[1])
+	if err != nil {
+		return false
+	}
+	return major > 2 || (major == 2 && minor >= 14)
+}
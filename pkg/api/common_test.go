@@ -2,11 +2,14 @@ package api
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/Azure/open-service-broker-azure/pkg/api/authenticator/always"
 	fakeAsync "github.com/Azure/open-service-broker-azure/pkg/async/fake"
 	"github.com/Azure/open-service-broker-azure/pkg/crypto/noop"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
 	"github.com/Azure/open-service-broker-azure/pkg/services/fake"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
 	memoryStorage "github.com/Azure/open-service-broker-azure/pkg/storage/memory"
 	uuid "github.com/satori/go.uuid"
 )
@@ -28,6 +31,38 @@ var (
 	testArbitraryMapJSON = []byte(fmt.Sprintf(`{"foo":"%s"}`, fooValue))
 )
 
+// conflictingStore is a storage.Store whose writes fail with a
+// *storage.ConflictError a given number of times, as if the records being
+// written were being concurrently modified, before they succeed
+type conflictingStore struct {
+	storage.Store
+	conflicts int
+}
+
+func (c *conflictingStore) WriteInstance(instance *service.Instance) error {
+	if c.conflicts > 0 {
+		c.conflicts--
+		return storage.NewConflictError(
+			"instance",
+			instance.InstanceID,
+			instance.Revision,
+		)
+	}
+	return c.Store.WriteInstance(instance)
+}
+
+func (c *conflictingStore) WriteBinding(binding *service.Binding) error {
+	if c.conflicts > 0 {
+		c.conflicts--
+		return storage.NewConflictError(
+			"binding",
+			binding.BindingID,
+			binding.Revision,
+		)
+	}
+	return c.Store.WriteBinding(binding)
+}
+
 func getDisposableInstanceID() string {
 	return uuid.NewV4().String()
 }
@@ -71,3 +106,13 @@ func getTestServer(
 	}
 	return s.(*server), fakeModule, nil
 }
+
+func setAcceptsIncomplete(req *http.Request, acceptsIncomplete string) {
+	q := req.URL.Query()
+	q.Set("accepts_incomplete", acceptsIncomplete)
+	req.URL.RawQuery = q.Encode()
+}
+
+func setBrokerAPIVersion(req *http.Request, version string) {
+	req.Header.Set("X-Broker-API-Version", version)
+}
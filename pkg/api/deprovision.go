@@ -4,10 +4,11 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/Azure/open-service-broker-azure/pkg/async/model"
 	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"github.com/satori/uuid"
 )
 
 func (s *server) deprovision(w http.ResponseWriter, r *http.Request) {
@@ -42,98 +43,134 @@ func (s *server) deprovision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	instance, ok, err := s.store.GetInstance(instanceID)
-	if err != nil {
-		logFields["error"] = err
-		log.WithFields(logFields).Error(
-			"pre-deprovisioning error: error retrieving instance by id",
-		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
-	}
-	if !ok {
-		log.WithFields(logFields).Debug(
-			"no such instance remains to be deprovisioned",
-		)
-		// No instance was found-- per spec, we return a 410
-		s.writeResponse(w, http.StatusGone, responseEmptyJSON)
-		return
-	}
-	switch instance.Status {
-	case service.InstanceStateDeprovisioning:
-		log.WithFields(logFields).Debug(
-			"deprovisioning is already in progress",
-		)
-		s.writeResponse(w, http.StatusAccepted, responseDeprovisioningAccepted)
-		return
-	case service.InstanceStateProvisioned:
-	case service.InstanceStateProvisioningFailed:
-	default:
-		// This is going to handle the case where we cannot deprovision because
-		// the instance isn't in a terminal state-- i.e. it's still provisioning
-		logFields["status"] = instance.Status
-		log.WithFields(logFields).Debug(
-			"cannot deprovision instance in its current state",
-		)
-		s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
-		return
-	}
+	// Whether and how the instance is deprovisioned depends on its status, so if
+	// the instance is modified concurrently before the decision is persisted,
+	// the decision is made again using the latest revision of the instance, a
+	// bounded number of times
+	var instance *service.Instance
+	var firstStepNames []string
+	for attempt := 1; ; attempt++ {
+		var ok bool
+		instance, ok, err = s.store.GetInstance(instanceID)
+		if err != nil {
+			logFields["error"] = err
+			log.WithFields(logFields).Error(
+				"pre-deprovisioning error: error retrieving instance by id",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			return
+		}
+		if !ok {
+			log.WithFields(logFields).Debug(
+				"no such instance remains to be deprovisioned",
+			)
+			// No instance was found-- per spec, we return a 410
+			s.writeResponse(w, http.StatusGone, responseEmptyJSON)
+			return
+		}
+		switch instance.Status {
+		case service.InstanceStateDeprovisioning:
+			log.WithFields(logFields).Debug(
+				"deprovisioning is already in progress",
+			)
+			s.writeResponse(w, http.StatusAccepted, responseDeprovisioningAccepted)
+			return
+		case service.InstanceStateProvisioned:
+		case service.InstanceStateProvisioningFailed:
+		default:
+			// This is going to handle the case where we cannot deprovision because
+			// the instance isn't in a terminal state-- i.e. it's still provisioning
+			logFields["status"] = instance.Status
+			log.WithFields(logFields).Debug(
+				"cannot deprovision instance in its current state",
+			)
+			s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
+			return
+		}
 
-	// If we get to here, we're dealing with an instance that is fully provisioned
-	// or has failed provisioning. We need to kick off asynchronous
-	// deprovisioning.
+		// If we get to here, we're dealing with an instance that is fully provisioned
+		// or has failed provisioning. We need to kick off asynchronous
+		// deprovisioning.
 
-	svc, ok := s.catalog.GetService(instance.ServiceID)
-	if !ok {
-		// If we don't find the Service in the catalog, something is really wrong.
-		// (It should exist, because an instance with this serviceID exists.)
-		logFields["serviceID"] = instance.ServiceID
-		log.WithFields(logFields).Error(
-			"pre-deprovisioning error: no Service found for serviceID",
-		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
-	}
-	plan, ok := svc.GetPlan(instance.PlanID)
-	if !ok {
-		// If we don't find the Service in the catalog, something is really wrong.
-		// (It should exist, because an instance with this serviceID exists.)
-		logFields["serviceID"] = instance.ServiceID
-		logFields["planID"] = instance.PlanID
-		log.WithFields(logFields).Error(
-			"pre-deprovisioning error: no Plan found for planID in Service",
-		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
-	}
-	serviceManager := svc.GetServiceManager()
+		var svc service.Service
+		svc, ok = s.catalog.GetService(instance.ServiceID)
+		if !ok {
+			// If we don't find the Service in the catalog, something is really wrong.
+			// (It should exist, because an instance with this serviceID exists.)
+			logFields["serviceID"] = instance.ServiceID
+			log.WithFields(logFields).Error(
+				"pre-deprovisioning error: no Service found for serviceID",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			return
+		}
+		var plan service.Plan
+		plan, ok = svc.GetPlan(instance.PlanID)
+		if !ok {
+			// If we don't find the Service in the catalog, something is really wrong.
+			// (It should exist, because an instance with this serviceID exists.)
+			logFields["serviceID"] = instance.ServiceID
+			logFields["planID"] = instance.PlanID
+			log.WithFields(logFields).Error(
+				"pre-deprovisioning error: no Plan found for planID in Service",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			return
+		}
+		serviceManager := svc.GetServiceManager()
 
-	deprovisioner, err := serviceManager.GetDeprovisioner(plan)
-	if err != nil {
-		logFields["serviceID"] = instance.ServiceID
-		logFields["planID"] = instance.PlanID
-		logFields["error"] = err
-		log.WithFields(logFields).Error(
-			"pre-deprovisioning error: error retrieving deprovisioner for service " +
-				"and plan",
-		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
-	}
-	firstStepName, ok := deprovisioner.GetFirstStepName()
-	if !ok {
-		logFields["serviceID"] = instance.ServiceID
-		logFields["planID"] = instance.PlanID
-		log.WithFields(logFields).Error(
-			"pre-deprovisioning error: no steps found for deprovisioning service " +
-				"and plan",
-		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
-	}
+		var deprovisioner service.Deprovisioner
+		deprovisioner, err = serviceManager.GetDeprovisioner(plan)
+		if err != nil {
+			logFields["serviceID"] = instance.ServiceID
+			logFields["planID"] = instance.PlanID
+			logFields["error"] = err
+			log.WithFields(logFields).Error(
+				"pre-deprovisioning error: error retrieving deprovisioner for service " +
+					"and plan",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			return
+		}
+		firstStepNames = deprovisioner.GetFirstStepNames()
+		if len(firstStepNames) == 0 {
+			logFields["serviceID"] = instance.ServiceID
+			logFields["planID"] = instance.PlanID
+			log.WithFields(logFields).Error(
+				"pre-deprovisioning error: no steps found for deprovisioning service " +
+					"and plan",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			return
+		}
 
-	instance.Status = service.InstanceStateDeprovisioning
-	if err = s.store.WriteInstance(instance); err != nil {
+		instance.Status = service.InstanceStateDeprovisioning
+		instance.OperationID = uuid.NewV4().String()
+		instance.CompletedSteps = nil
+		instance.SkippedSteps = nil
+		err = s.store.WriteInstance(instance)
+		if err == nil {
+			break
+		}
+		if _, ok := err.(*storage.ConflictError); ok {
+			if attempt < maxWriteAttempts {
+				logFields["attempt"] = attempt
+				log.WithFields(logFields).Debug(
+					"instance was modified concurrently; retrying deprovisioning",
+				)
+				continue
+			}
+			log.WithFields(logFields).Debug(
+				"deprovisioning conflict: instance was modified by a concurrent " +
+					"operation",
+			)
+			s.writeResponse(
+				w,
+				http.StatusUnprocessableEntity,
+				responseConcurrencyError,
+			)
+			return
+		}
 		logFields["error"] = err
 		log.WithFields(logFields).Error(
 			"deprovisioning error: error persisting updated instance",
@@ -142,21 +179,23 @@ func (s *server) deprovision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task := model.NewTask(
-		"deprovisionStep",
-		map[string]string{
-			"stepName":   firstStepName,
-			"instanceID": instanceID,
-		},
-	)
-	if err = s.asyncEngine.SubmitTask(task); err != nil {
-		logFields["step"] = firstStepName
-		logFields["error"] = err
-		log.WithFields(logFields).Error(
-			"deprovisioning error: error submitting deprovisioning task",
+	for _, firstStepName := range firstStepNames {
+		task := NewStepTask(
+			"deprovisionStep",
+			OperationDeprovisioning,
+			instanceID,
+			instance.OperationID,
+			firstStepName,
 		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
+		if err = s.asyncEngine.SubmitTask(task); err != nil {
+			logFields["step"] = firstStepName
+			logFields["error"] = err
+			log.WithFields(logFields).Error(
+				"deprovisioning error: error submitting deprovisioning task",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			return
+		}
 	}
 
 	// If we get all the way to here, we've been successful!
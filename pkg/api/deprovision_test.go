@@ -112,6 +112,8 @@ func TestKickOffNewAsyncDeprovisioning(t *testing.T) {
 		ServiceID:  fake.ServiceID,
 		PlanID:     fake.StandardPlanID,
 		Status:     service.InstanceStateProvisioned,
+		// Left over from provisioning
+		OperationID: "foo",
 	})
 	assert.Nil(t, err)
 	req, err := getDeprovisionRequest(
@@ -129,6 +131,25 @@ func TestKickOffNewAsyncDeprovisioning(t *testing.T) {
 	assert.Equal(t, http.StatusAccepted, rr.Code)
 	assert.Equal(t, responseDeprovisioningAccepted, rr.Body.Bytes())
 	assert.Equal(t, 1, len(e.SubmittedTasks))
+	// The operation should have been assigned a new ID that distinguishes its
+	// tasks from those of any earlier operation
+	instance, ok, err := s.store.GetInstance(instanceID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.NotEmpty(t, instance.OperationID)
+	assert.NotEqual(t, "foo", instance.OperationID)
+	for _, task := range e.SubmittedTasks {
+		assert.Equal(
+			t,
+			GetStepIdempotencyKey(
+				instanceID,
+				OperationDeprovisioning,
+				instance.OperationID,
+				task.GetArgs()["stepName"],
+			),
+			task.GetIdempotencyKey(),
+		)
+	}
 }
 
 func getDeprovisionRequest(
@@ -152,3 +173,61 @@ func getDeprovisionRequest(
 	}
 	return req, nil
 }
+
+func TestDeprovisioningInstanceThatIsModifiedConcurrently(t *testing.T) {
+	testCases := []struct {
+		name             string
+		conflicts        int
+		expectedCode     int
+		expectedResponse []byte
+		expectedStatus   string
+	}{
+		{
+			name:             "conflict resolved by retrying",
+			conflicts:        1,
+			expectedCode:     http.StatusAccepted,
+			expectedResponse: responseDeprovisioningAccepted,
+			expectedStatus:   service.InstanceStateDeprovisioning,
+		},
+		{
+			name:             "conflict persists",
+			conflicts:        maxWriteAttempts,
+			expectedCode:     http.StatusUnprocessableEntity,
+			expectedResponse: responseConcurrencyError,
+			expectedStatus:   service.InstanceStateProvisioned,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, _, err := getTestServer("", "")
+			assert.Nil(t, err)
+			instanceID := getDisposableInstanceID()
+			err = s.store.WriteInstance(&service.Instance{
+				InstanceID: instanceID,
+				ServiceID:  fake.ServiceID,
+				PlanID:     fake.StandardPlanID,
+				Status:     service.InstanceStateProvisioned,
+			})
+			assert.Nil(t, err)
+			s.store = &conflictingStore{
+				Store:     s.store,
+				conflicts: testCase.conflicts,
+			}
+			req, err := getDeprovisionRequest(
+				instanceID,
+				map[string]string{
+					"accepts_incomplete": "true",
+				},
+			)
+			assert.Nil(t, err)
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+			assert.Equal(t, testCase.expectedCode, rr.Code)
+			assert.Equal(t, testCase.expectedResponse, rr.Body.Bytes())
+			instance, ok, err := s.store.GetInstance(instanceID)
+			assert.Nil(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, testCase.expectedStatus, instance.Status)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// getBinding fetches a binding. Clients of asynchronous bindings use this to
+// retrieve credentials once polling indicates that binding has completed.
+func (s *server) getBinding(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instance_id"]
+	bindingID := mux.Vars(r)["binding_id"]
+
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"bindingID":  bindingID,
+	}
+
+	log.WithFields(logFields).Debug("received binding fetching request")
+
+	binding, ok, err := s.store.GetBinding(bindingID)
+	if err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"binding fetching error: error retrieving binding by id",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	// Per the spec, a binding that doesn't exist or for which binding is still
+	// in progress is reported as not found
+	if !ok ||
+		binding.InstanceID != instanceID ||
+		binding.Status != service.BindingStateBound {
+		s.writeResponse(w, http.StatusNotFound, responseEmptyJSON)
+		return
+	}
+
+	instance, ok, err := s.store.GetInstance(instanceID)
+	if err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"binding fetching error: error retrieving instance by id",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	if !ok {
+		s.writeResponse(w, http.StatusNotFound, responseEmptyJSON)
+		return
+	}
+
+	svc, ok := s.catalog.GetService(instance.ServiceID)
+	if !ok {
+		// If we don't find the Service in the catalog, something is really wrong.
+		// (It should exist, because an instance with this serviceID exists.)
+		logFields["serviceID"] = instance.ServiceID
+		log.WithFields(logFields).Error(
+			"binding fetching error: no Service found for serviceID",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+
+	credentials := svc.GetServiceManager().GetEmptyCredentials()
+	if err = binding.GetCredentials(credentials, s.codec); err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"binding fetching error: error decoding persisted credentials",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	bindingResponseJSON, err := (&BindingResponse{
+		Credentials: credentials,
+	}).ToJSON()
+	if err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"binding fetching error: error marshaling binding response",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	s.writeResponse(w, http.StatusOK, bindingResponseJSON)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/services/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGettingBindingThatIsNotFound(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	req, err := getGetBindingRequest(
+		getDisposableInstanceID(),
+		getDisposableBindingID(),
+	)
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, responseEmptyJSON, rr.Body.Bytes())
+}
+
+func TestGettingBindingThatIsInProgress(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	instanceID := getDisposableInstanceID()
+	bindingID := getDisposableBindingID()
+	err = s.store.WriteBinding(&service.Binding{
+		InstanceID: instanceID,
+		BindingID:  bindingID,
+		Status:     service.BindingStateBinding,
+	})
+	assert.Nil(t, err)
+	req, err := getGetBindingRequest(instanceID, bindingID)
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, responseEmptyJSON, rr.Body.Bytes())
+}
+
+func TestGettingBindingThatIsBound(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	instanceID := getDisposableInstanceID()
+	bindingID := getDisposableBindingID()
+	err = s.store.WriteInstance(&service.Instance{
+		InstanceID: instanceID,
+		ServiceID:  fake.ServiceID,
+		PlanID:     fake.StandardPlanID,
+		Status:     service.InstanceStateProvisioned,
+	})
+	assert.Nil(t, err)
+	err = s.store.WriteBinding(&service.Binding{
+		InstanceID: instanceID,
+		BindingID:  bindingID,
+		Status:     service.BindingStateBound,
+	})
+	assert.Nil(t, err)
+	req, err := getGetBindingRequest(instanceID, bindingID)
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	// TODO: Test the response body
+}
+
+func getGetBindingRequest(
+	instanceID string,
+	bindingID string,
+) (*http.Request, error) {
+	return http.NewRequest(
+		http.MethodGet,
+		fmt.Sprintf(
+			"/v2/service_instances/%s/service_bindings/%s",
+			instanceID,
+			bindingID,
+		),
+		nil,
+	)
+}
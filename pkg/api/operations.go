@@ -1,5 +1,11 @@
 package api
 
+import (
+	"strings"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+)
+
 const (
 	// OperationProvisioning represents the "provisioning" operation
 	OperationProvisioning = "provisioning"
@@ -7,6 +13,10 @@ const (
 	OperationUpdating = "updating"
 	// OperationDeprovisioning represents the "deprovisioning" operation
 	OperationDeprovisioning = "deprovisioning"
+	// OperationBinding represents the "binding" operation
+	OperationBinding = "binding"
+	// OperationUnbinding represents the "unbinding" operation
+	OperationUnbinding = "unbinding"
 	// OperationStateInProgress represents the state of an operation that is still
 	// pending completion
 	OperationStateInProgress = "in progress"
@@ -20,3 +30,72 @@ const (
 	// of an operation against an entity that no longer exists
 	OperationStateGone = "gone"
 )
+
+// GetStepIdempotencyKey returns the idempotency key for tasks that execute the
+// named step of the given attempt at an operation against the given instance
+// (or binding). Submitting such tasks with this key prevents a step from being
+// enqueued more than once if, for instance, the step before it is executed
+// again. Since the key identifies the attempt, tasks left over from an earlier
+// attempt at the same operation never hold up those of a retried one. Instances
+// and bindings persisted before operations were identified have no operation
+// ID, in which case the attempt isn't included in the key.
+func GetStepIdempotencyKey(
+	instanceID string,
+	operation string,
+	operationID string,
+	stepName string,
+) string {
+	if operationID == "" {
+		return strings.Join([]string{instanceID, operation, stepName}, ":")
+	}
+	return strings.Join(
+		[]string{instanceID, operation, operationID, stepName},
+		":",
+	)
+}
+
+// NewStepTask returns a task for the given job that executes the named step of
+// the given attempt at an operation against the given instance. The task
+// carries the step's idempotency key.
+func NewStepTask(
+	jobName string,
+	operation string,
+	instanceID string,
+	operationID string,
+	stepName string,
+) model.Task {
+	task := model.NewTask(
+		jobName,
+		map[string]string{
+			"stepName":   stepName,
+			"instanceID": instanceID,
+		},
+	)
+	task.SetIdempotencyKey(
+		GetStepIdempotencyKey(instanceID, operation, operationID, stepName),
+	)
+	return task
+}
+
+// NewBindingStepTask returns a task for the given job that executes the named
+// step of the given attempt at an operation against the given binding. The task
+// carries the step's idempotency key.
+func NewBindingStepTask(
+	jobName string,
+	operation string,
+	bindingID string,
+	operationID string,
+	stepName string,
+) model.Task {
+	task := model.NewTask(
+		jobName,
+		map[string]string{
+			"stepName":  stepName,
+			"bindingID": bindingID,
+		},
+	)
+	task.SetIdempotencyKey(
+		GetStepIdempotencyKey(bindingID, operation, operationID, stepName),
+	)
+	return task
+}
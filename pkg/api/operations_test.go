@@ -0,0 +1,40 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStepIdempotencyKey(t *testing.T) {
+	testCases := []struct {
+		name        string
+		operationID string
+		expectedKey string
+	}{
+		{
+			name:        "with operation ID",
+			operationID: "bar",
+			expectedKey: "foo:provisioning:bar:baz",
+		},
+		{
+			// Instances and bindings persisted before operations were identified
+			name:        "without operation ID",
+			expectedKey: "foo:provisioning:baz",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(
+				t,
+				testCase.expectedKey,
+				GetStepIdempotencyKey(
+					"foo",
+					OperationProvisioning,
+					testCase.operationID,
+					"baz",
+				),
+			)
+		})
+	}
+}
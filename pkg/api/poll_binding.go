@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+func (s *server) pollBinding(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	instanceID := mux.Vars(r)["instance_id"]
+	bindingID := mux.Vars(r)["binding_id"]
+
+	logFields := log.Fields{
+		"instanceID": instanceID,
+		"bindingID":  bindingID,
+	}
+
+	log.WithFields(logFields).Debug("received binding polling request")
+
+	operation := r.URL.Query().Get("operation")
+	if operation == "" {
+		logFields["parameter"] = "operation"
+		log.WithFields(logFields).Debug(
+			"bad binding polling request: request is missing required query " +
+				"parameter",
+		)
+		s.writeResponse(w, http.StatusBadRequest, responseOperationRequired)
+		return
+	}
+	if operation != OperationBinding && operation != OperationUnbinding {
+		logFields["operation"] = operation
+		log.WithFields(logFields).Debug(
+			fmt.Sprintf(
+				`bad binding polling request: query parameter has invalid value; `+
+					`only "%s" and "%s" are accepted`,
+				OperationBinding,
+				OperationUnbinding,
+			),
+		)
+		s.writeResponse(w, http.StatusBadRequest, responseOperationInvalid)
+		return
+	}
+
+	logFields["operation"] = operation
+
+	binding, ok, err := s.store.GetBinding(bindingID)
+	if err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"binding polling error: error retrieving binding by id",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	if !ok || binding.InstanceID != instanceID {
+		if operation == OperationUnbinding {
+			s.writeResponse(w, http.StatusGone, responseEmptyJSON)
+			return
+		}
+		s.writeResponse(w, http.StatusNotFound, responseEmptyJSON)
+		return
+	}
+
+	logFields["status"] = binding.Status
+
+	if operation == OperationBinding {
+		switch binding.Status {
+		case service.BindingStateBinding:
+			log.WithFields(logFields).Debug(
+				"binding is in progress",
+			)
+			s.writeResponse(w, http.StatusOK, responseInProgress)
+		case service.BindingStateBound:
+			log.WithFields(logFields).Debug(
+				"binding is complete",
+			)
+			s.writeResponse(w, http.StatusOK, responseSucceeded)
+		case service.BindingStateBindingFailed:
+			log.WithFields(logFields).Debug(
+				"binding has failed",
+			)
+			s.writeResponse(w, http.StatusOK, responseFailed)
+		default:
+			log.WithFields(logFields).Error(
+				"binding polling error: binding is in an unknown or invalid state",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		}
+		return
+	}
+
+	switch binding.Status {
+	case service.BindingStateUnbinding:
+		log.WithFields(logFields).Debug(
+			"unbinding is in progress",
+		)
+		s.writeResponse(w, http.StatusOK, responseInProgress)
+	case service.BindingStateUnbindingFailed:
+		log.WithFields(logFields).Debug(
+			"unbinding has failed",
+		)
+		s.writeResponse(w, http.StatusOK, responseFailed)
+	default:
+		log.WithFields(logFields).Error(
+			"binding polling error: binding is in an unknown or invalid state",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+	}
+}
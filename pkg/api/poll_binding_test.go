@@ -0,0 +1,156 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollingBindingWithMissingOperation(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	req, err := getBindingPollingRequest(
+		getDisposableInstanceID(),
+		getDisposableBindingID(),
+		"",
+	)
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, responseOperationRequired, rr.Body.Bytes())
+}
+
+func TestPollingBindingWithInvalidOperation(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	req, err := getBindingPollingRequest(
+		getDisposableInstanceID(),
+		getDisposableBindingID(),
+		OperationProvisioning,
+	)
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, responseOperationInvalid, rr.Body.Bytes())
+}
+
+func TestPollingBindingWithBindingGone(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	testCases := []struct {
+		operation    string
+		expectedCode int
+	}{
+		{
+			operation:    OperationBinding,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			operation:    OperationUnbinding,
+			expectedCode: http.StatusGone,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.operation, func(t *testing.T) {
+			req, err := getBindingPollingRequest(
+				getDisposableInstanceID(),
+				getDisposableBindingID(),
+				testCase.operation,
+			)
+			assert.Nil(t, err)
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+			assert.Equal(t, testCase.expectedCode, rr.Code)
+			assert.Equal(t, responseEmptyJSON, rr.Body.Bytes())
+		})
+	}
+}
+
+func TestPollingBindingWithBindingStatus(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	testCases := []struct {
+		status       string
+		operation    string
+		expectedBody []byte
+	}{
+		{
+			status:       service.BindingStateBinding,
+			operation:    OperationBinding,
+			expectedBody: responseInProgress,
+		},
+		{
+			status:       service.BindingStateBound,
+			operation:    OperationBinding,
+			expectedBody: responseSucceeded,
+		},
+		{
+			status:       service.BindingStateBindingFailed,
+			operation:    OperationBinding,
+			expectedBody: responseFailed,
+		},
+		{
+			status:       service.BindingStateUnbinding,
+			operation:    OperationUnbinding,
+			expectedBody: responseInProgress,
+		},
+		{
+			status:       service.BindingStateUnbindingFailed,
+			operation:    OperationUnbinding,
+			expectedBody: responseFailed,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.status, func(t *testing.T) {
+			instanceID := getDisposableInstanceID()
+			bindingID := getDisposableBindingID()
+			err := s.store.WriteBinding(&service.Binding{
+				InstanceID: instanceID,
+				BindingID:  bindingID,
+				Status:     testCase.status,
+			})
+			assert.Nil(t, err)
+			req, err := getBindingPollingRequest(
+				instanceID,
+				bindingID,
+				testCase.operation,
+			)
+			assert.Nil(t, err)
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, testCase.expectedBody, rr.Body.Bytes())
+		})
+	}
+}
+
+func getBindingPollingRequest(
+	instanceID string,
+	bindingID string,
+	operation string,
+) (*http.Request, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		fmt.Sprintf(
+			"/v2/service_instances/%s/service_bindings/%s/last_operation",
+			instanceID,
+			bindingID,
+		),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if operation != "" {
+		q := req.URL.Query()
+		q.Add("operation", operation)
+		req.URL.RawQuery = q.Encode()
+	}
+	return req, nil
+}
@@ -8,9 +8,9 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/Azure/open-service-broker-azure/pkg/async/model"
 	"github.com/Azure/open-service-broker-azure/pkg/azure"
 	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	"github.com/mitchellh/mapstructure"
@@ -242,7 +242,26 @@ func (s *server) provision(w http.ResponseWriter, r *http.Request) {
 
 	// If we get to here, we need to provision a new instance.
 
-	// Start by validating all the standard provisioning parameters
+	// Start by validating the request parameters against the schema derived from
+	// the standard and service-specific provisioning parameters
+	schemas, err := svc.GetPlanSchemas()
+	if err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"pre-provisioning error: error retrieving parameter schemas",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	err = schemas.ServiceInstance.Create.Parameters.Validate(
+		provisioningRequest.Parameters,
+	)
+	if err != nil {
+		s.handlePossibleValidationError(err, w, logFields)
+		return
+	}
+
+	// Next, validate all the standard provisioning parameters
 	err = s.validateStandardProvisioningParameters(standardProvisioningParameters)
 	if err != nil {
 		s.handlePossibleValidationError(err, w, logFields)
@@ -269,8 +288,8 @@ func (s *server) provision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	firstStepName, ok := provisioner.GetFirstStepName()
-	if !ok {
+	firstStepNames := provisioner.GetFirstStepNames()
+	if len(firstStepNames) == 0 {
 		logFields["serviceID"] = provisioningRequest.ServiceID
 		logFields["planID"] = provisioningRequest.PlanID
 		log.WithFields(logFields).Error(
@@ -291,6 +310,7 @@ func (s *server) provision(w http.ResponseWriter, r *http.Request) {
 		PlanID:     provisioningRequest.PlanID,
 		StandardProvisioningParameters: standardProvisioningParameters,
 		Status: service.InstanceStateProvisioning,
+		OperationID: uuid.NewV4().String(),
 		StandardProvisioningContext: standardProvisioningContext,
 		Created:                     time.Now(),
 	}
@@ -317,6 +337,13 @@ func (s *server) provision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err = s.store.WriteInstance(instance); err != nil {
+		if _, ok := err.(*storage.ConflictError); ok {
+			log.WithFields(logFields).Debug(
+				"provisioning conflict: instance was created by a concurrent request",
+			)
+			s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
+			return
+		}
 		logFields["error"] = err
 		log.WithFields(logFields).Error(
 			"provisioning error: error persisting new instance",
@@ -325,21 +352,23 @@ func (s *server) provision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task := model.NewTask(
-		"provisionStep",
-		map[string]string{
-			"stepName":   firstStepName,
-			"instanceID": instanceID,
-		},
-	)
-	if err = s.asyncEngine.SubmitTask(task); err != nil {
-		logFields["step"] = firstStepName
-		logFields["error"] = err
-		log.WithFields(logFields).Error(
-			"provisioning error: error submitting provisioning task",
+	for _, firstStepName := range firstStepNames {
+		task := NewStepTask(
+			"provisionStep",
+			OperationProvisioning,
+			instanceID,
+			instance.OperationID,
+			firstStepName,
 		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
+		if err = s.asyncEngine.SubmitTask(task); err != nil {
+			logFields["step"] = firstStepName
+			logFields["error"] = err
+			log.WithFields(logFields).Error(
+				"provisioning error: error submitting provisioning task",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			return
+		}
 	}
 
 	// If we get all the way to here, we've been successful!
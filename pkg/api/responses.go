@@ -38,6 +38,14 @@ var responseDeprovisioningAccepted = []byte(
 	fmt.Sprintf(`{ "operation": "%s" }`, OperationDeprovisioning),
 )
 
+var responseBindingAccepted = []byte(
+	fmt.Sprintf(`{ "operation": "%s" }`, OperationBinding),
+)
+
+var responseUnbindingAccepted = []byte(
+	fmt.Sprintf(`{ "operation": "%s" }`, OperationUnbinding),
+)
+
 var responseInProgress = []byte(
 	fmt.Sprintf(`{ "state": "%s" }`, OperationStateInProgress),
 )
@@ -50,6 +58,11 @@ var responseFailed = []byte(
 	fmt.Sprintf(`{ "state": "%s" }`, OperationStateFailed),
 )
 
+var responseConcurrencyError = []byte(
+	`{ "error": "ConcurrencyError", "description": "Another operation for ` +
+		`this service instance is in progress." }`,
+)
+
 var responseEmptyJSON = []byte("{}")
 
 // The following are custom to this broker-- i.e. not explicitly declared by
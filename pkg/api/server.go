@@ -96,6 +96,15 @@ func NewServer(
 		"/v2/service_instances/{instance_id}/service_bindings/{binding_id}",
 		s.authenticator.Authenticate(s.unbind),
 	).Methods(http.MethodDelete)
+	router.HandleFunc(
+		"/v2/service_instances/{instance_id}/service_bindings/{binding_id}",
+		s.authenticator.Authenticate(s.getBinding),
+	).Methods(http.MethodGet)
+	router.HandleFunc(
+		"/v2/service_instances/{instance_id}/service_bindings/{binding_id}/"+
+			"last_operation",
+		s.authenticator.Authenticate(s.pollBinding),
+	).Methods(http.MethodGet)
 	router.HandleFunc(
 		"/v2/service_instances/{instance_id}",
 		s.authenticator.Authenticate(s.deprovision),
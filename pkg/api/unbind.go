@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"github.com/satori/uuid"
 )
 
 func (s *server) unbind(w http.ResponseWriter, r *http.Request) {
@@ -20,117 +23,250 @@ func (s *server) unbind(w http.ResponseWriter, r *http.Request) {
 
 	log.WithFields(logFields).Debug("received unbinding request")
 
-	binding, ok, err := s.store.GetBinding(bindingID)
+	// Unbinding is carried out asynchronously only if the client explicitly
+	// indicates that they will accept an incomplete result. Otherwise, the
+	// unbinding steps are executed before responding.
+	acceptsIncomplete, err := getAcceptsIncomplete(r)
 	if err != nil {
-		logFields["error"] = err
-		log.WithFields(logFields).Error(
-			"pre-unbinding error: error retrieving binding by id",
-		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
-	}
-	if !ok {
-		log.WithFields(logFields).Debug(
-			"no such binding remains to be unbound",
-		)
-		// No binding was found-- per spec, we return a 410
-		s.writeResponse(w, http.StatusGone, responseEmptyJSON)
-		return
-	}
-
-	if binding.InstanceID != instanceID {
-		logFields["instanceID"] = binding.InstanceID
-		logFields["requestInstanceID"] = instanceID
+		logFields["accepts_incomplete"] = r.URL.Query().Get("accepts_incomplete")
 		log.WithFields(logFields).Debug(
-			"bad unbinding request: instanceID does not match instanceID on the " +
-				"binding",
+			"bad unbinding request: query parameter has invalid value",
 		)
-		// TODO: Write a more detailed response
-		s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
+		s.writeResponse(w, http.StatusBadRequest, responseEmptyJSON)
 		return
 	}
+	// Clients that predate asynchronous unbinding cannot poll for its outcome, so
+	// for them, unbinding is always carried out synchronously.
+	acceptsIncomplete = acceptsIncomplete && supportsAsyncBindings(r)
 
-	instance, ok, err := s.store.GetInstance(instanceID)
-	if err != nil {
-		logFields["error"] = err
-		log.WithFields(logFields).Error(
-			"pre-unbinding error: error retrieving instance by id",
-		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
-	}
-	if !ok {
-		// The instance to unbind from does not exist!
-		// krancour: Not totally sure what to do here. It seems within the realm
-		// of possibility that an instance could be deprovisioned without all
-		// bindings to that instance first having been unbound-- at least there
-		// isn't any logic in this broker to prevent that and I don't believe the
-		// spec is clear on whether that's permissible or not. So for now, we must
-		// accept the possibility that orphaned bindings may exist. I'm choosing
-		// to deal with this by skipping straight to deleting the binding from the
-		// datastore without invoking any service-specific unbinding logic. (We
-		// cannot, because with the instance no longer existing, we cannot identify
-		// the service and plan of the instance, and therefore do not know which
-		// serviceManager can successfully effect binding).
-		// TODO: Re-evaluate this decision later.
-		log.WithFields(logFields).Debug(
-			"unbinding an orphaned binding",
-		)
-	} else {
-		// We can go ahead and find the Service itself to get the ServiceManager.
-		svc, ok := s.catalog.GetService(instance.ServiceID)
-		if !ok {
-			// If we don't find the Service in the catalog, something is really wrong.
-			// (It should exist, because an instance with this serviceID exists.)
-			logFields["serviceID"] = instance.ServiceID
+	// Whether and how the binding is unbound depends on its status, so if the
+	// binding is modified concurrently before the decision is persisted, the
+	// decision is made again using the latest revision of the binding, a
+	// bounded number of times
+	var binding *service.Binding
+	for attempt := 1; ; attempt++ {
+		var ok bool
+		binding, ok, err = s.store.GetBinding(bindingID)
+		if err != nil {
+			logFields["error"] = err
 			log.WithFields(logFields).Error(
-				"pre-unbinding error: no Service found for serviceID",
+				"pre-unbinding error: error retrieving binding by id",
 			)
 			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
 			return
 		}
-		serviceManager := svc.GetServiceManager()
+		if !ok {
+			log.WithFields(logFields).Debug(
+				"no such binding remains to be unbound",
+			)
+			// No binding was found-- per spec, we return a 410
+			s.writeResponse(w, http.StatusGone, responseEmptyJSON)
+			return
+		}
 
-		provisioningContext := serviceManager.GetEmptyProvisioningContext()
-		err = instance.GetProvisioningContext(provisioningContext, s.codec)
-		if err != nil {
-			logFields["error"] = err
-			log.WithFields(logFields).Error(
-				"unbinding error: error decoding persisted provisioningContext",
+		if binding.InstanceID != instanceID {
+			logFields["instanceID"] = binding.InstanceID
+			logFields["requestInstanceID"] = instanceID
+			log.WithFields(logFields).Debug(
+				"bad unbinding request: instanceID does not match instanceID on the " +
+					"binding",
 			)
-			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			// TODO: Write a more detailed response
+			s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
 			return
 		}
 
-		bindingContext := serviceManager.GetEmptyBindingContext()
-		err = binding.GetBindingContext(bindingContext, s.codec)
+		switch binding.Status {
+		case service.BindingStateBinding:
+			log.WithFields(logFields).Debug(
+				"bad unbinding request: binding is still in progress",
+			)
+			// TODO: Write a more detailed response
+			s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
+			return
+		case service.BindingStateUnbinding:
+			// Unbinding is already in progress
+			if !acceptsIncomplete {
+				s.writeResponse(
+					w,
+					http.StatusUnprocessableEntity,
+					responseAsyncRequired,
+				)
+				return
+			}
+			s.writeResponse(w, http.StatusAccepted, responseUnbindingAccepted)
+			return
+		}
+
+		instance, ok, err := s.store.GetInstance(instanceID)
 		if err != nil {
 			logFields["error"] = err
 			log.WithFields(logFields).Error(
-				"unbinding error: error decoding persisted bindingContext",
+				"pre-unbinding error: error retrieving instance by id",
 			)
 			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
 			return
 		}
+		if !ok {
+			// The instance to unbind from does not exist!
+			// krancour: Not totally sure what to do here. It seems within the realm
+			// of possibility that an instance could be deprovisioned without all
+			// bindings to that instance first having been unbound-- at least there
+			// isn't any logic in this broker to prevent that and I don't believe the
+			// spec is clear on whether that's permissible or not. So for now, we must
+			// accept the possibility that orphaned bindings may exist. I'm choosing
+			// to deal with this by skipping straight to deleting the binding from the
+			// datastore without invoking any service-specific unbinding logic. (We
+			// cannot, because with the instance no longer existing, we cannot identify
+			// the service and plan of the instance, and therefore do not know which
+			// serviceManager can successfully effect binding).
+			// TODO: Re-evaluate this decision later.
+			log.WithFields(logFields).Debug(
+				"unbinding an orphaned binding",
+			)
+		} else {
+			// We can go ahead and find the Service itself to get the ServiceManager.
+			svc, ok := s.catalog.GetService(instance.ServiceID)
+			if !ok {
+				// If we don't find the Service in the catalog, something is really wrong.
+				// (It should exist, because an instance with this serviceID exists.)
+				logFields["serviceID"] = instance.ServiceID
+				log.WithFields(logFields).Error(
+					"pre-unbinding error: no Service found for serviceID",
+				)
+				s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+				return
+			}
+			serviceManager := svc.GetServiceManager()
 
-		// Starting here, if something goes wrong, we don't know what state service-
-		// specific code has left us in, so we'll attempt to record the error in
-		// the datastore.
-		err = serviceManager.Unbind(
-			instance.StandardProvisioningContext,
-			provisioningContext,
-			bindingContext,
-		)
-		if err != nil {
-			s.handleUnbindingError(
-				binding,
-				err,
-				"error executing service-specific unbinding logic",
-				w,
+			provisioningContext := serviceManager.GetEmptyProvisioningContext()
+			err = instance.GetProvisioningContext(provisioningContext, s.codec)
+			if err != nil {
+				logFields["error"] = err
+				log.WithFields(logFields).Error(
+					"unbinding error: error decoding persisted provisioningContext",
+				)
+				s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+				return
+			}
+
+			bindingContext := serviceManager.GetEmptyBindingContext()
+			err = binding.GetBindingContext(bindingContext, s.codec)
+			if err != nil {
+				logFields["error"] = err
+				log.WithFields(logFields).Error(
+					"unbinding error: error decoding persisted bindingContext",
+				)
+				s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+				return
+			}
+
+			plan, ok := svc.GetPlan(instance.PlanID)
+			if !ok {
+				// If we don't find the Plan, something is really wrong. (It should
+				// exist, because an instance with this planID exists.)
+				logFields["serviceID"] = instance.ServiceID
+				logFields["planID"] = instance.PlanID
+				log.WithFields(logFields).Error(
+					"pre-unbinding error: no Plan found for planID",
+				)
+				s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+				return
+			}
+			var unbinder service.Unbinder
+			unbinder, err = serviceManager.GetUnbinder(plan)
+			if err != nil {
+				logFields["serviceID"] = instance.ServiceID
+				logFields["planID"] = instance.PlanID
+				logFields["error"] = err
+				log.WithFields(logFields).Error(
+					"pre-unbinding error: error retrieving unbinder for service and plan",
+				)
+				s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+				return
+			}
+
+			// An unbinder with no steps has nothing to do asynchronously, so in that
+			// case, we fall through to deleting the binding right away.
+			firstStepNames := unbinder.GetFirstStepNames()
+			if acceptsIncomplete && len(firstStepNames) > 0 {
+				binding.Status = service.BindingStateUnbinding
+				binding.OperationID = uuid.NewV4().String()
+				binding.CompletedSteps = nil
+				if err = s.store.WriteBinding(binding); err != nil {
+					if _, ok := err.(*storage.ConflictError); ok {
+						if attempt < maxWriteAttempts {
+							logFields["attempt"] = attempt
+							log.WithFields(logFields).Debug(
+								"binding was modified concurrently; retrying unbinding",
+							)
+							continue
+						}
+						log.WithFields(logFields).Debug(
+							"unbinding conflict: binding was modified by a concurrent request",
+						)
+						s.writeResponse(
+							w,
+							http.StatusUnprocessableEntity,
+							responseConcurrencyError,
+						)
+						return
+					}
+					logFields["error"] = err
+					log.WithFields(logFields).Error(
+						"pre-unbinding error: error persisting binding with updated status",
+					)
+					s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+					return
+				}
+				for _, firstStepName := range firstStepNames {
+					task := NewBindingStepTask(
+						"unbindStep",
+						OperationUnbinding,
+						bindingID,
+						binding.OperationID,
+						firstStepName,
+					)
+					if err = s.asyncEngine.SubmitTask(task); err != nil {
+						s.handleUnbindingError(
+							binding,
+							err,
+							fmt.Sprintf(`error submitting unbinding step "%s"`, firstStepName),
+							w,
+						)
+						return
+					}
+				}
+				s.writeResponse(w, http.StatusAccepted, responseUnbindingAccepted)
+				log.WithFields(logFields).Debug("asynchronous unbinding initiated")
+				return
+			}
+
+			// Starting here, if something goes wrong, we don't know what state service-
+			// specific code has left us in, so we'll attempt to record the error in
+			// the datastore. The unbinding logic is deliberately not bound to the
+			// request's context, since a client that disconnects mid-way would
+			// otherwise abandon it in an indeterminate state.
+			err = service.UnbindSynchronously(
+				context.Background(),
+				unbinder,
+				bindingID,
+				plan,
+				instance.StandardProvisioningContext,
+				provisioningContext,
+				bindingContext,
 			)
-			return
+			if err != nil {
+				s.handleUnbindingError(
+					binding,
+					err,
+					"error executing service-specific unbinding logic",
+					w,
+				)
+				return
+			}
 		}
-
+		break
 	}
 
 	if _, err = s.store.DeleteBinding(bindingID); err != nil {
@@ -148,9 +284,11 @@ func (s *server) unbind(w http.ResponseWriter, r *http.Request) {
 
 // handleUnbindingError tries to handle the most serious unbinding errors. The
 // binding status is updated and an attempt is made to persist the binding with
-// updated status. If this fails, we have a very serious problem on our hands,
-// so we log that failure and kill the process. Barring such a failure, a nicely
-// formatted error message is logged.
+// updated status. If the binding was modified concurrently, the attempt is
+// retried a bounded number of times using the latest revision of the binding.
+// If this fails for any other reason, we have a very serious problem on our
+// hands, so we log that failure and kill the process. Barring such a failure, a
+// nicely formatted error message is logged.
 func (s *server) handleUnbindingError(
 	binding *service.Binding,
 	e error,
@@ -169,12 +307,45 @@ func (s *server) handleUnbindingError(
 		"status":     binding.Status,
 	}
 	err := s.store.WriteBinding(binding)
-	if err != nil {
-		logFields["originalError"] = binding.StatusReason
-		logFields["persistenceError"] = err
-		log.WithFields(logFields).Fatal(
-			"unbinding error: error persisting binding with updated status",
-		)
+	for attempt := 1; err != nil; attempt++ {
+		if _, ok := err.(*storage.ConflictError); !ok {
+			logFields["originalError"] = binding.StatusReason
+			logFields["persistenceError"] = err
+			log.WithFields(logFields).Fatal(
+				"unbinding error: error persisting binding with updated status",
+			)
+		}
+		// The binding was modified concurrently. Unless another operation has
+		// since begun, the failure is recorded on the latest revision of the
+		// binding.
+		var latestBinding *service.Binding
+		var ok bool
+		if attempt < maxWriteAttempts {
+			latestBinding, ok, err = s.store.GetBinding(binding.BindingID)
+			if err != nil {
+				logFields["originalError"] = binding.StatusReason
+				logFields["persistenceError"] = err
+				log.WithFields(logFields).Fatal(
+					"unbinding error: error retrieving binding to update its status",
+				)
+			}
+		}
+		if !ok || latestBinding.OperationID != binding.OperationID {
+			logFields["error"] = e
+			log.WithFields(logFields).Error(
+				fmt.Sprintf(`unbinding conflict: %s`, msg),
+			)
+			s.writeResponse(
+				w,
+				http.StatusUnprocessableEntity,
+				responseConcurrencyError,
+			)
+			return
+		}
+		latestBinding.Status = binding.Status
+		latestBinding.StatusReason = binding.StatusReason
+		binding = latestBinding
+		err = s.store.WriteBinding(binding)
 	}
 	if e != nil {
 		logFields["error"] = e
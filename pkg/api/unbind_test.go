@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -110,6 +111,117 @@ func TestUnbindingFromInstanceThatExists(t *testing.T) {
 	assert.False(t, ok)
 }
 
+func TestUnbindingBindingThatIsInProgress(t *testing.T) {
+	s, _, err := getTestServer("", "")
+	assert.Nil(t, err)
+	instanceID := getDisposableInstanceID()
+	bindingID := getDisposableBindingID()
+	err = s.store.WriteBinding(&service.Binding{
+		InstanceID: instanceID,
+		BindingID:  bindingID,
+		Status:     service.BindingStateBinding,
+	})
+	assert.Nil(t, err)
+	req, err := getUnbindingRequest(
+		instanceID,
+		bindingID,
+	)
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, responseEmptyJSON, rr.Body.Bytes())
+}
+
+func TestAsyncUnbindingFromInstanceThatExists(t *testing.T) {
+	s, m, err := getTestServer("", "")
+	assert.Nil(t, err)
+	unbindCalled := false
+	m.ServiceManager.UnbindBehavior = func(
+		service.StandardProvisioningContext,
+		service.ProvisioningContext,
+		service.BindingContext,
+	) error {
+		unbindCalled = true
+		return nil
+	}
+	instanceID := getDisposableInstanceID()
+	bindingID := getDisposableBindingID()
+	err = s.store.WriteInstance(&service.Instance{
+		InstanceID: instanceID,
+		ServiceID:  fake.ServiceID,
+		PlanID:     fake.StandardPlanID,
+	})
+	assert.Nil(t, err)
+	err = s.store.WriteBinding(&service.Binding{
+		InstanceID: instanceID,
+		BindingID:  bindingID,
+		Status:     service.BindingStateBound,
+	})
+	assert.Nil(t, err)
+	req, err := getUnbindingRequest(
+		instanceID,
+		bindingID,
+	)
+	assert.Nil(t, err)
+	setAcceptsIncomplete(req, "true")
+	setBrokerAPIVersion(req, "2.14")
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, responseUnbindingAccepted, rr.Body.Bytes())
+	// Unbinding steps are left to the async engine
+	assert.False(t, unbindCalled)
+	binding, ok, err := s.store.GetBinding(bindingID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, service.BindingStateUnbinding, binding.Status)
+}
+
+func TestAsyncUnbindingWithAPIVersionBefore214(t *testing.T) {
+	s, m, err := getTestServer("", "")
+	assert.Nil(t, err)
+	unbindCalled := false
+	m.ServiceManager.UnbindBehavior = func(
+		service.StandardProvisioningContext,
+		service.ProvisioningContext,
+		service.BindingContext,
+	) error {
+		unbindCalled = true
+		return nil
+	}
+	instanceID := getDisposableInstanceID()
+	bindingID := getDisposableBindingID()
+	err = s.store.WriteInstance(&service.Instance{
+		InstanceID: instanceID,
+		ServiceID:  fake.ServiceID,
+		PlanID:     fake.StandardPlanID,
+	})
+	assert.Nil(t, err)
+	err = s.store.WriteBinding(&service.Binding{
+		InstanceID: instanceID,
+		BindingID:  bindingID,
+		Status:     service.BindingStateBound,
+	})
+	assert.Nil(t, err)
+	req, err := getUnbindingRequest(
+		instanceID,
+		bindingID,
+	)
+	assert.Nil(t, err)
+	setAcceptsIncomplete(req, "true")
+	setBrokerAPIVersion(req, "2.13")
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	// Older clients cannot poll for the outcome, so unbinding is synchronous
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, responseEmptyJSON, rr.Body.Bytes())
+	assert.True(t, unbindCalled)
+	_, ok, err := s.store.GetBinding(bindingID)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
 func getUnbindingRequest(
 	instanceID string,
 	bindingID string,
@@ -124,3 +236,132 @@ func getUnbindingRequest(
 		nil,
 	)
 }
+
+func TestAsyncUnbindingBindingThatIsModifiedConcurrently(t *testing.T) {
+	testCases := []struct {
+		name             string
+		conflicts        int
+		expectedCode     int
+		expectedResponse []byte
+		expectedStatus   string
+	}{
+		{
+			name:             "conflict resolved by retrying",
+			conflicts:        1,
+			expectedCode:     http.StatusAccepted,
+			expectedResponse: responseUnbindingAccepted,
+			expectedStatus:   service.BindingStateUnbinding,
+		},
+		{
+			name:             "conflict persists",
+			conflicts:        maxWriteAttempts,
+			expectedCode:     http.StatusUnprocessableEntity,
+			expectedResponse: responseConcurrencyError,
+			expectedStatus:   service.BindingStateBound,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, _, err := getTestServer("", "")
+			assert.Nil(t, err)
+			instanceID := getDisposableInstanceID()
+			bindingID := getDisposableBindingID()
+			err = s.store.WriteInstance(&service.Instance{
+				InstanceID: instanceID,
+				ServiceID:  fake.ServiceID,
+				PlanID:     fake.StandardPlanID,
+			})
+			assert.Nil(t, err)
+			err = s.store.WriteBinding(&service.Binding{
+				InstanceID: instanceID,
+				BindingID:  bindingID,
+				Status:     service.BindingStateBound,
+			})
+			assert.Nil(t, err)
+			s.store = &conflictingStore{
+				Store:     s.store,
+				conflicts: testCase.conflicts,
+			}
+			req, err := getUnbindingRequest(instanceID, bindingID)
+			assert.Nil(t, err)
+			setAcceptsIncomplete(req, "true")
+			setBrokerAPIVersion(req, "2.14")
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+			assert.Equal(t, testCase.expectedCode, rr.Code)
+			assert.Equal(t, testCase.expectedResponse, rr.Body.Bytes())
+			binding, ok, err := s.store.GetBinding(bindingID)
+			assert.Nil(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, testCase.expectedStatus, binding.Status)
+		})
+	}
+}
+
+func TestUnbindingFailureRecordedOnBindingThatIsModifiedConcurrently(
+	t *testing.T,
+) {
+	testCases := []struct {
+		name             string
+		conflicts        int
+		expectedCode     int
+		expectedResponse []byte
+		expectedStatus   string
+	}{
+		{
+			name:             "conflict resolved by retrying",
+			conflicts:        1,
+			expectedCode:     http.StatusInternalServerError,
+			expectedResponse: responseEmptyJSON,
+			expectedStatus:   service.BindingStateUnbindingFailed,
+		},
+		{
+			name:             "conflict persists",
+			conflicts:        maxWriteAttempts,
+			expectedCode:     http.StatusUnprocessableEntity,
+			expectedResponse: responseConcurrencyError,
+			expectedStatus:   service.BindingStateBound,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, m, err := getTestServer("", "")
+			assert.Nil(t, err)
+			m.ServiceManager.UnbindBehavior = func(
+				service.StandardProvisioningContext,
+				service.ProvisioningContext,
+				service.BindingContext,
+			) error {
+				return errors.New("an error")
+			}
+			instanceID := getDisposableInstanceID()
+			bindingID := getDisposableBindingID()
+			err = s.store.WriteInstance(&service.Instance{
+				InstanceID: instanceID,
+				ServiceID:  fake.ServiceID,
+				PlanID:     fake.StandardPlanID,
+			})
+			assert.Nil(t, err)
+			err = s.store.WriteBinding(&service.Binding{
+				InstanceID: instanceID,
+				BindingID:  bindingID,
+				Status:     service.BindingStateBound,
+			})
+			assert.Nil(t, err)
+			s.store = &conflictingStore{
+				Store:     s.store,
+				conflicts: testCase.conflicts,
+			}
+			req, err := getUnbindingRequest(instanceID, bindingID)
+			assert.Nil(t, err)
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+			assert.Equal(t, testCase.expectedCode, rr.Code)
+			assert.Equal(t, testCase.expectedResponse, rr.Body.Bytes())
+			binding, ok, err := s.store.GetBinding(bindingID)
+			assert.Nil(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, testCase.expectedStatus, binding.Status)
+		})
+	}
+}
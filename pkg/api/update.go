@@ -6,11 +6,12 @@ import (
 	"reflect"
 	"strconv"
 
-	"github.com/Azure/open-service-broker-azure/pkg/async/model"
 	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	"github.com/mitchellh/mapstructure"
+	"github.com/satori/uuid"
 )
 
 func (s *server) update(w http.ResponseWriter, r *http.Request) {
@@ -219,8 +220,24 @@ func (s *server) update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// If we get to here, we need to update the instance.
-	// Start by carrying out serviceManager-specific request validation
-	err = serviceManager.ValidateUpdatingParameters(updatingRequest.Parameters)
+	// Start by validating the request parameters against the schema derived from
+	// the service's updating parameters, then carry out serviceManager-specific
+	// request validation
+	schemas, err := svc.GetPlanSchemas()
+	if err != nil {
+		logFields["error"] = err
+		log.WithFields(logFields).Error(
+			"pre-updating error: error retrieving parameter schemas",
+		)
+		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+		return
+	}
+	err = schemas.ServiceInstance.Update.Parameters.Validate(
+		updatingRequest.Parameters,
+	)
+	if err == nil {
+		err = serviceManager.ValidateUpdatingParameters(updatingRequest.Parameters)
+	}
 	if err != nil {
 		validationErr, ok := err.(*service.ValidationError)
 		if ok {
@@ -260,8 +277,8 @@ func (s *server) update(w http.ResponseWriter, r *http.Request) {
 		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
 		return
 	}
-	firstStepName, ok := updater.GetFirstStepName()
-	if !ok {
+	firstStepNames := updater.GetFirstStepNames()
+	if len(firstStepNames) == 0 {
 		logFields["serviceID"] = updatingRequest.ServiceID
 		logFields["planID"] = updatingRequest.PlanID
 		log.WithFields(logFields).Error(
@@ -284,8 +301,18 @@ func (s *server) update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	instance.Status = service.InstanceStateUpdating
+	instance.OperationID = uuid.NewV4().String()
+	instance.CompletedSteps = nil
+	instance.SkippedSteps = nil
 	instance.PlanID = updatingRequest.PlanID
 	if err := s.store.WriteInstance(instance); err != nil {
+		if _, ok := err.(*storage.ConflictError); ok {
+			log.WithFields(logFields).Debug(
+				"updating conflict: instance was modified by a concurrent operation",
+			)
+			s.writeResponse(w, http.StatusConflict, responseEmptyJSON)
+			return
+		}
 		logFields["error"] = err
 		log.WithFields(logFields).Error(
 			"updating error: error persisting updated instance",
@@ -294,21 +321,23 @@ func (s *server) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task := model.NewTask(
-		"updateStep",
-		map[string]string{
-			"stepName":   firstStepName,
-			"instanceID": instanceID,
-		},
-	)
-	if err := s.asyncEngine.SubmitTask(task); err != nil {
-		logFields["step"] = firstStepName
-		logFields["error"] = err
-		log.WithFields(logFields).Error(
-			"updating error: error submitting updating task",
+	for _, firstStepName := range firstStepNames {
+		task := NewStepTask(
+			"updateStep",
+			OperationUpdating,
+			instanceID,
+			instance.OperationID,
+			firstStepName,
 		)
-		s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
-		return
+		if err := s.asyncEngine.SubmitTask(task); err != nil {
+			logFields["step"] = firstStepName
+			logFields["error"] = err
+			log.WithFields(logFields).Error(
+				"updating error: error submitting updating task",
+			)
+			s.writeResponse(w, http.StatusInternalServerError, responseEmptyJSON)
+			return
+		}
 	}
 
 	// If we get all the way to here, we've been successful!
@@ -0,0 +1,861 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async"
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	log "github.com/Sirupsen/logrus"
+	"github.com/boltdb/bolt"
+)
+
+var (
+	tasksBucketName       = []byte("tasks")
+	scheduledBucketName   = []byte("scheduled")
+	deadLettersBucketName = []byte("deadLetters")
+	// idempotencyKeysBucketName names the bucket that maps the idempotency keys
+	// of pending and executing tasks to those tasks' IDs
+	idempotencyKeysBucketName = []byte("idempotencyKeys")
+)
+
+// workerID identifies the engine's worker goroutines, collectively, in engine
+// stats
+const workerID = "boltdb"
+
+// engine is a BoltDB-based implementation of the async.Engine interface.
+// Tasks are persisted to a bucket, in the order they were submitted, until
+// they have been completed. Tasks submitted for deferred execution are
+// persisted to a second bucket, in the order they are due, until they are
+// moved to the first. Tasks that cannot be processed are persisted to a third
+// bucket until they are requeued or purged. Tasks that were pending or in
+// progress when the broker process stopped are resumed when it restarts.
+// Since a BoltDB file can only be opened by a single process at a time, this
+// engine is only suitable for a broker running as a single replica.
+type engine struct {
+	db      *bolt.DB
+	jobsFns map[string]model.JobFunction
+	// jobsMaxConcurrency caps the concurrency of jobs registered with a limit
+	jobsMaxConcurrency map[string]int
+	// periodicJobs maps the names of periodic jobs to their intervals
+	periodicJobs map[string]time.Duration
+	jobsFnsMutex sync.RWMutex
+	// periodicJobsTicks maps the names of periodic jobs to the interval during
+	// which a task for each was last submitted. It is only accessed by the
+	// goroutine that promotes due tasks.
+	periodicJobsTicks map[string]int64
+	// inFlight tracks the keys of tasks that have been received by a worker
+	// goroutine, but not yet completed, mapped to the names of their jobs
+	inFlight map[string]string
+	// running tracks the number of in flight tasks for each job
+	running       map[string]int
+	inFlightMutex sync.Mutex
+	// runningTasks maps the IDs of tasks currently executing to functions that
+	// cancel them
+	runningTasks      map[string]context.CancelFunc
+	runningTasksMutex sync.Mutex
+	// workerCount is the number of worker goroutines, and therefore the number
+	// of tasks that may be executed concurrently
+	workerCount int
+	// defaultTaskTimeout is the maximum duration a job function is permitted to
+	// execute for when the task being executed doesn't specify its own timeout
+	defaultTaskTimeout time.Duration
+	// notify signals an idle worker goroutine that a task has been submitted
+	notify chan struct{}
+	// pollInterval bounds how long an idle worker goroutine waits before
+	// checking for tasks again
+	pollInterval time.Duration
+	// scheduleInterval is how often deferred tasks that have become due are
+	// moved from the scheduled bucket to the tasks bucket
+	scheduleInterval time.Duration
+	// drainTimeout is the maximum duration the engine waits, when shutting
+	// down, for in-flight tasks to complete
+	drainTimeout time.Duration
+}
+
+// NewEngine returns a new BoltDB-based implementation of the async.Engine
+// interface. Any buckets the engine requires are created, as needed, before
+// the engine is returned. The worker pool size, default task timeout, and
+// drain timeout are taken from the given config. Since no other engine can
+// take over tasks this engine doesn't know how to process, the config's
+// MaxWorkerRejections doesn't apply.
+func NewEngine(db *bolt.DB, config async.Config) (async.Engine, error) {
+	if config.WorkerPoolSize < 1 {
+		return nil, fmt.Errorf(
+			"invalid worker pool size %d; must be at least 1",
+			config.WorkerPoolSize,
+		)
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucketName := range [][]byte{
+			tasksBucketName,
+			scheduledBucketName,
+			deadLettersBucketName,
+			idempotencyKeysBucketName,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(bucketName); err != nil {
+				return fmt.Errorf(`error creating bucket "%s": %s`, bucketName, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &engine{
+		db:                 db,
+		jobsFns:            make(map[string]model.JobFunction),
+		jobsMaxConcurrency: make(map[string]int),
+		periodicJobs:       make(map[string]time.Duration),
+		periodicJobsTicks:  make(map[string]int64),
+		inFlight:           make(map[string]string),
+		running:            make(map[string]int),
+		runningTasks:       make(map[string]context.CancelFunc),
+		workerCount:        config.WorkerPoolSize,
+		defaultTaskTimeout: config.DefaultTaskTimeout,
+		notify:             make(chan struct{}, 1),
+		pollInterval:       time.Second * 5,
+		scheduleInterval:   time.Second,
+		drainTimeout:       config.DrainTimeout,
+	}, nil
+}
+
+// RegisterJob registers a new Job with the async engine
+func (e *engine) RegisterJob(name string, fn model.JobFunction) error {
+	return e.RegisterJobWithMaxConcurrency(name, fn, 0)
+}
+
+// RegisterJobWithMaxConcurrency registers a new Job with the async engine. No
+// more than the given number of tasks for the Job will be executed
+// concurrently. A limit of zero indicates no limit.
+func (e *engine) RegisterJobWithMaxConcurrency(
+	name string,
+	fn model.JobFunction,
+	maxConcurrency int,
+) error {
+	if maxConcurrency < 0 {
+		return &async.InvalidMaxConcurrencyError{
+			Name:           name,
+			MaxConcurrency: maxConcurrency,
+		}
+	}
+	e.jobsFnsMutex.Lock()
+	defer e.jobsFnsMutex.Unlock()
+	if _, ok := e.jobsFns[name]; ok {
+		return &async.DuplicateJobError{Name: name}
+	}
+	e.jobsFns[name] = fn
+	if maxConcurrency > 0 {
+		e.jobsMaxConcurrency[name] = maxConcurrency
+	}
+	return nil
+}
+
+// RegisterPeriodicJob registers a new Job with the async engine and causes a
+// task for the Job to be submitted once per interval. If the previous
+// interval's task is still pending or executing, no task is submitted.
+func (e *engine) RegisterPeriodicJob(
+	name string,
+	interval time.Duration,
+	fn model.JobFunction,
+) error {
+	if interval <= 0 {
+		return &async.InvalidIntervalError{Name: name, Interval: interval}
+	}
+	if err := e.RegisterJob(name, fn); err != nil {
+		return err
+	}
+	e.jobsFnsMutex.Lock()
+	defer e.jobsFnsMutex.Unlock()
+	e.periodicJobs[name] = interval
+	return nil
+}
+
+// SubmitTask submits an idempotent task to the async engine for reliable,
+// asynchronous completion. If the task has an idempotency key and another task
+// having the same key is pending or executing, the task is discarded.
+func (e *engine) SubmitTask(task model.Task) error {
+	taskJSON, err := task.ToJSON()
+	if err != nil {
+		return fmt.Errorf("error encoding task %#v: %s", task, err)
+	}
+	err = e.db.Update(func(tx *bolt.Tx) error {
+		claimed, txErr := claimIdempotencyKey(tx, task)
+		if txErr != nil || !claimed {
+			return txErr
+		}
+		return enqueue(tx, taskJSON)
+	})
+	if err != nil {
+		return fmt.Errorf("error submitting task %#v: %s", task, err)
+	}
+	e.signal()
+	return nil
+}
+
+// enqueue persists the given task to the tasks bucket. Keys are big-endian
+// sequence numbers so that tasks are iterated over in the order they were
+// submitted.
+func enqueue(tx *bolt.Tx, taskJSON []byte) error {
+	bucket := tx.Bucket(tasksBucketName)
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return bucket.Put(key, taskJSON)
+}
+
+// SubmitTaskAt submits an idempotent task to the async engine for reliable,
+// asynchronous completion no sooner than the given time. If the task has an
+// idempotency key and another task having the same key is pending or
+// executing, the task is discarded.
+func (e *engine) SubmitTaskAt(task model.Task, executeTime time.Time) error {
+	taskJSON, err := task.ToJSON()
+	if err != nil {
+		return fmt.Errorf("error encoding task %#v: %s", task, err)
+	}
+	err = e.db.Update(func(tx *bolt.Tx) error {
+		claimed, txErr := claimIdempotencyKey(tx, task)
+		if txErr != nil || !claimed {
+			return txErr
+		}
+		return schedule(tx, taskJSON, executeTime)
+	})
+	if err != nil {
+		return fmt.Errorf("error scheduling task %#v: %s", task, err)
+	}
+	return nil
+}
+
+// SubmitTaskAfter submits an idempotent task to the async engine for
+// reliable, asynchronous completion once the given delay has elapsed
+func (e *engine) SubmitTaskAfter(task model.Task, delay time.Duration) error {
+	return e.SubmitTaskAt(task, time.Now().Add(delay))
+}
+
+// claimIdempotencyKey records the given task as the holder of its idempotency
+// key and returns a bool indicating whether the task may be submitted. This is
+// the case if the task has no idempotency key or if no other pending or
+// executing task has the same idempotency key.
+func claimIdempotencyKey(tx *bolt.Tx, task model.Task) (bool, error) {
+	idempotencyKey := []byte(task.GetIdempotencyKey())
+	if len(idempotencyKey) == 0 {
+		return true, nil
+	}
+	bucket := tx.Bucket(idempotencyKeysBucketName)
+	holderID := bucket.Get(idempotencyKey)
+	if holderID != nil && string(holderID) != task.GetID() {
+		log.WithFields(log.Fields{
+			"job":            task.GetJobName(),
+			"taskID":         task.GetID(),
+			"idempotencyKey": task.GetIdempotencyKey(),
+		}).Debug("discarding duplicate task")
+		return false, nil
+	}
+	return true, bucket.Put(idempotencyKey, []byte(task.GetID()))
+}
+
+// releaseIdempotencyKey permits tasks having the same idempotency key as the
+// given task to be submitted again. It is called when the given task will not
+// be executed again.
+func releaseIdempotencyKey(tx *bolt.Tx, taskJSON []byte) error {
+	task, err := model.NewTaskFromJSON(taskJSON)
+	if err != nil {
+		// A malformed task cannot have claimed an idempotency key
+		return nil
+	}
+	idempotencyKey := []byte(task.GetIdempotencyKey())
+	if len(idempotencyKey) == 0 {
+		return nil
+	}
+	bucket := tx.Bucket(idempotencyKeysBucketName)
+	if string(bucket.Get(idempotencyKey)) != task.GetID() {
+		return nil
+	}
+	return bucket.Delete(idempotencyKey)
+}
+
+// schedule persists the given task to the scheduled bucket. Keys are the
+// big-endian due time followed by a big-endian sequence number so that tasks
+// are iterated over in the order they become due.
+func schedule(tx *bolt.Tx, taskJSON []byte, executeTime time.Time) error {
+	bucket := tx.Bucket(scheduledBucketName)
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(executeTime.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return bucket.Put(key, taskJSON)
+}
+
+// ListDeadLetters returns all tasks that have been set aside because they
+// could not be processed, most recently dead-lettered first
+func (e *engine) ListDeadLetters() ([]model.DeadLetter, error) {
+	deadLetters := []model.DeadLetter{}
+	err := e.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(deadLettersBucketName).Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			deadLetter, err := model.NewDeadLetterFromJSON(v)
+			if err != nil {
+				return fmt.Errorf("error decoding dead-lettered task: %s", err)
+			}
+			deadLetters = append(deadLetters, deadLetter)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing dead-lettered tasks: %s", err)
+	}
+	return deadLetters, nil
+}
+
+// RequeueDeadLetter removes the dead-lettered task having the given ID from
+// the dead letters bucket and resubmits it to the tasks bucket
+func (e *engine) RequeueDeadLetter(id string) error {
+	err := e.db.Update(func(tx *bolt.Tx) error {
+		key, deadLetter, err := findDeadLetter(tx, id)
+		if err != nil {
+			return err
+		}
+		task, err := deadLetter.GetTask()
+		if err != nil {
+			return fmt.Errorf("error decoding task: %s", err)
+		}
+		taskJSON, err := task.ToJSON()
+		if err != nil {
+			return fmt.Errorf("error encoding task %#v: %s", task, err)
+		}
+		// The task's idempotency key was released when it was dead-lettered.
+		// Now that the task is pending again, reclaim it.
+		if idempotencyKey := task.GetIdempotencyKey(); idempotencyKey != "" {
+			if err = tx.Bucket(idempotencyKeysBucketName).Put(
+				[]byte(idempotencyKey),
+				[]byte(task.GetID()),
+			); err != nil {
+				return err
+			}
+		}
+		if err = enqueue(tx, taskJSON); err != nil {
+			return err
+		}
+		return tx.Bucket(deadLettersBucketName).Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf(
+			`error requeuing dead-lettered task "%s": %s`,
+			id,
+			err,
+		)
+	}
+	e.signal()
+	return nil
+}
+
+// PurgeDeadLetter permanently removes the dead-lettered task having the given
+// ID from the dead letters bucket
+func (e *engine) PurgeDeadLetter(id string) error {
+	err := e.db.Update(func(tx *bolt.Tx) error {
+		key, _, err := findDeadLetter(tx, id)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(deadLettersBucketName).Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf(`error purging dead-lettered task "%s": %s`, id, err)
+	}
+	return nil
+}
+
+// CancelTask cancels the context passed to the job function of the task having
+// the given ID, if that task is executing. Tasks that are not executing are
+// unaffected.
+func (e *engine) CancelTask(taskID string) error {
+	e.runningTasksMutex.Lock()
+	cancel, ok := e.runningTasks[taskID]
+	e.runningTasksMutex.Unlock()
+	if ok {
+		cancel()
+	}
+	return nil
+}
+
+// GetStatus returns the status of the async engine. Since the BoltDB-based
+// engine cannot be shared by multiple brokers, it is always its own leader.
+func (e *engine) GetStatus() (model.Status, error) {
+	return model.Status{IsCleanerLeader: true}, nil
+}
+
+// GetStats returns a snapshot of the state of the engine's queues and workers.
+// Since the engine's worker goroutines share a single pool of in-flight tasks,
+// they are reported as a single worker.
+func (e *engine) GetStats() (model.EngineStats, error) {
+	now := time.Now()
+	workerStats := model.WorkerStats{
+		ID:            workerID,
+		Alive:         true,
+		InFlightTasks: []model.TaskStats{},
+	}
+	stats := model.EngineStats{
+		Workers: []model.WorkerStats{workerStats},
+	}
+	e.inFlightMutex.Lock()
+	defer e.inFlightMutex.Unlock()
+	err := e.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(tasksBucketName).Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			_, inFlight := e.inFlight[string(k)]
+			if !inFlight {
+				stats.PendingTaskCount++
+			}
+			// Malformed tasks are ignored
+			task, err := model.NewTaskFromJSON(v)
+			if err != nil {
+				continue
+			}
+			taskStats := model.NewTaskStats(task, now)
+			if inFlight {
+				workerStats.InFlightTasks = append(
+					workerStats.InFlightTasks,
+					taskStats,
+				)
+			} else if stats.OldestPendingTask == nil {
+				// Tasks are iterated over in the order they were submitted
+				stats.OldestPendingTask = &taskStats
+			}
+		}
+		stats.ScheduledTaskCount = int64(
+			tx.Bucket(scheduledBucketName).Stats().KeyN,
+		)
+		stats.DeadLetterCount = int64(
+			tx.Bucket(deadLettersBucketName).Stats().KeyN,
+		)
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("error retrieving engine stats: %s", err)
+	}
+	stats.Workers[0] = workerStats
+	return stats, nil
+}
+
+// findDeadLetter returns the key and value of the dead-lettered task having
+// the given ID
+func findDeadLetter(tx *bolt.Tx, id string) ([]byte, model.DeadLetter, error) {
+	cursor := tx.Bucket(deadLettersBucketName).Cursor()
+	for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		deadLetter, err := model.NewDeadLetterFromJSON(v)
+		if err != nil {
+			return nil, model.DeadLetter{}, err
+		}
+		if deadLetter.ID == id {
+			return k, deadLetter, nil
+		}
+	}
+	return nil, model.DeadLetter{}, &async.DeadLetterNotFoundError{ID: id}
+}
+
+// signal wakes an idle worker goroutine, if there is one
+func (e *engine) signal() {
+	select {
+	case e.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Start causes the async engine to begin executing queued tasks
+func (e *engine) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errChan := make(chan error)
+	go func() {
+		select {
+		case errChan <- e.promoteDueTasks(ctx):
+		case <-ctx.Done():
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < e.workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case errChan <- e.receiveAndWork(ctx):
+			case <-ctx.Done():
+			}
+		}()
+	}
+	select {
+	case <-ctx.Done():
+		log.Debug("context canceled; async engine draining")
+		e.drain(&wg)
+		return ctx.Err()
+	case err := <-errChan:
+		return err
+	}
+}
+
+// drain waits, for no longer than the drain timeout, for the given wait group,
+// which tracks the engine's worker goroutines. Tasks whose job functions are
+// still executing when the drain timeout elapses remain in the tasks bucket and
+// are resumed when the broker process restarts.
+func (e *engine) drain(wg *sync.WaitGroup) {
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+		log.Debug("async engine drained")
+	case <-time.After(e.drainTimeout):
+		log.Warn("timed out waiting for in-flight tasks to complete")
+	}
+}
+
+// promoteDueTasks periodically moves deferred tasks that have become due from
+// the scheduled bucket to the tasks bucket until the context is canceled or an
+// error is encountered
+func (e *engine) promoteDueTasks(ctx context.Context) error {
+	ticker := time.NewTicker(e.scheduleInterval)
+	defer ticker.Stop()
+	for {
+		var promoted bool
+		err := e.db.Update(func(tx *bolt.Tx) error {
+			now := uint64(time.Now().UnixNano())
+			cursor := tx.Bucket(scheduledBucketName).Cursor()
+			for k, v := cursor.First(); k != nil; k, v = cursor.First() {
+				if binary.BigEndian.Uint64(k) > now {
+					return nil
+				}
+				if err := enqueue(tx, v); err != nil {
+					return err
+				}
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+				promoted = true
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error promoting scheduled tasks: %s", err)
+		}
+		if promoted {
+			e.signal()
+		}
+		if err = e.submitPeriodicTasks(time.Now()); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// submitPeriodicTasks submits a task for each periodic job that hasn't already
+// had a task submitted during the interval containing the given time.
+// Intervals are aligned to the epoch.
+func (e *engine) submitPeriodicTasks(now time.Time) error {
+	e.jobsFnsMutex.RLock()
+	defer e.jobsFnsMutex.RUnlock()
+	for name, interval := range e.periodicJobs {
+		tick := now.UnixNano() / int64(interval)
+		if lastTick, ok := e.periodicJobsTicks[name]; ok && lastTick == tick {
+			continue
+		}
+		task := model.NewTask(name, nil)
+		// If the previous interval's task is still pending or executing, this
+		// interval's task is discarded
+		task.SetIdempotencyKey(fmt.Sprintf("periodicJobs:%s", name))
+		if err := e.SubmitTask(task); err != nil {
+			return fmt.Errorf(
+				`error submitting task for periodic job "%s": %s`,
+				name,
+				err,
+			)
+		}
+		e.periodicJobsTicks[name] = tick
+	}
+	return nil
+}
+
+// receiveAndWork synchronously receives and completes tasks until the context
+// is canceled or an error is encountered
+func (e *engine) receiveAndWork(ctx context.Context) error {
+	for {
+		key, taskJSON, err := e.receive()
+		if err != nil {
+			return fmt.Errorf("error receiving task: %s", err)
+		}
+		if key == nil {
+			select {
+			case <-e.notify:
+			case <-time.After(e.pollInterval):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		task, err := model.NewTaskFromJSON(taskJSON)
+		if err != nil {
+			// If the JSON is invalid, there's nothing we can do. Set the task aside
+			// in the dead letters bucket, log this and move on.
+			log.WithFields(log.Fields{
+				"taskJSON": taskJSON,
+				"error":    err,
+			}).Error("error decoding task")
+			if err = e.deadLetter(
+				key,
+				taskJSON,
+				fmt.Sprintf("error decoding task: %s", err),
+			); err != nil {
+				return err
+			}
+			continue
+		}
+		// Job functions execute with a context that is independent of the
+		// engine's so that in-flight tasks aren't interrupted when the engine
+		// begins draining
+		if err = e.work(context.Background(), task); err != nil {
+			if _, ok := err.(*async.JobNotFoundError); ok {
+				// Unlike with the Redis-based engine, there are no other workers that
+				// might know how to process this task, so it's dead-lettered
+				// immediately.
+				log.WithFields(log.Fields{
+					"job":    task.GetJobName(),
+					"taskID": task.GetID(),
+					"error":  err,
+				}).Error("error executing job")
+				if err = e.deadLetter(key, taskJSON, err.Error()); err != nil {
+					return err
+				}
+				continue
+			}
+			if task.WillRetry(err) {
+				if err = e.retry(key, task, err); err != nil {
+					return err
+				}
+				continue
+			}
+			// If we get to here, we have a legitimate failure executing the task.
+			// Simply log this.
+			logger := log.WithFields(log.Fields{
+				"job":    task.GetJobName(),
+				"taskID": task.GetID(),
+				"error":  err,
+			})
+			switch err.(type) {
+			case *async.TaskTimedOutError:
+				logger.Error("job timed out")
+			case *async.TaskCanceledError:
+				logger.Warn("job canceled")
+			default:
+				logger.Error("error executing job")
+			}
+		}
+		if err := e.complete(key); err != nil {
+			return fmt.Errorf("error removing completed task: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// receive returns the key and JSON of the oldest task that isn't already in
+// flight and whose job isn't already executing as many tasks as are permitted,
+// and marks it as in flight. If there is no such task, a nil key is returned.
+func (e *engine) receive() ([]byte, []byte, error) {
+	e.inFlightMutex.Lock()
+	defer e.inFlightMutex.Unlock()
+	var key, taskJSON []byte
+	var jobName string
+	err := e.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(tasksBucketName).Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			if _, ok := e.inFlight[string(k)]; ok {
+				continue
+			}
+			// Malformed tasks are received anyway so they can be dead-lettered
+			if task, err := model.NewTaskFromJSON(v); err == nil {
+				jobName = task.GetJobName()
+				if e.isAtMaxConcurrency(jobName) {
+					continue
+				}
+			}
+			// Byte slices returned by BoltDB are only valid for the life of the
+			// transaction, so they must be copied
+			key = append([]byte{}, k...)
+			taskJSON = append([]byte{}, v...)
+			return nil
+		}
+		return nil
+	})
+	if err != nil || key == nil {
+		return nil, nil, err
+	}
+	e.inFlight[string(key)] = jobName
+	e.running[jobName]++
+	return key, taskJSON, nil
+}
+
+// isAtMaxConcurrency returns a bool indicating whether as many tasks for the
+// given job as are permitted are already in flight. The caller must hold the
+// inFlightMutex.
+func (e *engine) isAtMaxConcurrency(jobName string) bool {
+	e.jobsFnsMutex.RLock()
+	maxConcurrency, ok := e.jobsMaxConcurrency[jobName]
+	e.jobsFnsMutex.RUnlock()
+	return ok && e.running[jobName] >= maxConcurrency
+}
+
+// release marks the task having the given key as no longer in flight. Since
+// this may permit a task for a job that was at its maximum concurrency to be
+// received, an idle worker goroutine is woken. The caller must hold the
+// inFlightMutex.
+func (e *engine) release(key []byte) {
+	jobName, ok := e.inFlight[string(key)]
+	if !ok {
+		return
+	}
+	delete(e.inFlight, string(key))
+	if e.running[jobName]--; e.running[jobName] <= 0 {
+		delete(e.running, jobName)
+	}
+	e.signal()
+}
+
+// complete removes the task having the given key from the tasks bucket and
+// releases its idempotency key
+func (e *engine) complete(key []byte) error {
+	e.inFlightMutex.Lock()
+	defer e.inFlightMutex.Unlock()
+	err := e.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tasksBucketName)
+		if txErr := releaseIdempotencyKey(tx, bucket.Get(key)); txErr != nil {
+			return txErr
+		}
+		return bucket.Delete(key)
+	})
+	e.release(key)
+	return err
+}
+
+// retry atomically moves a task that failed with a retryable error from the
+// tasks bucket to the scheduled bucket, to become due once the backoff dictated
+// by the task's retry policy has elapsed
+func (e *engine) retry(key []byte, task model.Task, cause error) error {
+	failedAttempts := task.IncrementFailedAttempts()
+	backoff := task.GetRetryPolicy().GetBackoff(failedAttempts)
+	log.WithFields(log.Fields{
+		"job":            task.GetJobName(),
+		"taskID":         task.GetID(),
+		"failedAttempts": failedAttempts,
+		"backoff":        backoff,
+		"error":          cause,
+	}).Warn("error executing job; will retry")
+	taskJSON, err := task.ToJSON()
+	if err != nil {
+		return fmt.Errorf("error encoding task %#v: %s", task, err)
+	}
+	e.inFlightMutex.Lock()
+	defer e.inFlightMutex.Unlock()
+	err = e.db.Update(func(tx *bolt.Tx) error {
+		if txErr := schedule(tx, taskJSON, time.Now().Add(backoff)); txErr != nil {
+			return txErr
+		}
+		return tx.Bucket(tasksBucketName).Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf("error scheduling retry of task %#v: %s", task, err)
+	}
+	e.release(key)
+	return nil
+}
+
+// deadLetter atomically moves a task that cannot be processed from the tasks
+// bucket to the dead letters bucket, along with the reason it cannot be
+// processed
+func (e *engine) deadLetter(key []byte, taskJSON []byte, reason string) error {
+	deadLetterJSON, err := model.NewDeadLetter(taskJSON, reason).ToJSON()
+	if err != nil {
+		return fmt.Errorf("error dead-lettering task %s: %s", taskJSON, err)
+	}
+	e.inFlightMutex.Lock()
+	defer e.inFlightMutex.Unlock()
+	err = e.db.Update(func(tx *bolt.Tx) error {
+		deadLetters := tx.Bucket(deadLettersBucketName)
+		seq, txErr := deadLetters.NextSequence()
+		if txErr != nil {
+			return txErr
+		}
+		deadLetterKey := make([]byte, 8)
+		binary.BigEndian.PutUint64(deadLetterKey, seq)
+		if txErr = deadLetters.Put(deadLetterKey, deadLetterJSON); txErr != nil {
+			return txErr
+		}
+		if txErr = releaseIdempotencyKey(tx, taskJSON); txErr != nil {
+			return txErr
+		}
+		return tx.Bucket(tasksBucketName).Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf("error dead-lettering task %s: %s", taskJSON, err)
+	}
+	e.release(key)
+	return nil
+}
+
+func (e *engine) work(ctx context.Context, task model.Task) error {
+	ctx, cancel := model.ContextWithCancel(model.ContextWithTask(ctx, task))
+	defer cancel()
+	timeout := task.GetTimeout()
+	if timeout == 0 {
+		timeout = e.defaultTaskTimeout
+	}
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+	e.jobsFnsMutex.RLock()
+	jobFn, ok := e.jobsFns[task.GetJobName()]
+	e.jobsFnsMutex.RUnlock()
+	if !ok {
+		return &async.JobNotFoundError{Name: task.GetJobName()}
+	}
+	e.runningTasksMutex.Lock()
+	e.runningTasks[task.GetID()] = cancel
+	e.runningTasksMutex.Unlock()
+	defer func() {
+		e.runningTasksMutex.Lock()
+		delete(e.runningTasks, task.GetID())
+		e.runningTasksMutex.Unlock()
+	}()
+	err := jobFn(ctx, task.GetArgs())
+	if err != nil {
+		if model.IsTimedOut(ctx) {
+			return &async.TaskTimedOutError{
+				TaskID:  task.GetID(),
+				Timeout: timeout,
+				Err:     err,
+			}
+		}
+		if model.IsCanceled(ctx) {
+			return &async.TaskCanceledError{TaskID: task.GetID(), Err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,607 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async"
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineRegisterDuplicateJob(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	err = e.RegisterJob("foo", nil)
+	assert.Nil(t, err)
+	err = e.RegisterJob("foo", nil)
+	assert.Equal(t, &async.DuplicateJobError{Name: "foo"}, err)
+}
+
+func TestEngineCompletesTasks(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	const expectedCount = 5
+	var wg sync.WaitGroup
+	wg.Add(expectedCount)
+	err = e.RegisterJob("foo", func(context.Context, map[string]string) error {
+		wg.Done()
+		return nil
+	})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	for range [expectedCount]struct{}{} {
+		err = e.SubmitTask(model.NewTask("foo", nil))
+		assert.Nil(t, err)
+	}
+	assertCompletes(t, &wg)
+	// Give the engine a moment to remove the last completed task
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, 0, countTasks(t, db))
+}
+
+func TestEngineDiscardsDuplicateTasks(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	newTask := func() model.Task {
+		task := model.NewTask("foo", nil)
+		task.SetIdempotencyKey("bar")
+		return task
+	}
+	for range [3]struct{}{} {
+		err = e.SubmitTask(newTask())
+		assert.Nil(t, err)
+	}
+	// Only the first of the tasks should have been submitted
+	assert.Equal(t, 1, countTasks(t, db))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	err = e.RegisterJob("foo", func(context.Context, map[string]string) error {
+		wg.Done()
+		return nil
+	})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	assertCompletes(t, &wg)
+	// Give the engine a moment to remove the completed task
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, 0, countTasks(t, db))
+	// Once the task has completed, a task having the same key may be submitted
+	wg.Add(1)
+	err = e.SubmitTask(newTask())
+	assert.Nil(t, err)
+	assertCompletes(t, &wg)
+}
+
+func TestEngineRespectsMaxConcurrency(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	const expectedCount = 5
+	const maxConcurrency = 2
+	var wg sync.WaitGroup
+	wg.Add(expectedCount)
+	var running, maxRunning int
+	var runningMutex sync.Mutex
+	err = e.RegisterJobWithMaxConcurrency(
+		"foo",
+		func(context.Context, map[string]string) error {
+			runningMutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			runningMutex.Unlock()
+			time.Sleep(time.Millisecond * 100)
+			runningMutex.Lock()
+			running--
+			runningMutex.Unlock()
+			wg.Done()
+			return nil
+		},
+		maxConcurrency,
+	)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	for range [expectedCount]struct{}{} {
+		err = e.SubmitTask(model.NewTask("foo", nil))
+		assert.Nil(t, err)
+	}
+	assertCompletes(t, &wg)
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+	assert.Equal(t, maxConcurrency, maxRunning)
+}
+
+func TestEngineRetriesRetryableFailures(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	e.(*engine).scheduleInterval = time.Millisecond * 10
+	const maxAttempts = 3
+	var wg sync.WaitGroup
+	wg.Add(maxAttempts)
+	var attempts int
+	var attemptsMutex sync.Mutex
+	err = e.RegisterJob("foo", func(context.Context, map[string]string) error {
+		attemptsMutex.Lock()
+		defer attemptsMutex.Unlock()
+		attempts++
+		wg.Done()
+		return service.NewRetryableError(errors.New("transient failure"))
+	})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	err = e.SubmitTask(model.NewTaskWithRetryPolicy(
+		"foo",
+		nil,
+		model.RetryPolicy{
+			MaxAttempts:    maxAttempts,
+			InitialBackoff: time.Millisecond * 10,
+		},
+	))
+	assert.Nil(t, err)
+	assertCompletes(t, &wg)
+	// Give the engine a moment to remove the task after its final attempt
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, 0, countTasks(t, db))
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+	assert.Equal(t, maxAttempts, attempts)
+}
+
+func TestEngineCompletesPeriodicTasks(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	e.(*engine).scheduleInterval = time.Millisecond * 10
+	const expectedCount = 3
+	var wg sync.WaitGroup
+	wg.Add(expectedCount)
+	var count int
+	var countMutex sync.Mutex
+	err = e.RegisterPeriodicJob(
+		"foo",
+		time.Millisecond*50,
+		func(context.Context, map[string]string) error {
+			countMutex.Lock()
+			defer countMutex.Unlock()
+			// Don't call wg.Done() more often than expected
+			if count++; count <= expectedCount {
+				wg.Done()
+			}
+			return nil
+		},
+	)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	assertCompletes(t, &wg)
+}
+
+func TestEngineRegisterPeriodicJobWithInvalidInterval(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	err = e.RegisterPeriodicJob("foo", 0, nil)
+	assert.Equal(t, &async.InvalidIntervalError{Name: "foo"}, err)
+}
+
+func TestEngineCompletesDeferredTasks(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	e.(*engine).scheduleInterval = time.Millisecond * 10
+	const delay = time.Millisecond * 200
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var completed time.Time
+	err = e.RegisterJob("foo", func(context.Context, map[string]string) error {
+		completed = time.Now()
+		wg.Done()
+		return nil
+	})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	submitted := time.Now()
+	err = e.SubmitTaskAfter(model.NewTask("foo", nil), delay)
+	assert.Nil(t, err)
+	assertCompletes(t, &wg)
+	assert.True(t, completed.Sub(submitted) >= delay)
+}
+
+func TestEngineDeadLettersTasksForUnregisteredJobs(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	task := model.NewTask("foo", nil)
+	err = e.SubmitTask(task)
+	assert.Nil(t, err)
+	deadLetters := waitForDeadLetters(t, e)
+	if !assert.Equal(t, 1, len(deadLetters)) {
+		return
+	}
+	assert.Equal(t, 0, countTasks(t, db))
+	// Once the job is registered, the requeued task should be completed
+	var wg sync.WaitGroup
+	wg.Add(1)
+	err = e.RegisterJob("foo", func(context.Context, map[string]string) error {
+		wg.Done()
+		return nil
+	})
+	assert.Nil(t, err)
+	err = e.RequeueDeadLetter(deadLetters[0].ID)
+	assert.Nil(t, err)
+	assertCompletes(t, &wg)
+	deadLetters, err = e.ListDeadLetters()
+	assert.Nil(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestEnginePurgesDeadLetters(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	err = db.Update(func(tx *bolt.Tx) error {
+		return enqueue(tx, []byte("bogus"))
+	})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	deadLetters := waitForDeadLetters(t, e)
+	if !assert.Equal(t, 1, len(deadLetters)) {
+		return
+	}
+	assert.Equal(t, "bogus", deadLetters[0].TaskJSON)
+	err = e.PurgeDeadLetter(deadLetters[0].ID)
+	assert.Nil(t, err)
+	deadLetters, err = e.ListDeadLetters()
+	assert.Nil(t, err)
+	assert.Empty(t, deadLetters)
+	err = e.PurgeDeadLetter("bogus")
+	assert.Equal(
+		t,
+		`error purging dead-lettered task "bogus": `+
+			(&async.DeadLetterNotFoundError{ID: "bogus"}).Error(),
+		err.Error(),
+	)
+}
+
+func TestEngineTimesOutTasks(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var timedOut bool
+	err = e.RegisterJob(
+		"foo",
+		func(ctx context.Context, _ map[string]string) error {
+			<-ctx.Done()
+			timedOut = model.IsTimedOut(ctx)
+			wg.Done()
+			return ctx.Err()
+		},
+	)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	task := model.NewTask("foo", nil)
+	task.SetTimeout(time.Millisecond * 100)
+	err = e.SubmitTask(task)
+	assert.Nil(t, err)
+	assertCompletes(t, &wg)
+	assert.True(t, timedOut)
+	// Give the engine a moment to remove the timed out task
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, 0, countTasks(t, db))
+}
+
+func TestEngineAppliesDefaultTaskTimeout(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	config := async.NewConfigWithDefaults()
+	config.DefaultTaskTimeout = time.Millisecond * 100
+	e, err := NewEngine(db, config)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var timedOut bool
+	err = e.RegisterJob(
+		"foo",
+		func(ctx context.Context, _ map[string]string) error {
+			<-ctx.Done()
+			timedOut = model.IsTimedOut(ctx)
+			wg.Done()
+			return ctx.Err()
+		},
+	)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	// The task doesn't specify its own timeout
+	err = e.SubmitTask(model.NewTask("foo", nil))
+	assert.Nil(t, err)
+	assertCompletes(t, &wg)
+	assert.True(t, timedOut)
+}
+
+func TestNewEngineWithInvalidWorkerPoolSize(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	config := async.NewConfigWithDefaults()
+	config.WorkerPoolSize = 0
+	_, err := NewEngine(db, config)
+	assert.NotNil(t, err)
+}
+
+func TestEngineCancelsTasks(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	startedCh := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var canceled bool
+	err = e.RegisterJob(
+		"foo",
+		func(ctx context.Context, _ map[string]string) error {
+			close(startedCh)
+			<-ctx.Done()
+			canceled = model.IsCanceled(ctx)
+			wg.Done()
+			return ctx.Err()
+		},
+	)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	task := model.NewTask("foo", nil)
+	err = e.SubmitTask(task)
+	assert.Nil(t, err)
+	<-startedCh
+	err = e.CancelTask(task.GetID())
+	assert.Nil(t, err)
+	assertCompletes(t, &wg)
+	assert.True(t, canceled)
+}
+
+func TestEngineDrainsWhenContextCanceled(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	startedCh := make(chan struct{})
+	var completed bool
+	err = e.RegisterJob(
+		"foo",
+		func(ctx context.Context, _ map[string]string) error {
+			close(startedCh)
+			select {
+			case <-time.After(time.Millisecond * 500):
+				completed = true
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = e.SubmitTask(model.NewTask("foo", nil))
+	assert.Nil(t, err)
+	errCh := make(chan error)
+	go func() {
+		errCh <- e.Start(ctx)
+	}()
+	<-startedCh
+	cancel()
+	select {
+	case err = <-errCh:
+		assert.Equal(t, ctx.Err(), err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for engine to drain")
+	}
+	assert.True(t, completed)
+	assert.Equal(t, 0, countTasks(t, db))
+}
+
+func TestEngineResumesPersistedTasks(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	// Submit tasks to an engine that is never started, as if the broker process
+	// stopped before they were completed
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	const expectedCount = 3
+	for range [expectedCount]struct{}{} {
+		err = e.SubmitTask(model.NewTask("foo", nil))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, expectedCount, countTasks(t, db))
+	// A new engine using the same database should complete them
+	e, err = NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	wg.Add(expectedCount)
+	err = e.RegisterJob("foo", func(context.Context, map[string]string) error {
+		wg.Done()
+		return nil
+	})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	assertCompletes(t, &wg)
+}
+
+func TestEngineGetStats(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	oldestTask := model.NewTask("foo", nil)
+	err = e.SubmitTask(oldestTask)
+	assert.Nil(t, err)
+	err = e.SubmitTask(model.NewTask("foo", nil))
+	assert.Nil(t, err)
+	err = e.SubmitTaskAfter(model.NewTask("foo", nil), time.Hour)
+	assert.Nil(t, err)
+	stats, err := e.GetStats()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), stats.PendingTaskCount)
+	if assert.NotNil(t, stats.OldestPendingTask) {
+		assert.Equal(t, oldestTask.GetID(), stats.OldestPendingTask.ID)
+	}
+	assert.Equal(t, int64(1), stats.ScheduledTaskCount)
+	assert.Equal(t, int64(0), stats.DeadLetterCount)
+	if assert.Len(t, stats.Workers, 1) {
+		assert.Empty(t, stats.Workers[0].InFlightTasks)
+	}
+}
+
+func TestEngineGetStatsReportsInFlightTasks(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	startedCh := make(chan struct{})
+	continueCh := make(chan struct{})
+	err = e.RegisterJob("foo", func(context.Context, map[string]string) error {
+		close(startedCh)
+		<-continueCh
+		return nil
+	})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx) // nolint: errcheck
+	task := model.NewTask("foo", nil)
+	err = e.SubmitTask(task)
+	assert.Nil(t, err)
+	select {
+	case <-startedCh:
+	case <-time.After(time.Second * 5):
+		assert.FailNow(t, "timed out waiting for job to start")
+	}
+	defer close(continueCh)
+	stats, err := e.GetStats()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), stats.PendingTaskCount)
+	assert.Nil(t, stats.OldestPendingTask)
+	if assert.Len(t, stats.Workers, 1) &&
+		assert.Len(t, stats.Workers[0].InFlightTasks, 1) {
+		assert.Equal(t, task.GetID(), stats.Workers[0].InFlightTasks[0].ID)
+	}
+}
+
+func TestEngineStartBlocksUntilContextCanceled(t *testing.T) {
+	db, cleanup := getTestDB(t)
+	defer cleanup()
+	e, err := NewEngine(db, async.NewConfigWithDefaults())
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = e.Start(ctx)
+	assert.Equal(t, ctx.Err(), err)
+}
+
+func assertCompletes(t *testing.T, wg *sync.WaitGroup) {
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for tasks to be completed")
+	}
+}
+
+// waitForDeadLetters polls the given engine until at least one task has been
+// dead-lettered or a timeout is reached
+func waitForDeadLetters(t *testing.T, e async.Engine) []model.DeadLetter {
+	timeout := time.After(time.Second * 5)
+	for {
+		deadLetters, err := e.ListDeadLetters()
+		assert.Nil(t, err)
+		if len(deadLetters) > 0 {
+			return deadLetters
+		}
+		select {
+		case <-time.After(time.Millisecond * 10):
+		case <-timeout:
+			t.Fatal("timed out waiting for tasks to be dead-lettered")
+		}
+	}
+}
+
+func countTasks(t *testing.T, db *bolt.DB) int {
+	var count int
+	err := db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(tasksBucketName).Stats().KeyN
+		return nil
+	})
+	assert.Nil(t, err)
+	return count
+}
+
+// getTestDB returns a new database file, along with a function that closes and
+// removes that file
+func getTestDB(t *testing.T) (*bolt.DB, func()) {
+	dir, err := ioutil.TempDir("", "osba-boltdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()        // nolint: errcheck
+		os.RemoveAll(dir) // nolint: errcheck
+	}
+}
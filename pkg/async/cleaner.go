@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
 	log "github.com/Sirupsen/logrus"
 	"github.com/go-redis/redis"
 )
@@ -17,20 +18,37 @@ type cleanWorkerFunction func(workerID, mainWorkQueueName string) error
 // assigned to dead workers
 type Cleaner interface {
 	Clean(context.Context) error
+	// GetLeaderID returns the ID of the cleaner that is currently elected to
+	// clean up after dead workers. If no cleaner is elected, an empty string is
+	// returned.
+	GetLeaderID() (string, error)
 }
 
-// cleaner is a Redis-based implementation of the Cleaner interface
+// cleaner is a Redis-based implementation of the Cleaner interface. Since
+// every broker runs a cleaner, cleaners elect a leader using a lease and only
+// the leader cleans up after dead workers.
 type cleaner struct {
 	redisClient *redis.Client
+	keyPrefix   string
+	lease       *lease
 	// This allows tests to inject an alternative implementation of this function
 	clean cleanFunction
 	// This allows tests to inject an alternative implementation of this function
 	cleanWorker cleanWorkerFunction
 }
 
-func newCleaner(redisClient *redis.Client) Cleaner {
+// newCleaner returns a new Redis-based implementation of the Cleaner
+// interface. The given ID identifies the cleaner when it is elected leader.
+func newCleaner(redisClient *redis.Client, keyPrefix, id string) Cleaner {
 	c := &cleaner{
 		redisClient: redisClient,
+		keyPrefix:   keyPrefix,
+		lease: newLease(
+			redisClient,
+			rediskey.Join(keyPrefix, cleanerLeaseName),
+			id,
+			time.Second*30,
+		),
 	}
 	c.clean = c.defaultClean
 	c.cleanWorker = c.defaultCleanWorker
@@ -40,12 +58,36 @@ func newCleaner(redisClient *redis.Client) Cleaner {
 func (c *cleaner) Clean(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	// Give up leadership, if held, so another cleaner needn't wait for the lease
+	// to expire before taking over
+	defer func() {
+		if err := c.lease.release(); err != nil {
+			log.WithField("error", err).Warn("error releasing cleaner lease")
+		}
+	}()
 	ticker := time.NewTicker(time.Second * 10)
 	defer ticker.Stop()
+	var wasLeader bool
 	for {
-		if err := c.clean("workers", mainWorkQueueName); err != nil {
+		isLeader, err := c.lease.acquire()
+		if err != nil {
 			return &errCleaning{err: err}
 		}
+		if isLeader != wasLeader {
+			log.WithFields(log.Fields{
+				"cleanerID": c.lease.holderID,
+				"isLeader":  isLeader,
+			}).Info("cleaner leadership changed")
+			wasLeader = isLeader
+		}
+		if isLeader {
+			if err = c.clean(
+				rediskey.Join(c.keyPrefix, workerSetName),
+				rediskey.Join(c.keyPrefix, mainWorkQueueName),
+			); err != nil {
+				return &errCleaning{err: err}
+			}
+		}
 		select {
 		case <-ticker.C:
 		case <-ctx.Done():
@@ -55,6 +97,12 @@ func (c *cleaner) Clean(ctx context.Context) error {
 	}
 }
 
+// GetLeaderID returns the ID of the cleaner that is currently elected to clean
+// up after dead workers. If no cleaner is elected, an empty string is returned.
+func (c *cleaner) GetLeaderID() (string, error) {
+	return c.lease.getHolderID()
+}
+
 func (c *cleaner) defaultClean(workerSetName, mainWorkQueueName string) error {
 	strsCmd := c.redisClient.SMembers(workerSetName)
 	if strsCmd.Err() == nil {
@@ -63,7 +111,7 @@ func (c *cleaner) defaultClean(workerSetName, mainWorkQueueName string) error {
 			return fmt.Errorf("error retrieving workers: %s", err)
 		}
 		for _, workerID := range workerIDs {
-			strCmd := c.redisClient.Get(workerID)
+			strCmd := c.redisClient.Get(getHeartbeatKey(c.keyPrefix, workerID))
 			if strCmd.Err() == nil {
 				continue
 			}
@@ -82,7 +130,7 @@ func (c *cleaner) defaultClean(workerSetName, mainWorkQueueName string) error {
 					err,
 				)
 			}
-			intCmd := c.redisClient.SRem("workers", workerID)
+			intCmd := c.redisClient.SRem(workerSetName, workerID)
 			if intCmd.Err() != nil && intCmd.Err() != redis.Nil {
 				return fmt.Errorf(
 					`error removing dead worker "%s" from worker set: %s`,
@@ -98,9 +146,34 @@ func (c *cleaner) defaultClean(workerSetName, mainWorkQueueName string) error {
 }
 
 func (c *cleaner) defaultCleanWorker(workerID, mainWorkQueueName string) error {
+	if err := requeueWorkerQueue(
+		c.redisClient,
+		c.keyPrefix,
+		workerID,
+		mainWorkQueueName,
+	); err != nil {
+		return fmt.Errorf(
+			`error cleaning up after dead worker "%s": %s`,
+			workerID,
+			err,
+		)
+	}
+	return nil
+}
+
+// requeueWorkerQueue moves all tasks in the given worker's queue back to the
+// main work queue. Each task is moved atomically, so no task is ever lost or
+// duplicated, even if the worker's queue is requeued by more than one process
+// at once.
+func requeueWorkerQueue(
+	redisClient *redis.Client,
+	keyPrefix string,
+	workerID string,
+	mainWorkQueueName string,
+) error {
 	for {
-		strCmd := c.redisClient.RPopLPush(
-			getWorkerQueueName(workerID),
+		strCmd := redisClient.RPopLPush(
+			getWorkerQueueName(keyPrefix, workerID),
 			mainWorkQueueName,
 		)
 		if strCmd.Err() == redis.Nil {
@@ -108,7 +181,7 @@ func (c *cleaner) defaultCleanWorker(workerID, mainWorkQueueName string) error {
 		}
 		if strCmd.Err() != nil {
 			return fmt.Errorf(
-				`error cleaning up after dead worker "%s": %s`,
+				`error requeuing tasks from worker "%s" queue: %s`,
 				workerID,
 				strCmd.Err(),
 			)
@@ -9,7 +9,11 @@ import (
 )
 
 func TestCleanerCleanBlocksUntilCleanInternalErrors(t *testing.T) {
-	c := newCleaner(redisClient).(*cleaner)
+	c := newCleaner(
+		redisClient,
+		getDisposableKeyPrefix(),
+		getDisposableWorkerID(),
+	).(*cleaner)
 	c.clean = func(string, string) error {
 		return errSome
 	}
@@ -20,7 +24,11 @@ func TestCleanerCleanBlocksUntilCleanInternalErrors(t *testing.T) {
 }
 
 func TestCleanerCleanBlocksUntilContextCanceled(t *testing.T) {
-	c := newCleaner(redisClient).(*cleaner)
+	c := newCleaner(
+		redisClient,
+		getDisposableKeyPrefix(),
+		getDisposableWorkerID(),
+	).(*cleaner)
 	c.clean = func(string, string) error {
 		return nil
 	}
@@ -30,6 +38,50 @@ func TestCleanerCleanBlocksUntilContextCanceled(t *testing.T) {
 	assert.Equal(t, ctx.Err(), err)
 }
 
+func TestCleanerCleanDoesNotCleanIfNotLeader(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	leader := newCleaner(
+		redisClient,
+		keyPrefix,
+		getDisposableWorkerID(),
+	).(*cleaner)
+	acquired, err := leader.lease.acquire()
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	c := newCleaner(redisClient, keyPrefix, getDisposableWorkerID()).(*cleaner)
+	var cleanCalled bool
+	c.clean = func(string, string) error {
+		cleanCalled = true
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = c.Clean(ctx)
+	assert.Equal(t, ctx.Err(), err)
+	assert.False(t, cleanCalled)
+	leaderID, err := c.GetLeaderID()
+	assert.Nil(t, err)
+	assert.Equal(t, leader.lease.holderID, leaderID)
+}
+
+func TestCleanerCleanReleasesLeadershipWhenContextCanceled(t *testing.T) {
+	c := newCleaner(
+		redisClient,
+		getDisposableKeyPrefix(),
+		getDisposableWorkerID(),
+	).(*cleaner)
+	c.clean = func(string, string) error {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := c.Clean(ctx)
+	assert.Equal(t, ctx.Err(), err)
+	leaderID, err := c.GetLeaderID()
+	assert.Nil(t, err)
+	assert.Empty(t, leaderID)
+}
+
 func TestCleanerCleanInternalCleansDeadWorkers(t *testing.T) {
 	queueName := getDisposableQueueName()
 	workerSetName := getDisposableWorkerSetName()
@@ -38,7 +90,7 @@ func TestCleanerCleanInternalCleansDeadWorkers(t *testing.T) {
 		intCmd := redisClient.SAdd(workerSetName, getDisposableWorkerID())
 		assert.Nil(t, intCmd.Err())
 	}
-	c := newCleaner(redisClient).(*cleaner)
+	c := newCleaner(redisClient, testKeyPrefix, getDisposableWorkerID()).(*cleaner)
 	var cleanWorkerCallCount int
 	c.cleanWorker = func(string, string) error {
 		cleanWorkerCallCount++
@@ -56,10 +108,14 @@ func TestCleanerCleanInternalDoesNotCleansLiveWorkers(t *testing.T) {
 		workerID := getDisposableWorkerID()
 		intCmd := redisClient.SAdd(workerSetName, workerID)
 		assert.Nil(t, intCmd.Err())
-		statusCmd := redisClient.Set(workerID, aliveIndicator, 0)
+		statusCmd := redisClient.Set(
+			getHeartbeatKey(testKeyPrefix, workerID),
+			aliveIndicator,
+			0,
+		)
 		assert.Nil(t, statusCmd.Err())
 	}
-	c := newCleaner(redisClient).(*cleaner)
+	c := newCleaner(redisClient, testKeyPrefix, getDisposableWorkerID()).(*cleaner)
 	var cleanWorkerCallCount int
 	c.cleanWorker = func(string, string) error {
 		cleanWorkerCallCount++
@@ -73,13 +129,13 @@ func TestCleanerCleanInternalDoesNotCleansLiveWorkers(t *testing.T) {
 func TestCleanerCleanWorker(t *testing.T) {
 	mainQueueName := getDisposableQueueName()
 	workerID := getDisposableWorkerID()
-	workerQueueName := getWorkerQueueName(workerID)
+	workerQueueName := getWorkerQueueName(testKeyPrefix, workerID)
 	const taskCount = 5
 	for range [taskCount]struct{}{} {
 		intCmd := redisClient.LPush(workerQueueName, "foo")
 		assert.Nil(t, intCmd.Err())
 	}
-	c := newCleaner(redisClient).(*cleaner)
+	c := newCleaner(redisClient, testKeyPrefix, getDisposableWorkerID()).(*cleaner)
 	err := c.cleanWorker(workerID, mainQueueName)
 	assert.Nil(t, err)
 	intCmd := redisClient.LLen(mainQueueName)
@@ -1,9 +1,23 @@
 package async
 
-import "fmt"
+import "github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
 
-const mainWorkQueueName = "work"
+const (
+	mainWorkQueueName        = "work"
+	scheduledSetName         = "scheduled"
+	deadLetterListName       = "deadLetters"
+	semaphoreSetName         = "semaphores"
+	cancellationsChannelName = "cancellations"
+	cleanerLeaseName         = "cleanerLeader"
+	idempotencyKeysName      = "idempotencyKeys"
+	periodicJobsName         = "periodicJobs"
+	workerSetName            = "workers"
+)
 
-func getWorkerQueueName(workerID string) string {
-	return fmt.Sprintf("%s-work", workerID)
+func getWorkerQueueName(keyPrefix, workerID string) string {
+	return rediskey.Join(keyPrefix, workerSetName, workerID, mainWorkQueueName)
+}
+
+func getHeartbeatKey(keyPrefix, workerID string) string {
+	return rediskey.Join(keyPrefix, workerSetName, workerID, "heartbeat")
 }
@@ -7,6 +7,8 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
+const testKeyPrefix = "osba-test"
+
 var (
 	redisClient = redis.NewClient(&redis.Options{
 		Addr:     "redis:6379",
@@ -26,3 +28,11 @@ func getDisposableWorkerID() string {
 func getDisposableWorkerSetName() string {
 	return uuid.NewV4().String()
 }
+
+func getDisposableScheduledSetName() string {
+	return uuid.NewV4().String()
+}
+
+func getDisposableKeyPrefix() string {
+	return uuid.NewV4().String()
+}
@@ -0,0 +1,32 @@
+package async
+
+import "time"
+
+// Config represents tunable behavior of the async engines
+type Config struct {
+	// MaxWorkerRejections is the number of times a task may be rejected by
+	// workers that have no job registered under the task's job name before the
+	// task is dead-lettered. It applies only to the Redis-based engine.
+	MaxWorkerRejections int
+	// WorkerPoolSize is the number of tasks a worker may execute concurrently
+	WorkerPoolSize int
+	// DefaultTaskTimeout is the maximum duration a job function is permitted to
+	// execute for when the task being executed doesn't specify its own timeout.
+	// A timeout of zero indicates no timeout.
+	DefaultTaskTimeout time.Duration
+	// DrainTimeout is the maximum duration a worker that is shutting down waits
+	// for the tasks it is executing to complete. Tasks still executing when it
+	// elapses are returned to the main work queue by the cleaner once the
+	// worker's heartbeat has expired. With the BoltDB-based engine, they are
+	// instead resumed when the broker restarts.
+	DrainTimeout time.Duration
+}
+
+// NewConfigWithDefaults returns a Config with default values
+func NewConfigWithDefaults() Config {
+	return Config{
+		MaxWorkerRejections: 10,
+		WorkerPoolSize:      5,
+		DrainTimeout:        time.Second * 30,
+	}
+}
@@ -0,0 +1,120 @@
+package async
+
+import (
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/go-redis/redis"
+)
+
+// ListDeadLetters returns all dead-lettered tasks, most recently dead-lettered
+// first
+func (e *engine) ListDeadLetters() ([]model.DeadLetter, error) {
+	deadLetterJSONs, err := e.redisClient.LRange(
+		rediskey.Join(e.keyPrefix, deadLetterListName),
+		0,
+		-1,
+	).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("error listing dead-lettered tasks: %s", err)
+	}
+	deadLetters := make([]model.DeadLetter, len(deadLetterJSONs))
+	for i, deadLetterJSON := range deadLetterJSONs {
+		if deadLetters[i], err = model.NewDeadLetterFromJSON(
+			[]byte(deadLetterJSON),
+		); err != nil {
+			return nil, fmt.Errorf("error decoding dead-lettered task: %s", err)
+		}
+	}
+	return deadLetters, nil
+}
+
+// RequeueDeadLetter removes the dead-lettered task having the given ID from
+// the dead-letter list and resubmits it to the main work queue
+func (e *engine) RequeueDeadLetter(id string) error {
+	deadLetterListName := rediskey.Join(e.keyPrefix, deadLetterListName)
+	err := e.redisClient.Watch(func(tx *redis.Tx) error {
+		deadLetter, deadLetterJSON, err := findDeadLetter(tx, deadLetterListName, id)
+		if err != nil {
+			return err
+		}
+		task, err := deadLetter.GetTask()
+		if err != nil {
+			return fmt.Errorf("error decoding task: %s", err)
+		}
+		// Give workers a fresh opportunity to process the task
+		task.ResetWorkerRejectionCount()
+		taskJSON, err := task.ToJSON()
+		if err != nil {
+			return fmt.Errorf("error encoding task %#v: %s", task, err)
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			pipeline.LRem(deadLetterListName, 1, deadLetterJSON)
+			pipeline.LPush(rediskey.Join(e.keyPrefix, mainWorkQueueName), taskJSON)
+			// The task's idempotency key was released when it was dead-lettered.
+			// Now that the task is pending again, reclaim it.
+			if idempotencyKey := task.GetIdempotencyKey(); idempotencyKey != "" {
+				pipeline.Set(
+					rediskey.Join(e.keyPrefix, idempotencyKeysName, idempotencyKey),
+					task.GetID(),
+					idempotencyKeyTTL,
+				)
+			}
+			return nil
+		})
+		return err
+	}, deadLetterListName)
+	if err != nil {
+		return fmt.Errorf(
+			`error requeuing dead-lettered task "%s": %s`,
+			id,
+			err,
+		)
+	}
+	return nil
+}
+
+// PurgeDeadLetter permanently removes the dead-lettered task having the given
+// ID from the dead-letter list
+func (e *engine) PurgeDeadLetter(id string) error {
+	deadLetterListName := rediskey.Join(e.keyPrefix, deadLetterListName)
+	err := e.redisClient.Watch(func(tx *redis.Tx) error {
+		_, deadLetterJSON, err := findDeadLetter(tx, deadLetterListName, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			pipeline.LRem(deadLetterListName, 1, deadLetterJSON)
+			return nil
+		})
+		return err
+	}, deadLetterListName)
+	if err != nil {
+		return fmt.Errorf(`error purging dead-lettered task "%s": %s`, id, err)
+	}
+	return nil
+}
+
+// findDeadLetter returns the dead-lettered task having the given ID, along
+// with its exact JSON representation in the dead-letter list
+func findDeadLetter(
+	tx *redis.Tx,
+	deadLetterListName string,
+	id string,
+) (model.DeadLetter, string, error) {
+	deadLetterJSONs, err := tx.LRange(deadLetterListName, 0, -1).Result()
+	if err != nil && err != redis.Nil {
+		return model.DeadLetter{}, "", err
+	}
+	for _, deadLetterJSON := range deadLetterJSONs {
+		deadLetter, err := model.NewDeadLetterFromJSON([]byte(deadLetterJSON))
+		if err != nil {
+			return model.DeadLetter{}, "", err
+		}
+		if deadLetter.ID == id {
+			return deadLetter, deadLetterJSON, nil
+		}
+	}
+	return model.DeadLetter{}, "", &DeadLetterNotFoundError{ID: id}
+}
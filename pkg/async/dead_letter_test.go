@@ -0,0 +1,83 @@
+package async
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineRequeueDeadLetter(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	e := NewEngine(redisClient, keyPrefix, NewConfigWithDefaults()).(*engine)
+	task := model.NewTask("foo", nil)
+	task.IncrementWorkerRejectionCount()
+	deadLetter := pushDeadLetter(t, keyPrefix, task)
+	deadLetters, err := e.ListDeadLetters()
+	assert.Nil(t, err)
+	if !assert.Equal(t, 1, len(deadLetters)) {
+		return
+	}
+	assert.Equal(t, deadLetter.ID, deadLetters[0].ID)
+	err = e.RequeueDeadLetter(deadLetter.ID)
+	assert.Nil(t, err)
+	deadLetters, err = e.ListDeadLetters()
+	assert.Nil(t, err)
+	assert.Empty(t, deadLetters)
+	taskJSONs, err := redisClient.LRange(
+		rediskey.Join(keyPrefix, mainWorkQueueName),
+		0,
+		-1,
+	).Result()
+	assert.Nil(t, err)
+	if !assert.Equal(t, 1, len(taskJSONs)) {
+		return
+	}
+	requeuedTask, err := model.NewTaskFromJSON([]byte(taskJSONs[0]))
+	assert.Nil(t, err)
+	assert.Equal(t, task.GetID(), requeuedTask.GetID())
+	assert.Equal(t, 0, requeuedTask.GetWorkerRejectionCount())
+}
+
+func TestEnginePurgeDeadLetter(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	e := NewEngine(redisClient, keyPrefix, NewConfigWithDefaults()).(*engine)
+	deadLetter := pushDeadLetter(t, keyPrefix, model.NewTask("foo", nil))
+	err := e.PurgeDeadLetter(deadLetter.ID)
+	assert.Nil(t, err)
+	deadLetters, err := e.ListDeadLetters()
+	assert.Nil(t, err)
+	assert.Empty(t, deadLetters)
+	intCmd := redisClient.LLen(rediskey.Join(keyPrefix, mainWorkQueueName))
+	assert.Nil(t, intCmd.Err())
+	assert.Empty(t, intCmd.Val())
+}
+
+func TestEngineRequeueNonExistentDeadLetter(t *testing.T) {
+	e := NewEngine(
+		redisClient,
+		getDisposableKeyPrefix(),
+		NewConfigWithDefaults(),
+	).(*engine)
+	err := e.RequeueDeadLetter("bogus")
+	assert.NotNil(t, err)
+}
+
+func pushDeadLetter(
+	t *testing.T,
+	keyPrefix string,
+	task model.Task,
+) model.DeadLetter {
+	taskJSON, err := task.ToJSON()
+	assert.Nil(t, err)
+	deadLetter := model.NewDeadLetter(taskJSON, "some reason")
+	deadLetterJSON, err := deadLetter.ToJSON()
+	assert.Nil(t, err)
+	err = redisClient.LPush(
+		rediskey.Join(keyPrefix, deadLetterListName),
+		deadLetterJSON,
+	).Err()
+	assert.Nil(t, err)
+	return deadLetter
+}
@@ -3,8 +3,10 @@ package async
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
 	log "github.com/Sirupsen/logrus"
 	"github.com/go-redis/redis"
 )
@@ -12,31 +14,85 @@ import (
 // Engine is an interface for a broker-specifc framework for submitting and
 // asynchronously completing provisioning and deprovisioning tasks.
 type Engine interface {
+	Inspector
 	// RegisterJob registers a new Job with the async engine
 	RegisterJob(name string, fn model.JobFunction) error
+	// RegisterJobWithMaxConcurrency registers a new Job with the async engine.
+	// No more than the given number of tasks for the Job will be executed
+	// concurrently. A limit of zero indicates no limit.
+	RegisterJobWithMaxConcurrency(
+		name string,
+		fn model.JobFunction,
+		maxConcurrency int,
+	) error
+	// RegisterPeriodicJob registers a new Job with the async engine and causes a
+	// task for the Job to be submitted once per interval. Only one broker
+	// sharing the engine's storage submits the task each interval and, if the
+	// previous interval's task is still pending or executing, no task is
+	// submitted.
+	RegisterPeriodicJob(
+		name string,
+		interval time.Duration,
+		fn model.JobFunction,
+	) error
 	// SubmitTask submits an idempotent task to the async engine for reliable,
-	// asynchronous completion
+	// asynchronous completion. If the task has an idempotency key and another
+	// task having the same key is pending or executing, the task is discarded.
 	SubmitTask(model.Task) error
-	// Start causes the async engine to begin executing queued tasks
+	// SubmitTaskAt submits an idempotent task to the async engine for reliable,
+	// asynchronous completion no sooner than the given time
+	SubmitTaskAt(model.Task, time.Time) error
+	// SubmitTaskAfter submits an idempotent task to the async engine for
+	// reliable, asynchronous completion once the given delay has elapsed
+	SubmitTaskAfter(model.Task, time.Duration) error
+	// ListDeadLetters returns all tasks that have been set aside because they
+	// could not be processed
+	ListDeadLetters() ([]model.DeadLetter, error)
+	// RequeueDeadLetter resubmits the dead-lettered task having the given ID
+	RequeueDeadLetter(id string) error
+	// PurgeDeadLetter permanently discards the dead-lettered task having the
+	// given ID
+	PurgeDeadLetter(id string) error
+	// CancelTask signals whichever worker is executing the task having the
+	// given ID to cancel the context passed to the task's job function. Tasks
+	// that are not executing are unaffected.
+	CancelTask(taskID string) error
+	// GetStatus returns the status of the async engine, including whether it is
+	// the elected leader responsible for cleaning up after dead workers
+	GetStatus() (model.Status, error)
+	// Start causes the async engine to begin executing queued tasks. When the
+	// context is canceled, Start stops receiving new tasks and, for a bounded
+	// period, waits for in-flight tasks to complete before returning.
 	Start(context.Context) error
 }
 
 // engine is a Redis-based implementation of the Engine interface.
 type engine struct {
 	redisClient *redis.Client
+	keyPrefix   string
 	// This allows tests to inject an alternative implementation of Worker
 	worker Worker
 	// This allows tests to inject an alternative implementation of Cleaner
 	cleaner Cleaner
+	// This allows tests to inject an alternative implementation of Scheduler
+	scheduler Scheduler
 }
 
 // NewEngine returns a new Redis-based implementation of the Engine
-// interface
-func NewEngine(redisClient *redis.Client) Engine {
+// interface. All keys used by the engine are namespaced by the given key
+// prefix so that multiple brokers may safely share a single Redis database.
+func NewEngine(
+	redisClient *redis.Client,
+	keyPrefix string,
+	config Config,
+) Engine {
+	w := newWorker(redisClient, keyPrefix, config)
 	return &engine{
 		redisClient: redisClient,
-		cleaner:     newCleaner(redisClient),
-		worker:      newWorker(redisClient),
+		keyPrefix:   keyPrefix,
+		cleaner:     newCleaner(redisClient, keyPrefix, w.GetID()),
+		scheduler:   newScheduler(redisClient, keyPrefix),
+		worker:      w,
 	}
 }
 
@@ -45,20 +101,138 @@ func (e *engine) RegisterJob(name string, fn model.JobFunction) error {
 	return e.worker.RegisterJob(name, fn)
 }
 
+// RegisterJobWithMaxConcurrency registers a new Job with the async engine. No
+// more than the given number of tasks for the Job will be executed
+// concurrently across all brokers sharing the Redis database. A limit of zero
+// indicates no limit.
+func (e *engine) RegisterJobWithMaxConcurrency(
+	name string,
+	fn model.JobFunction,
+	maxConcurrency int,
+) error {
+	return e.worker.RegisterJobWithMaxConcurrency(name, fn, maxConcurrency)
+}
+
+// RegisterPeriodicJob registers a new Job with the async engine and causes a
+// task for the Job to be submitted once per interval. Only one broker sharing
+// the Redis database submits the task each interval and, if the previous
+// interval's task is still pending or executing, no task is submitted.
+func (e *engine) RegisterPeriodicJob(
+	name string,
+	interval time.Duration,
+	fn model.JobFunction,
+) error {
+	if interval <= 0 {
+		return &InvalidIntervalError{Name: name, Interval: interval}
+	}
+	if err := e.worker.RegisterJob(name, fn); err != nil {
+		return err
+	}
+	return e.scheduler.RegisterPeriodicJob(name, interval)
+}
+
 // SubmitTask submits an idempotent task to the async engine for reliable,
-// asynchronous completion
+// asynchronous completion. If the task has an idempotency key and another task
+// having the same key is pending or executing, the task is discarded.
 func (e *engine) SubmitTask(task model.Task) error {
+	return submitTask(e.redisClient, e.keyPrefix, task)
+}
+
+// submitTask adds the given task to the main work queue unless it has an
+// idempotency key and another task having the same key is pending or
+// executing
+func submitTask(
+	redisClient *redis.Client,
+	keyPrefix string,
+	task model.Task,
+) error {
 	taskJSON, err := task.ToJSON()
 	if err != nil {
 		return fmt.Errorf("error encoding task %#v: %s", task, err)
 	}
-	intCmd := e.redisClient.LPush(mainWorkQueueName, taskJSON)
+	claimed, err := claimIdempotencyKey(redisClient, keyPrefix, task)
+	if err != nil {
+		return fmt.Errorf("error submitting task %#v: %s", task, err)
+	}
+	if !claimed {
+		return nil
+	}
+	intCmd := redisClient.LPush(
+		rediskey.Join(keyPrefix, mainWorkQueueName),
+		taskJSON,
+	)
 	if intCmd.Err() != nil {
+		releaseIdempotencyKey(redisClient, keyPrefix, task)
+		return fmt.Errorf("error submitting task %#v: %s", task, intCmd.Err())
+	}
+	return nil
+}
+
+// SubmitTaskAt submits an idempotent task to the async engine for reliable,
+// asynchronous completion no sooner than the given time. If the task has an
+// idempotency key and another task having the same key is pending or
+// executing, the task is discarded.
+func (e *engine) SubmitTaskAt(task model.Task, executeTime time.Time) error {
+	taskJSON, err := task.ToJSON()
+	if err != nil {
 		return fmt.Errorf("error encoding task %#v: %s", task, err)
 	}
+	claimed, err := claimIdempotencyKey(e.redisClient, e.keyPrefix, task)
+	if err != nil {
+		return fmt.Errorf("error scheduling task %#v: %s", task, err)
+	}
+	if !claimed {
+		return nil
+	}
+	err = e.redisClient.ZAdd(
+		rediskey.Join(e.keyPrefix, scheduledSetName),
+		redis.Z{
+			Score:  float64(getScore(executeTime)),
+			Member: taskJSON,
+		},
+	).Err()
+	if err != nil {
+		releaseIdempotencyKey(e.redisClient, e.keyPrefix, task)
+		return fmt.Errorf("error scheduling task %#v: %s", task, err)
+	}
+	return nil
+}
+
+// SubmitTaskAfter submits an idempotent task to the async engine for
+// reliable, asynchronous completion once the given delay has elapsed
+func (e *engine) SubmitTaskAfter(task model.Task, delay time.Duration) error {
+	return e.SubmitTaskAt(task, time.Now().Add(delay))
+}
+
+// CancelTask signals whichever worker is executing the task having the given
+// ID to cancel the context passed to the task's job function. Tasks that are
+// not executing are unaffected.
+func (e *engine) CancelTask(taskID string) error {
+	err := e.redisClient.Publish(
+		rediskey.Join(e.keyPrefix, cancellationsChannelName),
+		taskID,
+	).Err()
+	if err != nil {
+		return fmt.Errorf(`error canceling task "%s": %s`, taskID, err)
+	}
 	return nil
 }
 
+// GetStatus returns the status of the async engine, including whether it is
+// the elected leader responsible for cleaning up after dead workers
+func (e *engine) GetStatus() (model.Status, error) {
+	leaderID, err := e.cleaner.GetLeaderID()
+	if err != nil {
+		return model.Status{}, fmt.Errorf("error retrieving engine status: %s", err)
+	}
+	workerID := e.worker.GetID()
+	return model.Status{
+		WorkerID:        workerID,
+		CleanerLeaderID: leaderID,
+		IsCleanerLeader: leaderID == workerID,
+	}, nil
+}
+
 func (e *engine) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -70,8 +244,17 @@ func (e *engine) Start(ctx context.Context) error {
 		case <-ctx.Done():
 		}
 	}()
+	// Start the scheduler
+	go func() {
+		select {
+		case errChan <- &errSchedulerStopped{err: e.scheduler.Schedule(ctx)}:
+		case <-ctx.Done():
+		}
+	}()
 	// Start the worker
+	workerDoneCh := make(chan struct{})
 	go func() {
+		defer close(workerDoneCh)
 		select {
 		case errChan <- &errWorkerStopped{
 			workerID: e.worker.GetID(),
@@ -83,6 +266,8 @@ func (e *engine) Start(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		log.Debug("context canceled; async engine shutting down")
+		// Wait for the worker to drain
+		<-workerDoneCh
 		return ctx.Err()
 	case err := <-errChan:
 		return err
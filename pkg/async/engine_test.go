@@ -10,7 +10,8 @@ import (
 )
 
 func TestEngineStartBlocksUntilCleanerErrors(t *testing.T) {
-	e := NewEngine(redisClient).(*engine)
+	e := NewEngine(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*engine)
+	e.scheduler = fakeAsync.NewScheduler()
 	c := fakeAsync.NewCleaner()
 	c.RunBehavior = func(context.Context) error {
 		return errSome
@@ -33,7 +34,8 @@ func TestEngineStartBlocksUntilCleanerErrors(t *testing.T) {
 }
 
 func TestEngineStartBlocksUntilCleanerReturns(t *testing.T) {
-	e := NewEngine(redisClient).(*engine)
+	e := NewEngine(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*engine)
+	e.scheduler = fakeAsync.NewScheduler()
 	c := fakeAsync.NewCleaner()
 	c.RunBehavior = func(context.Context) error {
 		return nil
@@ -56,7 +58,8 @@ func TestEngineStartBlocksUntilCleanerReturns(t *testing.T) {
 }
 
 func TestEngineStartBlocksUntilWorkerErrors(t *testing.T) {
-	e := NewEngine(redisClient).(*engine)
+	e := NewEngine(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*engine)
+	e.scheduler = fakeAsync.NewScheduler()
 	cleanerStopped := false
 	c := fakeAsync.NewCleaner()
 	c.RunBehavior = func(ctx context.Context) error {
@@ -77,7 +80,8 @@ func TestEngineStartBlocksUntilWorkerErrors(t *testing.T) {
 }
 
 func TestEngineStartBlocksUntilWorkerReturns(t *testing.T) {
-	e := NewEngine(redisClient).(*engine)
+	e := NewEngine(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*engine)
+	e.scheduler = fakeAsync.NewScheduler()
 	cleanerStopped := false
 	c := fakeAsync.NewCleaner()
 	c.RunBehavior = func(ctx context.Context) error {
@@ -98,7 +102,8 @@ func TestEngineStartBlocksUntilWorkerReturns(t *testing.T) {
 }
 
 func TestEngineStartBlocksUntilContextCanceled(t *testing.T) {
-	e := NewEngine(redisClient).(*engine)
+	e := NewEngine(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*engine)
+	e.scheduler = fakeAsync.NewScheduler()
 	cleanerStopped := false
 	c := fakeAsync.NewCleaner()
 	c.RunBehavior = func(ctx context.Context) error {
@@ -1,6 +1,9 @@
 package async
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type errCleaning struct {
 	workerID string
@@ -32,6 +35,25 @@ func (e *errCleanerStopped) Error() string {
 	return fmt.Sprintf("cleaner stopped: %s", e.err)
 }
 
+type errScheduling struct {
+	err error
+}
+
+func (e *errScheduling) Error() string {
+	return fmt.Sprintf("error promoting scheduled tasks: %s", e.err)
+}
+
+type errSchedulerStopped struct {
+	err error
+}
+
+func (e *errSchedulerStopped) Error() string {
+	if e.err == nil {
+		return "scheduler stopped"
+	}
+	return fmt.Sprintf("scheduler stopped: %s", e.err)
+}
+
 type errWorkerStopped struct {
 	workerID string
 	err      error
@@ -72,18 +94,86 @@ func (e *errReceiveAndWorkStopped) Error() string {
 	)
 }
 
-type errDuplicateJob struct {
-	name string
+// TaskTimedOutError represents a task whose job function returned an error
+// after the task's timeout elapsed
+type TaskTimedOutError struct {
+	TaskID  string
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TaskTimedOutError) Error() string {
+	return fmt.Sprintf(
+		`task "%s" timed out after %s: %s`,
+		e.TaskID,
+		e.Timeout,
+		e.Err,
+	)
+}
+
+// TaskCanceledError represents a task whose job function returned an error
+// after the task was canceled
+type TaskCanceledError struct {
+	TaskID string
+	Err    error
+}
+
+func (e *TaskCanceledError) Error() string {
+	return fmt.Sprintf(`task "%s" canceled: %s`, e.TaskID, e.Err)
+}
+
+// DuplicateJobError represents an attempt to register a job under a name that
+// is already registered with an engine
+type DuplicateJobError struct {
+	Name string
+}
+
+func (e *DuplicateJobError) Error() string {
+	return fmt.Sprintf(`duplicate job name "%s"`, e.Name)
+}
+
+// InvalidMaxConcurrencyError represents an attempt to register a job with a
+// negative concurrency limit
+type InvalidMaxConcurrencyError struct {
+	Name           string
+	MaxConcurrency int
+}
+
+func (e *InvalidMaxConcurrencyError) Error() string {
+	return fmt.Sprintf(
+		`invalid max concurrency %d for job "%s"`,
+		e.MaxConcurrency,
+		e.Name,
+	)
+}
+
+// InvalidIntervalError represents an attempt to register a periodic job with
+// an interval that isn't positive
+type InvalidIntervalError struct {
+	Name     string
+	Interval time.Duration
+}
+
+func (e *InvalidIntervalError) Error() string {
+	return fmt.Sprintf(`invalid interval %s for job "%s"`, e.Interval, e.Name)
+}
+
+// JobNotFoundError represents a task for a job that isn't registered with the
+// engine (or worker) that received it
+type JobNotFoundError struct {
+	Name string
 }
 
-func (e *errDuplicateJob) Error() string {
-	return fmt.Sprintf(`duplicate job name "%s"`, e.name)
+func (e *JobNotFoundError) Error() string {
+	return fmt.Sprintf(`no job named "%s" is registered`, e.Name)
 }
 
-type errJobNotFound struct {
-	name string
+// DeadLetterNotFoundError represents a reference to a dead-lettered task that
+// doesn't exist
+type DeadLetterNotFoundError struct {
+	ID string
 }
 
-func (e *errJobNotFound) Error() string {
-	return fmt.Sprintf(`no job named "%s" is registered with the worker`, e.name)
+func (e *DeadLetterNotFoundError) Error() string {
+	return fmt.Sprintf(`no dead-lettered task with ID "%s" exists`, e.ID)
 }
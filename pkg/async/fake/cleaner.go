@@ -20,6 +20,12 @@ func (c *Cleaner) Clean(ctx context.Context) error {
 	return c.RunBehavior(ctx)
 }
 
+// GetLeaderID returns the ID of the cleaner that is currently elected to clean
+// up after dead workers
+func (c *Cleaner) GetLeaderID() (string, error) {
+	return "", nil
+}
+
 func defaultCleanerRunBehavior(ctx context.Context) error {
 	<-ctx.Done()
 	return ctx.Err()
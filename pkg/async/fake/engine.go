@@ -2,6 +2,7 @@ package fake
 
 import (
 	"context"
+	"time"
 
 	"github.com/Azure/open-service-broker-azure/pkg/async/model"
 )
@@ -25,6 +26,27 @@ func (e *Engine) RegisterJob(name string, fn model.JobFunction) error {
 	return nil
 }
 
+// RegisterJobWithMaxConcurrency registers a new Job with the async engine.
+// No more than the given number of tasks for the Job will be executed
+// concurrently. A limit of zero indicates no limit.
+func (e *Engine) RegisterJobWithMaxConcurrency(
+	name string,
+	fn model.JobFunction,
+	maxConcurrency int,
+) error {
+	return nil
+}
+
+// RegisterPeriodicJob registers a new Job with the async engine and causes a
+// task for the Job to be submitted once per interval
+func (e *Engine) RegisterPeriodicJob(
+	name string,
+	interval time.Duration,
+	fn model.JobFunction,
+) error {
+	return nil
+}
+
 // SubmitTask submits an idempotent task to the async engine for reliable,
 // asynchronous completion
 func (e *Engine) SubmitTask(task model.Task) error {
@@ -32,7 +54,69 @@ func (e *Engine) SubmitTask(task model.Task) error {
 	return nil
 }
 
-// Start causes the async engine to begin executing queued tasks
+// SubmitTaskAt submits an idempotent task to the async engine for reliable,
+// asynchronous completion no sooner than the given time
+func (e *Engine) SubmitTaskAt(task model.Task, executeTime time.Time) error {
+	e.SubmittedTasks[task.GetID()] = task
+	return nil
+}
+
+// SubmitTaskAfter submits an idempotent task to the async engine for
+// reliable, asynchronous completion once the given delay has elapsed
+func (e *Engine) SubmitTaskAfter(task model.Task, delay time.Duration) error {
+	e.SubmittedTasks[task.GetID()] = task
+	return nil
+}
+
+// ListDeadLetters returns all tasks that have been set aside because they
+// could not be processed
+func (e *Engine) ListDeadLetters() ([]model.DeadLetter, error) {
+	return nil, nil
+}
+
+// RequeueDeadLetter resubmits the dead-lettered task having the given ID
+func (e *Engine) RequeueDeadLetter(id string) error {
+	return nil
+}
+
+// PurgeDeadLetter permanently discards the dead-lettered task having the
+// given ID
+func (e *Engine) PurgeDeadLetter(id string) error {
+	return nil
+}
+
+// CancelTask signals whichever worker is executing the task having the given
+// ID to cancel the context passed to the task's job function
+func (e *Engine) CancelTask(taskID string) error {
+	return nil
+}
+
+// GetStatus returns the status of the async engine
+func (e *Engine) GetStatus() (model.Status, error) {
+	return model.Status{}, nil
+}
+
+// GetStats returns a snapshot of the state of the engine's queues and workers.
+// All submitted tasks are reported as pending.
+func (e *Engine) GetStats() (model.EngineStats, error) {
+	stats := model.EngineStats{
+		PendingTaskCount: int64(len(e.SubmittedTasks)),
+		Workers:          []model.WorkerStats{},
+	}
+	now := time.Now()
+	for _, task := range e.SubmittedTasks {
+		taskStats := model.NewTaskStats(task, now)
+		if stats.OldestPendingTask == nil ||
+			taskStats.Created.Before(stats.OldestPendingTask.Created) {
+			stats.OldestPendingTask = &taskStats
+		}
+	}
+	return stats, nil
+}
+
+// Start causes the async engine to begin executing queued tasks. When the
+// context is canceled, Start stops receiving new tasks and, for a bounded
+// period, waits for in-flight tasks to complete before returning.
 func (e *Engine) Start(ctx context.Context) error {
 	return e.RunBehavior(ctx)
 }
@@ -0,0 +1,38 @@
+package fake
+
+import (
+	"context"
+	"time"
+)
+
+// Scheduler is a fake implementation of async.Scheduler used for testing
+type Scheduler struct {
+	RunBehavior RunFunction
+}
+
+// NewScheduler returns a new, fake implementation of async.Scheduler used for
+// testing
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		RunBehavior: defaultSchedulerRunBehavior,
+	}
+}
+
+// RegisterPeriodicJob causes a task for the named job to be submitted once per
+// interval
+func (s *Scheduler) RegisterPeriodicJob(
+	name string,
+	interval time.Duration,
+) error {
+	return nil
+}
+
+// Schedule causes the scheduler to begin promoting due tasks
+func (s *Scheduler) Schedule(ctx context.Context) error {
+	return s.RunBehavior(ctx)
+}
+
+func defaultSchedulerRunBehavior(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
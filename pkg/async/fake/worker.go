@@ -28,6 +28,17 @@ func (w *Worker) RegisterJob(name string, fn model.JobFunction) error {
 	return nil
 }
 
+// RegisterJobWithMaxConcurrency registers a new Job with the worker. No more
+// than the given number of tasks for the Job will be executed concurrently
+// across all workers. A limit of zero indicates no limit.
+func (w *Worker) RegisterJobWithMaxConcurrency(
+	name string,
+	fn model.JobFunction,
+	maxConcurrency int,
+) error {
+	return nil
+}
+
 // Work causes the worker to begin processing tags
 func (w *Worker) Work(ctx context.Context) error {
 	return w.RunBehavior(ctx)
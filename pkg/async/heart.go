@@ -36,6 +36,7 @@ type Heart interface {
 // heart is a Redis-based implementation of the Heart interface
 type heart struct {
 	workerID    string
+	keyPrefix   string
 	frequency   time.Duration
 	ttl         time.Duration
 	redisClient *redis.Client
@@ -48,9 +49,11 @@ func newHeart(
 	workerID string,
 	frequency time.Duration,
 	redisClient *redis.Client,
+	keyPrefix string,
 ) Heart {
 	h := &heart{
 		workerID:    workerID,
+		keyPrefix:   keyPrefix,
 		frequency:   frequency,
 		ttl:         frequency * 2,
 		redisClient: redisClient,
@@ -87,7 +90,11 @@ func (h *heart) Start(ctx context.Context) error {
 // This is the default function for sending a heartbeat. It can be overridden
 // to facilitate testing.
 func (h *heart) defaultBeat() error {
-	statusCmd := h.redisClient.Set(h.workerID, aliveIndicator, h.ttl)
+	statusCmd := h.redisClient.Set(
+		getHeartbeatKey(h.keyPrefix, h.workerID),
+		aliveIndicator,
+		h.ttl,
+	)
 	if statusCmd.Err() != nil {
 		return fmt.Errorf(
 			"error sending heartbeat for worker %s: %s",
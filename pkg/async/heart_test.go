@@ -10,7 +10,7 @@ import (
 
 func TestHeartBeatError(t *testing.T) {
 	workerID := getDisposableWorkerID()
-	h := newHeart(workerID, time.Second, redisClient).(*heart)
+	h := newHeart(workerID, time.Second, redisClient, testKeyPrefix).(*heart)
 	h.beat = func() error {
 		return errSome
 	}
@@ -19,10 +19,15 @@ func TestHeartBeatError(t *testing.T) {
 }
 
 func TestHeartBeat(t *testing.T) {
-	h := newHeart(getDisposableWorkerID(), time.Second, redisClient).(*heart)
+	h := newHeart(
+		getDisposableWorkerID(),
+		time.Second,
+		redisClient,
+		testKeyPrefix,
+	).(*heart)
 	err := h.Beat()
 	assert.Nil(t, err)
-	strCmd := redisClient.Get(h.workerID)
+	strCmd := redisClient.Get(getHeartbeatKey(h.keyPrefix, h.workerID))
 	assert.Nil(t, strCmd.Err())
 	str, err := strCmd.Result()
 	assert.Nil(t, err)
@@ -31,7 +36,7 @@ func TestHeartBeat(t *testing.T) {
 
 func TestHeartStartBlocksUntilBeatErrors(t *testing.T) {
 	workerID := getDisposableWorkerID()
-	h := newHeart(workerID, time.Second, redisClient).(*heart)
+	h := newHeart(workerID, time.Second, redisClient, testKeyPrefix).(*heart)
 	h.beat = func() error {
 		return errSome
 	}
@@ -42,7 +47,12 @@ func TestHeartStartBlocksUntilBeatErrors(t *testing.T) {
 }
 
 func TestHeartStartBlocksUntilContextCanceled(t *testing.T) {
-	h := newHeart(getDisposableWorkerID(), time.Second, redisClient).(*heart)
+	h := newHeart(
+		getDisposableWorkerID(),
+		time.Second,
+		redisClient,
+		testKeyPrefix,
+	).(*heart)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	err := h.Start(ctx)
@@ -0,0 +1,83 @@
+package async
+
+import (
+	"time"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-redis/redis"
+)
+
+// idempotencyKeyTTL bounds how long an idempotency key remains claimed by a
+// task that is never completed-- for instance, because the task was lost
+const idempotencyKeyTTL = 24 * time.Hour
+
+// getIdempotencyLease returns a lease on the given task's idempotency key, held
+// by the task. Submitting a task acquires the lease and completing the task
+// releases it, so, in between, tasks having the same idempotency key cannot be
+// submitted. If the task has no idempotency key, nil is returned.
+func getIdempotencyLease(
+	redisClient *redis.Client,
+	keyPrefix string,
+	task model.Task,
+) *lease {
+	idempotencyKey := task.GetIdempotencyKey()
+	if idempotencyKey == "" {
+		return nil
+	}
+	return newLease(
+		redisClient,
+		rediskey.Join(keyPrefix, idempotencyKeysName, idempotencyKey),
+		task.GetID(),
+		idempotencyKeyTTL,
+	)
+}
+
+// claimIdempotencyKey returns a bool indicating whether the given task may be
+// submitted. This is the case if the task has no idempotency key or if no other
+// pending or executing task has the same idempotency key.
+func claimIdempotencyKey(
+	redisClient *redis.Client,
+	keyPrefix string,
+	task model.Task,
+) (bool, error) {
+	l := getIdempotencyLease(redisClient, keyPrefix, task)
+	if l == nil {
+		return true, nil
+	}
+	claimed, err := l.acquire()
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		log.WithFields(log.Fields{
+			"job":            task.GetJobName(),
+			"taskID":         task.GetID(),
+			"idempotencyKey": task.GetIdempotencyKey(),
+		}).Debug("discarding duplicate task")
+	}
+	return claimed, nil
+}
+
+// releaseIdempotencyKey permits tasks having the same idempotency key as the
+// given task to be submitted again. It is called when the given task will not
+// be executed again. Failure to release the key is logged, but not returned,
+// since the key expires on its own eventually.
+func releaseIdempotencyKey(
+	redisClient *redis.Client,
+	keyPrefix string,
+	task model.Task,
+) {
+	l := getIdempotencyLease(redisClient, keyPrefix, task)
+	if l == nil {
+		return
+	}
+	if err := l.release(); err != nil {
+		log.WithFields(log.Fields{
+			"taskID":         task.GetID(),
+			"idempotencyKey": task.GetIdempotencyKey(),
+			"error":          err,
+		}).Warn("error releasing idempotency key")
+	}
+}
@@ -0,0 +1,61 @@
+package async
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimIdempotencyKeyWithoutKey(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	for range [2]struct{}{} {
+		claimed, err := claimIdempotencyKey(
+			redisClient,
+			keyPrefix,
+			model.NewTask("foo", nil),
+		)
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+	}
+}
+
+func TestClaimIdempotencyKeyRejectsDuplicates(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	task := model.NewTask("foo", nil)
+	task.SetIdempotencyKey("bar")
+	claimed, err := claimIdempotencyKey(redisClient, keyPrefix, task)
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+	// The same task may be submitted again
+	claimed, err = claimIdempotencyKey(redisClient, keyPrefix, task)
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+	duplicate := model.NewTask("foo", nil)
+	duplicate.SetIdempotencyKey("bar")
+	claimed, err = claimIdempotencyKey(redisClient, keyPrefix, duplicate)
+	assert.Nil(t, err)
+	assert.False(t, claimed)
+	// Once the first task releases the key, the duplicate may be submitted
+	releaseIdempotencyKey(redisClient, keyPrefix, task)
+	claimed, err = claimIdempotencyKey(redisClient, keyPrefix, duplicate)
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+}
+
+func TestEngineSubmitTaskDiscardsDuplicates(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	e := NewEngine(redisClient, keyPrefix, NewConfigWithDefaults())
+	for range [3]struct{}{} {
+		task := model.NewTask("foo", nil)
+		task.SetIdempotencyKey("bar")
+		err := e.SubmitTask(task)
+		assert.Nil(t, err)
+	}
+	queueDepth, err := redisClient.LLen(
+		rediskey.Join(keyPrefix, mainWorkQueueName),
+	).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), queueDepth)
+}
@@ -0,0 +1,118 @@
+package async
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/go-redis/redis"
+)
+
+// Inspector is an interface to be implemented by components that report on the
+// state of an async engine's queues and workers. This is useful for surfacing
+// through logs, metrics, or operator tooling when tasks appear to be stuck.
+type Inspector interface {
+	// GetStats returns a snapshot of the state of the engine's queues and
+	// workers
+	GetStats() (model.EngineStats, error)
+}
+
+// GetStats returns a snapshot of the state of the engine's queues and workers.
+// Since the snapshot is assembled from several Redis commands that aren't
+// executed atomically, it may be slightly inconsistent if tasks are moving
+// between queues at the time.
+func (e *engine) GetStats() (model.EngineStats, error) {
+	now := time.Now()
+	stats := model.EngineStats{}
+	var err error
+	mainWorkQueueName := rediskey.Join(e.keyPrefix, mainWorkQueueName)
+	stats.PendingTaskCount, err = e.redisClient.LLen(mainWorkQueueName).Result()
+	if err != nil {
+		return stats, fmt.Errorf("error counting pending tasks: %s", err)
+	}
+	// Workers receive tasks from the tail of the main work queue, so the task
+	// at the tail has been pending longest
+	oldestTaskJSON, err := e.redisClient.LIndex(mainWorkQueueName, -1).Result()
+	if err != nil && err != redis.Nil {
+		return stats, fmt.Errorf("error retrieving oldest pending task: %s", err)
+	}
+	if err == nil {
+		// Malformed tasks are ignored
+		task, decodeErr := model.NewTaskFromJSON([]byte(oldestTaskJSON))
+		if decodeErr == nil {
+			taskStats := model.NewTaskStats(task, now)
+			stats.OldestPendingTask = &taskStats
+		}
+	}
+	stats.ScheduledTaskCount, err = e.redisClient.ZCard(
+		rediskey.Join(e.keyPrefix, scheduledSetName),
+	).Result()
+	if err != nil {
+		return stats, fmt.Errorf("error counting scheduled tasks: %s", err)
+	}
+	stats.DeadLetterCount, err = e.redisClient.LLen(
+		rediskey.Join(e.keyPrefix, deadLetterListName),
+	).Result()
+	if err != nil {
+		return stats, fmt.Errorf("error counting dead-lettered tasks: %s", err)
+	}
+	workerIDs, err := e.redisClient.SMembers(
+		rediskey.Join(e.keyPrefix, workerSetName),
+	).Result()
+	if err != nil && err != redis.Nil {
+		return stats, fmt.Errorf("error retrieving workers: %s", err)
+	}
+	stats.Workers = make([]model.WorkerStats, len(workerIDs))
+	for i, workerID := range workerIDs {
+		if stats.Workers[i], err = e.getWorkerStats(workerID, now); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// getWorkerStats returns a snapshot of the state of the worker having the
+// given ID
+func (e *engine) getWorkerStats(
+	workerID string,
+	now time.Time,
+) (model.WorkerStats, error) {
+	workerStats := model.WorkerStats{
+		ID:            workerID,
+		InFlightTasks: []model.TaskStats{},
+	}
+	err := e.redisClient.Get(getHeartbeatKey(e.keyPrefix, workerID)).Err()
+	if err != nil && err != redis.Nil {
+		return workerStats, fmt.Errorf(
+			`error checking health of worker "%s": %s`,
+			workerID,
+			err,
+		)
+	}
+	workerStats.Alive = err == nil
+	taskJSONs, err := e.redisClient.LRange(
+		getWorkerQueueName(e.keyPrefix, workerID),
+		0,
+		-1,
+	).Result()
+	if err != nil && err != redis.Nil {
+		return workerStats, fmt.Errorf(
+			`error retrieving tasks in worker "%s" queue: %s`,
+			workerID,
+			err,
+		)
+	}
+	for _, taskJSON := range taskJSONs {
+		// Malformed tasks are ignored
+		task, decodeErr := model.NewTaskFromJSON([]byte(taskJSON))
+		if decodeErr != nil {
+			continue
+		}
+		workerStats.InFlightTasks = append(
+			workerStats.InFlightTasks,
+			model.NewTaskStats(task, now),
+		)
+	}
+	return workerStats, nil
+}
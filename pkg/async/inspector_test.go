@@ -0,0 +1,69 @@
+package async
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineGetStats(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	e := NewEngine(redisClient, keyPrefix, NewConfigWithDefaults())
+	oldestTask := model.NewTask("foo", nil)
+	err := e.SubmitTask(oldestTask)
+	assert.Nil(t, err)
+	err = e.SubmitTask(model.NewTask("foo", nil))
+	assert.Nil(t, err)
+	// Simulate a live worker that is executing a task and a dead worker
+	liveWorkerID := getDisposableWorkerID()
+	deadWorkerID := getDisposableWorkerID()
+	err = redisClient.SAdd(
+		rediskey.Join(keyPrefix, workerSetName),
+		liveWorkerID,
+		deadWorkerID,
+	).Err()
+	assert.Nil(t, err)
+	err = redisClient.Set(
+		getHeartbeatKey(keyPrefix, liveWorkerID),
+		aliveIndicator,
+		0,
+	).Err()
+	assert.Nil(t, err)
+	inFlightTask := model.NewTask("foo", nil)
+	inFlightTaskJSON, err := inFlightTask.ToJSON()
+	assert.Nil(t, err)
+	err = redisClient.LPush(
+		getWorkerQueueName(keyPrefix, liveWorkerID),
+		inFlightTaskJSON,
+	).Err()
+	assert.Nil(t, err)
+	stats, err := e.GetStats()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), stats.PendingTaskCount)
+	if assert.NotNil(t, stats.OldestPendingTask) {
+		assert.Equal(t, oldestTask.GetID(), stats.OldestPendingTask.ID)
+	}
+	assert.Equal(t, int64(0), stats.ScheduledTaskCount)
+	assert.Equal(t, int64(0), stats.DeadLetterCount)
+	assert.Len(t, stats.Workers, 2)
+	for _, workerStats := range stats.Workers {
+		switch workerStats.ID {
+		case liveWorkerID:
+			assert.True(t, workerStats.Alive)
+			if assert.Len(t, workerStats.InFlightTasks, 1) {
+				assert.Equal(
+					t,
+					inFlightTask.GetID(),
+					workerStats.InFlightTasks[0].ID,
+				)
+			}
+		case deadWorkerID:
+			assert.False(t, workerStats.Alive)
+			assert.Empty(t, workerStats.InFlightTasks)
+		default:
+			assert.Fail(t, "unexpected worker", workerStats.ID)
+		}
+	}
+}
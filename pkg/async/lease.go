@@ -0,0 +1,108 @@
+package async
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// lease is a Redis-based, time-limited lease that at most one holder may hold
+// at any given time. It is used to elect a single leader from among the engines
+// sharing a Redis database. The lease is a key whose value is the ID of the
+// holder and which expires unless the holder periodically renews it, so if the
+// holder dies, another may acquire the lease once it expires.
+type lease struct {
+	redisClient *redis.Client
+	key         string
+	holderID    string
+	ttl         time.Duration
+}
+
+func newLease(
+	redisClient *redis.Client,
+	key string,
+	holderID string,
+	ttl time.Duration,
+) *lease {
+	return &lease{
+		redisClient: redisClient,
+		key:         key,
+		holderID:    holderID,
+		ttl:         ttl,
+	}
+}
+
+// acquire attempts, without blocking, to obtain the lease or, if it is already
+// held by this holder, to renew it. It returns a bool indicating whether this
+// holder holds the lease.
+func (l *lease) acquire() (bool, error) {
+	acquired, err := l.redisClient.SetNX(l.key, l.holderID, l.ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf(`error acquiring lease "%s": %s`, l.key, err)
+	}
+	if acquired {
+		return true, nil
+	}
+	var renewed bool
+	err = l.redisClient.Watch(func(tx *redis.Tx) error {
+		holderID, err := tx.Get(l.key).Result()
+		if err == redis.Nil || (err == nil && holderID != l.holderID) {
+			// The lease expired since we attempted to acquire it or it is held by
+			// someone else
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			pipeline.Expire(l.key, l.ttl)
+			return nil
+		})
+		renewed = err == nil
+		return err
+	}, l.key)
+	if err != nil && err != redis.TxFailedErr {
+		return false, fmt.Errorf(`error renewing lease "%s": %s`, l.key, err)
+	}
+	return renewed, nil
+}
+
+// release gives up the lease if it is held by this holder
+func (l *lease) release() error {
+	err := l.redisClient.Watch(func(tx *redis.Tx) error {
+		holderID, err := tx.Get(l.key).Result()
+		if err == redis.Nil || (err == nil && holderID != l.holderID) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			pipeline.Del(l.key)
+			return nil
+		})
+		return err
+	}, l.key)
+	if err != nil && err != redis.TxFailedErr {
+		return fmt.Errorf(`error releasing lease "%s": %s`, l.key, err)
+	}
+	return nil
+}
+
+// getHolderID returns the ID of the lease's current holder. If the lease is
+// not held, an empty string is returned.
+func (l *lease) getHolderID() (string, error) {
+	holderID, err := l.redisClient.Get(l.key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf(
+			`error retrieving holder of lease "%s": %s`,
+			l.key,
+			err,
+		)
+	}
+	return holderID, nil
+}
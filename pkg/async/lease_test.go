@@ -0,0 +1,61 @@
+package async
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseAcquireIsExclusive(t *testing.T) {
+	key := getDisposableKeyPrefix()
+	l := newLease(redisClient, key, getDisposableWorkerID(), time.Minute)
+	acquired, err := l.acquire()
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	// Acquiring a lease that is already held renews it
+	acquired, err = l.acquire()
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	other := newLease(redisClient, key, getDisposableWorkerID(), time.Minute)
+	acquired, err = other.acquire()
+	assert.Nil(t, err)
+	assert.False(t, acquired)
+	holderID, err := other.getHolderID()
+	assert.Nil(t, err)
+	assert.Equal(t, l.holderID, holderID)
+}
+
+func TestLeaseAcquireAfterExpiry(t *testing.T) {
+	key := getDisposableKeyPrefix()
+	const ttl = time.Millisecond * 100
+	l := newLease(redisClient, key, getDisposableWorkerID(), ttl)
+	acquired, err := l.acquire()
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	time.Sleep(ttl * 2)
+	other := newLease(redisClient, key, getDisposableWorkerID(), ttl)
+	acquired, err = other.acquire()
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+}
+
+func TestLeaseRelease(t *testing.T) {
+	key := getDisposableKeyPrefix()
+	l := newLease(redisClient, key, getDisposableWorkerID(), time.Minute)
+	other := newLease(redisClient, key, getDisposableWorkerID(), time.Minute)
+	acquired, err := l.acquire()
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	// Releasing a lease held by someone else has no effect
+	err = other.release()
+	assert.Nil(t, err)
+	holderID, err := l.getHolderID()
+	assert.Nil(t, err)
+	assert.Equal(t, l.holderID, holderID)
+	err = l.release()
+	assert.Nil(t, err)
+	holderID, err = l.getHolderID()
+	assert.Nil(t, err)
+	assert.Empty(t, holderID)
+}
@@ -0,0 +1,112 @@
+package async
+
+import (
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/go-redis/redis"
+)
+
+// MigrateLegacyKeys moves tasks that were queued under the unprefixed keys used
+// by versions of the broker that predate namespaced keys into the main work
+// queue of an engine having the given key prefix. This includes tasks that were
+// assigned to (now necessarily dead) workers from those versions. Those
+// workers' heartbeats and the legacy worker set are deleted afterward. It must
+// not be run while any such older broker is still running. The migration is
+// safe to run more than once. The number of tasks migrated is returned.
+func MigrateLegacyKeys(
+	redisClient *redis.Client,
+	keyPrefix string,
+) (int, error) {
+	queueName := rediskey.Join(keyPrefix, mainWorkQueueName)
+	var migrated int
+	if queueName != mainWorkQueueName {
+		count, err := moveTasks(redisClient, mainWorkQueueName, queueName)
+		migrated += count
+		if err != nil {
+			return migrated, err
+		}
+	}
+	workerIDs, err := redisClient.SMembers(workerSetName).Result()
+	if err != nil && err != redis.Nil {
+		return migrated, fmt.Errorf("error retrieving legacy workers: %s", err)
+	}
+	for _, workerID := range workerIDs {
+		count, err := moveTasks(
+			redisClient,
+			fmt.Sprintf("%s-work", workerID),
+			queueName,
+		)
+		migrated += count
+		if err != nil {
+			return migrated, err
+		}
+		if err = deleteLegacyHeartbeat(redisClient, workerID); err != nil {
+			return migrated, err
+		}
+	}
+	// If there is no key prefix, the legacy worker set is also the current one,
+	// so it's left alone. Any legacy workers in it are dead and will be removed
+	// by the cleaner.
+	if keyPrefix != "" {
+		if err := redisClient.Del(workerSetName).Err(); err != nil {
+			return migrated, fmt.Errorf("error deleting legacy worker set: %s", err)
+		}
+	}
+	return migrated, nil
+}
+
+// deleteLegacyHeartbeat deletes the heartbeat of a worker from a version of the
+// broker that predates namespaced keys. Such heartbeats were stored under the
+// worker's raw id, so, to be safe, the key is only deleted if it holds a
+// heartbeat.
+func deleteLegacyHeartbeat(redisClient *redis.Client, workerID string) error {
+	err := redisClient.Watch(func(tx *redis.Tx) error {
+		val, err := tx.Get(workerID).Result()
+		if err == redis.Nil || (err == nil && val != aliveIndicator) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			pipeline.Del(workerID)
+			return nil
+		})
+		return err
+	}, workerID)
+	if err != nil && err != redis.TxFailedErr {
+		return fmt.Errorf(
+			`error deleting heartbeat of legacy worker "%s": %s`,
+			workerID,
+			err,
+		)
+	}
+	return nil
+}
+
+// moveTasks atomically moves tasks, one at a time, from the tail of the source
+// queue to the head of the destination queue until the source queue is empty.
+// The number of tasks moved is returned.
+func moveTasks(
+	redisClient *redis.Client,
+	sourceQueueName string,
+	destinationQueueName string,
+) (int, error) {
+	var moved int
+	for {
+		err := redisClient.RPopLPush(sourceQueueName, destinationQueueName).Err()
+		if err == redis.Nil {
+			return moved, nil
+		}
+		if err != nil {
+			return moved, fmt.Errorf(
+				`error moving tasks from queue "%s" to queue "%s": %s`,
+				sourceQueueName,
+				destinationQueueName,
+				err,
+			)
+		}
+		moved++
+	}
+}
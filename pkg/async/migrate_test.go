@@ -0,0 +1,46 @@
+package async
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateLegacyKeys(t *testing.T) {
+	// Use a disposable key prefix so the destination queue starts empty
+	keyPrefix := getDisposableQueueName()
+	const mainQueueTaskCount = 3
+	for range [mainQueueTaskCount]struct{}{} {
+		intCmd := redisClient.LPush(mainWorkQueueName, "foo")
+		assert.Nil(t, intCmd.Err())
+	}
+	workerID := getDisposableWorkerID()
+	intCmd := redisClient.SAdd(workerSetName, workerID)
+	assert.Nil(t, intCmd.Err())
+	err := redisClient.Set(workerID, aliveIndicator, 0).Err()
+	assert.Nil(t, err)
+	const workerQueueTaskCount = 2
+	for range [workerQueueTaskCount]struct{}{} {
+		intCmd = redisClient.LPush(fmt.Sprintf("%s-work", workerID), "bar")
+		assert.Nil(t, intCmd.Err())
+	}
+	migrated, err := MigrateLegacyKeys(redisClient, keyPrefix)
+	assert.Nil(t, err)
+	assert.True(t, migrated >= mainQueueTaskCount+workerQueueTaskCount)
+	mainQueueDepth, err := redisClient.LLen(
+		rediskey.Join(keyPrefix, mainWorkQueueName),
+	).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(migrated), mainQueueDepth)
+	legacyQueueDepth, err := redisClient.LLen(mainWorkQueueName).Result()
+	assert.Nil(t, err)
+	assert.Empty(t, legacyQueueDepth)
+	isMember, err := redisClient.SIsMember(workerSetName, workerID).Result()
+	assert.Nil(t, err)
+	assert.False(t, isMember)
+	exists, err := redisClient.Exists(workerID).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), exists)
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"context"
+	"sync"
+)
+
+type cancellationContextKey struct{}
+
+// cancellation records whether a task's context was explicitly canceled
+type cancellation struct {
+	canceled bool
+	mutex    sync.RWMutex
+}
+
+// ContextWithCancel returns a copy of the given context along with a function
+// that cancels it. Unlike with context.WithCancel, a context canceled using the
+// returned function is reported as canceled by IsCanceled. Engines use this so
+// that job functions can distinguish a task that was explicitly canceled from
+// an engine that is shutting down.
+func ContextWithCancel(
+	ctx context.Context,
+) (context.Context, context.CancelFunc) {
+	c := &cancellation{}
+	ctx, cancel := context.WithCancel(
+		context.WithValue(ctx, cancellationContextKey{}, c),
+	)
+	return ctx, func() {
+		c.mutex.Lock()
+		c.canceled = true
+		c.mutex.Unlock()
+		cancel()
+	}
+}
+
+// IsCanceled returns a bool indicating whether the task carried by the given
+// context was explicitly canceled
+func IsCanceled(ctx context.Context) bool {
+	c, ok := ctx.Value(cancellationContextKey{}).(*cancellation)
+	if !ok {
+		return false
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.canceled
+}
+
+// IsTimedOut returns a bool indicating whether the task carried by the given
+// context has exceeded its timeout
+func IsTimedOut(ctx context.Context) bool {
+	return ctx.Err() == context.DeadlineExceeded
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCanceled(t *testing.T) {
+	ctx, cancel := ContextWithCancel(context.Background())
+	assert.False(t, IsCanceled(ctx))
+	cancel()
+	assert.True(t, IsCanceled(ctx))
+	assert.NotNil(t, ctx.Err())
+}
+
+func TestIsCanceledWhenParentCanceled(t *testing.T) {
+	parentCtx, parentCancel := context.WithCancel(context.Background())
+	ctx, cancel := ContextWithCancel(parentCtx)
+	defer cancel()
+	parentCancel()
+	assert.NotNil(t, ctx.Err())
+	assert.False(t, IsCanceled(ctx))
+}
+
+func TestIsTimedOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	assert.True(t, IsTimedOut(ctx))
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, IsTimedOut(ctx))
+}
+
+func TestWillRetryAfterCancellationOrTimeout(t *testing.T) {
+	err := &testRetryableError{}
+	ctx, cancel := ContextWithCancel(
+		ContextWithTask(context.Background(), NewTask("foo", nil)),
+	)
+	assert.True(t, WillRetry(ctx, err))
+	cancel()
+	assert.False(t, WillRetry(ctx, err))
+	ctx, cancel = context.WithTimeout(
+		ContextWithTask(context.Background(), NewTask("foo", nil)),
+		time.Millisecond,
+	)
+	defer cancel()
+	<-ctx.Done()
+	assert.False(t, WillRetry(ctx, err))
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// DeadLetter represents a task that could not be processed and has been set
+// aside, along with the reason it could not be processed. The task is retained
+// exactly as it was received since, if it was malformed, it may not be
+// possible to decode it.
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	TaskJSON string    `json:"taskJSON"`
+	Reason   string    `json:"reason"`
+	Created  time.Time `json:"created"`
+}
+
+// NewDeadLetter returns a new DeadLetter for the given task JSON and reason
+func NewDeadLetter(taskJSON []byte, reason string) DeadLetter {
+	return DeadLetter{
+		ID:       uuid.NewV4().String(),
+		TaskJSON: string(taskJSON),
+		Reason:   reason,
+		Created:  time.Now().UTC(),
+	}
+}
+
+// NewDeadLetterFromJSON returns a new DeadLetter unmarshalled from the provided
+// []byte
+func NewDeadLetterFromJSON(jsonBytes []byte) (DeadLetter, error) {
+	deadLetter := DeadLetter{}
+	err := json.Unmarshal(jsonBytes, &deadLetter)
+	return deadLetter, err
+}
+
+// GetTask decodes and returns the dead-lettered task
+func (d DeadLetter) GetTask() (Task, error) {
+	return NewTaskFromJSON([]byte(d.TaskJSON))
+}
+
+// ToJSON returns a []byte containing a JSON representation of the dead letter
+func (d DeadLetter) ToJSON() ([]byte, error) {
+	return json.Marshal(d)
+}
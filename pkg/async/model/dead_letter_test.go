@@ -0,0 +1,27 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadLetterRoundTrip(t *testing.T) {
+	deadLetter := NewDeadLetter(testTaskJSON, "some reason")
+	jsonBytes, err := deadLetter.ToJSON()
+	assert.Nil(t, err)
+	decodedDeadLetter, err := NewDeadLetterFromJSON(jsonBytes)
+	assert.Nil(t, err)
+	assert.Equal(t, deadLetter.ID, decodedDeadLetter.ID)
+	assert.Equal(t, deadLetter.Reason, decodedDeadLetter.Reason)
+	assert.True(t, deadLetter.Created.Equal(decodedDeadLetter.Created))
+	task, err := decodedDeadLetter.GetTask()
+	assert.Nil(t, err)
+	assert.Equal(t, testTask, task)
+}
+
+func TestDeadLetterGetMalformedTask(t *testing.T) {
+	deadLetter := NewDeadLetter([]byte("bogus"), "some reason")
+	_, err := deadLetter.GetTask()
+	assert.NotNil(t, err)
+}
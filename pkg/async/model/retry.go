@@ -0,0 +1,84 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultRetryPolicy is the retry policy applied to tasks created using
+// NewTask. Since tasks are only ever retried when they fail with a retryable
+// error, this is a safe default even for jobs that never return one.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second * 10,
+	MaxBackoff:     time.Minute * 5,
+}
+
+// RetryPolicy describes how many times, and how often, a task that fails with
+// a retryable error should be attempted
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the task will be attempted,
+	// including the first attempt. A value less than two disables retries.
+	MaxAttempts int `json:"maxAttempts"`
+	// InitialBackoff is how long to wait before the first retry. The wait
+	// doubles with each subsequent retry.
+	InitialBackoff time.Duration `json:"initialBackoff"`
+	// MaxBackoff caps how long to wait before any retry
+	MaxBackoff time.Duration `json:"maxBackoff"`
+}
+
+// GetBackoff returns how long to wait before retrying a task that has failed
+// the given number of times
+func (r RetryPolicy) GetBackoff(failedAttempts int) time.Duration {
+	backoff := r.InitialBackoff
+	for i := 1; i < failedAttempts; i++ {
+		backoff *= 2
+		if r.MaxBackoff > 0 && backoff >= r.MaxBackoff {
+			break
+		}
+	}
+	if r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+	return backoff
+}
+
+// retryable is an interface that may be implemented by errors returned from
+// job functions to indicate whether the failure was transient
+type retryable interface {
+	IsRetryable() bool
+}
+
+// IsRetryable returns a bool indicating whether the given error indicates a
+// transient failure. An error is retryable if it implements an
+// IsRetryable() bool function that returns true.
+func IsRetryable(err error) bool {
+	r, ok := err.(retryable)
+	return ok && r.IsRetryable()
+}
+
+type taskContextKey struct{}
+
+// ContextWithTask returns a copy of the given context that carries the given
+// task. Engines call this before invoking a job function.
+func ContextWithTask(ctx context.Context, task Task) context.Context {
+	return context.WithValue(ctx, taskContextKey{}, task)
+}
+
+// TaskFromContext returns the task carried by the given context, if any
+func TaskFromContext(ctx context.Context) (Task, bool) {
+	task, ok := ctx.Value(taskContextKey{}).(Task)
+	return task, ok
+}
+
+// WillRetry returns a bool indicating whether the engine will retry the task
+// carried by the given context if its job function fails with the given error.
+// Tasks that were explicitly canceled or that exceeded their timeout are never
+// retried. Job functions can use this to decide whether a failure is final.
+func WillRetry(ctx context.Context, err error) bool {
+	if IsCanceled(ctx) || IsTimedOut(ctx) {
+		return false
+	}
+	task, ok := TaskFromContext(ctx)
+	return ok && task.WillRetry(err)
+}
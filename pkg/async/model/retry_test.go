@@ -0,0 +1,52 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRetryableError struct{}
+
+func (testRetryableError) Error() string {
+	return "transient failure"
+}
+
+func (testRetryableError) IsRetryable() bool {
+	return true
+}
+
+func TestRetryPolicyGetBackoff(t *testing.T) {
+	r := RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+	}
+	assert.Equal(t, time.Second, r.GetBackoff(1))
+	assert.Equal(t, time.Second*2, r.GetBackoff(2))
+	assert.Equal(t, time.Second*4, r.GetBackoff(3))
+	assert.Equal(t, time.Second*5, r.GetBackoff(4))
+	assert.Equal(t, time.Second*5, r.GetBackoff(100))
+}
+
+func TestTaskWillRetry(t *testing.T) {
+	task := NewTaskWithRetryPolicy(
+		"test-job",
+		nil,
+		RetryPolicy{MaxAttempts: 2},
+	)
+	assert.False(t, task.WillRetry(errors.New("permanent failure")))
+	assert.True(t, task.WillRetry(testRetryableError{}))
+	task.IncrementFailedAttempts()
+	assert.False(t, task.WillRetry(testRetryableError{}))
+}
+
+func TestWillRetry(t *testing.T) {
+	// Without a task in the context, nothing is retried
+	assert.False(t, WillRetry(context.Background(), testRetryableError{}))
+	ctx := ContextWithTask(context.Background(), NewTask("test-job", nil))
+	assert.True(t, WillRetry(ctx, testRetryableError{}))
+}
@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// EngineStats is a snapshot of the state of an async engine's queues and
+// workers
+type EngineStats struct {
+	// PendingTaskCount is the number of tasks waiting to be received by a worker
+	PendingTaskCount int64 `json:"pendingTaskCount"`
+	// OldestPendingTask describes the task that has been waiting longest to be
+	// received by a worker. It is nil if no tasks are pending.
+	OldestPendingTask *TaskStats `json:"oldestPendingTask,omitempty"`
+	// ScheduledTaskCount is the number of tasks deferred for execution at a
+	// later time, including those awaiting a retry
+	ScheduledTaskCount int64 `json:"scheduledTaskCount"`
+	// DeadLetterCount is the number of tasks set aside because they could not
+	// be processed
+	DeadLetterCount int64 `json:"deadLetterCount"`
+	// Workers describes every worker known to the engine
+	Workers []WorkerStats `json:"workers"`
+}
+
+// WorkerStats is a snapshot of the state of a single worker
+type WorkerStats struct {
+	ID string `json:"id"`
+	// Alive indicates whether the worker's heartbeat is current. Tasks assigned
+	// to a worker that isn't alive are eventually returned to the pending tasks.
+	Alive bool `json:"alive"`
+	// InFlightTasks describes the tasks the worker has received, but not yet
+	// completed
+	InFlightTasks []TaskStats `json:"inFlightTasks"`
+}
+
+// TaskStats describes a single task
+type TaskStats struct {
+	ID      string    `json:"id"`
+	JobName string    `json:"jobName"`
+	Created time.Time `json:"created"`
+	// Age is the time elapsed since the task was created. It is zero for tasks
+	// whose time of creation is unknown.
+	Age time.Duration `json:"age"`
+}
+
+// NewTaskStats returns a TaskStats describing the given task as of the given
+// time
+func NewTaskStats(task Task, now time.Time) TaskStats {
+	taskStats := TaskStats{
+		ID:      task.GetID(),
+		JobName: task.GetJobName(),
+		Created: task.GetCreated(),
+	}
+	if !taskStats.Created.IsZero() {
+		taskStats.Age = now.Sub(taskStats.Created)
+	}
+	return taskStats
+}
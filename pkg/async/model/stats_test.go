@@ -0,0 +1,26 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTaskStats(t *testing.T) {
+	task := NewTask("foo", nil)
+	now := task.GetCreated().Add(time.Minute)
+	taskStats := NewTaskStats(task, now)
+	assert.Equal(t, task.GetID(), taskStats.ID)
+	assert.Equal(t, "foo", taskStats.JobName)
+	assert.Equal(t, task.GetCreated(), taskStats.Created)
+	assert.Equal(t, time.Minute, taskStats.Age)
+}
+
+func TestNewTaskStatsForTaskWithUnknownCreationTime(t *testing.T) {
+	task, err := NewTaskFromJSON([]byte(`{"id":"bar","jobName":"foo"}`))
+	assert.Nil(t, err)
+	taskStats := NewTaskStats(task, time.Now())
+	assert.True(t, taskStats.Created.IsZero())
+	assert.Equal(t, time.Duration(0), taskStats.Age)
+}
@@ -0,0 +1,14 @@
+package model
+
+// Status represents the state of an async engine and its role among the
+// engines sharing the same underlying storage
+type Status struct {
+	// WorkerID is the ID of the engine's worker
+	WorkerID string `json:"workerID"`
+	// CleanerLeaderID is the ID of the engine whose cleaner is currently elected
+	// to clean up after dead workers. It is empty if no cleaner is elected.
+	CleanerLeaderID string `json:"cleanerLeaderID"`
+	// IsCleanerLeader indicates whether this engine's cleaner is the elected
+	// leader
+	IsCleanerLeader bool `json:"isCleanerLeader"`
+}
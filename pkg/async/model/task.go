@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 )
@@ -14,6 +15,32 @@ type Task interface {
 	GetArgs() map[string]string
 	GetWorkerRejectionCount() int
 	IncrementWorkerRejectionCount() int
+	// ResetWorkerRejectionCount clears the number of times the task has been
+	// rejected by workers that have no job registered under the task's job name
+	ResetWorkerRejectionCount()
+	GetRetryPolicy() RetryPolicy
+	// GetFailedAttempts returns the number of times the task has previously
+	// failed with a retryable error
+	GetFailedAttempts() int
+	IncrementFailedAttempts() int
+	// WillRetry returns a bool indicating whether, in accordance with the task's
+	// retry policy, the task should be retried after failing with the given
+	// error
+	WillRetry(err error) bool
+	// GetTimeout returns the maximum duration the task's job function is
+	// permitted to execute for. A timeout of zero indicates the engine's
+	// default applies.
+	GetTimeout() time.Duration
+	SetTimeout(timeout time.Duration)
+	// GetIdempotencyKey returns a key identifying the unit of work the task
+	// represents. While a task having a given idempotency key is pending or
+	// executing, the engine discards other tasks submitted with the same key.
+	// An empty key indicates the task is never considered a duplicate.
+	GetIdempotencyKey() string
+	SetIdempotencyKey(key string)
+	// GetCreated returns the time the task was created. Tasks created by older
+	// versions of the broker return the zero time.
+	GetCreated() time.Time
 	ToJSON() ([]byte, error)
 }
 
@@ -22,13 +49,31 @@ type task struct {
 	JobName              string            `json:"jobName"`
 	Args                 map[string]string `json:"args"`
 	WorkerRejectionCount int               `json:"workerRejectionCount"`
+	RetryPolicy          RetryPolicy       `json:"retryPolicy"`
+	FailedAttempts       int               `json:"failedAttempts"`
+	Timeout              time.Duration     `json:"timeout"`
+	IdempotencyKey       string            `json:"idempotencyKey"`
+	Created              time.Time         `json:"created"`
 }
 
-// NewTask returns a new task
+// NewTask returns a new task that is retried in accordance with the
+// DefaultRetryPolicy
 func NewTask(jobName string, args map[string]string) Task {
+	return NewTaskWithRetryPolicy(jobName, args, DefaultRetryPolicy)
+}
+
+// NewTaskWithRetryPolicy returns a new task that is retried in accordance with
+// the given retry policy
+func NewTaskWithRetryPolicy(
+	jobName string,
+	args map[string]string,
+	retryPolicy RetryPolicy,
+) Task {
 	t := &task{
-		JobName: jobName,
-		Args:    args,
+		JobName:     jobName,
+		Args:        args,
+		RetryPolicy: retryPolicy,
+		Created:     time.Now().UTC(),
 	}
 	t.ID = uuid.NewV4().String()
 	return t
@@ -64,6 +109,47 @@ func (t *task) IncrementWorkerRejectionCount() int {
 	return t.WorkerRejectionCount
 }
 
+func (t *task) ResetWorkerRejectionCount() {
+	t.WorkerRejectionCount = 0
+}
+
+func (t *task) GetRetryPolicy() RetryPolicy {
+	return t.RetryPolicy
+}
+
+func (t *task) GetFailedAttempts() int {
+	return t.FailedAttempts
+}
+
+func (t *task) IncrementFailedAttempts() int {
+	t.FailedAttempts++
+	return t.FailedAttempts
+}
+
+func (t *task) WillRetry(err error) bool {
+	return IsRetryable(err) && t.FailedAttempts+1 < t.RetryPolicy.MaxAttempts
+}
+
+func (t *task) GetTimeout() time.Duration {
+	return t.Timeout
+}
+
+func (t *task) SetTimeout(timeout time.Duration) {
+	t.Timeout = timeout
+}
+
+func (t *task) GetIdempotencyKey() string {
+	return t.IdempotencyKey
+}
+
+func (t *task) SetIdempotencyKey(key string) {
+	t.IdempotencyKey = key
+}
+
+func (t *task) GetCreated() time.Time {
+	return t.Created
+}
+
 // ToJSON returns a []byte containing a JSON representation of the task
 func (t *task) ToJSON() ([]byte, error) {
 	return json.Marshal(t)
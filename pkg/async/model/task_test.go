@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,6 +18,7 @@ func init() {
 	jobName := "test-job"
 	argName := "foo"
 	argValue := "FOO"
+	idempotencyKey := "test-key"
 
 	testTask = NewTask(
 		jobName,
@@ -24,19 +26,36 @@ func init() {
 			argName: argValue,
 		},
 	)
+	testTask.SetIdempotencyKey(idempotencyKey)
 
 	testTaskJSONStr := fmt.Sprintf(
 		`{
 			"id":"%s",
 			"jobName":"%s",
 			"args":{"%s":"%s"},
-			"workerRejectionCount": %d
+			"workerRejectionCount": %d,
+			"retryPolicy":{
+				"maxAttempts":%d,
+				"initialBackoff":%d,
+				"maxBackoff":%d
+			},
+			"failedAttempts":%d,
+			"timeout":%d,
+			"idempotencyKey":"%s",
+			"created":"%s"
 		}`,
 		testTask.GetID(),
 		jobName,
 		argName,
 		argValue,
 		0,
+		DefaultRetryPolicy.MaxAttempts,
+		DefaultRetryPolicy.InitialBackoff,
+		DefaultRetryPolicy.MaxBackoff,
+		0,
+		0,
+		idempotencyKey,
+		testTask.GetCreated().Format(time.RFC3339Nano),
 	)
 	testTaskJSONStr = strings.Replace(testTaskJSONStr, " ", "", -1)
 	testTaskJSONStr = strings.Replace(testTaskJSONStr, "\n", "", -1)
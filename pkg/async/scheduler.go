@@ -0,0 +1,206 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-redis/redis"
+)
+
+type promoteFunction func(scheduledSetName, mainWorkQueueName string) error
+
+// Scheduler is an interface to be implemented by components that move tasks
+// that were submitted for deferred execution into the main work queue when
+// they become due and that submit tasks for periodic jobs
+type Scheduler interface {
+	// RegisterPeriodicJob causes a task for the named job to be submitted once
+	// per interval
+	RegisterPeriodicJob(name string, interval time.Duration) error
+	Schedule(context.Context) error
+}
+
+// scheduler is a Redis-based implementation of the Scheduler interface.
+// Deferred tasks are members of a sorted set, scored by the time (in
+// milliseconds since the epoch) at which they are due.
+type scheduler struct {
+	redisClient *redis.Client
+	keyPrefix   string
+	interval    time.Duration
+	// periodicJobs maps the names of periodic jobs to their intervals
+	periodicJobs      map[string]time.Duration
+	periodicJobsMutex sync.RWMutex
+	// This allows tests to inject an alternative implementation of this function
+	promote promoteFunction
+}
+
+func newScheduler(redisClient *redis.Client, keyPrefix string) Scheduler {
+	s := &scheduler{
+		redisClient:  redisClient,
+		keyPrefix:    keyPrefix,
+		interval:     time.Second * 5,
+		periodicJobs: make(map[string]time.Duration),
+	}
+	s.promote = s.defaultPromote
+	return s
+}
+
+// RegisterPeriodicJob causes a task for the named job to be submitted once per
+// interval. Since tasks are submitted only as often as the scheduler runs,
+// intervals shorter than the scheduler's are effectively rounded up.
+func (s *scheduler) RegisterPeriodicJob(
+	name string,
+	interval time.Duration,
+) error {
+	if interval <= 0 {
+		return &InvalidIntervalError{Name: name, Interval: interval}
+	}
+	s.periodicJobsMutex.Lock()
+	defer s.periodicJobsMutex.Unlock()
+	if _, ok := s.periodicJobs[name]; ok {
+		return &DuplicateJobError{Name: name}
+	}
+	s.periodicJobs[name] = interval
+	return nil
+}
+
+func (s *scheduler) Schedule(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.promote(
+			rediskey.Join(s.keyPrefix, scheduledSetName),
+			rediskey.Join(s.keyPrefix, mainWorkQueueName),
+		); err != nil {
+			return &errScheduling{err: err}
+		}
+		s.submitPeriodicTasks(time.Now())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Debug("context canceled; async scheduler shutting down")
+			return ctx.Err()
+		}
+	}
+}
+
+// defaultPromote atomically moves all tasks that are due from the scheduled
+// set to the main work queue. Since every broker runs a scheduler, a
+// transaction that fails because another scheduler concurrently modified the
+// scheduled set is not an error-- the other scheduler has promoted (or is
+// promoting) the due tasks.
+func (s *scheduler) defaultPromote(
+	scheduledSetName string,
+	mainWorkQueueName string,
+) error {
+	err := s.redisClient.Watch(func(tx *redis.Tx) error {
+		taskJSONs, err := tx.ZRangeByScore(
+			scheduledSetName,
+			redis.ZRangeBy{
+				Min: "-inf",
+				Max: strconv.FormatInt(getScore(time.Now()), 10),
+			},
+		).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if len(taskJSONs) == 0 {
+			return nil
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			for _, taskJSON := range taskJSONs {
+				pipeline.ZRem(scheduledSetName, taskJSON)
+				pipeline.LPush(mainWorkQueueName, taskJSON)
+			}
+			return nil
+		})
+		return err
+	}, scheduledSetName)
+	if err != nil && err != redis.TxFailedErr {
+		return fmt.Errorf("error promoting due tasks: %s", err)
+	}
+	return nil
+}
+
+// submitPeriodicTasks submits a task for each periodic job that hasn't already
+// had a task submitted during the interval containing the given time. Intervals
+// are aligned to the epoch so that every broker agrees on when they begin.
+// Failure to submit one job's task is logged, but doesn't prevent the other
+// jobs' tasks from being submitted.
+func (s *scheduler) submitPeriodicTasks(now time.Time) {
+	s.periodicJobsMutex.RLock()
+	defer s.periodicJobsMutex.RUnlock()
+	for name, interval := range s.periodicJobs {
+		if err := s.submitPeriodicTask(name, interval, now); err != nil {
+			log.WithFields(log.Fields{
+				"job":   name,
+				"error": err,
+			}).Error("error submitting periodic task")
+		}
+	}
+}
+
+// submitPeriodicTask submits a task for the named periodic job if one hasn't
+// already been submitted during the interval containing the given time. Since
+// every broker runs a scheduler, a lock per job per interval ensures only one
+// broker submits the interval's task. If submission fails, the lock is
+// released so that the task can be submitted on a subsequent pass.
+func (s *scheduler) submitPeriodicTask(
+	name string,
+	interval time.Duration,
+	now time.Time,
+) error {
+	tick := now.UnixNano() / int64(interval)
+	task := model.NewTask(name, nil)
+	// If the previous interval's task is still pending or executing, this
+	// interval's task is discarded
+	task.SetIdempotencyKey(rediskey.Join("", periodicJobsName, name))
+	lockKey := rediskey.Join(
+		s.keyPrefix,
+		periodicJobsName,
+		name,
+		strconv.FormatInt(tick, 10),
+	)
+	locked, err := s.redisClient.SetNX(lockKey, task.GetID(), interval).Result()
+	if err != nil {
+		return fmt.Errorf(
+			`error locking interval for periodic job "%s": %s`,
+			name,
+			err,
+		)
+	}
+	if !locked {
+		// Another scheduler has already submitted this interval's task
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"job":    name,
+		"taskID": task.GetID(),
+	}).Debug("submitting periodic task")
+	if err = submitTask(s.redisClient, s.keyPrefix, task); err != nil {
+		if delErr := s.redisClient.Del(lockKey).Err(); delErr != nil {
+			log.WithFields(log.Fields{
+				"job":   name,
+				"error": delErr,
+			}).Warn("error unlocking interval for periodic job")
+		}
+		return fmt.Errorf(
+			`error submitting task for periodic job "%s": %s`,
+			name,
+			err,
+		)
+	}
+	return nil
+}
+
+// getScore returns the score of a task that is due at the given time
+func getScore(executeTime time.Time) int64 {
+	return executeTime.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,128 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerScheduleBlocksUntilPromoteErrors(t *testing.T) {
+	s := newScheduler(redisClient, testKeyPrefix).(*scheduler)
+	s.promote = func(string, string) error {
+		return errSome
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err := s.Schedule(ctx)
+	assert.Equal(t, &errScheduling{err: errSome}, err)
+}
+
+func TestSchedulerScheduleBlocksUntilContextCanceled(t *testing.T) {
+	s := newScheduler(redisClient, testKeyPrefix).(*scheduler)
+	s.promote = func(string, string) error {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := s.Schedule(ctx)
+	assert.Equal(t, ctx.Err(), err)
+}
+
+func TestSchedulerPromoteInternalPromotesDueTasks(t *testing.T) {
+	scheduledSetName := getDisposableScheduledSetName()
+	queueName := getDisposableQueueName()
+	now := time.Now()
+	intCmd := redisClient.ZAdd(
+		scheduledSetName,
+		redis.Z{
+			Score:  float64(getScore(now.Add(-time.Minute))),
+			Member: "due",
+		},
+		redis.Z{
+			Score:  float64(getScore(now.Add(time.Hour))),
+			Member: "not-due",
+		},
+	)
+	assert.Nil(t, intCmd.Err())
+	s := newScheduler(redisClient, testKeyPrefix).(*scheduler)
+	err := s.promote(scheduledSetName, queueName)
+	assert.Nil(t, err)
+	queued, err := redisClient.LRange(queueName, 0, -1).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"due"}, queued)
+	scheduled, err := redisClient.ZRange(scheduledSetName, 0, -1).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"not-due"}, scheduled)
+}
+
+func TestSchedulerRegisterPeriodicJobWithInvalidInterval(t *testing.T) {
+	s := newScheduler(redisClient, getDisposableKeyPrefix())
+	err := s.RegisterPeriodicJob("foo", 0)
+	assert.Equal(t, &InvalidIntervalError{Name: "foo"}, err)
+}
+
+func TestSchedulerSubmitsPeriodicTasksOncePerInterval(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	// Simulate two brokers sharing a Redis database
+	schedulers := []*scheduler{
+		newScheduler(redisClient, keyPrefix).(*scheduler),
+		newScheduler(redisClient, keyPrefix).(*scheduler),
+	}
+	for _, s := range schedulers {
+		err := s.RegisterPeriodicJob("foo", time.Hour)
+		assert.Nil(t, err)
+	}
+	now := time.Now()
+	for _, s := range schedulers {
+		s.submitPeriodicTasks(now)
+	}
+	mainWorkQueueName := rediskey.Join(keyPrefix, mainWorkQueueName)
+	queueDepth, err := redisClient.LLen(mainWorkQueueName).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), queueDepth)
+	// The first interval's task is still pending, so no task is submitted for
+	// the next interval
+	schedulers[0].submitPeriodicTasks(now.Add(time.Hour))
+	queueDepth, err = redisClient.LLen(mainWorkQueueName).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), queueDepth)
+}
+
+func TestSchedulerRetriesFailedPeriodicTaskSubmissions(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	s := newScheduler(redisClient, keyPrefix).(*scheduler)
+	err := s.RegisterPeriodicJob("foo", time.Hour)
+	assert.Nil(t, err)
+	err = s.RegisterPeriodicJob("bar", time.Hour)
+	assert.Nil(t, err)
+	// Make submitting foo's task fail by storing a value of the wrong type
+	// under its idempotency key
+	idempotencyKey := rediskey.Join(
+		keyPrefix,
+		idempotencyKeysName,
+		periodicJobsName,
+		"foo",
+	)
+	err = redisClient.RPush(idempotencyKey, "bat").Err()
+	assert.Nil(t, err)
+	now := time.Now()
+	s.submitPeriodicTasks(now)
+	// The failure to submit foo's task doesn't prevent bar's from being
+	// submitted
+	mainWorkQueueName := rediskey.Join(keyPrefix, mainWorkQueueName)
+	queueDepth, err := redisClient.LLen(mainWorkQueueName).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), queueDepth)
+	// Once the cause of the failure is gone, foo's task is submitted during the
+	// same interval
+	err = redisClient.Del(idempotencyKey).Err()
+	assert.Nil(t, err)
+	s.submitPeriodicTasks(now)
+	queueDepth, err = redisClient.LLen(mainWorkQueueName).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), queueDepth)
+}
@@ -0,0 +1,125 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-redis/redis"
+)
+
+// semaphore is a Redis-based counting semaphore used to cap the number of
+// tasks for a given job that may execute concurrently across all workers.
+// Holders are members of a sorted set, scored by the time (in milliseconds
+// since the epoch) at which their lease expires. Leases are renewed for as long
+// as the holder is executing a task, so if a worker dies while holding a lease,
+// that lease will simply expire.
+type semaphore struct {
+	redisClient *redis.Client
+	key         string
+	limit       int
+	ttl         time.Duration
+}
+
+func newSemaphore(
+	redisClient *redis.Client,
+	key string,
+	limit int,
+) *semaphore {
+	return &semaphore{
+		redisClient: redisClient,
+		key:         key,
+		limit:       limit,
+		ttl:         time.Minute,
+	}
+}
+
+// acquire attempts to obtain a lease on behalf of the given holder without
+// blocking. It returns a bool indicating whether the lease was obtained. Since
+// the semaphore is shared by every worker, a transaction that fails because
+// another worker concurrently modified the semaphore is not an error-- the
+// lease simply was not obtained.
+func (s *semaphore) acquire(holderID string) (bool, error) {
+	var acquired bool
+	err := s.redisClient.Watch(func(tx *redis.Tx) error {
+		now := getScore(time.Now())
+		count, err := tx.ZCount(
+			s.key,
+			strconv.FormatInt(now, 10),
+			"+inf",
+		).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if count >= int64(s.limit) {
+			return nil
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			// Clean up leases that have expired
+			pipeline.ZRemRangeByScore(
+				s.key,
+				"-inf",
+				fmt.Sprintf("(%d", now),
+			)
+			pipeline.ZAdd(
+				s.key,
+				redis.Z{
+					Score:  float64(getScore(time.Now().Add(s.ttl))),
+					Member: holderID,
+				},
+			)
+			return nil
+		})
+		if err == nil {
+			acquired = true
+		}
+		return err
+	}, s.key)
+	if err != nil && err != redis.TxFailedErr {
+		return false, fmt.Errorf(
+			`error acquiring semaphore "%s": %s`,
+			s.key,
+			err,
+		)
+	}
+	return acquired, nil
+}
+
+// hold renews the given holder's lease at regular intervals until the context
+// is canceled, then releases it
+func (s *semaphore) hold(ctx context.Context, holderID string) {
+	ticker := time.NewTicker(s.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := s.redisClient.ZAddXX(
+				s.key,
+				redis.Z{
+					Score:  float64(getScore(time.Now().Add(s.ttl))),
+					Member: holderID,
+				},
+			).Err()
+			if err != nil {
+				// The lease will be renewed on the next tick or, failing that, will
+				// expire-- either way, there's no sense failing the task over this
+				log.WithFields(log.Fields{
+					"semaphore": s.key,
+					"holderID":  holderID,
+					"error":     err,
+				}).Warn("error renewing semaphore lease")
+			}
+		case <-ctx.Done():
+			if err := s.redisClient.ZRem(s.key, holderID).Err(); err != nil {
+				log.WithFields(log.Fields{
+					"semaphore": s.key,
+					"holderID":  holderID,
+					"error":     err,
+				}).Warn("error releasing semaphore lease; lease will expire")
+			}
+			return
+		}
+	}
+}
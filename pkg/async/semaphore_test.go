@@ -0,0 +1,59 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaphoreAcquireRespectsLimit(t *testing.T) {
+	const limit = 2
+	s := newSemaphore(redisClient, getDisposableKeyPrefix(), limit)
+	for i := 0; i < limit; i++ {
+		acquired, err := s.acquire(getDisposableWorkerID())
+		assert.Nil(t, err)
+		assert.True(t, acquired)
+	}
+	acquired, err := s.acquire(getDisposableWorkerID())
+	assert.Nil(t, err)
+	assert.False(t, acquired)
+}
+
+func TestSemaphoreAcquireIgnoresExpiredLeases(t *testing.T) {
+	s := newSemaphore(redisClient, getDisposableKeyPrefix(), 1)
+	s.ttl = time.Millisecond * 100
+	acquired, err := s.acquire(getDisposableWorkerID())
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	time.Sleep(s.ttl * 2)
+	acquired, err = s.acquire(getDisposableWorkerID())
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+}
+
+func TestSemaphoreHoldReleasesWhenContextCanceled(t *testing.T) {
+	s := newSemaphore(redisClient, getDisposableKeyPrefix(), 1)
+	s.ttl = time.Millisecond * 300
+	holderID := getDisposableWorkerID()
+	acquired, err := s.acquire(holderID)
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+	go func() {
+		s.hold(ctx, holderID)
+		close(doneCh)
+	}()
+	// The lease should be renewed, so it shouldn't have expired
+	time.Sleep(s.ttl * 2)
+	acquired, err = s.acquire(getDisposableWorkerID())
+	assert.Nil(t, err)
+	assert.False(t, acquired)
+	cancel()
+	<-doneCh
+	acquired, err = s.acquire(getDisposableWorkerID())
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+}
@@ -7,11 +7,16 @@ import (
 	"time"
 
 	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
 	log "github.com/Sirupsen/logrus"
 	"github.com/go-redis/redis"
 	uuid "github.com/satori/go.uuid"
 )
 
+// jobSlotWaitInterval is how long a task is put aside for when as many tasks
+// for its job as are permitted are already executing
+const jobSlotWaitInterval = time.Second * 5
+
 type receiveAndWorkFunction func(ctx context.Context, queueName string) error
 type workFunction func(ctx context.Context, task model.Task) error
 
@@ -22,6 +27,14 @@ type Worker interface {
 	GetID() string
 	// RegisterJob registers a new Job with the worker
 	RegisterJob(name string, fn model.JobFunction) error
+	// RegisterJobWithMaxConcurrency registers a new Job with the worker. No more
+	// than the given number of tasks for the Job will be executed concurrently
+	// across all workers. A limit of zero indicates no limit.
+	RegisterJobWithMaxConcurrency(
+		name string,
+		fn model.JobFunction,
+		maxConcurrency int,
+	) error
 	// Work causes the worker to begin completing tasks
 	Work(context.Context) error
 }
@@ -30,10 +43,24 @@ type Worker interface {
 type worker struct {
 	id          string
 	redisClient *redis.Client
+	keyPrefix   string
+	// maxWorkerRejections is the number of times a task may be rejected by
+	// workers that have no job registered under the task's job name before the
+	// task is dead-lettered
+	maxWorkerRejections int
+	poolSize            int
+	defaultTaskTimeout  time.Duration
+	drainTimeout        time.Duration
 	// This allows tests to inject an alternative implementation
-	heart        Heart
-	jobsFns      map[string]model.JobFunction
-	jobsFnsMutex sync.RWMutex
+	heart   Heart
+	jobsFns map[string]model.JobFunction
+	// jobsSemaphores cap the concurrency of jobs registered with a limit
+	jobsSemaphores map[string]*semaphore
+	jobsFnsMutex   sync.RWMutex
+	// runningTasks maps the IDs of tasks this worker is currently executing to
+	// functions that cancel them
+	runningTasks      map[string]context.CancelFunc
+	runningTasksMutex sync.Mutex
 	// This allows tests to inject an alternative implementation of this function
 	receiveAndWork receiveAndWorkFunction
 	// This allows tests to inject an alternative implementation of this function
@@ -41,14 +68,25 @@ type worker struct {
 }
 
 // newWorker returns a new Reids-based implementation of the Worker interface
-func newWorker(redisClient *redis.Client) Worker {
+func newWorker(
+	redisClient *redis.Client,
+	keyPrefix string,
+	config Config,
+) Worker {
 	workerID := uuid.NewV4().String()
 	w := &worker{
-		id:          workerID,
-		redisClient: redisClient,
-		heart:       newHeart(workerID, time.Second*30, redisClient),
-		jobsFns:     make(map[string]model.JobFunction),
+		id:                  workerID,
+		redisClient:         redisClient,
+		keyPrefix:           keyPrefix,
+		maxWorkerRejections: config.MaxWorkerRejections,
+		poolSize:            config.WorkerPoolSize,
+		defaultTaskTimeout:  config.DefaultTaskTimeout,
+		drainTimeout:        config.DrainTimeout,
+		jobsFns:             make(map[string]model.JobFunction),
+		jobsSemaphores:      make(map[string]*semaphore),
+		runningTasks:        make(map[string]context.CancelFunc),
 	}
+	w.heart = newHeart(workerID, time.Second*30, redisClient, keyPrefix)
 	w.receiveAndWork = w.defaultReceiveAndWork
 	w.work = w.defaultWork
 	return w
@@ -61,19 +99,51 @@ func (w *worker) GetID() string {
 
 // RegisterJob registers a new Job with the worker
 func (w *worker) RegisterJob(name string, fn model.JobFunction) error {
+	return w.RegisterJobWithMaxConcurrency(name, fn, 0)
+}
+
+// RegisterJobWithMaxConcurrency registers a new Job with the worker. No more
+// than the given number of tasks for the Job will be executed concurrently
+// across all workers. A limit of zero indicates no limit.
+func (w *worker) RegisterJobWithMaxConcurrency(
+	name string,
+	fn model.JobFunction,
+	maxConcurrency int,
+) error {
+	if maxConcurrency < 0 {
+		return &InvalidMaxConcurrencyError{
+			Name:           name,
+			MaxConcurrency: maxConcurrency,
+		}
+	}
 	w.jobsFnsMutex.Lock()
 	defer w.jobsFnsMutex.Unlock()
 	if _, ok := w.jobsFns[name]; ok {
-		return &errDuplicateJob{name: name}
+		return &DuplicateJobError{Name: name}
 	}
 	w.jobsFns[name] = fn
+	if maxConcurrency > 0 {
+		w.jobsSemaphores[name] = newSemaphore(
+			w.redisClient,
+			rediskey.Join(w.keyPrefix, semaphoreSetName, name),
+			maxConcurrency,
+		)
+	}
 	return nil
 }
 
-// Work causes the worker to begin completing tasks
+// Work causes the worker to begin completing tasks. When the context is
+// canceled, the worker drains-- it stops receiving new tasks and waits, for no
+// longer than the drain timeout, for the tasks it is executing to complete
+// before returning any tasks it received but didn't begin executing to the main
+// work queue.
 func (w *worker) Work(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	// The heart and the cancellation listener must outlive the context while the
+	// worker drains
+	lifetimeCtx, cancelLifetime := context.WithCancel(context.Background())
+	defer cancelLifetime()
 	errChan := make(chan error)
 	// As soon as we add the worker to the workers set, it's eligible for the
 	// cleaner to clean up after it, so it's important that we guarantee the
@@ -87,12 +157,30 @@ func (w *worker) Work(ctx context.Context) error {
 	// Heartbeat loop
 	go func() {
 		select {
-		case errChan <- &errHeartStopped{workerID: w.id, err: w.heart.Start(ctx)}:
-		case <-ctx.Done():
+		case errChan <- &errHeartStopped{
+			workerID: w.id,
+			err:      w.heart.Start(lifetimeCtx),
+		}:
+		case <-lifetimeCtx.Done():
 		}
 	}()
+	// Listen for cancellations. The subscription is confirmed synchronously so
+	// that no cancellation published after this worker begins receiving tasks
+	// is missed.
+	pubsub := w.redisClient.Subscribe(
+		rediskey.Join(w.keyPrefix, cancellationsChannelName),
+	)
+	defer pubsub.Close() // nolint: errcheck
+	if _, err := pubsub.Receive(); err != nil {
+		return fmt.Errorf(
+			`error subscribing worker "%s" to cancellations: %s`,
+			w.id,
+			err,
+		)
+	}
+	go w.listenForCancellations(lifetimeCtx, pubsub.Channel())
 	// Announce this worker's existence
-	intCmd := w.redisClient.SAdd("workers", w.id)
+	intCmd := w.redisClient.SAdd(rediskey.Join(w.keyPrefix, workerSetName), w.id)
 	if intCmd.Err() != nil {
 		return fmt.Errorf(
 			`error adding worker "%s" to worker set: %s`,
@@ -101,12 +189,16 @@ func (w *worker) Work(ctx context.Context) error {
 		)
 	}
 	// Receive and do work
-	for range [5]struct{}{} {
+	queueName := rediskey.Join(w.keyPrefix, mainWorkQueueName)
+	var wg sync.WaitGroup
+	for i := 0; i < w.poolSize; i++ {
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			select {
 			case errChan <- &errReceiveAndWorkStopped{
 				workerID: w.id,
-				err:      w.receiveAndWork(ctx, mainWorkQueueName),
+				err:      w.receiveAndWork(ctx, queueName),
 			}:
 			case <-ctx.Done():
 			}
@@ -114,13 +206,63 @@ func (w *worker) Work(ctx context.Context) error {
 	}
 	select {
 	case <-ctx.Done():
-		log.Debug("context canceled; async worker shutting down")
+		log.Debug("context canceled; async worker draining")
+		if err := w.drain(&wg); err != nil {
+			return err
+		}
 		return ctx.Err()
 	case err := <-errChan:
 		return err
 	}
 }
 
+// drain waits, for no longer than the drain timeout, for the given wait group,
+// which tracks this worker's receiveAndWork goroutines, then moves any tasks
+// remaining in this worker's queue back to the main work queue and removes
+// this worker from the workers set. If the drain timeout elapses first, job
+// functions may still be executing tasks from this worker's queue. Requeuing
+// those tasks would let another worker execute them concurrently, so they are
+// instead left where they are, along with this worker's membership in the
+// workers set. Once this worker's heartbeat expires, the cleaner will return
+// them to the main work queue.
+func (w *worker) drain(wg *sync.WaitGroup) error {
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+	case <-time.After(w.drainTimeout):
+		log.WithField(
+			"workerID",
+			w.id,
+		).Warn(
+			"timed out waiting for in-flight tasks to complete; leaving them for " +
+				"the cleaner",
+		)
+		return nil
+	}
+	if err := requeueWorkerQueue(
+		w.redisClient,
+		w.keyPrefix,
+		w.id,
+		rediskey.Join(w.keyPrefix, mainWorkQueueName),
+	); err != nil {
+		return err
+	}
+	intCmd := w.redisClient.SRem(rediskey.Join(w.keyPrefix, workerSetName), w.id)
+	if intCmd.Err() != nil && intCmd.Err() != redis.Nil {
+		return fmt.Errorf(
+			`error removing worker "%s" from worker set: %s`,
+			w.id,
+			intCmd.Err(),
+		)
+	}
+	log.WithField("workerID", w.id).Debug("async worker drained")
+	return nil
+}
+
 // defaultReceiveAndWork synchronously receives and completes work. By combining
 // these two operations, a worker never receives more work than it currently
 // has the capacity to process.
@@ -133,44 +275,71 @@ func (w *worker) defaultReceiveAndWork(
 	for {
 		strCmd := w.redisClient.BRPopLPush(
 			queueName,
-			getWorkerQueueName(w.id),
+			getWorkerQueueName(w.keyPrefix, w.id),
 			time.Second*5,
 		)
 		if strCmd.Err() != redis.Nil {
 			if strCmd.Err() != nil {
 				return fmt.Errorf("error receiving task: %s", strCmd.Err())
 			}
+			// If the worker began draining while waiting for this task, leave the
+			// task in this worker's queue to be returned to the main work queue
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
 			taskJSON, err := strCmd.Bytes()
 			if err != nil {
 				return fmt.Errorf("error receiving task: %s", err)
 			}
 			task, err := model.NewTaskFromJSON(taskJSON)
 			if err != nil {
-				// If the JSON is invalid, remove the message from this worker's queue,
-				// log this and move on. No other worker is going to be able to process
+				// If the JSON is invalid, no worker is going to be able to process
 				// this-- there's nothing we can do and there's no sense letting this
-				// whole process die over this.
+				// whole process die over this. Set the task aside in the dead-letter
+				// list, log this and move on.
 				log.WithFields(log.Fields{
 					"taskJSON": taskJSON,
 					"error":    err,
 				}).Error("error decoding task")
-				intCmd := w.redisClient.LRem(
-					getWorkerQueueName(w.id),
-					0,
+				if err = w.deadLetter(
 					taskJSON,
-				)
-				if intCmd.Err() != nil {
+					taskJSON,
+					fmt.Sprintf("error decoding task: %s", err),
+				); err != nil {
+					return err
+				}
+				continue
+			}
+			release, err := w.acquireJobSlot(task)
+			if err != nil {
+				return err
+			}
+			if release == nil {
+				// As many tasks for this job as are permitted are already executing.
+				// Rather than tying up this goroutine waiting for one to finish, put
+				// the task aside for a little while.
+				if err = w.schedule(
+					taskJSON,
+					taskJSON,
+					time.Now().Add(jobSlotWaitInterval),
+				); err != nil {
 					return fmt.Errorf(
-						"error removing malformed task from the worker's work queue; "+
-							"task: %s: %s",
-						taskJSON,
+						"error postponing task; task: %#v: %s",
+						task,
 						err,
 					)
 				}
 				continue
 			}
-			if err := w.work(ctx, task); err != nil {
-				if _, ok := err.(*errJobNotFound); ok {
+			// Job functions execute with a context that is independent of the
+			// worker's so that in-flight tasks aren't interrupted when the worker
+			// begins draining
+			err = w.work(context.Background(), task)
+			release()
+			if err != nil {
+				if _, ok := err.(*JobNotFoundError); ok {
 					// The error is that this worker doesn't know how to process this
 					// task. That doesn't mean another worker doesn't know how. Re-queue
 					// the task.
@@ -178,7 +347,7 @@ func (w *worker) defaultReceiveAndWork(
 					// if and when we extract the async package into its own library.
 					// Construct and execute a transaction that removes the task from this
 					// worker's queue and re-queues it in the main work queue.
-					task.IncrementWorkerRejectionCount()
+					rejections := task.IncrementWorkerRejectionCount()
 					newTaskJSON, err := task.ToJSON()
 					if err != nil {
 						return fmt.Errorf(
@@ -187,10 +356,35 @@ func (w *worker) defaultReceiveAndWork(
 							err,
 						)
 					}
+					// If the task has been rejected too many times, it's likely that no
+					// worker knows how to process it. Rather than letting it bounce
+					// between queues forever, set it aside in the dead-letter list.
+					if w.maxWorkerRejections > 0 &&
+						rejections >= w.maxWorkerRejections {
+						log.WithFields(log.Fields{
+							"job":        task.GetJobName(),
+							"taskID":     task.GetID(),
+							"rejections": rejections,
+						}).Error("task rejected by too many workers")
+						if err = w.deadLetter(
+							taskJSON,
+							newTaskJSON,
+							fmt.Sprintf(
+								`task rejected %d times; no worker has a job named "%s" `+
+									"registered",
+								rejections,
+								task.GetJobName(),
+							),
+						); err != nil {
+							return err
+						}
+						releaseIdempotencyKey(w.redisClient, w.keyPrefix, task)
+						continue
+					}
 					pipeline := w.redisClient.TxPipeline()
 					pipeline.LPush(queueName, newTaskJSON)
 					pipeline.LRem(
-						getWorkerQueueName(w.id),
+						getWorkerQueueName(w.keyPrefix, w.id),
 						0,
 						taskJSON,
 					)
@@ -204,18 +398,32 @@ func (w *worker) defaultReceiveAndWork(
 					}
 					continue
 				}
+				if task.WillRetry(err) {
+					if err = w.retry(task, taskJSON, err); err != nil {
+						return err
+					}
+					continue
+				}
 				// If we get to here, we have a legitimate failure executing the task.
 				// This isn't the worker's fault. Simply log this.
 				// krancour: This behavior is something we can revisit in the future if
 				// and when we extract the async package into its own library.
-				log.WithFields(log.Fields{
+				logger := log.WithFields(log.Fields{
 					"job":    task.GetJobName(),
 					"taskID": task.GetID(),
 					"error":  err,
-				}).Error("error executing job")
+				})
+				switch err.(type) {
+				case *TaskTimedOutError:
+					logger.Error("job timed out")
+				case *TaskCanceledError:
+					logger.Warn("job canceled")
+				default:
+					logger.Error("error executing job")
+				}
 			}
 			intCmd := w.redisClient.LRem(
-				getWorkerQueueName(w.id),
+				getWorkerQueueName(w.keyPrefix, w.id),
 				0,
 				taskJSON,
 			)
@@ -224,9 +432,12 @@ func (w *worker) defaultReceiveAndWork(
 					`error removing task %s from worker "%s" work queue: %s`,
 					taskJSON,
 					w.id,
-					err,
+					intCmd.Err(),
 				)
 			}
+			// The task won't be executed again, so tasks having the same idempotency
+			// key may be submitted once more
+			releaseIdempotencyKey(w.redisClient, w.keyPrefix, task)
 		}
 		select {
 		case <-ctx.Done():
@@ -236,14 +447,182 @@ func (w *worker) defaultReceiveAndWork(
 	}
 }
 
+// retry atomically moves a task that failed with a retryable error from this
+// worker's queue to the scheduled set. The scheduler will move it back to the
+// main work queue once the backoff dictated by the task's retry policy has
+// elapsed.
+func (w *worker) retry(task model.Task, taskJSON []byte, cause error) error {
+	failedAttempts := task.IncrementFailedAttempts()
+	backoff := task.GetRetryPolicy().GetBackoff(failedAttempts)
+	log.WithFields(log.Fields{
+		"job":            task.GetJobName(),
+		"taskID":         task.GetID(),
+		"failedAttempts": failedAttempts,
+		"backoff":        backoff,
+		"error":          cause,
+	}).Warn("error executing job; will retry")
+	newTaskJSON, err := task.ToJSON()
+	if err == nil {
+		err = w.schedule(taskJSON, newTaskJSON, time.Now().Add(backoff))
+	}
+	if err != nil {
+		return fmt.Errorf(
+			"error scheduling retry of failed task; task: %#v: %s",
+			task,
+			err,
+		)
+	}
+	return nil
+}
+
+// schedule atomically removes a task from this worker's queue and adds it to
+// the scheduled set. The scheduler will move it back to the main work queue
+// once the given time has passed. Since the task may have been modified since
+// it was received, the JSON to remove and the JSON to schedule are specified
+// separately.
+func (w *worker) schedule(
+	receivedTaskJSON []byte,
+	taskJSON []byte,
+	executeTime time.Time,
+) error {
+	pipeline := w.redisClient.TxPipeline()
+	pipeline.ZAdd(
+		rediskey.Join(w.keyPrefix, scheduledSetName),
+		redis.Z{
+			Score:  float64(getScore(executeTime)),
+			Member: taskJSON,
+		},
+	)
+	pipeline.LRem(getWorkerQueueName(w.keyPrefix, w.id), 0, receivedTaskJSON)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// acquireJobSlot attempts, without blocking, to obtain one of the limited
+// number of slots for executing tasks for the given task's job. If the job
+// was registered without a limit, this always succeeds. If a slot is
+// obtained, a function that must be called to release the slot is returned.
+// Otherwise, a nil function is returned.
+func (w *worker) acquireJobSlot(task model.Task) (func(), error) {
+	w.jobsFnsMutex.RLock()
+	sem, ok := w.jobsSemaphores[task.GetJobName()]
+	w.jobsFnsMutex.RUnlock()
+	if !ok {
+		return func() {}, nil
+	}
+	acquired, err := sem.acquire(task.GetID())
+	if err != nil || !acquired {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+	go func() {
+		sem.hold(ctx, task.GetID())
+		close(doneCh)
+	}()
+	return func() {
+		cancel()
+		<-doneCh
+	}, nil
+}
+
+// deadLetter atomically removes a task that cannot be processed from this
+// worker's queue and adds it, along with the reason it cannot be processed, to
+// the dead-letter list. Since the task may have been modified since it was
+// received, the JSON to remove and the JSON to dead-letter are specified
+// separately.
+func (w *worker) deadLetter(
+	receivedTaskJSON []byte,
+	taskJSON []byte,
+	reason string,
+) error {
+	deadLetterJSON, err := model.NewDeadLetter(taskJSON, reason).ToJSON()
+	if err != nil {
+		return fmt.Errorf(
+			"error dead-lettering unprocessable task; task: %s: %s",
+			taskJSON,
+			err,
+		)
+	}
+	pipeline := w.redisClient.TxPipeline()
+	pipeline.LPush(rediskey.Join(w.keyPrefix, deadLetterListName), deadLetterJSON)
+	pipeline.LRem(getWorkerQueueName(w.keyPrefix, w.id), 0, receivedTaskJSON)
+	if _, err = pipeline.Exec(); err != nil {
+		return fmt.Errorf(
+			"error dead-lettering unprocessable task; task: %s: %s",
+			taskJSON,
+			err,
+		)
+	}
+	return nil
+}
+
+// listenForCancellations cancels tasks this worker is executing as their IDs
+// are received on the given channel until the context is canceled
+func (w *worker) listenForCancellations(
+	ctx context.Context,
+	msgCh <-chan *redis.Message,
+) {
+	for {
+		select {
+		case msg, ok := <-msgCh:
+			if !ok {
+				return
+			}
+			w.runningTasksMutex.Lock()
+			cancel, ok := w.runningTasks[msg.Payload]
+			w.runningTasksMutex.Unlock()
+			if ok {
+				log.WithFields(log.Fields{
+					"workerID": w.id,
+					"taskID":   msg.Payload,
+				}).Debug("canceling task")
+				cancel()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (w *worker) defaultWork(ctx context.Context, task model.Task) error {
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := model.ContextWithCancel(model.ContextWithTask(ctx, task))
 	defer cancel()
+	timeout := task.GetTimeout()
+	if timeout == 0 {
+		timeout = w.defaultTaskTimeout
+	}
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
 	w.jobsFnsMutex.RLock()
-	defer w.jobsFnsMutex.RUnlock()
 	jobFn, ok := w.jobsFns[task.GetJobName()]
+	w.jobsFnsMutex.RUnlock()
 	if !ok {
-		return &errJobNotFound{name: task.GetJobName()}
+		return &JobNotFoundError{Name: task.GetJobName()}
+	}
+	w.runningTasksMutex.Lock()
+	w.runningTasks[task.GetID()] = cancel
+	w.runningTasksMutex.Unlock()
+	defer func() {
+		w.runningTasksMutex.Lock()
+		delete(w.runningTasks, task.GetID())
+		w.runningTasksMutex.Unlock()
+	}()
+	err := jobFn(ctx, task.GetArgs())
+	if err != nil {
+		if model.IsTimedOut(ctx) {
+			return &TaskTimedOutError{
+				TaskID:  task.GetID(),
+				Timeout: timeout,
+				Err:     err,
+			}
+		}
+		if model.IsCanceled(ctx) {
+			return &TaskCanceledError{TaskID: task.GetID(), Err: err}
+		}
 	}
-	return jobFn(ctx, task.GetArgs())
+	return err
 }
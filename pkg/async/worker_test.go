@@ -7,14 +7,16 @@ import (
 
 	fakeAsync "github.com/Azure/open-service-broker-azure/pkg/async/fake"
 	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/go-redis/redis"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestWorkerGetsUniqueID(t *testing.T) {
 	// Create two workers-- make sure their IDs are at least different from one
 	// another
-	w1 := newWorker(redisClient).(*worker)
-	w2 := newWorker(redisClient).(*worker)
+	w1 := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
+	w2 := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	assert.NotEqual(t, w1.id, w2.id)
 }
 
@@ -23,7 +25,7 @@ func TestWorkerWorkBlocksUntilHeartErrors(t *testing.T) {
 	h.RunBehavior = func(context.Context) error {
 		return errSome
 	}
-	w := newWorker(redisClient).(*worker)
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	w.heart = h
 	receiveAndWorkStopped := false
 	w.receiveAndWork = func(ctx context.Context, queueName string) error {
@@ -48,7 +50,7 @@ func TestWorkerWorkBlocksUntilHeartReturns(t *testing.T) {
 	h.RunBehavior = func(context.Context) error {
 		return nil
 	}
-	w := newWorker(redisClient).(*worker)
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	w.heart = h
 	receiveAndWorkStopped := false
 	w.receiveAndWork = func(ctx context.Context, queueName string) error {
@@ -76,7 +78,7 @@ func TestWorkerWorkBlocksUntilReceiveAndWorkErrors(t *testing.T) {
 		heartStopped = true
 		return ctx.Err()
 	}
-	w := newWorker(redisClient).(*worker)
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	w.heart = h
 	w.receiveAndWork = func(context.Context, string) error {
 		return errSome
@@ -101,7 +103,7 @@ func TestWorkerWorkBlocksUntilReceiveAndWorkReturns(t *testing.T) {
 		heartStopped = true
 		return ctx.Err()
 	}
-	w := newWorker(redisClient).(*worker)
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	w.heart = h
 	w.receiveAndWork = func(context.Context, string) error {
 		return nil
@@ -126,7 +128,7 @@ func TestWorkerWorkBlocksUntilContextCanceled(t *testing.T) {
 		heartStopped = true
 		return ctx.Err()
 	}
-	w := newWorker(redisClient).(*worker)
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	w.heart = h
 	receiveAndWorkStopped := false
 	w.receiveAndWork = func(ctx context.Context, queueName string) error {
@@ -152,7 +154,7 @@ func TestReceiveAndWorkCallsWorkOncePerTask(t *testing.T) {
 		intCmd := redisClient.LPush(queueName, taskJSON)
 		assert.Nil(t, intCmd.Err())
 	}
-	w := newWorker(redisClient).(*worker)
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	var workCount int
 	w.work = func(context.Context, model.Task) error {
 		workCount++
@@ -168,7 +170,7 @@ func TestReceiveAndWorkCallsWorkOncePerTask(t *testing.T) {
 	currentMainQueueDepth, err := intCmd.Result()
 	assert.Nil(t, err)
 	assert.Empty(t, currentMainQueueDepth)
-	intCmd = redisClient.LLen(getWorkerQueueName(w.id))
+	intCmd = redisClient.LLen(getWorkerQueueName(w.keyPrefix, w.id))
 	assert.Nil(t, intCmd.Err())
 	currentWorkerQueueDepth, err := intCmd.Result()
 	assert.Nil(t, err)
@@ -179,7 +181,7 @@ func TestWorkerReceiveAndWorkBlocksEvenAfterInvalidTask(t *testing.T) {
 	queueName := getDisposableQueueName()
 	intCmd := redisClient.LPush(queueName, "bogus")
 	assert.Nil(t, intCmd.Err())
-	w := newWorker(redisClient).(*worker)
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	workCalled := false
 	w.work = func(context.Context, model.Task) error {
 		workCalled = true
@@ -195,7 +197,7 @@ func TestWorkerReceiveAndWorkBlocksEvenAfterInvalidTask(t *testing.T) {
 	currentMainQueueDepth, err := intCmd.Result()
 	assert.Nil(t, err)
 	assert.Empty(t, currentMainQueueDepth)
-	intCmd = redisClient.LLen(getWorkerQueueName(w.id))
+	intCmd = redisClient.LLen(getWorkerQueueName(w.keyPrefix, w.id))
 	assert.Nil(t, intCmd.Err())
 	currentWorkerQueueDepth, err := intCmd.Result()
 	assert.Nil(t, err)
@@ -208,7 +210,7 @@ func TestWorkerReceiveAndWorkBlocksEvenAfterWorkError(t *testing.T) {
 	assert.Nil(t, err)
 	intCmd := redisClient.LPush(queueName, taskJSON)
 	assert.Nil(t, intCmd.Err())
-	w := newWorker(redisClient).(*worker)
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	workCalled := false
 	w.work = func(context.Context, model.Task) error {
 		workCalled = true
@@ -222,9 +224,231 @@ func TestWorkerReceiveAndWorkBlocksEvenAfterWorkError(t *testing.T) {
 }
 
 func TestWorkerReceiveAndWorkBlocksUntilContextCanceled(t *testing.T) {
-	w := newWorker(redisClient).(*worker)
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	err := w.receiveAndWork(ctx, getDisposableQueueName())
 	assert.Equal(t, ctx.Err(), err)
 }
+
+func TestWorkerReceiveAndWorkDeadLettersInvalidTask(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	queueName := rediskey.Join(keyPrefix, mainWorkQueueName)
+	intCmd := redisClient.LPush(queueName, "bogus")
+	assert.Nil(t, intCmd.Err())
+	w := newWorker(redisClient, keyPrefix, NewConfigWithDefaults()).(*worker)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err := w.receiveAndWork(ctx, queueName)
+	assert.Equal(t, ctx.Err(), err)
+	deadLetterJSONs, err := redisClient.LRange(
+		rediskey.Join(keyPrefix, deadLetterListName),
+		0,
+		-1,
+	).Result()
+	assert.Nil(t, err)
+	if !assert.Equal(t, 1, len(deadLetterJSONs)) {
+		return
+	}
+	deadLetter, err := model.NewDeadLetterFromJSON([]byte(deadLetterJSONs[0]))
+	assert.Nil(t, err)
+	assert.Equal(t, "bogus", deadLetter.TaskJSON)
+	assert.NotEmpty(t, deadLetter.Reason)
+}
+
+func TestWorkerReceiveAndWorkDeadLettersRepeatedlyRejectedTask(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	queueName := rediskey.Join(keyPrefix, mainWorkQueueName)
+	const maxWorkerRejections = 3
+	taskJSON, err := model.NewTask("foo", nil).ToJSON()
+	assert.Nil(t, err)
+	intCmd := redisClient.LPush(queueName, taskJSON)
+	assert.Nil(t, intCmd.Err())
+	w := newWorker(
+		redisClient,
+		keyPrefix,
+		Config{MaxWorkerRejections: maxWorkerRejections},
+	).(*worker)
+	var workCount int
+	w.work = func(_ context.Context, task model.Task) error {
+		workCount++
+		return &JobNotFoundError{Name: task.GetJobName()}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err = w.receiveAndWork(ctx, queueName)
+	assert.Equal(t, ctx.Err(), err)
+	assert.Equal(t, maxWorkerRejections, workCount)
+	intCmd = redisClient.LLen(queueName)
+	assert.Nil(t, intCmd.Err())
+	assert.Empty(t, intCmd.Val())
+	deadLetterJSONs, err := redisClient.LRange(
+		rediskey.Join(keyPrefix, deadLetterListName),
+		0,
+		-1,
+	).Result()
+	assert.Nil(t, err)
+	if !assert.Equal(t, 1, len(deadLetterJSONs)) {
+		return
+	}
+	deadLetter, err := model.NewDeadLetterFromJSON([]byte(deadLetterJSONs[0]))
+	assert.Nil(t, err)
+	task, err := deadLetter.GetTask()
+	assert.Nil(t, err)
+	assert.Equal(t, maxWorkerRejections, task.GetWorkerRejectionCount())
+}
+
+func TestWorkerDefaultWorkTimesOut(t *testing.T) {
+	config := NewConfigWithDefaults()
+	config.DefaultTaskTimeout = time.Millisecond * 100
+	w := newWorker(redisClient, testKeyPrefix, config).(*worker)
+	err := w.RegisterJob("foo", waitForCancellation)
+	assert.Nil(t, err)
+	task := model.NewTask("foo", nil)
+	err = w.work(context.Background(), task)
+	assert.Equal(
+		t,
+		&TaskTimedOutError{
+			TaskID:  task.GetID(),
+			Timeout: config.DefaultTaskTimeout,
+			Err:     context.DeadlineExceeded,
+		},
+		err,
+	)
+	assert.False(t, task.WillRetry(err))
+}
+
+func TestWorkerDefaultWorkPrefersTaskTimeout(t *testing.T) {
+	config := NewConfigWithDefaults()
+	config.DefaultTaskTimeout = time.Hour
+	w := newWorker(redisClient, testKeyPrefix, config).(*worker)
+	err := w.RegisterJob("foo", waitForCancellation)
+	assert.Nil(t, err)
+	task := model.NewTask("foo", nil)
+	task.SetTimeout(time.Millisecond * 100)
+	err = w.work(context.Background(), task)
+	_, ok := err.(*TaskTimedOutError)
+	assert.True(t, ok)
+}
+
+func TestWorkerListenForCancellationsCancelsRunningTask(t *testing.T) {
+	w := newWorker(redisClient, testKeyPrefix, NewConfigWithDefaults()).(*worker)
+	startedCh := make(chan struct{})
+	err := w.RegisterJob(
+		"foo",
+		func(ctx context.Context, args map[string]string) error {
+			close(startedCh)
+			return waitForCancellation(ctx, args)
+		},
+	)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgCh := make(chan *redis.Message)
+	go w.listenForCancellations(ctx, msgCh)
+	task := model.NewTask("foo", nil)
+	errCh := make(chan error)
+	go func() {
+		errCh <- w.work(context.Background(), task)
+	}()
+	<-startedCh
+	msgCh <- &redis.Message{Payload: task.GetID()}
+	select {
+	case err = <-errCh:
+		assert.Equal(
+			t,
+			&TaskCanceledError{TaskID: task.GetID(), Err: context.Canceled},
+			err,
+		)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for task to be canceled")
+	}
+}
+
+func waitForCancellation(ctx context.Context, _ map[string]string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestWorkerWorkDrainsWhenContextCanceled(t *testing.T) {
+	keyPrefix := getDisposableKeyPrefix()
+	w := newWorker(redisClient, keyPrefix, NewConfigWithDefaults()).(*worker)
+	w.heart = fakeAsync.NewHeart()
+	// Simulate a task that was received, but not completed, before the worker
+	// began draining
+	taskJSON, err := model.NewTask("foo", nil).ToJSON()
+	assert.Nil(t, err)
+	intCmd := redisClient.LPush(getWorkerQueueName(keyPrefix, w.id), taskJSON)
+	assert.Nil(t, intCmd.Err())
+	var inFlightTaskCompleted bool
+	w.receiveAndWork = func(ctx context.Context, queueName string) error {
+		<-ctx.Done()
+		// Simulate an in-flight task that completes shortly after the worker
+		// begins draining
+		time.Sleep(time.Millisecond * 500)
+		inFlightTaskCompleted = true
+		return ctx.Err()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = w.Work(ctx)
+	assert.Equal(t, ctx.Err(), err)
+	assert.True(t, inFlightTaskCompleted)
+	taskJSONs, err := redisClient.LRange(
+		rediskey.Join(keyPrefix, mainWorkQueueName),
+		0,
+		-1,
+	).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{string(taskJSON)}, taskJSONs)
+	intCmd = redisClient.LLen(getWorkerQueueName(keyPrefix, w.id))
+	assert.Nil(t, intCmd.Err())
+	assert.Empty(t, intCmd.Val())
+}
+
+func TestWorkerWorkDrainTimesOut(t *testing.T) {
+	config := NewConfigWithDefaults()
+	config.DrainTimeout = time.Millisecond * 100
+	keyPrefix := getDisposableKeyPrefix()
+	w := newWorker(redisClient, keyPrefix, config).(*worker)
+	w.heart = fakeAsync.NewHeart()
+	blockCh := make(chan struct{})
+	defer close(blockCh)
+	// Simulate a task whose job function is still executing when the drain
+	// timeout elapses
+	taskJSON, err := model.NewTask("foo", nil).ToJSON()
+	assert.Nil(t, err)
+	intCmd := redisClient.LPush(getWorkerQueueName(keyPrefix, w.id), taskJSON)
+	assert.Nil(t, intCmd.Err())
+	w.receiveAndWork = func(ctx context.Context, queueName string) error {
+		<-blockCh
+		return ctx.Err()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	doneCh := make(chan error)
+	go func() {
+		doneCh <- w.Work(ctx)
+	}()
+	select {
+	case err := <-doneCh:
+		assert.Equal(t, ctx.Err(), err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for worker to drain")
+	}
+	// The unfinished task must not have been returned to the main work queue
+	// while its job function may still be executing. It's left, along with the
+	// worker's membership in the worker set, for the cleaner.
+	intCmd = redisClient.LLen(rediskey.Join(keyPrefix, mainWorkQueueName))
+	assert.Nil(t, intCmd.Err())
+	assert.Empty(t, intCmd.Val())
+	intCmd = redisClient.LLen(getWorkerQueueName(keyPrefix, w.id))
+	assert.Nil(t, intCmd.Err())
+	assert.Equal(t, int64(1), intCmd.Val())
+	isMember, err := redisClient.SIsMember(
+		rediskey.Join(keyPrefix, workerSetName),
+		w.id,
+	).Result()
+	assert.Nil(t, err)
+	assert.True(t, isMember)
+}
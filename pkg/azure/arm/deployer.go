@@ -26,7 +26,8 @@ const (
 )
 
 // Deployer is an interface to be implemented by any component capable of
-// deploying resource to Azure using an ARM template
+// deploying resource to Azure using an ARM template. Failures that are likely
+// transient, such as throttling, are returned as *service.RetryableError.
 type Deployer interface {
 	Deploy(
 		deploymentName string,
@@ -95,12 +96,12 @@ func (d *deployer) Deploy(
 		d.clientSecret,
 	)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, newError(
+			err,
 			`error deploying "%s" in resource group "%s": error getting bearer `+
-				`token authorizer: %s`,
+				`token authorizer`,
 			deploymentName,
 			resourceGroupName,
-			err,
 		)
 	}
 
@@ -117,12 +118,12 @@ func (d *deployer) Deploy(
 		resourceGroupName,
 	)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, newError(
+			err,
 			`error deploying "%s" in resource group "%s": error getting `+
-				`deployment: %s`,
+				`deployment`,
 			deploymentName,
 			resourceGroupName,
-			err,
 		)
 	}
 
@@ -145,11 +146,11 @@ func (d *deployer) Deploy(
 			armParams,
 			tags,
 		); err != nil {
-			return nil, fmt.Errorf(
-				`error deploying "%s" in resource group "%s": %s`,
+			return nil, newError(
+				err,
+				`error deploying "%s" in resource group "%s"`,
 				deploymentName,
 				resourceGroupName,
-				err,
 			)
 		}
 	case deploymentStatusRunning:
@@ -164,11 +165,11 @@ func (d *deployer) Deploy(
 			deploymentName,
 			resourceGroupName,
 		); err != nil {
-			return nil, fmt.Errorf(
-				`error deploying "%s" in resource group "%s": %s`,
+			return nil, newError(
+				err,
+				`error deploying "%s" in resource group "%s"`,
 				deploymentName,
 				resourceGroupName,
-				err,
 			)
 		}
 	case deploymentStatusSucceeded:
@@ -212,12 +213,12 @@ func (d *deployer) Delete(
 		d.clientSecret,
 	)
 	if err != nil {
-		return fmt.Errorf(
+		return newError(
+			err,
 			`error deleting deployment "%s" from resource group "%s": error `+
-				`getting bearer token authorizer: %s`,
+				`getting bearer token authorizer`,
 			deploymentName,
 			resourceGroupName,
-			err,
 		)
 	}
 
@@ -238,11 +239,11 @@ func (d *deployer) Delete(
 	select {
 	case err := <-errChan:
 		if err != nil {
-			return fmt.Errorf(
-				`error deleting deployment "%s" from resource group "%s": %s`,
+			return newError(
+				err,
+				`error deleting deployment "%s" from resource group "%s"`,
 				deploymentName,
 				resourceGroupName,
-				err,
 			)
 		}
 	case <-timer.C:
@@ -304,10 +305,7 @@ func (d *deployer) doNewDeployment(
 	groupsClient.Authorizer = authorizer
 	res, err := groupsClient.CheckExistence(resourceGroupName)
 	if err != nil {
-		return nil, fmt.Errorf(
-			"error checking existence of resource group: %s",
-			err,
-		)
+		return nil, newError(err, "error checking existence of resource group")
 	}
 	if res.StatusCode == http.StatusNotFound {
 		if _, err = groupsClient.CreateOrUpdate(
@@ -317,10 +315,7 @@ func (d *deployer) doNewDeployment(
 				Location: &location,
 			},
 		); err != nil {
-			return nil, fmt.Errorf(
-				"error creating resource group: %s",
-				err,
-			)
+			return nil, newError(err, "error creating resource group")
 		}
 	}
 
@@ -390,7 +385,7 @@ func (d *deployer) doNewDeployment(
 	select {
 	case err = <-errChan:
 		if err != nil {
-			return nil, fmt.Errorf("error submitting ARM template: %s", err)
+			return nil, newError(err, "error submitting ARM template")
 		}
 	case <-timer.C:
 		return nil, errors.New("timed out waiting for deployment to complete")
@@ -400,7 +395,7 @@ func (d *deployer) doNewDeployment(
 	// so we need to make a separate call to retrieve the deployment
 	deployment, err := deploymentsClient.Get(resourceGroupName, deploymentName)
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving completed deployment: %s", err)
+		return nil, newError(err, "error retrieving completed deployment")
 	}
 
 	return &deployment, nil
@@ -0,0 +1,17 @@
+package arm
+
+import (
+	az "github.com/Azure/open-service-broker-azure/pkg/azure"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+)
+
+// newError returns an error whose message prefixes the given error's message
+// with the formatted message. If the given error is transient, the returned
+// error is a *service.RetryableError so that the operation that encountered it
+// can be retried later instead of being failed permanently.
+func newError(err error, format string, a ...interface{}) error {
+	if az.IsTransientError(err) {
+		err = service.NewRetryableError(err)
+	}
+	return service.WrapError(err, format, a...)
+}
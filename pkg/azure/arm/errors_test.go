@@ -0,0 +1,50 @@
+package arm
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewError(t *testing.T) {
+	testCases := []struct {
+		name            string
+		err             error
+		retryable       bool
+		expectedMessage string
+	}{
+		{
+			name:            "permanent error",
+			err:             errors.New("an error"),
+			expectedMessage: "error deploying: an error",
+		},
+		{
+			name: "throttled request",
+			err: autorest.DetailedError{
+				StatusCode: http.StatusTooManyRequests,
+				Message:    "throttled",
+			},
+			retryable: true,
+		},
+		{
+			name:            "retryable error",
+			err:             service.NewRetryableError(errors.New("an error")),
+			retryable:       true,
+			expectedMessage: "retryable error: error deploying: an error",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := newError(testCase.err, "error %s", "deploying")
+			_, ok := err.(*service.RetryableError)
+			assert.Equal(t, testCase.retryable, ok)
+			if testCase.expectedMessage != "" {
+				assert.Equal(t, testCase.expectedMessage, err.Error())
+			}
+		})
+	}
+}
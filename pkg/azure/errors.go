@@ -0,0 +1,48 @@
+package azure
+
+import (
+	"database/sql/driver"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// IsTransientError returns a bool indicating whether the given error represents
+// a failure that may not recur if the operation that caused it is attempted
+// again later; namely, a throttled request (HTTP 429), a server-side error
+// (HTTP 5xx), or a network error such as a timeout or a dropped connection
+func IsTransientError(err error) bool {
+	switch e := err.(type) {
+	case nil:
+		return false
+	case autorest.DetailedError:
+		return isTransientStatusCode(e.StatusCode) || IsTransientError(e.Original)
+	case *autorest.DetailedError:
+		return e != nil && IsTransientError(*e)
+	case azure.RequestError:
+		return IsTransientError(e.DetailedError)
+	case *azure.RequestError:
+		return e != nil && IsTransientError(e.DetailedError)
+	case *url.Error:
+		return IsTransientError(e.Err)
+	case *net.OpError:
+		// Failures to dial, read from, or write to a connection
+		return true
+	case net.Error:
+		return e.Timeout() || e.Temporary()
+	}
+	return err == driver.ErrBadConn || err == io.ErrUnexpectedEOF
+}
+
+func isTransientStatusCode(statusCode interface{}) bool {
+	code, ok := statusCode.(int)
+	if !ok {
+		return false
+	}
+	return code == http.StatusTooManyRequests ||
+		code >= http.StatusInternalServerError
+}
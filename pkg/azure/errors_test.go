@@ -0,0 +1,116 @@
+package azure
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/stretchr/testify/assert"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string {
+	return "i/o timeout"
+}
+
+func (timeoutError) Timeout() bool {
+	return true
+}
+
+func (timeoutError) Temporary() bool {
+	return false
+}
+
+func TestIsTransientError(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "plain error",
+			err:  errors.New("an error"),
+		},
+		{
+			name:      "throttled request",
+			err:       autorest.DetailedError{StatusCode: http.StatusTooManyRequests},
+			transient: true,
+		},
+		{
+			name:      "server error",
+			err:       autorest.DetailedError{StatusCode: http.StatusBadGateway},
+			transient: true,
+		},
+		{
+			name: "client error",
+			err:  autorest.DetailedError{StatusCode: http.StatusBadRequest},
+		},
+		{
+			name: "no status code",
+			err:  autorest.DetailedError{StatusCode: autorest.UndefinedStatusCode},
+		},
+		{
+			name: "azure request error",
+			err: &azure.RequestError{
+				DetailedError: autorest.DetailedError{
+					StatusCode: http.StatusServiceUnavailable,
+				},
+			},
+			transient: true,
+		},
+		{
+			name: "wrapped azure request error",
+			err: autorest.DetailedError{
+				Original: azure.RequestError{
+					DetailedError: autorest.DetailedError{
+						StatusCode: http.StatusTooManyRequests,
+					},
+				},
+			},
+			transient: true,
+		},
+		{
+			name: "wrapped network error",
+			err: autorest.DetailedError{
+				Original: &url.Error{
+					Op:  "Get",
+					URL: "https://management.azure.com",
+					Err: &net.OpError{Op: "dial", Err: errors.New("refused")},
+				},
+			},
+			transient: true,
+		},
+		{
+			name:      "timeout",
+			err:       timeoutError{},
+			transient: true,
+		},
+		{
+			name: "unparseable url",
+			err: &url.Error{
+				Op:  "parse",
+				URL: "%zz",
+				Err: url.EscapeError("%zz"),
+			},
+		},
+		{
+			name:      "bad connection",
+			err:       driver.ErrBadConn,
+			transient: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.transient, IsTransientError(testCase.err))
+		})
+	}
+}
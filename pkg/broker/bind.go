@@ -0,0 +1,355 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/api"
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	log "github.com/Sirupsen/logrus"
+)
+
+func (b *broker) doBindStep(
+	ctx context.Context,
+	args map[string]string,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stepName, ok := args["stepName"]
+	if !ok {
+		return errors.New(`missing required argument "stepName"`)
+	}
+	bindingID, ok := args["bindingID"]
+	if !ok {
+		return errors.New(`missing required argument "bindingID"`)
+	}
+	binding, ok, err := b.store.GetBinding(bindingID)
+	if err != nil {
+		return b.handleBindingError(
+			bindingID,
+			stepName,
+			err,
+			"error loading persisted binding",
+		)
+	}
+	if !ok {
+		return b.handleBindingError(
+			bindingID,
+			stepName,
+			nil,
+			"binding does not exist in the data store",
+		)
+	}
+	log.WithFields(log.Fields{
+		"step":       stepName,
+		"bindingID":  binding.BindingID,
+		"instanceID": binding.InstanceID,
+	}).Debug("executing binding step")
+	instance, ok, err := b.store.GetInstance(binding.InstanceID)
+	if err != nil {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			err,
+			"error loading persisted instance",
+		)
+	}
+	if !ok {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			nil,
+			"instance does not exist in the data store",
+		)
+	}
+	svc, ok := b.catalog.GetService(instance.ServiceID)
+	if !ok {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			nil,
+			fmt.Sprintf(
+				`no service was found for handling serviceID "%s"`,
+				instance.ServiceID,
+			),
+		)
+	}
+	plan, ok := svc.GetPlan(instance.PlanID)
+	if !ok {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			nil,
+			fmt.Sprintf(
+				`no plan was found for handling planID "%s"`,
+				instance.PlanID,
+			),
+		)
+	}
+	serviceManager := svc.GetServiceManager()
+	provisioningContext := serviceManager.GetEmptyProvisioningContext()
+	err = instance.GetProvisioningContext(provisioningContext, b.codec)
+	if err != nil {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			err,
+			"error decoding provisioningContext from persisted instance",
+		)
+	}
+	bindingParameters := serviceManager.GetEmptyBindingParameters()
+	err = binding.GetBindingParameters(bindingParameters, b.codec)
+	if err != nil {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			err,
+			"error decoding bindingParameters from persisted binding",
+		)
+	}
+	bindingContext := serviceManager.GetEmptyBindingContext()
+	err = binding.GetBindingContext(bindingContext, b.codec)
+	if err != nil {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			err,
+			"error decoding bindingContext from persisted binding",
+		)
+	}
+	binder, err := serviceManager.GetBinder(plan)
+	if err != nil {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			err,
+			fmt.Sprintf(
+				`error retrieving binder for service "%s"`,
+				instance.ServiceID,
+			),
+		)
+	}
+	step, ok := binder.GetStep(stepName)
+	if !ok {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			nil,
+			"binder does not know how to process step",
+		)
+	}
+	if isBindingStepCompleted(binding, stepName) {
+		// The step has already been executed-- by an earlier delivery of the same
+		// task, for instance. Rather than executing it again, just ensure that the
+		// steps that depend on it have been submitted.
+		if binding.Status != service.BindingStateBinding {
+			return nil
+		}
+		if err = b.submitNextBindingSteps(
+			binder,
+			"bindStep",
+			api.OperationBinding,
+			binding,
+			stepName,
+		); err != nil {
+			return b.handleBindingError(
+				binding,
+				stepName,
+				err,
+				"error enqueing next steps",
+			)
+		}
+		return nil
+	}
+	originalBindingContextJSON, err := json.Marshal(bindingContext)
+	if err != nil {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			err,
+			"error encoding bindingContext",
+		)
+	}
+	updatedBindingContext, credentials, err := step.Execute(
+		ctx,
+		bindingID,
+		plan,
+		instance.StandardProvisioningContext,
+		provisioningContext,
+		bindingContext,
+		bindingParameters,
+	)
+	if err != nil {
+		if model.WillRetry(ctx, err) {
+			// The async engine will retry the step later. In the meantime, the
+			// binding's status is left alone.
+			return err
+		}
+		return b.handleBindingError(
+			binding,
+			stepName,
+			err,
+			getStepErrorMessage(ctx, "binding"),
+		)
+	}
+	if binding, err = b.writeBinding(
+		binding,
+		bindingMutations(
+			requireBindingStatus(service.BindingStateBinding),
+			b.mergeBindingContext(
+				originalBindingContextJSON,
+				updatedBindingContext,
+			),
+			func(bdg *service.Binding) error {
+				if credentials == nil {
+					return nil
+				}
+				return bdg.SetCredentials(credentials, b.codec)
+			},
+			completeBindingStep(stepName),
+			func(bdg *service.Binding) error {
+				if binder.IsComplete(bdg.CompletedSteps) {
+					// All steps have completed-- we're done binding!
+					bdg.Status = service.BindingStateBound
+				}
+				return nil
+			},
+		),
+	); err != nil {
+		if isStatusChanged(err) {
+			// Binding failed in a concurrent branch while this step was executing.
+			// The binding's status, which already explains the failure, is left
+			// alone.
+			return b.handleBindingError(
+				bindingID,
+				stepName,
+				err,
+				"error persisting binding",
+			)
+		}
+		return b.handleBindingError(
+			binding,
+			stepName,
+			err,
+			"error persisting binding",
+		)
+	}
+	if binding.Status == service.BindingStateBound {
+		return nil
+	}
+	if err = b.submitNextBindingSteps(
+		binder,
+		"bindStep",
+		api.OperationBinding,
+		binding,
+		stepName,
+	); err != nil {
+		return b.handleBindingError(
+			binding,
+			stepName,
+			err,
+			"error enqueing next steps",
+		)
+	}
+	return nil
+}
+
+// handleBindingError tries to handle async binding errors. If a binding is
+// passed in, its status is updated and an attempt is made to persist the
+// binding with updated status, provided the operation is still in progress. If
+// this fails for any reason other than a concurrent modification, we have a
+// very serious problem on our hands, so we log that failure and kill the
+// process. Barring such a failure, a nicely formatted error is returned to be,
+// in-turn, returned by the caller of this function. If a bindingID is passed in
+// (instead of a binding), only error formatting is handled.
+func (b *broker) handleBindingError(
+	bindingOrBindingID interface{},
+	stepName string,
+	e error,
+	msg string,
+) error {
+	binding, ok := bindingOrBindingID.(*service.Binding)
+	if !ok {
+		return formatBindingStepError(
+			"binding",
+			stepName,
+			bindingOrBindingID,
+			e,
+			msg,
+		)
+	}
+	// If we get to here, we have a binding (not just a bindingID)
+	ret := formatBindingStepError(
+		"binding",
+		stepName,
+		binding.BindingID,
+		e,
+		msg,
+	)
+	_, err := b.writeBinding(
+		binding,
+		bindingMutations(
+			requireBindingStatus(service.BindingStateBinding),
+			func(bdg *service.Binding) error {
+				bdg.Status = service.BindingStateBindingFailed
+				bdg.StatusReason = ret.Error()
+				return nil
+			},
+		),
+	)
+	if isStatusChanged(err) {
+		// The operation already concluded or another one has since begun, so
+		// the failure is not recorded
+		log.WithFields(log.Fields{
+			"bindingID":     binding.BindingID,
+			"originalError": ret,
+			"reason":        err,
+		}).Warn("not persisting binding with updated status")
+	} else if isConflict(err) {
+		log.WithFields(log.Fields{
+			"bindingID":        binding.BindingID,
+			"status":           service.BindingStateBindingFailed,
+			"originalError":    ret,
+			"persistenceError": err,
+		}).Error("error persisting binding with updated status")
+	} else if err != nil {
+		log.WithFields(log.Fields{
+			"bindingID":        binding.BindingID,
+			"status":           service.BindingStateBindingFailed,
+			"originalError":    ret,
+			"persistenceError": err,
+		}).Fatal("error persisting binding with updated status")
+	}
+	return ret
+}
+
+// formatBindingStepError returns an error describing the failure of the named
+// step of the given operation (i.e. "binding" or "unbinding") for a binding
+func formatBindingStepError(
+	operation string,
+	stepName string,
+	bindingID interface{},
+	e error,
+	msg string,
+) error {
+	if e == nil {
+		return fmt.Errorf(
+			`error executing %s step "%s" for binding "%s": %s`,
+			operation,
+			stepName,
+			bindingID,
+			msg,
+		)
+	}
+	return fmt.Errorf(
+		`error executing %s step "%s" for binding "%s": %s: %s`,
+		operation,
+		stepName,
+		bindingID,
+		msg,
+		e,
+	)
+}
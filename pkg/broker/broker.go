@@ -4,15 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/Azure/open-service-broker-azure/pkg/api"
 	"github.com/Azure/open-service-broker-azure/pkg/api/authenticator"
 	"github.com/Azure/open-service-broker-azure/pkg/async"
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
 	"github.com/Azure/open-service-broker-azure/pkg/crypto"
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 	"github.com/Azure/open-service-broker-azure/pkg/storage"
 	log "github.com/Sirupsen/logrus"
-	"github.com/go-redis/redis"
 )
 
 type errAsyncEngineStopped struct {
@@ -35,7 +36,8 @@ func (e *errAPIServerStopped) Error() string {
 // OSB functionality.
 type Broker interface {
 	// Start starts all broker components (e.g. API server and async execution
-	// engine) and blocks until one of those components returns or fails.
+	// engine) and blocks until one of those components returns or fails and
+	// all of them have shut down.
 	Start(context.Context) error
 }
 
@@ -47,9 +49,13 @@ type broker struct {
 	catalog     service.Catalog
 }
 
-// NewBroker returns a new Broker
+// NewBroker returns a new Broker. The number of tasks for each async job that
+// may execute concurrently can be capped by mapping the job's name (e.g.
+// "deprovisionStep") to a limit in jobsMaxConcurrency.
 func NewBroker(
-	redisClient *redis.Client,
+	store storage.Store,
+	asyncEngine async.Engine,
+	jobsMaxConcurrency map[string]int,
 	codec crypto.Codec,
 	authenticator authenticator.Authenticator,
 	modules []service.Module,
@@ -58,8 +64,8 @@ func NewBroker(
 	defaultAzureResourceGroup string,
 ) (Broker, error) {
 	b := &broker{
-		store:       storage.NewStore(redisClient),
-		asyncEngine: async.NewEngine(redisClient),
+		store:       store,
+		asyncEngine: asyncEngine,
 		codec:       codec,
 	}
 
@@ -94,30 +100,65 @@ func NewBroker(
 			}
 		}
 	}
-	b.catalog = service.NewCatalog(services)
+	var err error
+	if b.catalog, err = service.NewCatalog(services); err != nil {
+		return nil, err
+	}
 
-	err := b.asyncEngine.RegisterJob("provisionStep", b.doProvisionStep)
+	err = b.asyncEngine.RegisterJobWithMaxConcurrency(
+		"provisionStep",
+		b.doProvisionStep,
+		jobsMaxConcurrency["provisionStep"],
+	)
 	if err != nil {
 		return nil, errors.New(
 			"error registering async job for executing provisioning steps",
 		)
 	}
-	err = b.asyncEngine.RegisterJob("updateStep", b.doUpdateStep)
+	err = b.asyncEngine.RegisterJobWithMaxConcurrency(
+		"updateStep",
+		b.doUpdateStep,
+		jobsMaxConcurrency["updateStep"],
+	)
 	if err != nil {
 		return nil, errors.New(
 			"error registering async job for executing updating steps",
 		)
 	}
-	err = b.asyncEngine.RegisterJob("deprovisionStep", b.doDeprovisionStep)
+	err = b.asyncEngine.RegisterJobWithMaxConcurrency(
+		"deprovisionStep",
+		b.doDeprovisionStep,
+		jobsMaxConcurrency["deprovisionStep"],
+	)
 	if err != nil {
 		return nil, errors.New(
 			"error registering async job for executing deprovisioning steps",
 		)
 	}
+	err = b.asyncEngine.RegisterJobWithMaxConcurrency(
+		"bindStep",
+		b.doBindStep,
+		jobsMaxConcurrency["bindStep"],
+	)
+	if err != nil {
+		return nil, errors.New(
+			"error registering async job for executing binding steps",
+		)
+	}
+	err = b.asyncEngine.RegisterJobWithMaxConcurrency(
+		"unbindStep",
+		b.doUnbindStep,
+		jobsMaxConcurrency["unbindStep"],
+	)
+	if err != nil {
+		return nil, errors.New(
+			"error registering async job for executing unbinding steps",
+		)
+	}
 
 	b.apiServer, err = api.NewServer(
 		8080,
-		storage.NewStore(redisClient),
+		b.store,
 		b.asyncEngine,
 		b.codec,
 		authenticator,
@@ -133,30 +174,48 @@ func NewBroker(
 }
 
 // Start starts all broker components (e.g. API server and async execution
-// engine) and blocks until one of those components returns or fails.
+// engine) and blocks until one of those components returns or fails. The
+// remaining components are then stopped and, so that the async engine can
+// drain in-flight tasks before the process exits, waited for.
 func (b *broker) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	errChan := make(chan error)
+	// Buffered so that components that stop after the first one don't block
+	errChan := make(chan error, 2)
+	wg := sync.WaitGroup{}
+	wg.Add(2)
 	// Start async engine
 	go func() {
-		select {
-		case errChan <- &errAsyncEngineStopped{err: b.asyncEngine.Start(ctx)}:
-		case <-ctx.Done():
-		}
+		defer wg.Done()
+		errChan <- &errAsyncEngineStopped{err: b.asyncEngine.Start(ctx)}
 	}()
 	// Start api server
 	go func() {
-		select {
-		case errChan <- &errAPIServerStopped{err: b.apiServer.Start(ctx)}:
-		case <-ctx.Done():
-		}
+		defer wg.Done()
+		errChan <- &errAPIServerStopped{err: b.apiServer.Start(ctx)}
 	}()
+	var err error
 	select {
 	case <-ctx.Done():
 		log.Debug("context canceled; broker shutting down")
-		return ctx.Err()
-	case err := <-errChan:
-		return err
+		err = ctx.Err()
+	case err = <-errChan:
+	}
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// getStepErrorMessage returns a message describing the failure of a step for
+// the given operation (e.g. "provisioning"), distinguishing steps that timed
+// out or were canceled from those that simply failed
+func getStepErrorMessage(ctx context.Context, operation string) string {
+	switch {
+	case model.IsTimedOut(ctx):
+		return fmt.Sprintf("timed out executing %s step", operation)
+	case model.IsCanceled(ctx):
+		return fmt.Sprintf("%s step canceled", operation)
+	default:
+		return fmt.Sprintf("error executing %s step", operation)
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/Azure/open-service-broker-azure/pkg/api/authenticator/always"
 	fakeAPI "github.com/Azure/open-service-broker-azure/pkg/api/fake"
 	fakeAsync "github.com/Azure/open-service-broker-azure/pkg/async/fake"
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 	"github.com/stretchr/testify/assert"
 )
@@ -35,7 +36,6 @@ func TestBrokerStartBlocksUntilAsyncEngineErrors(t *testing.T) {
 	defer cancel()
 	err = b.Start(ctx)
 	assert.Equal(t, &errAsyncEngineStopped{err: errSome}, err)
-	time.Sleep(time.Second)
 	assert.True(t, apiServerStopped)
 }
 
@@ -59,7 +59,6 @@ func TestBrokerStartBlocksUntilAsyncEngineReturns(t *testing.T) {
 	defer cancel()
 	err = b.Start(ctx)
 	assert.Equal(t, &errAsyncEngineStopped{}, err)
-	time.Sleep(time.Second)
 	assert.True(t, apiServerStopped)
 }
 
@@ -83,7 +82,6 @@ func TestBrokerStartBlocksUntilAPIServerErrors(t *testing.T) {
 	defer cancel()
 	err = b.Start(ctx)
 	assert.Equal(t, &errAPIServerStopped{err: errSome}, err)
-	time.Sleep(time.Second)
 	assert.True(t, asyncEngineStopped)
 }
 
@@ -107,7 +105,6 @@ func TestBrokerStartBlocksUntilAPIServerReturns(t *testing.T) {
 	defer cancel()
 	err = b.Start(ctx)
 	assert.Equal(t, &errAPIServerStopped{}, err)
-	time.Sleep(time.Second)
 	assert.True(t, asyncEngineStopped)
 }
 
@@ -134,13 +131,14 @@ func TestBrokerStartBlocksUntilContextCanceled(t *testing.T) {
 	defer cancel()
 	err = b.Start(ctx)
 	assert.Equal(t, ctx.Err(), err)
-	time.Sleep(time.Second)
 	assert.True(t, apiServerStopped)
 	assert.True(t, asyncEngineStopped)
 }
 
 func getTestBroker() (*broker, error) {
 	b, err := NewBroker(
+		nil,
+		fakeAsync.NewEngine(),
 		nil,
 		nil,
 		always.NewAuthenticator(),
@@ -154,3 +152,26 @@ func getTestBroker() (*broker, error) {
 	}
 	return b.(*broker), nil
 }
+
+func TestGetStepErrorMessage(t *testing.T) {
+	ctx, cancel := model.ContextWithCancel(context.Background())
+	assert.Equal(
+		t,
+		"error executing provisioning step",
+		getStepErrorMessage(ctx, "provisioning"),
+	)
+	cancel()
+	assert.Equal(
+		t,
+		"provisioning step canceled",
+		getStepErrorMessage(ctx, "provisioning"),
+	)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	assert.Equal(
+		t,
+		"timed out executing provisioning step",
+		getStepErrorMessage(ctx, "provisioning"),
+	)
+}
@@ -2,9 +2,11 @@ package broker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
+	"github.com/Azure/open-service-broker-azure/pkg/api"
 	"github.com/Azure/open-service-broker-azure/pkg/async/model"
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 	log "github.com/Sirupsen/logrus"
@@ -101,9 +103,39 @@ func (b *broker) doDeprovisionStep(
 			`deprovisioner does not know how to process step "%s"`,
 		)
 	}
-	updatedProvisioningContext, err := step.Execute(
-		ctx,
-		instanceID,
+	if isStepCompleted(instance, stepName) {
+		// The step has already been executed-- by an earlier delivery of the same
+		// task, for instance. Rather than executing it again, just ensure that the
+		// steps that depend on it have been submitted.
+		if instance.Status != service.InstanceStateDeprovisioning {
+			return nil
+		}
+		if err = b.submitNextSteps(
+			deprovisioner,
+			"deprovisionStep",
+			api.OperationDeprovisioning,
+			instance,
+			stepName,
+		); err != nil {
+			return b.handleDeprovisioningError(
+				instance,
+				stepName,
+				err,
+				"error enqueing next steps",
+			)
+		}
+		return nil
+	}
+	originalProvisioningContextJSON, err := json.Marshal(provisioningContext)
+	if err != nil {
+		return b.handleDeprovisioningError(
+			instance,
+			stepName,
+			err,
+			"error encoding provisioningContext",
+		)
+	}
+	skipped, err := step.IsSkipped(
 		plan,
 		instance.StandardProvisioningContext,
 		provisioningContext,
@@ -113,46 +145,72 @@ func (b *broker) doDeprovisionStep(
 			instance,
 			stepName,
 			err,
-			"error executing deprovisioning step",
+			"error evaluating whether to skip step",
+		)
+	}
+	updatedProvisioningContext := provisioningContext
+	recordStep := completeStep(stepName)
+	if skipped {
+		log.WithFields(log.Fields{
+			"step":       stepName,
+			"instanceID": instance.InstanceID,
+		}).Debug("skipping deprovisioning step")
+		recordStep = skipStep(stepName)
+	} else {
+		updatedProvisioningContext, err = step.Execute(
+			ctx,
+			instanceID,
+			plan,
+			instance.StandardProvisioningContext,
+			provisioningContext,
 		)
 	}
-	err = instance.SetProvisioningContext(updatedProvisioningContext, b.codec)
 	if err != nil {
+		if model.WillRetry(ctx, err) {
+			// The async engine will retry the step later. In the meantime, the
+			// instance's status is left alone.
+			return err
+		}
 		return b.handleDeprovisioningError(
 			instance,
 			stepName,
 			err,
-			"error encoding modified provisioningContext",
+			getStepErrorMessage(ctx, "deprovisioning"),
 		)
 	}
-	if nextStepName, ok := deprovisioner.GetNextStepName(step.GetName()); ok {
-		if err = b.store.WriteInstance(instance); err != nil {
+	if instance, err = b.writeInstance(
+		instance,
+		mutations(
+			requireStatus(service.InstanceStateDeprovisioning),
+			b.mergeProvisioningContext(
+				originalProvisioningContextJSON,
+				updatedProvisioningContext,
+			),
+			backfillCompletedSteps(deprovisioner, stepName),
+			recordStep,
+		),
+	); err != nil {
+		if isStatusChanged(err) {
+			// Deprovisioning failed in a concurrent branch while this step was
+			// executing. The instance's status, which already explains the
+			// failure, is left alone.
 			return b.handleDeprovisioningError(
-				instance,
+				instanceID,
 				stepName,
 				err,
 				"error persisting instance",
 			)
 		}
-		task := model.NewTask(
-			"deprovisionStep",
-			map[string]string{
-				"stepName":   nextStepName,
-				"instanceID": instanceID,
-			},
+		return b.handleDeprovisioningError(
+			instance,
+			stepName,
+			err,
+			"error persisting instance",
 		)
-		if err = b.asyncEngine.SubmitTask(task); err != nil {
-			return b.handleDeprovisioningError(
-				instance,
-				stepName,
-				err,
-				fmt.Sprintf(`error enqueing next step: "%s"`, nextStepName),
-			)
-		}
-	} else {
-		// No next step-- we're done deprovisioning!
-		_, err = b.store.DeleteInstance(instance.InstanceID)
-		if err != nil {
+	}
+	if deprovisioner.IsComplete(instance.CompletedSteps) {
+		// All steps have completed-- we're done deprovisioning!
+		if _, err = b.store.DeleteInstance(instance.InstanceID); err != nil {
 			return b.handleDeprovisioningError(
 				instance,
 				stepName,
@@ -160,17 +218,33 @@ func (b *broker) doDeprovisionStep(
 				"error deleting deprovisioned instance",
 			)
 		}
+		return nil
+	}
+	if err = b.submitNextSteps(
+		deprovisioner,
+		"deprovisionStep",
+		api.OperationDeprovisioning,
+		instance,
+		stepName,
+	); err != nil {
+		return b.handleDeprovisioningError(
+			instance,
+			stepName,
+			err,
+			"error enqueing next steps",
+		)
 	}
 	return nil
 }
 
 // handleDeprovisioningError tries to handle async deprovisioning errors. If an
 // instance is passed in, its status is updated and an attempt is made to
-// persist the instance with updated status. If this fails, we have a very
-// serious problem on our hands, so we log that failure and kill the process.
-// Barring such a failure, a nicely formatted error is returned to be, in-turn,
-// returned by the caller of this function. If an instanceID is passed in
-// (instead of an instance), only error formatting is handled.
+// persist the instance with updated status, provided the operation is still in
+// progress. If this fails for any reason other than a concurrent modification,
+// we have a very serious problem on our hands, so we log that failure and kill
+// the process. Barring such a failure, a nicely formatted error is returned to
+// be, in-turn, returned by the caller of this function. If an instanceID is
+// passed in (instead of an instance), only error formatting is handled.
 func (b *broker) handleDeprovisioningError(
 	instanceOrInstanceID interface{},
 	stepName string,
@@ -197,7 +271,6 @@ func (b *broker) handleDeprovisioningError(
 		)
 	}
 	// If we get to here, we have an instance (not just and instanceID)
-	instance.Status = service.InstanceStateDeprovisioningFailed
 	var ret error
 	if e == nil {
 		ret = fmt.Errorf(
@@ -215,11 +288,36 @@ func (b *broker) handleDeprovisioningError(
 			e,
 		)
 	}
-	instance.StatusReason = ret.Error()
-	if err := b.store.WriteInstance(instance); err != nil {
+	_, err := b.writeInstance(
+		instance,
+		mutations(
+			requireStatus(service.InstanceStateDeprovisioning),
+			func(i *service.Instance) error {
+				i.Status = service.InstanceStateDeprovisioningFailed
+				i.StatusReason = ret.Error()
+				return nil
+			},
+		),
+	)
+	if isStatusChanged(err) {
+		// The operation already concluded or another one has since begun, so
+		// the failure is not recorded
+		log.WithFields(log.Fields{
+			"instanceID":    instance.InstanceID,
+			"originalError": ret,
+			"reason":        err,
+		}).Warn("not persisting instance with updated status")
+	} else if isConflict(err) {
+		log.WithFields(log.Fields{
+			"instanceID":       instance.InstanceID,
+			"status":           service.InstanceStateDeprovisioningFailed,
+			"originalError":    ret,
+			"persistenceError": err,
+		}).Error("error persisting instance with updated status")
+	} else if err != nil {
 		log.WithFields(log.Fields{
 			"instanceID":       instance.InstanceID,
-			"status":           instance.Status,
+			"status":           service.InstanceStateDeprovisioningFailed,
 			"originalError":    ret,
 			"persistenceError": err,
 		}).Fatal("error persisting instance with updated status")
@@ -0,0 +1,190 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
+	log "github.com/Sirupsen/logrus"
+)
+
+// maxWriteAttempts bounds the number of times writeInstance will attempt to
+// persist an instance that is being concurrently modified
+const maxWriteAttempts = 5
+
+// instanceMutation is the signature for functions that apply a modification to
+// an instance. Such functions may be invoked more than once-- each time against
+// a freshly loaded revision of the instance-- so they must not depend on any
+// state of the instance beyond what they, themselves, verify.
+type instanceMutation func(*service.Instance) error
+
+// writeInstance applies the given mutation to the given instance and persists
+// it. If persisting fails because the instance was modified concurrently, the
+// latest revision of the instance is loaded, the mutation is re-applied to it,
+// and the write is retried a bounded number of times. The instance that was
+// ultimately persisted (or that the last attempt was made with) is returned.
+func (b *broker) writeInstance(
+	instance *service.Instance,
+	mutate instanceMutation,
+) (*service.Instance, error) {
+	if err := mutate(instance); err != nil {
+		return instance, err
+	}
+	for attempt := 1; ; attempt++ {
+		err := b.store.WriteInstance(instance)
+		if _, ok := err.(*storage.ConflictError); !ok ||
+			attempt == maxWriteAttempts {
+			return instance, err
+		}
+		log.WithFields(log.Fields{
+			"instanceID": instance.InstanceID,
+			"attempt":    attempt,
+		}).Debug("instance was modified concurrently; retrying write")
+		latestInstance, ok, err := b.store.GetInstance(instance.InstanceID)
+		if err != nil {
+			return instance, err
+		}
+		if !ok {
+			return instance, fmt.Errorf(
+				`instance "%s" was concurrently deleted`,
+				instance.InstanceID,
+			)
+		}
+		if err := mutate(latestInstance); err != nil {
+			return latestInstance, err
+		}
+		instance = latestInstance
+	}
+}
+
+// errStatusChanged is returned by mutations created with requireStatus or
+// requireBindingStatus when an instance's or binding's status is no longer the
+// expected one
+type errStatusChanged struct {
+	kind     string
+	expected string
+	actual   string
+}
+
+func (e *errStatusChanged) Error() string {
+	return fmt.Sprintf(
+		`%s status was concurrently changed from "%s" to "%s"`,
+		e.kind,
+		e.expected,
+		e.actual,
+	)
+}
+
+// isStatusChanged returns a bool indicating whether the given error is an
+// errStatusChanged
+func isStatusChanged(err error) bool {
+	_, ok := err.(*errStatusChanged)
+	return ok
+}
+
+// isConflict returns a bool indicating whether the given error is a
+// storage.ConflictError-- i.e. whether a write failed because the record was
+// being concurrently modified
+func isConflict(err error) bool {
+	_, ok := err.(*storage.ConflictError)
+	return ok
+}
+
+// requireStatus returns an instanceMutation that fails if the instance's
+// status is no longer the expected one. It is used to guard mutations that are
+// only valid while an operation is still in progress.
+func requireStatus(status string) instanceMutation {
+	return func(instance *service.Instance) error {
+		if instance.Status != status {
+			return &errStatusChanged{
+				kind:     "instance",
+				expected: status,
+				actual:   instance.Status,
+			}
+		}
+		return nil
+	}
+}
+
+// mutations returns an instanceMutation that applies all of the given
+// mutations, in order, stopping at the first failure
+func mutations(fns ...instanceMutation) instanceMutation {
+	return func(instance *service.Instance) error {
+		for _, fn := range fns {
+			if err := fn(instance); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// bindingMutation is the signature for functions that apply a modification to
+// a binding. Like instanceMutations, they may be invoked more than once-- each
+// time against a freshly loaded revision of the binding.
+type bindingMutation func(*service.Binding) error
+
+// writeBinding applies the given mutation to the given binding and persists it,
+// retrying a bounded number of times if the binding was modified concurrently.
+// See writeInstance.
+func (b *broker) writeBinding(
+	binding *service.Binding,
+	mutate bindingMutation,
+) (*service.Binding, error) {
+	if err := mutate(binding); err != nil {
+		return binding, err
+	}
+	for attempt := 1; ; attempt++ {
+		err := b.store.WriteBinding(binding)
+		if _, ok := err.(*storage.ConflictError); !ok ||
+			attempt == maxWriteAttempts {
+			return binding, err
+		}
+		log.WithFields(log.Fields{
+			"bindingID": binding.BindingID,
+			"attempt":   attempt,
+		}).Debug("binding was modified concurrently; retrying write")
+		latestBinding, ok, err := b.store.GetBinding(binding.BindingID)
+		if err != nil {
+			return binding, err
+		}
+		if !ok {
+			return binding, fmt.Errorf(
+				`binding "%s" was concurrently deleted`,
+				binding.BindingID,
+			)
+		}
+		if err := mutate(latestBinding); err != nil {
+			return latestBinding, err
+		}
+		binding = latestBinding
+	}
+}
+
+// requireBindingStatus returns a bindingMutation that fails if the binding's
+// status is no longer the expected one
+func requireBindingStatus(status string) bindingMutation {
+	return func(binding *service.Binding) error {
+		if binding.Status != status {
+			return &errStatusChanged{
+				kind:     "binding",
+				expected: status,
+				actual:   binding.Status,
+			}
+		}
+		return nil
+	}
+}
+
+// bindingMutations returns a bindingMutation that applies all of the given
+// mutations, in order, stopping at the first failure
+func bindingMutations(fns ...bindingMutation) bindingMutation {
+	return func(binding *service.Binding) error {
+		for _, fn := range fns {
+			if err := fn(binding); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
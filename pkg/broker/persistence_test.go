@@ -0,0 +1,95 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	memoryStorage "github.com/Azure/open-service-broker-azure/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteInstanceRetriesOnConflict(t *testing.T) {
+	b, err := getTestBroker()
+	assert.Nil(t, err)
+	b.store = memoryStorage.NewStore()
+	instance := &service.Instance{
+		InstanceID: "foo",
+		Status:     service.InstanceStateProvisioning,
+	}
+	err = b.store.WriteInstance(instance)
+	assert.Nil(t, err)
+	staleInstance := *instance
+	// Concurrently modify the instance
+	instance.StatusReason = "concurrent modification"
+	err = b.store.WriteInstance(instance)
+	assert.Nil(t, err)
+	// Writing the stale copy should succeed after reloading the instance and
+	// re-applying the mutation
+	persistedInstance, err := b.writeInstance(
+		&staleInstance,
+		func(i *service.Instance) error {
+			i.Status = service.InstanceStateProvisioned
+			return nil
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, service.InstanceStateProvisioned, persistedInstance.Status)
+	assert.Equal(t, "concurrent modification", persistedInstance.StatusReason)
+	assert.Equal(t, 3, persistedInstance.Revision)
+}
+
+func TestWriteInstanceFailsWhenStatusChangedConcurrently(t *testing.T) {
+	b, err := getTestBroker()
+	assert.Nil(t, err)
+	b.store = memoryStorage.NewStore()
+	instance := &service.Instance{
+		InstanceID: "foo",
+		Status:     service.InstanceStateProvisioning,
+	}
+	err = b.store.WriteInstance(instance)
+	assert.Nil(t, err)
+	staleInstance := *instance
+	// Concurrently modify the instance's status
+	instance.Status = service.InstanceStateDeprovisioning
+	err = b.store.WriteInstance(instance)
+	assert.Nil(t, err)
+	_, err = b.writeInstance(
+		&staleInstance,
+		requireStatus(service.InstanceStateProvisioning),
+	)
+	assert.True(t, isStatusChanged(err))
+	persistedInstance, ok, err := b.store.GetInstance("foo")
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, service.InstanceStateDeprovisioning, persistedInstance.Status)
+}
+
+func TestWriteBindingFailsWhenStatusChangedConcurrently(t *testing.T) {
+	b, err := getTestBroker()
+	assert.Nil(t, err)
+	b.store = memoryStorage.NewStore()
+	binding := &service.Binding{
+		BindingID: "foo",
+		Status:    service.BindingStateBinding,
+	}
+	err = b.store.WriteBinding(binding)
+	assert.Nil(t, err)
+	staleBinding := *binding
+	// Concurrently modify the binding's status
+	binding.Status = service.BindingStateBindingFailed
+	err = b.store.WriteBinding(binding)
+	assert.Nil(t, err)
+	_, err = b.writeBinding(
+		&staleBinding,
+		bindingMutations(
+			requireBindingStatus(service.BindingStateBinding),
+			completeBindingStep("bar"),
+		),
+	)
+	assert.True(t, isStatusChanged(err))
+	persistedBinding, ok, err := b.store.GetBinding("foo")
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, service.BindingStateBindingFailed, persistedBinding.Status)
+	assert.Empty(t, persistedBinding.CompletedSteps)
+}
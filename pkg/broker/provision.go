@@ -2,9 +2,12 @@ package broker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/Azure/open-service-broker-azure/pkg/api"
 	"github.com/Azure/open-service-broker-azure/pkg/async/model"
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 	log "github.com/Sirupsen/logrus"
@@ -111,9 +114,39 @@ func (b *broker) doProvisionStep(
 			`provisioner does not know how to process step "%s"`,
 		)
 	}
-	updatedProvisioningContext, err := step.Execute(
-		ctx,
-		instanceID,
+	if isStepCompleted(instance, stepName) {
+		// The step has already been executed-- by an earlier delivery of the same
+		// task, for instance. Rather than executing it again, just ensure that the
+		// steps that depend on it have been submitted.
+		if instance.Status != service.InstanceStateProvisioning {
+			return nil
+		}
+		if err = b.submitNextSteps(
+			provisioner,
+			"provisionStep",
+			api.OperationProvisioning,
+			instance,
+			stepName,
+		); err != nil {
+			return b.handleProvisioningError(
+				instance,
+				stepName,
+				err,
+				"error enqueing next steps",
+			)
+		}
+		return nil
+	}
+	originalProvisioningContextJSON, err := json.Marshal(provisioningContext)
+	if err != nil {
+		return b.handleProvisioningError(
+			instance,
+			stepName,
+			err,
+			"error encoding provisioningContext",
+		)
+	}
+	skipped, err := step.IsSkipped(
 		plan,
 		instance.StandardProvisioningContext,
 		provisioningContext,
@@ -124,64 +157,257 @@ func (b *broker) doProvisionStep(
 			instance,
 			stepName,
 			err,
-			"error executing provisioning step",
+			"error evaluating whether to skip step",
+		)
+	}
+	updatedProvisioningContext := provisioningContext
+	recordStep := completeStep(stepName)
+	if skipped {
+		log.WithFields(log.Fields{
+			"step":       stepName,
+			"instanceID": instance.InstanceID,
+		}).Debug("skipping provisioning step")
+		recordStep = skipStep(stepName)
+	} else {
+		updatedProvisioningContext, err = step.Execute(
+			ctx,
+			instanceID,
+			plan,
+			instance.StandardProvisioningContext,
+			provisioningContext,
+			provisioningParams,
 		)
 	}
-	err = instance.SetProvisioningContext(updatedProvisioningContext, b.codec)
 	if err != nil {
-		return b.handleProvisioningError(
+		if model.WillRetry(ctx, err) {
+			// The async engine will retry the step later. In the meantime, the
+			// instance's status is left alone.
+			return err
+		}
+		// The step failed permanently. Before the steps that completed before it
+		// are rolled back, the instance is marked as failed so that steps still
+		// executing in concurrent branches roll themselves back instead of
+		// recording their completion.
+		failedInstance, writeErr := b.writeInstance(
 			instance,
-			stepName,
-			err,
-			"error encoding modified provisioningContext",
+			mutations(
+				requireStatus(service.InstanceStateProvisioning),
+				// The steps that completed before the failed one are rolled back, so
+				// they must all be accounted for
+				backfillCompletedSteps(provisioner, stepName),
+				func(i *service.Instance) error {
+					i.Status = service.InstanceStateProvisioningFailed
+					return nil
+				},
+			),
 		)
-	}
-	if nextStepName, ok := provisioner.GetNextStepName(step.GetName()); ok {
-		if err = b.store.WriteInstance(instance); err != nil {
+		if isStatusChanged(writeErr) {
+			// Provisioning already failed in a concurrent branch, which is
+			// responsible for rolling back completed steps
 			return b.handleProvisioningError(
-				instance,
+				instanceID,
 				stepName,
 				err,
+				getStepErrorMessage(ctx, "provisioning"),
+			)
+		} else if writeErr != nil {
+			return b.handleProvisioningError(
+				instance,
+				stepName,
+				writeErr,
 				"error persisting instance",
 			)
 		}
-		task := model.NewTask(
-			"provisionStep",
-			map[string]string{
-				"stepName":   nextStepName,
-				"instanceID": instanceID,
-			},
-		)
-		if err = b.asyncEngine.SubmitTask(task); err != nil {
+		instance = failedInstance
+		completedProvisioningContext :=
+			serviceManager.GetEmptyProvisioningContext()
+		if decodeErr := instance.GetProvisioningContext(
+			completedProvisioningContext,
+			b.codec,
+		); decodeErr != nil {
 			return b.handleProvisioningError(
 				instance,
 				stepName,
-				err,
-				fmt.Sprintf(`error enqueing next step: "%s"`, nextStepName),
+				decodeErr,
+				"error decoding provisioningContext from persisted instance",
 			)
 		}
-	} else {
-		// No next step-- we're done provisioning!
-		instance.Status = service.InstanceStateProvisioned
-		if err = b.store.WriteInstance(instance); err != nil {
+		if outcome := compensateProvisioningSteps(
+			provisioner,
+			getExecutedStepNames(instance),
+			instanceID,
+			plan,
+			instance.StandardProvisioningContext,
+			completedProvisioningContext,
+			provisioningParams,
+		); outcome != "" {
+			err = fmt.Errorf("%s; %s", err, outcome)
+		}
+		return b.handleProvisioningError(
+			instance,
+			stepName,
+			err,
+			getStepErrorMessage(ctx, "provisioning"),
+		)
+	}
+	if instance, err = b.writeInstance(
+		instance,
+		mutations(
+			requireStatus(service.InstanceStateProvisioning),
+			b.mergeProvisioningContext(
+				originalProvisioningContextJSON,
+				updatedProvisioningContext,
+			),
+			backfillCompletedSteps(provisioner, stepName),
+			recordStep,
+			func(i *service.Instance) error {
+				if provisioner.IsComplete(i.CompletedSteps) {
+					// All steps have completed-- we're done provisioning!
+					i.Status = service.InstanceStateProvisioned
+				}
+				return nil
+			},
+		),
+	); err != nil {
+		if isStatusChanged(err) {
+			// Provisioning failed while this step was executing because a step in
+			// a concurrent branch failed. The steps that had completed are rolled
+			// back by that branch, so this step rolls itself back (unless it was
+			// skipped) and leaves the instance's status, which already explains
+			// the failure, alone.
+			if !skipped {
+				if outcome := compensateProvisioningSteps(
+					provisioner,
+					[]string{stepName},
+					instanceID,
+					plan,
+					instance.StandardProvisioningContext,
+					updatedProvisioningContext,
+					provisioningParams,
+				); outcome != "" {
+					log.WithFields(log.Fields{
+						"step":       stepName,
+						"instanceID": instanceID,
+					}).Warn(outcome)
+				}
+			}
 			return b.handleProvisioningError(
-				instance,
+				instanceID,
 				stepName,
 				err,
 				"error persisting instance",
 			)
 		}
+		return b.handleProvisioningError(
+			instance,
+			stepName,
+			err,
+			"error persisting instance",
+		)
+	}
+	if err = b.submitNextSteps(
+		provisioner,
+		"provisionStep",
+		api.OperationProvisioning,
+		instance,
+		stepName,
+	); err != nil {
+		return b.handleProvisioningError(
+			instance,
+			stepName,
+			err,
+			"error enqueing next steps",
+		)
 	}
 	return nil
 }
 
+// compensateProvisioningSteps undoes, in the reverse of the order in which they
+// completed, the effects of the named provisioning steps. It is used when a
+// step fails permanently to roll back the steps that completed before it; the
+// failed step itself is not compensated. Compensation is best effort-- if
+// one step's compensation fails, compensation of the remaining steps is still
+// attempted. A description of the outcome, suitable for inclusion in the
+// instance's status reason, is returned. If none of the named steps declares a
+// compensation, an empty string is returned.
+func compensateProvisioningSteps(
+	provisioner service.Provisioner,
+	completedStepNames []string,
+	instanceID string,
+	plan service.Plan,
+	standardProvisioningContext service.StandardProvisioningContext,
+	provisioningContext service.ProvisioningContext,
+	provisioningParams service.ProvisioningParameters,
+) string {
+	// The failed step's context may have timed out or been canceled, so
+	// compensations execute with a fresh one
+	ctx := context.Background()
+	compensatedStepNames := []string{}
+	failures := []string{}
+	for i := len(completedStepNames) - 1; i >= 0; i-- {
+		stepName := completedStepNames[i]
+		step, found := provisioner.GetStep(stepName)
+		if !found || !step.HasCompensation() {
+			continue
+		}
+		logFields := log.Fields{
+			"step":       stepName,
+			"instanceID": instanceID,
+		}
+		log.WithFields(logFields).Debug("compensating provisioning step")
+		if err := step.Compensate(
+			ctx,
+			instanceID,
+			plan,
+			standardProvisioningContext,
+			provisioningContext,
+			provisioningParams,
+		); err != nil {
+			logFields["error"] = err
+			log.WithFields(logFields).Error(
+				"error compensating provisioning step",
+			)
+			failures = append(failures, fmt.Sprintf(`"%s": %s`, stepName, err))
+			continue
+		}
+		compensatedStepNames = append(compensatedStepNames, stepName)
+	}
+	if len(failures) > 0 {
+		return fmt.Sprintf(
+			"error rolling back provisioning steps; manual cleanup may be "+
+				"required: %s",
+			strings.Join(failures, "; "),
+		)
+	}
+	if len(compensatedStepNames) > 0 {
+		return fmt.Sprintf(
+			"rolled back provisioning steps: %s",
+			strings.Join(compensatedStepNames, ", "),
+		)
+	}
+	return ""
+}
+
+// requireProvisioningNotConcluded is an instanceMutation that fails unless
+// provisioning is still in progress or has failed without the failure having
+// been explained yet-- as is the case while a failed step rolls back the steps
+// that completed before it
+func requireProvisioningNotConcluded(instance *service.Instance) error {
+	if instance.Status == service.InstanceStateProvisioningFailed &&
+		instance.StatusReason == "" {
+		return nil
+	}
+	return requireStatus(service.InstanceStateProvisioning)(instance)
+}
+
 // handleProvisioningError tries to handle async provisioning errors. If an
 // instance is passed in, its status is updated and an attempt is made to
-// persist the instance with updated status. If this fails, we have a very
-// serious problem on our hands, so we log that failure and kill the process.
-// Barring such a failure, a nicely formatted error is returned to be, in-turn,
-// returned by the caller of this function. If an instanceID is passed in
-// (instead of an instance), only error formatting is handled.
+// persist the instance with updated status, provided the operation is still in
+// progress. If this fails for any reason other than a concurrent modification,
+// we have a very serious problem on our hands, so we log that failure and kill
+// the process. Barring such a failure, a nicely formatted error is returned to
+// be, in-turn, returned by the caller of this function. If an instanceID is
+// passed in (instead of an instance), only error formatting is handled.
 func (b *broker) handleProvisioningError(
 	instanceOrInstanceID interface{},
 	stepName string,
@@ -208,7 +434,6 @@ func (b *broker) handleProvisioningError(
 		)
 	}
 	// If we get to here, we have an instance (not just an instanceID)
-	instance.Status = service.InstanceStateProvisioningFailed
 	var ret error
 	if e == nil {
 		ret = fmt.Errorf(
@@ -226,11 +451,36 @@ func (b *broker) handleProvisioningError(
 			e,
 		)
 	}
-	instance.StatusReason = ret.Error()
-	if err := b.store.WriteInstance(instance); err != nil {
+	_, err := b.writeInstance(
+		instance,
+		mutations(
+			requireProvisioningNotConcluded,
+			func(i *service.Instance) error {
+				i.Status = service.InstanceStateProvisioningFailed
+				i.StatusReason = ret.Error()
+				return nil
+			},
+		),
+	)
+	if isStatusChanged(err) {
+		// The operation already concluded or another one has since begun, so
+		// the failure is not recorded
+		log.WithFields(log.Fields{
+			"instanceID":    instance.InstanceID,
+			"originalError": ret,
+			"reason":        err,
+		}).Warn("not persisting instance with updated status")
+	} else if isConflict(err) {
+		log.WithFields(log.Fields{
+			"instanceID":       instance.InstanceID,
+			"status":           service.InstanceStateProvisioningFailed,
+			"originalError":    ret,
+			"persistenceError": err,
+		}).Error("error persisting instance with updated status")
+	} else if err != nil {
 		log.WithFields(log.Fields{
 			"instanceID":       instance.InstanceID,
-			"status":           instance.Status,
+			"status":           service.InstanceStateProvisioningFailed,
 			"originalError":    ret,
 			"persistenceError": err,
 		}).Fatal("error persisting instance with updated status")
@@ -0,0 +1,337 @@
+package broker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/noop"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/services/fake"
+	"github.com/Azure/open-service-broker-azure/pkg/services/postgresqldb"
+	memoryStorage "github.com/Azure/open-service-broker-azure/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// twoStepServiceManager is a fake ServiceManager whose provisioner executes
+// two steps, one after the other
+type twoStepServiceManager struct {
+	*fake.ServiceManager
+}
+
+func (t *twoStepServiceManager) GetProvisioner(
+	service.Plan,
+) (service.Provisioner, error) {
+	return service.NewProvisioner(
+		service.NewProvisioningStep("foo", provisionNothing),
+		service.NewProvisioningStep("bar", provisionNothing),
+	)
+}
+
+// recordingARMDeployer is a fake arm.Deployer that records the deployments it
+// is asked to delete
+type recordingARMDeployer struct {
+	outputs                map[string]interface{}
+	deletedDeploymentNames []string
+}
+
+func (r *recordingARMDeployer) Deploy(
+	string, // deploymentName
+	string, // resourceGroupName
+	string, // location
+	[]byte, // template
+	interface{}, // goParams
+	map[string]interface{}, // armParams
+	map[string]string, // tags
+) (map[string]interface{}, error) {
+	return r.outputs, nil
+}
+
+func (r *recordingARMDeployer) Delete(
+	deploymentName string,
+	_ string, // resourceGroupName
+) error {
+	r.deletedDeploymentNames = append(
+		r.deletedDeploymentNames,
+		deploymentName,
+	)
+	return nil
+}
+
+// recordingPostgreSQLManager is a fake postgresql.Manager that records the
+// servers it is asked to delete
+type recordingPostgreSQLManager struct {
+	deletedServerNames []string
+}
+
+func (r *recordingPostgreSQLManager) DeleteServer(
+	serverName string,
+	_ string, // resourceGroupName
+) error {
+	r.deletedServerNames = append(r.deletedServerNames, serverName)
+	return nil
+}
+
+func provisionNothing(
+	_ context.Context,
+	_ string, // instanceID
+	_ service.Plan,
+	_ service.StandardProvisioningContext,
+	provisioningContext service.ProvisioningContext,
+	_ service.ProvisioningParameters,
+) (service.ProvisioningContext, error) {
+	return provisioningContext, nil
+}
+
+func TestCompensateProvisioningSteps(t *testing.T) {
+	compensatedStepNames := []string{}
+	getCompensation := func(
+		stepName string,
+		err error,
+	) service.ProvisioningCompensationFunction {
+		return func(
+			context.Context,
+			string,
+			service.Plan,
+			service.StandardProvisioningContext,
+			service.ProvisioningContext,
+			service.ProvisioningParameters,
+		) error {
+			compensatedStepNames = append(compensatedStepNames, stepName)
+			return err
+		}
+	}
+	testCases := []struct {
+		name            string
+		compensationErr error
+		expectedOutcome string
+	}{
+		{
+			name:            "compensations succeed",
+			expectedOutcome: "rolled back provisioning steps: bar, foo",
+		},
+		{
+			name:            "compensations fail",
+			compensationErr: errSome,
+			expectedOutcome: `error rolling back provisioning steps; manual ` +
+				`cleanup may be required: "bar": an error; "foo": an error`,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			compensatedStepNames = []string{}
+			provisioner, err := service.NewProvisioner(
+				service.NewProvisioningStepWithCompensation(
+					"foo",
+					nil,
+					getCompensation("foo", testCase.compensationErr),
+				),
+				// Steps without compensations are skipped
+				service.NewProvisioningStep("bat", nil),
+				service.NewProvisioningStepWithCompensation(
+					"bar",
+					nil,
+					getCompensation("bar", testCase.compensationErr),
+				),
+				// Steps that didn't complete aren't compensated
+				service.NewProvisioningStepWithCompensation(
+					"baz",
+					nil,
+					getCompensation("baz", testCase.compensationErr),
+				),
+			)
+			assert.Nil(t, err)
+			outcome := compensateProvisioningSteps(
+				provisioner,
+				[]string{"foo", "bat", "bar"},
+				"instance-id",
+				nil,
+				service.StandardProvisioningContext{},
+				nil,
+				nil,
+			)
+			assert.Equal(t, testCase.expectedOutcome, outcome)
+			assert.Equal(t, []string{"bar", "foo"}, compensatedStepNames)
+		})
+	}
+}
+
+func TestCompensateProvisioningStepsWithoutCompensations(t *testing.T) {
+	provisioner, err := service.NewProvisioner(
+		service.NewProvisioningStep("foo", nil),
+		service.NewProvisioningStep("bar", nil),
+	)
+	assert.Nil(t, err)
+	outcome := compensateProvisioningSteps(
+		provisioner,
+		[]string{"foo", "bar"},
+		"instance-id",
+		nil,
+		service.StandardProvisioningContext{},
+		nil,
+		nil,
+	)
+	assert.Empty(t, outcome)
+}
+
+func TestHandleProvisioningError(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		status               string
+		statusReason         string
+		expectedStatus       string
+		expectedReasonChange bool
+	}{
+		{
+			name:                 "provisioning in progress",
+			status:               service.InstanceStateProvisioning,
+			expectedStatus:       service.InstanceStateProvisioningFailed,
+			expectedReasonChange: true,
+		},
+		{
+			name:                 "failure not yet explained",
+			status:               service.InstanceStateProvisioningFailed,
+			expectedStatus:       service.InstanceStateProvisioningFailed,
+			expectedReasonChange: true,
+		},
+		{
+			name:           "failure already explained",
+			status:         service.InstanceStateProvisioningFailed,
+			statusReason:   "an earlier failure",
+			expectedStatus: service.InstanceStateProvisioningFailed,
+		},
+		{
+			name:           "deprovisioning begun concurrently",
+			status:         service.InstanceStateDeprovisioning,
+			expectedStatus: service.InstanceStateDeprovisioning,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			b, err := getTestBroker()
+			assert.Nil(t, err)
+			b.store = memoryStorage.NewStore()
+			instance := &service.Instance{
+				InstanceID: "foo",
+				Status:     service.InstanceStateProvisioning,
+			}
+			err = b.store.WriteInstance(instance)
+			assert.Nil(t, err)
+			staleInstance := *instance
+			// Concurrently modify the instance
+			instance.Status = testCase.status
+			instance.StatusReason = testCase.statusReason
+			err = b.store.WriteInstance(instance)
+			assert.Nil(t, err)
+			err = b.handleProvisioningError(&staleInstance, "bar", nil, "baz")
+			assert.NotNil(t, err)
+			persistedInstance, ok, err := b.store.GetInstance("foo")
+			assert.True(t, ok)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expectedStatus, persistedInstance.Status)
+			if testCase.expectedReasonChange {
+				assert.Contains(t, persistedInstance.StatusReason, "baz")
+			} else {
+				assert.Equal(t, testCase.statusReason, persistedInstance.StatusReason)
+			}
+		})
+	}
+}
+
+func TestProvisionStepCompletesLegacyOperation(t *testing.T) {
+	b, err := getTestBroker()
+	assert.Nil(t, err)
+	b.codec = noop.NewCodec()
+	b.store = memoryStorage.NewStore()
+	fakeModule, err := fake.New()
+	assert.Nil(t, err)
+	b.catalog, err = service.NewCatalog([]service.Service{
+		service.NewService(
+			&service.ServiceProperties{
+				ID: "test-service-id",
+			},
+			&twoStepServiceManager{
+				ServiceManager: fakeModule.ServiceManager,
+			},
+			service.NewPlan(&service.PlanProperties{
+				ID: "test-plan-id",
+			}),
+		),
+	})
+	assert.Nil(t, err)
+	// An instance whose provisioning was begun by a version of the broker that
+	// didn't record completed steps and that has made it to the last step
+	err = b.store.WriteInstance(&service.Instance{
+		InstanceID: "test-instance-id",
+		ServiceID:  "test-service-id",
+		PlanID:     "test-plan-id",
+		Status:     service.InstanceStateProvisioning,
+	})
+	assert.Nil(t, err)
+	err = b.doProvisionStep(
+		context.Background(),
+		map[string]string{
+			"stepName":   "bar",
+			"instanceID": "test-instance-id",
+		},
+	)
+	assert.Nil(t, err)
+	instance, ok, err := b.store.GetInstance("test-instance-id")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, service.InstanceStateProvisioned, instance.Status)
+	assert.Equal(t, []string{"foo", "bar"}, instance.CompletedSteps)
+}
+
+func TestProvisionStepFailureRollsBackDeployment(t *testing.T) {
+	b, err := getTestBroker()
+	assert.Nil(t, err)
+	b.codec = noop.NewCodec()
+	b.store = memoryStorage.NewStore()
+	armDeployer := &recordingARMDeployer{
+		// A domain name that can't be parsed makes setupDatabase fail right
+		// away, without attempting to connect to anything
+		outputs: map[string]interface{}{"fullyQualifiedDomainName": "%zz"},
+	}
+	postgresqlManager := &recordingPostgreSQLManager{}
+	b.catalog, err = postgresqldb.New(
+		armDeployer,
+		postgresqlManager,
+	).GetCatalog()
+	assert.Nil(t, err)
+	svc := b.catalog.GetServices()[0]
+	err = b.store.WriteInstance(&service.Instance{
+		InstanceID: "test-instance-id",
+		ServiceID:  svc.GetID(),
+		PlanID:     svc.GetPlans()[0].GetID(),
+		StandardProvisioningContext: service.StandardProvisioningContext{
+			ResourceGroup: "test-resource-group",
+		},
+		Status: service.InstanceStateProvisioning,
+	})
+	assert.Nil(t, err)
+	for _, stepName := range []string{"preProvision", "deployARMTemplate"} {
+		err = b.doProvisionStep(
+			context.Background(),
+			map[string]string{
+				"stepName":   stepName,
+				"instanceID": "test-instance-id",
+			},
+		)
+		assert.Nil(t, err)
+	}
+	err = b.doProvisionStep(
+		context.Background(),
+		map[string]string{
+			"stepName":   "setupDatabase",
+			"instanceID": "test-instance-id",
+		},
+	)
+	assert.NotNil(t, err)
+	instance, ok, err := b.store.GetInstance("test-instance-id")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, service.InstanceStateProvisioningFailed, instance.Status)
+	assert.Contains(t, instance.StatusReason, "rolled back provisioning steps")
+	assert.Len(t, armDeployer.deletedDeploymentNames, 1)
+	assert.Len(t, postgresqlManager.deletedServerNames, 1)
+}
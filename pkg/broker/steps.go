@@ -0,0 +1,267 @@
+package broker
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/Azure/open-service-broker-azure/pkg/api"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+)
+
+// isStepCompleted returns a bool indicating whether the named step of the
+// operation in progress has already been completed for the given instance
+func isStepCompleted(instance *service.Instance, stepName string) bool {
+	for _, completedStepName := range instance.CompletedSteps {
+		if completedStepName == stepName {
+			return true
+		}
+	}
+	return false
+}
+
+// completeStep returns an instanceMutation that records the completion of the
+// named step of the operation in progress
+func completeStep(stepName string) instanceMutation {
+	return func(instance *service.Instance) error {
+		if !isStepCompleted(instance, stepName) {
+			instance.CompletedSteps = append(instance.CompletedSteps, stepName)
+		}
+		return nil
+	}
+}
+
+// backfillCompletedSteps returns an instanceMutation that accounts for
+// operations begun by versions of the broker that predate the tracking of
+// completed steps. Those versions executed steps strictly one after another,
+// in order, and recorded none of them. If no steps have been recorded as
+// completed but the named step depends on others, every step preceding it is
+// recorded as completed. Without this, such an operation could never be found
+// to be complete.
+func backfillCompletedSteps(
+	stepGraph service.StepGraph,
+	stepName string,
+) instanceMutation {
+	return func(instance *service.Instance) error {
+		if len(instance.CompletedSteps) > 0 {
+			return nil
+		}
+		for _, firstStepName := range stepGraph.GetFirstStepNames() {
+			if firstStepName == stepName {
+				return nil
+			}
+		}
+		for _, precedingStepName := range stepGraph.GetStepNames() {
+			if precedingStepName == stepName {
+				break
+			}
+			instance.CompletedSteps = append(
+				instance.CompletedSteps,
+				precedingStepName,
+			)
+		}
+		return nil
+	}
+}
+
+// skipStep returns an instanceMutation that records that the named step of the
+// operation in progress was skipped. A skipped step is also recorded as
+// completed so that the steps that depend on it aren't held up.
+func skipStep(stepName string) instanceMutation {
+	return func(instance *service.Instance) error {
+		if !isStepCompleted(instance, stepName) {
+			instance.CompletedSteps = append(instance.CompletedSteps, stepName)
+			instance.SkippedSteps = append(instance.SkippedSteps, stepName)
+		}
+		return nil
+	}
+}
+
+// getExecutedStepNames returns the names of the completed steps of the
+// operation in progress that weren't skipped, in the order they completed
+func getExecutedStepNames(instance *service.Instance) []string {
+	executedStepNames := []string{}
+	for _, stepName := range instance.CompletedSteps {
+		skipped := false
+		for _, skippedStepName := range instance.SkippedSteps {
+			if skippedStepName == stepName {
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			executedStepNames = append(executedStepNames, stepName)
+		}
+	}
+	return executedStepNames
+}
+
+// mergeProvisioningContext returns an instanceMutation that applies to an
+// instance's provisioning context only the changes that a step made to it.
+// Steps in concurrent branches each begin from the provisioning context as it
+// was when they started, so overwriting the context wholesale would discard
+// changes made by other branches in the meantime. Changes are detected and
+// merged one top-level field at a time, so concurrent steps must not modify
+// the same fields.
+func (b *broker) mergeProvisioningContext(
+	originalJSON []byte,
+	updated service.ProvisioningContext,
+) instanceMutation {
+	return func(instance *service.Instance) error {
+		merged, err := mergeContext(
+			originalJSON,
+			updated,
+			func(current interface{}) error {
+				return instance.GetProvisioningContext(current, b.codec)
+			},
+		)
+		if err != nil {
+			return err
+		}
+		return instance.SetProvisioningContext(merged, b.codec)
+	}
+}
+
+// mergeBindingContext returns a bindingMutation that applies to a binding's
+// binding context only the changes that a step made to it. See
+// mergeProvisioningContext.
+func (b *broker) mergeBindingContext(
+	originalJSON []byte,
+	updated service.BindingContext,
+) bindingMutation {
+	return func(binding *service.Binding) error {
+		merged, err := mergeContext(
+			originalJSON,
+			updated,
+			func(current interface{}) error {
+				return binding.GetBindingContext(current, b.codec)
+			},
+		)
+		if err != nil {
+			return err
+		}
+		return binding.SetBindingContext(merged, b.codec)
+	}
+}
+
+// mergeContext applies the top-level fields that differ between the JSON
+// representations of a context before and after a step executed to the
+// context's current value, as decoded by getCurrent, and returns the result.
+// If the context isn't a JSON object, there are no fields to merge and the
+// updated context is returned as is.
+func mergeContext(
+	originalJSON []byte,
+	updated interface{},
+	getCurrent func(interface{}) error,
+) (interface{}, error) {
+	updatedJSON, err := json.Marshal(updated)
+	if err != nil {
+		return nil, err
+	}
+	originalFields := map[string]json.RawMessage{}
+	updatedFields := map[string]json.RawMessage{}
+	if json.Unmarshal(originalJSON, &originalFields) != nil ||
+		json.Unmarshal(updatedJSON, &updatedFields) != nil ||
+		updatedFields == nil {
+		return updated, nil
+	}
+	currentFields := map[string]json.RawMessage{}
+	if err := getCurrent(&currentFields); err != nil {
+		return nil, err
+	}
+	if currentFields == nil {
+		currentFields = map[string]json.RawMessage{}
+	}
+	for field, value := range updatedFields {
+		if !bytes.Equal(originalFields[field], value) {
+			currentFields[field] = value
+		}
+	}
+	for field := range originalFields {
+		if _, found := updatedFields[field]; !found {
+			delete(currentFields, field)
+		}
+	}
+	return currentFields, nil
+}
+
+// submitNextSteps submits a task for each step of the given step graph that
+// the completion of the named step has made ready for execution. Steps that
+// still await the completion of other dependencies are submitted later by
+// whichever step completes last.
+func (b *broker) submitNextSteps(
+	stepGraph service.StepGraph,
+	jobName string,
+	operation string,
+	instance *service.Instance,
+	stepName string,
+) error {
+	for _, nextStepName := range stepGraph.GetNextStepNames(
+		stepName,
+		instance.CompletedSteps,
+	) {
+		if err := b.asyncEngine.SubmitTask(
+			api.NewStepTask(
+				jobName,
+				operation,
+				instance.InstanceID,
+				instance.OperationID,
+				nextStepName,
+			),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBindingStepCompleted returns a bool indicating whether the named step of
+// the binding or unbinding operation in progress has already been completed
+// for the given binding
+func isBindingStepCompleted(binding *service.Binding, stepName string) bool {
+	for _, completedStepName := range binding.CompletedSteps {
+		if completedStepName == stepName {
+			return true
+		}
+	}
+	return false
+}
+
+// completeBindingStep returns a bindingMutation that records the completion of
+// the named step of the binding or unbinding operation in progress
+func completeBindingStep(stepName string) bindingMutation {
+	return func(binding *service.Binding) error {
+		if !isBindingStepCompleted(binding, stepName) {
+			binding.CompletedSteps = append(binding.CompletedSteps, stepName)
+		}
+		return nil
+	}
+}
+
+// submitNextBindingSteps submits a task for each step of the given step graph
+// that the completion of the named step has made ready for execution. See
+// submitNextSteps.
+func (b *broker) submitNextBindingSteps(
+	stepGraph service.StepGraph,
+	jobName string,
+	operation string,
+	binding *service.Binding,
+	stepName string,
+) error {
+	for _, nextStepName := range stepGraph.GetNextStepNames(
+		stepName,
+		binding.CompletedSteps,
+	) {
+		if err := b.asyncEngine.SubmitTask(
+			api.NewBindingStepTask(
+				jobName,
+				operation,
+				binding.BindingID,
+				binding.OperationID,
+				nextStepName,
+			),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
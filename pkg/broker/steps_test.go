@@ -0,0 +1,152 @@
+package broker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/noop"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+type testProvisioningContext struct {
+	Foo string `json:"foo"`
+	Bar string `json:"bar"`
+	Baz string `json:"baz"`
+}
+
+func TestCompleteStep(t *testing.T) {
+	instance := &service.Instance{}
+	assert.False(t, isStepCompleted(instance, "foo"))
+	err := completeStep("foo")(instance)
+	assert.Nil(t, err)
+	assert.True(t, isStepCompleted(instance, "foo"))
+	// Completing the same step again is a no-op
+	err = completeStep("foo")(instance)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, instance.CompletedSteps)
+}
+
+func TestSkipStep(t *testing.T) {
+	instance := &service.Instance{}
+	err := mutations(
+		completeStep("foo"),
+		skipStep("bar"),
+		completeStep("baz"),
+	)(instance)
+	assert.Nil(t, err)
+	// Skipped steps count as completed...
+	assert.True(t, isStepCompleted(instance, "bar"))
+	assert.Equal(t, []string{"bar"}, instance.SkippedSteps)
+	// ...but not as executed
+	assert.Equal(t, []string{"foo", "baz"}, getExecutedStepNames(instance))
+}
+
+func TestBackfillCompletedSteps(t *testing.T) {
+	provisioner, err := service.NewProvisioner(
+		service.NewProvisioningStep("foo", nil),
+		service.NewProvisioningStep("bar", nil),
+		service.NewProvisioningStep("baz", nil),
+	)
+	assert.Nil(t, err)
+	testCases := []struct {
+		name                   string
+		completedSteps         []string
+		stepName               string
+		expectedCompletedSteps []string
+	}{
+		{
+			name:     "first step",
+			stepName: "foo",
+		},
+		{
+			name:                   "legacy operation",
+			stepName:               "baz",
+			expectedCompletedSteps: []string{"foo", "bar"},
+		},
+		{
+			// Legacy steps aren't part of the graph and follow every step in it
+			name:                   "legacy step",
+			stepName:               "qux",
+			expectedCompletedSteps: []string{"foo", "bar", "baz"},
+		},
+		{
+			name:                   "steps already tracked",
+			completedSteps:         []string{"foo"},
+			stepName:               "bar",
+			expectedCompletedSteps: []string{"foo"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			instance := &service.Instance{
+				CompletedSteps: testCase.completedSteps,
+			}
+			err := backfillCompletedSteps(provisioner, testCase.stepName)(instance)
+			assert.Nil(t, err)
+			assert.Equal(
+				t,
+				testCase.expectedCompletedSteps,
+				instance.CompletedSteps,
+			)
+		})
+	}
+}
+
+func TestMergeProvisioningContext(t *testing.T) {
+	b, err := getTestBroker()
+	assert.Nil(t, err)
+	b.codec = noop.NewCodec()
+	originalPC := testProvisioningContext{Foo: "foo"}
+	originalPCJSON, err := json.Marshal(originalPC)
+	assert.Nil(t, err)
+	instance := &service.Instance{InstanceID: "foo"}
+	// Meanwhile, a step in a concurrent branch has set Bar...
+	err = instance.SetProvisioningContext(
+		testProvisioningContext{Foo: "foo", Bar: "bar"},
+		b.codec,
+	)
+	assert.Nil(t, err)
+	// ...and this step sets Baz
+	updatedPC := originalPC
+	updatedPC.Baz = "baz"
+	err = b.mergeProvisioningContext(originalPCJSON, updatedPC)(instance)
+	assert.Nil(t, err)
+	mergedPC := testProvisioningContext{}
+	err = instance.GetProvisioningContext(&mergedPC, b.codec)
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		testProvisioningContext{Foo: "foo", Bar: "bar", Baz: "baz"},
+		mergedPC,
+	)
+}
+
+func TestMergeBindingContext(t *testing.T) {
+	b, err := getTestBroker()
+	assert.Nil(t, err)
+	b.codec = noop.NewCodec()
+	originalBC := testProvisioningContext{Foo: "foo"}
+	originalBCJSON, err := json.Marshal(originalBC)
+	assert.Nil(t, err)
+	binding := &service.Binding{BindingID: "foo"}
+	// Meanwhile, a step in a concurrent branch has set Bar...
+	err = binding.SetBindingContext(
+		testProvisioningContext{Foo: "foo", Bar: "bar"},
+		b.codec,
+	)
+	assert.Nil(t, err)
+	// ...and this step sets Baz
+	updatedBC := originalBC
+	updatedBC.Baz = "baz"
+	err = b.mergeBindingContext(originalBCJSON, updatedBC)(binding)
+	assert.Nil(t, err)
+	mergedBC := testProvisioningContext{}
+	err = binding.GetBindingContext(&mergedBC, b.codec)
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		testProvisioningContext{Foo: "foo", Bar: "bar", Baz: "baz"},
+		mergedBC,
+	)
+}
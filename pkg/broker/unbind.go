@@ -0,0 +1,324 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/api"
+	"github.com/Azure/open-service-broker-azure/pkg/async/model"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	log "github.com/Sirupsen/logrus"
+)
+
+func (b *broker) doUnbindStep(
+	ctx context.Context,
+	args map[string]string,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stepName, ok := args["stepName"]
+	if !ok {
+		return errors.New(`missing required argument "stepName"`)
+	}
+	bindingID, ok := args["bindingID"]
+	if !ok {
+		return errors.New(`missing required argument "bindingID"`)
+	}
+	binding, ok, err := b.store.GetBinding(bindingID)
+	if err != nil {
+		return b.handleUnbindingError(
+			bindingID,
+			stepName,
+			err,
+			"error loading persisted binding",
+		)
+	}
+	if !ok {
+		return b.handleUnbindingError(
+			bindingID,
+			stepName,
+			nil,
+			"binding does not exist in the data store",
+		)
+	}
+	log.WithFields(log.Fields{
+		"step":       stepName,
+		"bindingID":  binding.BindingID,
+		"instanceID": binding.InstanceID,
+	}).Debug("executing unbinding step")
+	instance, ok, err := b.store.GetInstance(binding.InstanceID)
+	if err != nil {
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			err,
+			"error loading persisted instance",
+		)
+	}
+	if !ok {
+		// The instance was deprovisioned while unbinding was in progress. As when
+		// unbinding synchronously, an orphaned binding is simply deleted, since
+		// without the instance, there is no way to identify the service and plan
+		// that know how to unbind it.
+		log.WithFields(log.Fields{
+			"step":       stepName,
+			"bindingID":  binding.BindingID,
+			"instanceID": binding.InstanceID,
+		}).Debug("unbinding an orphaned binding")
+		if _, err = b.store.DeleteBinding(binding.BindingID); err != nil {
+			return b.handleUnbindingError(
+				binding,
+				stepName,
+				err,
+				"error deleting orphaned binding",
+			)
+		}
+		return nil
+	}
+	svc, ok := b.catalog.GetService(instance.ServiceID)
+	if !ok {
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			nil,
+			fmt.Sprintf(
+				`no service was found for handling serviceID "%s"`,
+				instance.ServiceID,
+			),
+		)
+	}
+	plan, ok := svc.GetPlan(instance.PlanID)
+	if !ok {
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			nil,
+			fmt.Sprintf(
+				`no plan was found for handling planID "%s"`,
+				instance.PlanID,
+			),
+		)
+	}
+	serviceManager := svc.GetServiceManager()
+	provisioningContext := serviceManager.GetEmptyProvisioningContext()
+	err = instance.GetProvisioningContext(provisioningContext, b.codec)
+	if err != nil {
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			err,
+			"error decoding provisioningContext from persisted instance",
+		)
+	}
+	bindingContext := serviceManager.GetEmptyBindingContext()
+	err = binding.GetBindingContext(bindingContext, b.codec)
+	if err != nil {
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			err,
+			"error decoding bindingContext from persisted binding",
+		)
+	}
+	unbinder, err := serviceManager.GetUnbinder(plan)
+	if err != nil {
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			err,
+			fmt.Sprintf(
+				`error retrieving unbinder for service "%s"`,
+				instance.ServiceID,
+			),
+		)
+	}
+	step, ok := unbinder.GetStep(stepName)
+	if !ok {
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			nil,
+			"unbinder does not know how to process step",
+		)
+	}
+	if isBindingStepCompleted(binding, stepName) {
+		// The step has already been executed-- by an earlier delivery of the same
+		// task, for instance. Rather than executing it again, just ensure that the
+		// steps that depend on it have been submitted.
+		if binding.Status != service.BindingStateUnbinding {
+			return nil
+		}
+		if err = b.submitNextBindingSteps(
+			unbinder,
+			"unbindStep",
+			api.OperationUnbinding,
+			binding,
+			stepName,
+		); err != nil {
+			return b.handleUnbindingError(
+				binding,
+				stepName,
+				err,
+				"error enqueing next steps",
+			)
+		}
+		return nil
+	}
+	originalBindingContextJSON, err := json.Marshal(bindingContext)
+	if err != nil {
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			err,
+			"error encoding bindingContext",
+		)
+	}
+	updatedBindingContext, err := step.Execute(
+		ctx,
+		bindingID,
+		plan,
+		instance.StandardProvisioningContext,
+		provisioningContext,
+		bindingContext,
+	)
+	if err != nil {
+		if model.WillRetry(ctx, err) {
+			// The async engine will retry the step later. In the meantime, the
+			// binding's status is left alone.
+			return err
+		}
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			err,
+			getStepErrorMessage(ctx, "unbinding"),
+		)
+	}
+	if binding, err = b.writeBinding(
+		binding,
+		bindingMutations(
+			requireBindingStatus(service.BindingStateUnbinding),
+			b.mergeBindingContext(
+				originalBindingContextJSON,
+				updatedBindingContext,
+			),
+			completeBindingStep(stepName),
+		),
+	); err != nil {
+		if isStatusChanged(err) {
+			// Unbinding failed in a concurrent branch while this step was
+			// executing. The binding's status, which already explains the failure,
+			// is left alone.
+			return b.handleUnbindingError(
+				bindingID,
+				stepName,
+				err,
+				"error persisting binding",
+			)
+		}
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			err,
+			"error persisting binding",
+		)
+	}
+	if unbinder.IsComplete(binding.CompletedSteps) {
+		// All steps have completed-- we're done unbinding!
+		if _, err = b.store.DeleteBinding(binding.BindingID); err != nil {
+			return b.handleUnbindingError(
+				binding,
+				stepName,
+				err,
+				"error deleting unbound binding",
+			)
+		}
+		return nil
+	}
+	if err = b.submitNextBindingSteps(
+		unbinder,
+		"unbindStep",
+		api.OperationUnbinding,
+		binding,
+		stepName,
+	); err != nil {
+		return b.handleUnbindingError(
+			binding,
+			stepName,
+			err,
+			"error enqueing next steps",
+		)
+	}
+	return nil
+}
+
+// handleUnbindingError tries to handle async unbinding errors. If a binding is
+// passed in, its status is updated and an attempt is made to persist the
+// binding with updated status, provided the operation is still in progress. If
+// this fails for any reason other than a concurrent modification, we have a
+// very serious problem on our hands, so we log that failure and kill the
+// process. Barring such a failure, a nicely formatted error is returned to be,
+// in-turn, returned by the caller of this function. If a bindingID is passed in
+// (instead of a binding), only error formatting is handled.
+func (b *broker) handleUnbindingError(
+	bindingOrBindingID interface{},
+	stepName string,
+	e error,
+	msg string,
+) error {
+	binding, ok := bindingOrBindingID.(*service.Binding)
+	if !ok {
+		return formatBindingStepError(
+			"unbinding",
+			stepName,
+			bindingOrBindingID,
+			e,
+			msg,
+		)
+	}
+	// If we get to here, we have a binding (not just a bindingID)
+	ret := formatBindingStepError(
+		"unbinding",
+		stepName,
+		binding.BindingID,
+		e,
+		msg,
+	)
+	_, err := b.writeBinding(
+		binding,
+		bindingMutations(
+			requireBindingStatus(service.BindingStateUnbinding),
+			func(bdg *service.Binding) error {
+				bdg.Status = service.BindingStateUnbindingFailed
+				bdg.StatusReason = ret.Error()
+				return nil
+			},
+		),
+	)
+	if isStatusChanged(err) {
+		// The operation already concluded or another one has since begun, so
+		// the failure is not recorded
+		log.WithFields(log.Fields{
+			"bindingID":     binding.BindingID,
+			"originalError": ret,
+			"reason":        err,
+		}).Warn("not persisting binding with updated status")
+	} else if isConflict(err) {
+		log.WithFields(log.Fields{
+			"bindingID":        binding.BindingID,
+			"status":           service.BindingStateUnbindingFailed,
+			"originalError":    ret,
+			"persistenceError": err,
+		}).Error("error persisting binding with updated status")
+	} else if err != nil {
+		log.WithFields(log.Fields{
+			"bindingID":        binding.BindingID,
+			"status":           service.BindingStateUnbindingFailed,
+			"originalError":    ret,
+			"persistenceError": err,
+		}).Fatal("error persisting binding with updated status")
+	}
+	return ret
+}
@@ -31,12 +31,15 @@ func NewCodec(key []byte) (crypto.Codec, error) {
 	}, nil
 }
 
-func (c *codec) Encrypt(plaintext []byte) ([]byte, error) {
+func (c *codec) Encrypt(
+	plaintext []byte,
+	associatedData []byte,
+) ([]byte, error) {
 	nonce := make([]byte, nonceLength)
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, fmt.Errorf("error generating nonce: %s", err)
 	}
-	ciphertext := c.aesgcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext := c.aesgcm.Seal(nil, nonce, plaintext, associatedData)
 	// Return the ciphertext prefixed with the nonce-- this consolidates both
 	// into a single value so that anyone who has encrypted using this scheme
 	// isn't burdened with schlepping / storing the nonce in addition to the
@@ -46,10 +49,16 @@ func (c *codec) Encrypt(plaintext []byte) ([]byte, error) {
 	return append(nonce, ciphertext...), nil
 }
 
-func (c *codec) Decrypt(ciphertext []byte) ([]byte, error) {
+func (c *codec) Decrypt(
+	ciphertext []byte,
+	associatedData []byte,
+) ([]byte, error) {
+	if len(ciphertext) < nonceLength {
+		return nil, fmt.Errorf("error decrypting ciphertext: too short")
+	}
 	nonce := ciphertext[:nonceLength]
 	ciphertext = ciphertext[nonceLength:]
-	plaintext, err := c.aesgcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := c.aesgcm.Open(nil, nonce, ciphertext, associatedData)
 	if err != nil {
 		return nil, fmt.Errorf("error decrypting ciphertext: %s", err)
 	}
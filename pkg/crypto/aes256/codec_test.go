@@ -10,10 +10,25 @@ func TestCodecEncryptAndDecrypt(t *testing.T) {
 	c, err := NewCodec([]byte("AES256Key-32Characters1234567890"))
 	assert.Nil(t, err)
 	initialPlaintext := []byte("foo")
-	ciphertext, err := c.Encrypt(initialPlaintext)
+	ciphertext, err := c.Encrypt(initialPlaintext, nil)
 	assert.Nil(t, err)
 	assert.NotEqual(t, initialPlaintext, ciphertext)
-	plaintext, err := c.Decrypt(ciphertext)
+	plaintext, err := c.Decrypt(ciphertext, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, initialPlaintext, plaintext)
 }
+
+func TestCodecEncryptAndDecryptWithAssociatedData(t *testing.T) {
+	c, err := NewCodec([]byte("AES256Key-32Characters1234567890"))
+	assert.Nil(t, err)
+	initialPlaintext := []byte("foo")
+	ciphertext, err := c.Encrypt(initialPlaintext, []byte("bar"))
+	assert.Nil(t, err)
+	plaintext, err := c.Decrypt(ciphertext, []byte("bar"))
+	assert.Nil(t, err)
+	assert.Equal(t, initialPlaintext, plaintext)
+	_, err = c.Decrypt(ciphertext, []byte("baz"))
+	assert.NotNil(t, err)
+	_, err = c.Decrypt(ciphertext, nil)
+	assert.NotNil(t, err)
+}
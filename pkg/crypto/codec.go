@@ -1,8 +1,20 @@
 package crypto
 
 // Codec is an interface to be implemented by any type that can encrypt and
-// decrypt values
+// decrypt values. Ciphertexts are authenticated together with the (optional)
+// associated data passed to Encrypt, and Decrypt fails unless it is passed the
+// same associated data. This permits a ciphertext to be bound to the context
+// it is used in so that it cannot be moved elsewhere undetected.
 type Codec interface {
-	Encrypt([]byte) ([]byte, error)
-	Decrypt([]byte) ([]byte, error)
+	Encrypt(plaintext []byte, associatedData []byte) ([]byte, error)
+	Decrypt(ciphertext []byte, associatedData []byte) ([]byte, error)
+}
+
+// Rotator is an interface that may optionally be implemented by Codecs that
+// support key rotation
+type Rotator interface {
+	// IsCurrent returns a bool indicating whether the given ciphertext was
+	// encrypted using the currently active key. Ciphertexts for which this
+	// returns false should be re-encrypted.
+	IsCurrent(ciphertext []byte) bool
 }
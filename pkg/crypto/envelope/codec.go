@@ -0,0 +1,101 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/aes256"
+)
+
+const (
+	dataKeyLength = 32
+	// wrappedKeyLengthLength is the number of bytes used to encode the length
+	// of the wrapped data key that prefixes every ciphertext
+	wrappedKeyLengthLength = 2
+)
+
+// KeyEncrypter is an interface to be implemented by any type that can wrap
+// (encrypt) and unwrap (decrypt) data keys using a master key. Implementations
+// should, ideally, keep the master key outside the broker process entirely.
+type KeyEncrypter interface {
+	WrapKey(key []byte) ([]byte, error)
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+type codec struct {
+	keyEncrypter KeyEncrypter
+}
+
+// NewCodec returns a new implementation of crypto.Codec that uses envelope
+// encryption. Every value is encrypted (using aes256) with its own, randomly
+// generated data key. That data key is wrapped using the given KeyEncrypter and
+// stored alongside the ciphertext. Note that this means every call to Encrypt
+// or Decrypt results in a call to the KeyEncrypter.
+func NewCodec(keyEncrypter KeyEncrypter) crypto.Codec {
+	return &codec{
+		keyEncrypter: keyEncrypter,
+	}
+}
+
+func (c *codec) Encrypt(
+	plaintext []byte,
+	associatedData []byte,
+) ([]byte, error) {
+	dataKey := make([]byte, dataKeyLength)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("error generating data key: %s", err)
+	}
+	dataCodec, err := aes256.NewCodec(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := dataCodec.Encrypt(plaintext, associatedData)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := c.keyEncrypter.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("error wrapping data key: %s", err)
+	}
+	if len(wrappedKey) > 1<<(8*wrappedKeyLengthLength)-1 {
+		return nil, fmt.Errorf(
+			"wrapped data key is too long: %d bytes",
+			len(wrappedKey),
+		)
+	}
+	// Return the ciphertext prefixed with the wrapped data key and its length
+	envelope := make(
+		[]byte,
+		wrappedKeyLengthLength,
+		wrappedKeyLengthLength+len(wrappedKey)+len(ciphertext),
+	)
+	binary.BigEndian.PutUint16(envelope, uint16(len(wrappedKey)))
+	envelope = append(envelope, wrappedKey...)
+	return append(envelope, ciphertext...), nil
+}
+
+func (c *codec) Decrypt(
+	ciphertext []byte,
+	associatedData []byte,
+) ([]byte, error) {
+	if len(ciphertext) < wrappedKeyLengthLength {
+		return nil, fmt.Errorf("error decrypting ciphertext: too short")
+	}
+	wrappedKeyLength := int(binary.BigEndian.Uint16(ciphertext))
+	ciphertext = ciphertext[wrappedKeyLengthLength:]
+	if len(ciphertext) < wrappedKeyLength {
+		return nil, fmt.Errorf("error decrypting ciphertext: too short")
+	}
+	dataKey, err := c.keyEncrypter.UnwrapKey(ciphertext[:wrappedKeyLength])
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key: %s", err)
+	}
+	dataCodec, err := aes256.NewCodec(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return dataCodec.Decrypt(ciphertext[wrappedKeyLength:], associatedData)
+}
@@ -0,0 +1,59 @@
+package envelope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKeyEncrypter "wraps" data keys by reversing them and records how many
+// data keys it has wrapped
+type fakeKeyEncrypter struct {
+	wrappedKeys [][]byte
+}
+
+func (f *fakeKeyEncrypter) WrapKey(key []byte) ([]byte, error) {
+	f.wrappedKeys = append(f.wrappedKeys, key)
+	return reverse(key), nil
+}
+
+func (f *fakeKeyEncrypter) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return reverse(wrappedKey), nil
+}
+
+func reverse(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return reversed
+}
+
+func TestCodecEncryptAndDecrypt(t *testing.T) {
+	keyEncrypter := &fakeKeyEncrypter{}
+	c := NewCodec(keyEncrypter)
+	initialPlaintext := []byte("foo")
+	ciphertext, err := c.Encrypt(initialPlaintext, nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, initialPlaintext, ciphertext)
+	plaintext, err := c.Decrypt(ciphertext, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, initialPlaintext, plaintext)
+}
+
+func TestCodecUsesDataKeyPerValue(t *testing.T) {
+	keyEncrypter := &fakeKeyEncrypter{}
+	c := NewCodec(keyEncrypter)
+	_, err := c.Encrypt([]byte("foo"), nil)
+	assert.Nil(t, err)
+	_, err = c.Encrypt([]byte("foo"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(keyEncrypter.wrappedKeys))
+	assert.NotEqual(t, keyEncrypter.wrappedKeys[0], keyEncrypter.wrappedKeys[1])
+}
+
+func TestCodecDecryptTruncatedCiphertext(t *testing.T) {
+	c := NewCodec(&fakeKeyEncrypter{})
+	_, err := c.Decrypt([]byte{0, 32, 1}, nil)
+	assert.NotNil(t, err)
+}
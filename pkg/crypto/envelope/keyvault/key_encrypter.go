@@ -0,0 +1,108 @@
+package keyvault
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	kv "github.com/Azure/azure-sdk-for-go/dataplane/keyvault"
+	"github.com/Azure/go-autorest/autorest/azure"
+	az "github.com/Azure/open-service-broker-azure/pkg/azure"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/envelope"
+)
+
+type keyEncrypter struct {
+	client       kv.ManagementClient
+	vaultBaseURL string
+	keyName      string
+	keyVersion   string
+}
+
+// NewKeyEncrypter returns a new implementation of envelope.KeyEncrypter that
+// wraps and unwraps data keys using the specified RSA key in Azure Key Vault.
+// The master key never leaves Key Vault. If no key version is specified, data
+// keys are wrapped using the latest version of the key. Credentials are
+// obtained in the same manner as for all other Azure APIs the broker uses.
+func NewKeyEncrypter(
+	vaultBaseURL string,
+	keyName string,
+	keyVersion string,
+) (envelope.KeyEncrypter, error) {
+	azureConfig, err := az.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	azureEnvironment, err := azure.EnvironmentFromName(azureConfig.Environment)
+	if err != nil {
+		return nil, fmt.Errorf(
+			`error parsing Azure environment name "%s"`,
+			azureConfig.Environment,
+		)
+	}
+	authorizer, err := az.GetBearerTokenAuthorizerForResource(
+		azureEnvironment,
+		azureConfig.TenantID,
+		azureConfig.ClientID,
+		azureConfig.ClientSecret,
+		strings.TrimSuffix(azureEnvironment.KeyVaultEndpoint, "/"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting bearer token authorizer: %s", err)
+	}
+	client := kv.New()
+	client.Authorizer = authorizer
+	return &keyEncrypter{
+		client:       client,
+		vaultBaseURL: vaultBaseURL,
+		keyName:      keyName,
+		keyVersion:   keyVersion,
+	}, nil
+}
+
+func (k *keyEncrypter) WrapKey(key []byte) ([]byte, error) {
+	value := base64.RawURLEncoding.EncodeToString(key)
+	result, err := k.client.WrapKey(
+		k.vaultBaseURL,
+		k.keyName,
+		k.keyVersion,
+		kv.KeyOperationsParameters{
+			Algorithm: kv.RSAOAEP,
+			Value:     &value,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error wrapping key: %s", err)
+	}
+	if result.Kid == nil || result.Result == nil {
+		return nil, fmt.Errorf("error wrapping key: incomplete response")
+	}
+	// The wrapped key alone isn't enough to unwrap it later if a specific key
+	// version wasn't configured, so it's prefixed with the ID of the key
+	// version that was used
+	return []byte(*result.Kid + " " + *result.Result), nil
+}
+
+func (k *keyEncrypter) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	tokens := strings.SplitN(string(wrappedKey), " ", 2)
+	if len(tokens) != 2 {
+		return nil, fmt.Errorf("error unwrapping key: malformed wrapped key")
+	}
+	keyVersion := tokens[0][strings.LastIndex(tokens[0], "/")+1:]
+	value := tokens[1]
+	result, err := k.client.UnwrapKey(
+		k.vaultBaseURL,
+		k.keyName,
+		keyVersion,
+		kv.KeyOperationsParameters{
+			Algorithm: kv.RSAOAEP,
+			Value:     &value,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping key: %s", err)
+	}
+	if result.Result == nil {
+		return nil, fmt.Errorf("error unwrapping key: incomplete response")
+	}
+	return base64.RawURLEncoding.DecodeString(*result.Result)
+}
@@ -0,0 +1,46 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/aes256"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/envelope"
+)
+
+type keyEncrypter struct {
+	codec crypto.Codec
+}
+
+// NewKeyEncrypter returns a new implementation of envelope.KeyEncrypter that
+// wraps and unwraps data keys using a 32 character master key read from the
+// file at the given path. Leading and trailing whitespace in the file is
+// ignored. Since the master key is held in the broker process, this is
+// intended for development and testing only.
+func NewKeyEncrypter(path string) (envelope.KeyEncrypter, error) {
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(`error reading master key file "%s": %s`, path, err)
+	}
+	codec, err := aes256.NewCodec(bytes.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf(
+			`error creating codec from master key file "%s": %s`,
+			path,
+			err,
+		)
+	}
+	return &keyEncrypter{
+		codec: codec,
+	}, nil
+}
+
+func (k *keyEncrypter) WrapKey(key []byte) ([]byte, error) {
+	return k.codec.Encrypt(key, nil)
+}
+
+func (k *keyEncrypter) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return k.codec.Decrypt(wrappedKey, nil)
+}
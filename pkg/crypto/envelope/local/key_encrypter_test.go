@@ -0,0 +1,33 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyEncrypterWrapAndUnwrap(t *testing.T) {
+	file, err := ioutil.TempFile("", "osba-master-key")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("AES256Key-32Characters1234567890\n")
+	assert.Nil(t, err)
+	err = file.Close()
+	assert.Nil(t, err)
+	keyEncrypter, err := NewKeyEncrypter(file.Name())
+	assert.Nil(t, err)
+	initialKey := []byte("data-key")
+	wrappedKey, err := keyEncrypter.WrapKey(initialKey)
+	assert.Nil(t, err)
+	assert.NotEqual(t, initialKey, wrappedKey)
+	key, err := keyEncrypter.UnwrapKey(wrappedKey)
+	assert.Nil(t, err)
+	assert.Equal(t, initialKey, key)
+}
+
+func TestNewKeyEncrypterWithMissingFile(t *testing.T) {
+	_, err := NewKeyEncrypter("/nonexistent/master.key")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,113 @@
+package keyring
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto"
+)
+
+// tagPrefix prefixes every ciphertext produced by this codec. It's followed by
+// the ID of the key that was used for encryption, a separator, and finally, the
+// ciphertext produced by the codec for that key.
+var tagPrefix = []byte("keyring:")
+
+const tagSeparator = ':'
+
+type codec struct {
+	activeKeyID string
+	codecs      map[string]crypto.Codec
+	legacyCodec crypto.Codec
+}
+
+// NewCodec returns a new implementation of crypto.Codec that delegates to one
+// of several Codecs, each identified by a key ID. Values are always encrypted
+// using the Codec identified by the active key ID, and the resulting
+// ciphertexts are tagged with that key ID so they can be decrypted using the
+// same Codec after a different key has become active. If a legacy Codec is
+// specified, it's used to decrypt untagged ciphertexts produced before the
+// keyring was introduced.
+func NewCodec(
+	activeKeyID string,
+	codecs map[string]crypto.Codec,
+	legacyCodec crypto.Codec,
+) (crypto.Codec, error) {
+	for keyID := range codecs {
+		if keyID == "" || strings.ContainsRune(keyID, tagSeparator) {
+			return nil, fmt.Errorf(`invalid key ID "%s"`, keyID)
+		}
+	}
+	if _, ok := codecs[activeKeyID]; !ok {
+		return nil, fmt.Errorf(`active key ID "%s" is unknown`, activeKeyID)
+	}
+	return &codec{
+		activeKeyID: activeKeyID,
+		codecs:      codecs,
+		legacyCodec: legacyCodec,
+	}, nil
+}
+
+func (c *codec) Encrypt(
+	plaintext []byte,
+	associatedData []byte,
+) ([]byte, error) {
+	ciphertext, err := c.codecs[c.activeKeyID].Encrypt(
+		plaintext,
+		associatedData,
+	)
+	if err != nil {
+		return nil, err
+	}
+	tagged := make([]byte, 0, len(tagPrefix)+len(c.activeKeyID)+1+len(ciphertext))
+	tagged = append(tagged, tagPrefix...)
+	tagged = append(tagged, c.activeKeyID...)
+	tagged = append(tagged, tagSeparator)
+	return append(tagged, ciphertext...), nil
+}
+
+func (c *codec) Decrypt(
+	ciphertext []byte,
+	associatedData []byte,
+) ([]byte, error) {
+	keyID, untagged, ok := parseTag(ciphertext)
+	if ok {
+		if codec, known := c.codecs[keyID]; known {
+			plaintext, err := codec.Decrypt(untagged, associatedData)
+			// An untagged legacy ciphertext could, however improbably, appear to be
+			// tagged, so only fail here if there is nothing to fall back to
+			if err == nil || c.legacyCodec == nil {
+				return plaintext, err
+			}
+		} else if c.legacyCodec == nil {
+			return nil, fmt.Errorf(
+				`ciphertext was encrypted with unknown key "%s"`,
+				keyID,
+			)
+		}
+	}
+	if c.legacyCodec == nil {
+		return nil, fmt.Errorf("ciphertext is not tagged with a key ID")
+	}
+	return c.legacyCodec.Decrypt(ciphertext, associatedData)
+}
+
+func (c *codec) IsCurrent(ciphertext []byte) bool {
+	keyID, _, ok := parseTag(ciphertext)
+	return ok && keyID == c.activeKeyID
+}
+
+// parseTag splits the given ciphertext into the key ID it is tagged with and
+// the remainder of the ciphertext. The returned bool indicates whether the
+// ciphertext was tagged at all.
+func parseTag(ciphertext []byte) (string, []byte, bool) {
+	if !bytes.HasPrefix(ciphertext, tagPrefix) {
+		return "", nil, false
+	}
+	rest := ciphertext[len(tagPrefix):]
+	index := bytes.IndexByte(rest, tagSeparator)
+	if index < 1 {
+		return "", nil, false
+	}
+	return string(rest[:index]), rest[index+1:], true
+}
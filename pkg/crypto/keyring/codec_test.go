@@ -0,0 +1,93 @@
+package keyring
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/aes256"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestAES256Codec(t *testing.T, key string) crypto.Codec {
+	c, err := aes256.NewCodec([]byte(key))
+	assert.Nil(t, err)
+	return c
+}
+
+func TestCodecEncryptAndDecrypt(t *testing.T) {
+	c, err := NewCodec(
+		"1",
+		map[string]crypto.Codec{
+			"1": getTestAES256Codec(t, "AES256Key-32Characters1234567890"),
+		},
+		nil,
+	)
+	assert.Nil(t, err)
+	initialPlaintext := []byte("foo")
+	ciphertext, err := c.Encrypt(initialPlaintext, nil)
+	assert.Nil(t, err)
+	assert.True(t, c.(crypto.Rotator).IsCurrent(ciphertext))
+	plaintext, err := c.Decrypt(ciphertext, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, initialPlaintext, plaintext)
+}
+
+func TestCodecDecryptsWithRetiredKey(t *testing.T) {
+	oldCodec := getTestAES256Codec(t, "AES256Key-32Characters1234567890")
+	newCodec := getTestAES256Codec(t, "AES256Key-32Characters0987654321")
+	c, err := NewCodec("old", map[string]crypto.Codec{"old": oldCodec}, nil)
+	assert.Nil(t, err)
+	initialPlaintext := []byte("foo")
+	ciphertext, err := c.Encrypt(initialPlaintext, nil)
+	assert.Nil(t, err)
+	c, err = NewCodec(
+		"new",
+		map[string]crypto.Codec{
+			"old": oldCodec,
+			"new": newCodec,
+		},
+		nil,
+	)
+	assert.Nil(t, err)
+	assert.False(t, c.(crypto.Rotator).IsCurrent(ciphertext))
+	plaintext, err := c.Decrypt(ciphertext, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, initialPlaintext, plaintext)
+}
+
+func TestCodecDecryptsLegacyCiphertext(t *testing.T) {
+	legacyCodec := getTestAES256Codec(t, "AES256Key-32Characters1234567890")
+	initialPlaintext := []byte("foo")
+	ciphertext, err := legacyCodec.Encrypt(initialPlaintext, nil)
+	assert.Nil(t, err)
+	c, err := NewCodec(
+		"1",
+		map[string]crypto.Codec{
+			"1": getTestAES256Codec(t, "AES256Key-32Characters0987654321"),
+		},
+		legacyCodec,
+	)
+	assert.Nil(t, err)
+	assert.False(t, c.(crypto.Rotator).IsCurrent(ciphertext))
+	plaintext, err := c.Decrypt(ciphertext, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, initialPlaintext, plaintext)
+}
+
+func TestCodecDecryptWithUnknownKey(t *testing.T) {
+	c, err := NewCodec(
+		"1",
+		map[string]crypto.Codec{
+			"1": getTestAES256Codec(t, "AES256Key-32Characters1234567890"),
+		},
+		nil,
+	)
+	assert.Nil(t, err)
+	_, err = c.Decrypt([]byte("keyring:2:foo"), nil)
+	assert.NotNil(t, err)
+}
+
+func TestNewCodecWithUnknownActiveKey(t *testing.T) {
+	_, err := NewCodec("1", map[string]crypto.Codec{}, nil)
+	assert.NotNil(t, err)
+}
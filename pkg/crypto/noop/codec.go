@@ -9,10 +9,10 @@ func NewCodec() crypto.Codec {
 	return &codec{}
 }
 
-func (c *codec) Encrypt(plaintext []byte) ([]byte, error) {
+func (c *codec) Encrypt(plaintext []byte, _ []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
-func (c *codec) Decrypt(ciphertext []byte) ([]byte, error) {
+func (c *codec) Decrypt(ciphertext []byte, _ []byte) ([]byte, error) {
 	return ciphertext, nil
 }
@@ -10,14 +10,14 @@ var testCodec = NewCodec()
 
 func TestCodecEncrypt(t *testing.T) {
 	plaintext := []byte("foo")
-	ciphertext, err := testCodec.Encrypt(plaintext)
+	ciphertext, err := testCodec.Encrypt(plaintext, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, plaintext, ciphertext)
 }
 
 func TestCodecDecrypt(t *testing.T) {
 	ciphertext := []byte("foo")
-	plaintext, err := testCodec.Decrypt(ciphertext)
+	plaintext, err := testCodec.Decrypt(ciphertext, nil)
 	assert.Nil(t, err)
 	assert.Equal(t, ciphertext, plaintext)
 }
@@ -0,0 +1,33 @@
+package crypto
+
+type unboundCompatibleCodec struct {
+	Codec
+}
+
+// NewUnboundCompatibleCodec returns a Codec that decorates the given Codec. If
+// decrypting a ciphertext using the given associated data fails, decryption is
+// retried without any associated data. This permits ciphertexts produced
+// before they were bound to associated data to be decrypted, but also permits
+// those ciphertexts to be moved elsewhere undetected, so this should only be
+// used until all such ciphertexts have been re-encrypted.
+func NewUnboundCompatibleCodec(codec Codec) Codec {
+	return &unboundCompatibleCodec{
+		Codec: codec,
+	}
+}
+
+func (u *unboundCompatibleCodec) Decrypt(
+	ciphertext []byte,
+	associatedData []byte,
+) ([]byte, error) {
+	plaintext, err := u.Codec.Decrypt(ciphertext, associatedData)
+	if err == nil || associatedData == nil {
+		return plaintext, err
+	}
+	plaintext, unboundErr := u.Codec.Decrypt(ciphertext, nil)
+	if unboundErr != nil {
+		// Report the original error
+		return nil, err
+	}
+	return plaintext, nil
+}
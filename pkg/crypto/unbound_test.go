@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCodec "encrypts" by prefixing the plaintext with the associated data
+// and its length and only "decrypts" ciphertexts prefixed with exactly the
+// given associated data
+type fakeCodec struct{}
+
+func (fakeCodec) Encrypt(
+	plaintext []byte,
+	associatedData []byte,
+) ([]byte, error) {
+	ciphertext := append([]byte{byte(len(associatedData))}, associatedData...)
+	return append(ciphertext, plaintext...), nil
+}
+
+func (fakeCodec) Decrypt(
+	ciphertext []byte,
+	associatedData []byte,
+) ([]byte, error) {
+	prefix := append([]byte{byte(len(associatedData))}, associatedData...)
+	if !bytes.HasPrefix(ciphertext, prefix) {
+		return nil, errors.New("associated data mismatch")
+	}
+	return ciphertext[len(prefix):], nil
+}
+
+func TestUnboundCompatibleCodecDecryptsBoundCiphertext(t *testing.T) {
+	c := NewUnboundCompatibleCodec(fakeCodec{})
+	ciphertext, err := c.Encrypt([]byte("foo"), []byte("bar"))
+	assert.Nil(t, err)
+	plaintext, err := c.Decrypt(ciphertext, []byte("bar"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo"), plaintext)
+	_, err = c.Decrypt(ciphertext, []byte("baz"))
+	assert.NotNil(t, err)
+}
+
+func TestUnboundCompatibleCodecDecryptsUnboundCiphertext(t *testing.T) {
+	c := NewUnboundCompatibleCodec(fakeCodec{})
+	ciphertext, err := c.Encrypt([]byte("foo"), nil)
+	assert.Nil(t, err)
+	plaintext, err := c.Decrypt(ciphertext, []byte("bar"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("foo"), plaintext)
+}
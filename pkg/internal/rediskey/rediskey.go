@@ -0,0 +1,14 @@
+// Package rediskey builds the keys under which the Redis-based implementations
+// of storage and the async engine keep their data
+package rediskey
+
+import "strings"
+
+// Join joins the given key prefix (if non-empty) and key parts into a single,
+// colon-delimited Redis key
+func Join(keyPrefix string, parts ...string) string {
+	if keyPrefix != "" {
+		parts = append([]string{keyPrefix}, parts...)
+	}
+	return strings.Join(parts, ":")
+}
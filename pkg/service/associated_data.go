@@ -0,0 +1,20 @@
+package service
+
+// Names of the encrypted fields of instances and bindings. Each field's
+// ciphertext is bound to both the field's name and the ID of the instance or
+// binding it belongs to, so it cannot be copied to another field or record
+// undetected.
+const (
+	FieldProvisioningParameters = "provisioningParameters"
+	FieldUpdatingParameters     = "updatingParameters"
+	FieldProvisioningContext    = "provisioningContext"
+	FieldBindingParameters      = "bindingParameters"
+	FieldBindingContext         = "bindingContext"
+	FieldCredentials            = "credentials"
+)
+
+// GetAssociatedData returns the associated data that the ciphertext in the
+// named field of the instance or binding having the given ID is bound to
+func GetAssociatedData(id string, field string) []byte {
+	return []byte(id + "/" + field)
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/aes256"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCiphertextsAreBoundToRecordAndField(t *testing.T) {
+	codec, err := aes256.NewCodec([]byte("AES256Key-32Characters1234567890"))
+	assert.Nil(t, err)
+	instance := &Instance{
+		InstanceID: "foo",
+	}
+	err = instance.SetProvisioningContext(testArbitraryObject, codec)
+	assert.Nil(t, err)
+	pc := &ArbitraryType{}
+	err = instance.GetProvisioningContext(pc, codec)
+	assert.Nil(t, err)
+	assert.Equal(t, testArbitraryObject, pc)
+	// Copying the ciphertext to another instance must be detected
+	otherInstance := &Instance{
+		InstanceID:                   "bar",
+		EncryptedProvisioningContext: instance.EncryptedProvisioningContext,
+	}
+	err = otherInstance.GetProvisioningContext(&ArbitraryType{}, codec)
+	assert.NotNil(t, err)
+	// Copying the ciphertext to another field must be detected
+	instance.EncryptedProvisioningParameters =
+		instance.EncryptedProvisioningContext
+	err = instance.GetProvisioningParameters(&ArbitraryType{}, codec)
+	assert.NotNil(t, err)
+}
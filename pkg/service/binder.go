@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// BindingStepFunction is the signature for functions that implement a binding
+// step. Steps that produce credentials return them. Steps that don't return
+// nil credentials.
+type BindingStepFunction func(
+	ctx context.Context,
+	bindingID string,
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	bindingContext BindingContext,
+	params BindingParameters,
+) (BindingContext, Credentials, error)
+
+// BindingStep is an interface to be implemented by types that represent
+// a single step in a chain of steps that defines a binding process
+type BindingStep interface {
+	GetName() string
+	Execute(
+		ctx context.Context,
+		bindingID string,
+		plan Plan,
+		standardProvisioningContext StandardProvisioningContext,
+		provisioningContext ProvisioningContext,
+		bindingContext BindingContext,
+		params BindingParameters,
+	) (BindingContext, Credentials, error)
+}
+
+type bindingStep struct {
+	name string
+	fn   BindingStepFunction
+}
+
+// Binder is an interface to be implemented by types that model a declared
+// graph of tasks used to asynchronously bind to a service
+type Binder interface {
+	StepGraph
+	GetStep(name string) (BindingStep, bool)
+}
+
+type binder struct {
+	*stepGraph
+	steps map[string]BindingStep
+}
+
+// BinderBuilder is an interface to be implemented by types that declare the
+// steps of a binding process and the dependencies among them
+type BinderBuilder interface {
+	// AddStep declares a step that may be executed once all of the named steps
+	// have completed. Steps must be declared after the steps they depend on.
+	AddStep(step BindingStep, dependencies ...string) BinderBuilder
+	// Build returns a Binder that executes the declared steps
+	Build() (Binder, error)
+}
+
+type binderBuilder struct {
+	binder *binder
+	err    error
+}
+
+// NewBindingStep returns a new BindingStep
+func NewBindingStep(
+	name string,
+	fn BindingStepFunction,
+) BindingStep {
+	return &bindingStep{
+		name: name,
+		fn:   fn,
+	}
+}
+
+// GetName returns a binding step's name
+func (b *bindingStep) GetName() string {
+	return b.name
+}
+
+// Execute executes a step
+func (b *bindingStep) Execute(
+	ctx context.Context,
+	bindingID string,
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	bindingContext BindingContext,
+	params BindingParameters,
+) (BindingContext, Credentials, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return b.fn(
+		ctx,
+		bindingID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+		bindingContext,
+		params,
+	)
+}
+
+// NewBinder returns a new binder that executes the given steps one at a time,
+// in order
+func NewBinder(steps ...BindingStep) (Binder, error) {
+	builder := NewBinderBuilder()
+	for i, step := range steps {
+		if i == 0 {
+			builder.AddStep(step)
+		} else {
+			builder.AddStep(step, steps[i-1].GetName())
+		}
+	}
+	return builder.Build()
+}
+
+// NewBinderBuilder returns a new BinderBuilder
+func NewBinderBuilder() BinderBuilder {
+	return &binderBuilder{
+		binder: &binder{
+			stepGraph: newStepGraph(),
+			steps:     make(map[string]BindingStep),
+		},
+	}
+}
+
+// AddStep declares a step that may be executed once all of the named steps
+// have completed. Steps must be declared after the steps they depend on.
+func (b *binderBuilder) AddStep(
+	step BindingStep,
+	dependencies ...string,
+) BinderBuilder {
+	if b.err != nil {
+		return b
+	}
+	b.err = b.binder.addStep(step.GetName(), dependencies)
+	if b.err == nil {
+		b.binder.steps[step.GetName()] = step
+	}
+	return b
+}
+
+// Build returns a Binder that executes the declared steps
+func (b *binderBuilder) Build() (Binder, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.binder, nil
+}
+
+// GetStep retrieves a step by name
+func (b *binder) GetStep(name string) (BindingStep, bool) {
+	step, ok := b.steps[name]
+	return step, ok
+}
+
+// BindSynchronously executes all of the given binder's steps, one at a time, in
+// an order that respects their dependencies. It is used to bind on behalf of
+// clients that don't support asynchronous bindings. The resulting binding
+// context and the last credentials produced by any step are returned.
+func BindSynchronously(
+	ctx context.Context,
+	binder Binder,
+	bindingID string,
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	bindingContext BindingContext,
+	params BindingParameters,
+) (BindingContext, Credentials, error) {
+	var credentials Credentials
+	for _, stepName := range binder.GetStepNames() {
+		step, ok := binder.GetStep(stepName)
+		if !ok {
+			return nil, nil, fmt.Errorf(
+				`binder does not know how to process step "%s"`,
+				stepName,
+			)
+		}
+		updatedBindingContext, stepCredentials, err := step.Execute(
+			ctx,
+			bindingID,
+			plan,
+			standardProvisioningContext,
+			provisioningContext,
+			bindingContext,
+			params,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf(
+				`error executing binding step "%s": %s`,
+				stepName,
+				err,
+			)
+		}
+		bindingContext = updatedBindingContext
+		if stepCredentials != nil {
+			credentials = stepCredentials
+		}
+	}
+	return bindingContext, credentials, nil
+}
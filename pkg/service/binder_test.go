@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindSynchronouslyExecutesStepsInOrder(t *testing.T) {
+	executedStepNames := []string{}
+	getStep := func(
+		name string,
+		credentials Credentials,
+	) BindingStep {
+		return NewBindingStep(
+			name,
+			func(
+				context.Context,
+				string, // bindingID
+				Plan,
+				StandardProvisioningContext,
+				ProvisioningContext,
+				BindingContext,
+				BindingParameters,
+			) (BindingContext, Credentials, error) {
+				executedStepNames = append(executedStepNames, name)
+				return name, credentials, nil
+			},
+		)
+	}
+	b, err := NewBinder(
+		getStep("foo", "credentials"),
+		getStep("bar", nil),
+	)
+	assert.Nil(t, err)
+	bc, credentials, err := BindSynchronously(
+		context.Background(),
+		b,
+		"",
+		nil,
+		StandardProvisioningContext{},
+		nil,
+		nil,
+		nil,
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, executedStepNames)
+	assert.Equal(t, "bar", bc)
+	// Credentials produced by an earlier step aren't discarded by a later step
+	// that doesn't produce any
+	assert.Equal(t, "credentials", credentials)
+}
+
+func TestUnbindSynchronouslyWithoutSteps(t *testing.T) {
+	u, err := NewUnbinder()
+	assert.Nil(t, err)
+	assert.Empty(t, u.GetFirstStepNames())
+	err = UnbindSynchronously(
+		context.Background(),
+		u,
+		"",
+		nil,
+		StandardProvisioningContext{},
+		nil,
+		nil,
+	)
+	assert.Nil(t, err)
+}
@@ -17,6 +17,18 @@ type Binding struct {
 	EncryptedBindingContext    []byte    `json:"bindingContext"`
 	EncryptedCredentials       []byte    `json:"credentials"`
 	Created                    time.Time `json:"created"`
+	// Revision is incremented by the storage layer each time the binding is
+	// persisted. It is used to detect (and reject) concurrent modifications.
+	Revision int `json:"revision"`
+	// SchemaVersion is the schema version of the module-specific binding
+	// context. See MigrationRegistry.
+	SchemaVersion int `json:"schemaVersion"`
+	// OperationID uniquely identifies the binding or unbinding operation in
+	// progress (or most recently attempted). See Instance.
+	OperationID string `json:"operationId"`
+	// CompletedSteps are the names of the steps of the binding or unbinding
+	// operation in progress (or most recently attempted) that have completed
+	CompletedSteps []string `json:"completedSteps"`
 }
 
 // NewBindingFromJSON returns a new Binding unmarshalled from the provided JSON
@@ -44,7 +56,10 @@ func (b *Binding) SetBindingParameters(
 	if err != nil {
 		return err
 	}
-	ciphertext, err := codec.Encrypt(jsonBytes)
+	ciphertext, err := codec.Encrypt(
+		jsonBytes,
+		GetAssociatedData(b.BindingID, FieldBindingParameters),
+	)
 	if err != nil {
 		return err
 	}
@@ -61,7 +76,10 @@ func (b *Binding) GetBindingParameters(
 	if len(b.EncryptedBindingParameters) == 0 {
 		return nil
 	}
-	plaintext, err := codec.Decrypt(b.EncryptedBindingParameters)
+	plaintext, err := codec.Decrypt(
+		b.EncryptedBindingParameters,
+		GetAssociatedData(b.BindingID, FieldBindingParameters),
+	)
 	if err != nil {
 		return err
 	}
@@ -78,7 +96,10 @@ func (b *Binding) SetBindingContext(
 	if err != nil {
 		return err
 	}
-	ciphertext, err := codec.Encrypt(jsonBytes)
+	ciphertext, err := codec.Encrypt(
+		jsonBytes,
+		GetAssociatedData(b.BindingID, FieldBindingContext),
+	)
 	if err != nil {
 		return err
 	}
@@ -95,7 +116,10 @@ func (b *Binding) GetBindingContext(
 	if len(b.EncryptedBindingContext) == 0 {
 		return nil
 	}
-	plaintext, err := codec.Decrypt(b.EncryptedBindingContext)
+	plaintext, err := codec.Decrypt(
+		b.EncryptedBindingContext,
+		GetAssociatedData(b.BindingID, FieldBindingContext),
+	)
 	if err != nil {
 		return err
 	}
@@ -112,7 +136,10 @@ func (b *Binding) SetCredentials(
 	if err != nil {
 		return err
 	}
-	ciphertext, err := codec.Encrypt(jsonBytes)
+	ciphertext, err := codec.Encrypt(
+		jsonBytes,
+		GetAssociatedData(b.BindingID, FieldCredentials),
+	)
 	if err != nil {
 		return err
 	}
@@ -129,7 +156,10 @@ func (b *Binding) GetCredentials(
 	if len(b.EncryptedCredentials) == 0 {
 		return nil
 	}
-	plaintext, err := codec.Decrypt(b.EncryptedCredentials)
+	plaintext, err := codec.Decrypt(
+		b.EncryptedCredentials,
+		GetAssociatedData(b.BindingID, FieldCredentials),
+	)
 	if err != nil {
 		return err
 	}
@@ -26,6 +26,10 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	revision := 3
+	schemaVersion := 2
+	operationID := "test-operation-id"
+	completedSteps := []string{"foo"}
 
 	testBinding = &Binding{
 		BindingID:                  bindingID,
@@ -36,6 +40,10 @@ func init() {
 		EncryptedBindingContext: encryptedBindingContext,
 		EncryptedCredentials:    encryptedCredentials,
 		Created:                 created,
+		Revision:                revision,
+		SchemaVersion:           schemaVersion,
+		OperationID:             operationID,
+		CompletedSteps:          completedSteps,
 	}
 
 	b64EncryptedBindingParameters := base64.StdEncoding.EncodeToString(
@@ -57,7 +65,11 @@ func init() {
 			"statusReason":"%s",
 			"bindingContext":"%s",
 			"credentials":"%s",
-			"created":"%s"
+			"created":"%s",
+			"revision":%d,
+			"schemaVersion":%d,
+			"operationId":"%s",
+			"completedSteps":["%s"]
 		}`,
 		bindingID,
 		instanceID,
@@ -67,6 +79,10 @@ func init() {
 		b64EncryptedBindingContext,
 		b64EncryptedCredentials,
 		created.Format(time.RFC3339),
+		revision,
+		schemaVersion,
+		operationID,
+		completedSteps[0],
 	)
 	testBindingJSONStr = strings.Replace(testBindingJSONStr, " ", "", -1)
 	testBindingJSONStr = strings.Replace(testBindingJSONStr, "\n", "", -1)
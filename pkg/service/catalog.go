@@ -2,6 +2,7 @@ package service
 
 import (
 	"encoding/json"
+	"fmt"
 	"sync"
 )
 
@@ -31,6 +32,10 @@ type ServiceProperties struct { // nolint: golint
 	Bindable      bool     `json:"bindable"`
 	PlanUpdatable bool     `json:"plan_updateable"` // Misspelling is deliberate
 	// to match the spec
+	// BindingsRetrievable need not be specified. NewService() sets it for any
+	// bindable service, since the broker can always retrieve a binding it has
+	// persisted.
+	BindingsRetrievable bool `json:"bindings_retrievable"`
 }
 
 // Service is an interface to be implemented by types that represent a single
@@ -42,6 +47,7 @@ type Service interface {
 	GetServiceManager() ServiceManager
 	GetPlans() []Plan
 	GetPlan(planID string) (Plan, bool)
+	GetPlanSchemas() (*PlanSchemas, error)
 }
 
 type service struct {
@@ -50,6 +56,8 @@ type service struct {
 	indexedPlans   map[string]Plan
 	Plans          []json.RawMessage `json:"plans"`
 	plans          []Plan
+	schemas        *PlanSchemas
+	schemasErr     error
 	jsonMutex      sync.Mutex
 }
 
@@ -62,6 +70,9 @@ type PlanProperties struct {
 	Description string                 `json:"description"`
 	Free        bool                   `json:"free"`
 	Extended    map[string]interface{} `json:"-"`
+	// Schemas need not be specified. They're derived from the service manager's
+	// parameter types when the service is initialized.
+	Schemas *PlanSchemas `json:"schemas,omitempty"`
 }
 
 // Plan is an interface to be implemented by types that represent a single
@@ -77,16 +88,20 @@ type plan struct {
 	*PlanProperties
 }
 
-// NewCatalog initializes and returns a new Catalog
-func NewCatalog(services []Service) Catalog {
+// NewCatalog initializes and returns a new Catalog. An error is returned if
+// parameter schemas could not be derived for any of the given services.
+func NewCatalog(services []Service) (Catalog, error) {
 	c := &catalog{
 		services:        services,
 		indexedServices: make(map[string]Service),
 	}
 	for _, service := range services {
+		if _, err := service.GetPlanSchemas(); err != nil {
+			return nil, err
+		}
 		c.indexedServices[service.GetID()] = service
 	}
-	return c
+	return c, nil
 }
 
 // NewCatalogFromJSON returns a new Catalog unmarshalled from the provided JSON
@@ -141,7 +156,11 @@ func (c *catalog) GetService(serviceID string) (Service, bool) {
 	return service, ok
 }
 
-// NewService initialized and returns a new Service
+// NewService initialized and returns a new Service. Parameter schemas are
+// derived from the service manager's parameter types once, here, and are
+// recorded in the properties of any plan that doesn't already specify them.
+// Any error deriving them is reported by GetPlanSchemas() (and by
+// NewCatalog()).
 func NewService(
 	serviceProperties *ServiceProperties,
 	serviceManager ServiceManager,
@@ -153,8 +172,22 @@ func NewService(
 		plans:             plans,
 		indexedPlans:      make(map[string]Plan),
 	}
+	s.BindingsRetrievable = s.Bindable
+	if serviceManager != nil {
+		s.schemas, s.schemasErr = GetPlanSchemas(serviceManager)
+		if s.schemasErr != nil {
+			s.schemasErr = fmt.Errorf(
+				`error deriving plan schemas for service "%s": %s`,
+				s.ID,
+				s.schemasErr,
+			)
+		}
+	}
 	for _, plan := range s.plans {
 		s.indexedPlans[plan.GetID()] = plan
+		if s.schemas != nil && plan.GetProperties().Schemas == nil {
+			plan.GetProperties().Schemas = s.schemas
+		}
 	}
 	return s
 }
@@ -222,6 +255,14 @@ func (s *service) GetPlan(planID string) (Plan, bool) {
 	return plan, ok
 }
 
+// GetPlanSchemas returns the schemas for the parameters that the service's
+// plans accept, as derived from the service manager's parameter types. Nil is
+// returned for a service without a service manager (e.g. one unmarshaled from
+// JSON).
+func (s *service) GetPlanSchemas() (*PlanSchemas, error) {
+	return s.schemas, s.schemasErr
+}
+
 // NewPlan initializes and returns a new Plan
 func NewPlan(planProperties *PlanProperties) Plan {
 	return &plan{
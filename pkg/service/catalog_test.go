@@ -22,7 +22,8 @@ func init() {
 	planUpdatable := false
 	free := false
 
-	testCatalog = NewCatalog([]Service{
+	var err error
+	testCatalog, err = NewCatalog([]Service{
 		NewService(
 			&ServiceProperties{
 				Name:          name,
@@ -41,6 +42,9 @@ func init() {
 			}),
 		),
 	})
+	if err != nil {
+		panic(err)
+	}
 
 	testCatalogJSONStr := fmt.Sprintf(
 		`{
@@ -52,6 +56,7 @@ func init() {
 					"tags":["%s"],
 					"bindable":%t,
 					"plan_updateable":%t,
+					"bindings_retrievable":%t,
 					"plans":[
 						{
 							"id":"%s",
@@ -69,6 +74,7 @@ func init() {
 		tag,
 		bindable,
 		planUpdatable,
+		bindable,
 		id,
 		name,
 		description,
@@ -107,3 +113,25 @@ func TestGetNonExistingPlanByID(t *testing.T) {
 func TestGetExistingPlanByID(t *testing.T) {
 
 }
+
+type invalidSchemaServiceManager struct {
+	ServiceManager
+}
+
+func (
+	i *invalidSchemaServiceManager,
+) GetEmptyProvisioningParameters() ProvisioningParameters {
+	return &struct {
+		Foo string `json:"foo" jsonschema:"minimum=1"`
+	}{}
+}
+
+func TestNewCatalogWithInvalidParameterSchemas(t *testing.T) {
+	_, err := NewCatalog([]Service{
+		NewService(
+			&ServiceProperties{ID: "test-id"},
+			&invalidSchemaServiceManager{},
+		),
+	})
+	assert.NotNil(t, err)
+}
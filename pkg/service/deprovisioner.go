@@ -15,10 +15,27 @@ type DeprovisioningStepFunction func(
 	provisioningContext ProvisioningContext,
 ) (ProvisioningContext, error)
 
+// DeprovisioningStepPredicate is the signature for functions that determine,
+// from the plan and context of a deprovisioning process, whether a
+// deprovisioning step should be skipped. They return true if the step should
+// be skipped.
+type DeprovisioningStepPredicate func(
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+) (bool, error)
+
 // DeprovisioningStep is an interface to be implemented by types that represent
 // a single step in a chain of steps that defines a deprovisioning process
 type DeprovisioningStep interface {
 	GetName() string
+	// IsSkipped returns a bool indicating whether, given the plan and context of
+	// the deprovisioning process, the step should be skipped
+	IsSkipped(
+		plan Plan,
+		standardProvisioningContext StandardProvisioningContext,
+		provisioningContext ProvisioningContext,
+	) (bool, error)
 	Execute(
 		ctx context.Context,
 		instanceID string,
@@ -33,18 +50,43 @@ type deprovisioningStep struct {
 	fn   DeprovisioningStepFunction
 }
 
+type conditionalDeprovisioningStep struct {
+	DeprovisioningStep
+	skipFn DeprovisioningStepPredicate
+}
+
 // Deprovisioner is an interface to be implemented by types that model a
-// declared chain of tasks used to asynchronously deprovision a service
+// declared graph of tasks used to asynchronously deprovision a service
 type Deprovisioner interface {
-	GetFirstStepName() (string, bool)
+	StepGraph
 	GetStep(name string) (DeprovisioningStep, bool)
-	GetNextStepName(name string) (string, bool)
 }
 
 type deprovisioner struct {
-	firstStepName string
-	steps         map[string]DeprovisioningStep
-	nextSteps     map[string]string
+	*stepGraph
+	steps map[string]DeprovisioningStep
+}
+
+// DeprovisionerBuilder is an interface to be implemented by types that declare
+// the steps of a deprovisioning process and the dependencies among them
+type DeprovisionerBuilder interface {
+	// AddStep declares a step that may be executed once all of the named steps
+	// have completed. Steps must be declared after the steps they depend on.
+	AddStep(step DeprovisioningStep, dependencies ...string) DeprovisionerBuilder
+	// AddLegacyStep declares a step that is no longer part of the deprovisioning
+	// process, but that tasks submitted by older versions of the broker may
+	// still name. Such a step can be retrieved, and therefore executed, but it
+	// is never a dependency of, or dependent on, any other step. Older versions
+	// of the broker executed steps one after another, so a legacy step is
+	// understood to follow all declared steps.
+	AddLegacyStep(step DeprovisioningStep) DeprovisionerBuilder
+	// Build returns a Deprovisioner that executes the declared steps
+	Build() (Deprovisioner, error)
+}
+
+type deprovisionerBuilder struct {
+	deprovisioner *deprovisioner
+	err           error
 }
 
 // NewDeprovisioningStep returns a new DeprovisioningStep
@@ -58,11 +100,33 @@ func NewDeprovisioningStep(
 	}
 }
 
+// NewConditionalDeprovisioningStep returns a new DeprovisioningStep that wraps
+// the given one and is skipped whenever the given predicate returns true
+func NewConditionalDeprovisioningStep(
+	step DeprovisioningStep,
+	skipFn DeprovisioningStepPredicate,
+) DeprovisioningStep {
+	return &conditionalDeprovisioningStep{
+		DeprovisioningStep: step,
+		skipFn:             skipFn,
+	}
+}
+
 // GetName returns a deprovisioning step's name
 func (d *deprovisioningStep) GetName() string {
 	return d.name
 }
 
+// IsSkipped returns a bool indicating whether the step should be skipped.
+// Unconditional steps are never skipped.
+func (d *deprovisioningStep) IsSkipped(
+	Plan,
+	StandardProvisioningContext,
+	ProvisioningContext,
+) (bool, error) {
+	return false, nil
+}
+
 // Execute executes a step
 func (d *deprovisioningStep) Execute(
 	ctx context.Context,
@@ -82,38 +146,83 @@ func (d *deprovisioningStep) Execute(
 	)
 }
 
-// NewDeprovisioner returns a new deprovisioner
+// IsSkipped returns a bool indicating whether, given the plan and context of
+// the deprovisioning process, the step should be skipped
+func (c *conditionalDeprovisioningStep) IsSkipped(
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+) (bool, error) {
+	return c.skipFn(plan, standardProvisioningContext, provisioningContext)
+}
+
+// NewDeprovisioner returns a new deprovisioner that executes the given steps
+// one at a time, in order
 func NewDeprovisioner(steps ...DeprovisioningStep) (Deprovisioner, error) {
-	d := &deprovisioner{
-		steps:     make(map[string]DeprovisioningStep),
-		nextSteps: make(map[string]string),
-	}
-	if len(steps) > 0 {
-		d.firstStepName = steps[0].GetName()
-		var lastStep DeprovisioningStep
-		for _, step := range steps {
-			_, ok := d.steps[step.GetName()]
-			if ok {
-				// This means a duplicate step name has been detected. This is a serious
-				// problem.
-				return nil, fmt.Errorf(
-					`duplicate step name "%s" detected`,
-					step.GetName(),
-				)
-			}
-			d.steps[step.GetName()] = step
-			if lastStep != nil {
-				d.nextSteps[lastStep.GetName()] = step.GetName()
-			}
-			lastStep = step
+	builder := NewDeprovisionerBuilder()
+	for i, step := range steps {
+		if i == 0 {
+			builder.AddStep(step)
+		} else {
+			builder.AddStep(step, steps[i-1].GetName())
 		}
 	}
-	return d, nil
+	return builder.Build()
+}
+
+// NewDeprovisionerBuilder returns a new DeprovisionerBuilder
+func NewDeprovisionerBuilder() DeprovisionerBuilder {
+	return &deprovisionerBuilder{
+		deprovisioner: &deprovisioner{
+			stepGraph: newStepGraph(),
+			steps:     make(map[string]DeprovisioningStep),
+		},
+	}
+}
+
+// AddStep declares a step that may be executed once all of the named steps
+// have completed. Steps must be declared after the steps they depend on.
+func (d *deprovisionerBuilder) AddStep(
+	step DeprovisioningStep,
+	dependencies ...string,
+) DeprovisionerBuilder {
+	if d.err != nil {
+		return d
+	}
+	if _, ok := d.deprovisioner.steps[step.GetName()]; ok {
+		d.err = fmt.Errorf(`duplicate step name "%s" detected`, step.GetName())
+		return d
+	}
+	d.err = d.deprovisioner.addStep(step.GetName(), dependencies)
+	if d.err == nil {
+		d.deprovisioner.steps[step.GetName()] = step
+	}
+	return d
+}
+
+// AddLegacyStep declares a step that is no longer part of the deprovisioning
+// process, but that tasks submitted by older versions of the broker may still
+// name
+func (d *deprovisionerBuilder) AddLegacyStep(
+	step DeprovisioningStep,
+) DeprovisionerBuilder {
+	if d.err != nil {
+		return d
+	}
+	if _, ok := d.deprovisioner.steps[step.GetName()]; ok {
+		d.err = fmt.Errorf(`duplicate step name "%s" detected`, step.GetName())
+		return d
+	}
+	d.deprovisioner.steps[step.GetName()] = step
+	return d
 }
 
-// GetFirstStepName retrieves the name of the first step in the chain
-func (d *deprovisioner) GetFirstStepName() (string, bool) {
-	return d.firstStepName, (d.firstStepName != "")
+// Build returns a Deprovisioner that executes the declared steps
+func (d *deprovisionerBuilder) Build() (Deprovisioner, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.deprovisioner, nil
 }
 
 // GetStep retrieves a step by name
@@ -121,10 +230,3 @@ func (d *deprovisioner) GetStep(name string) (DeprovisioningStep, bool) {
 	step, ok := d.steps[name]
 	return step, ok
 }
-
-// GetNextStepName, given the name of one step, returns the name of the next
-// step and a boolean indicating whether a next step actually exists
-func (d *deprovisioner) GetNextStepName(name string) (string, bool) {
-	nextStepName, ok := d.nextSteps[name]
-	return nextStepName, ok
-}
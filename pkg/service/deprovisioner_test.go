@@ -0,0 +1,40 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprovisionerBuilderLegacyStep(t *testing.T) {
+	d, err := NewDeprovisionerBuilder().
+		AddStep(NewDeprovisioningStep("foo", nil)).
+		AddStep(NewDeprovisioningStep("bar", nil), "foo").
+		AddLegacyStep(NewDeprovisioningStep("baz", nil)).
+		Build()
+	assert.Nil(t, err)
+	// The legacy step can be retrieved...
+	_, ok := d.GetStep("baz")
+	assert.True(t, ok)
+	// ...but isn't part of the graph
+	assert.Equal(t, []string{"foo", "bar"}, d.GetStepNames())
+	assert.Equal(
+		t,
+		[]string{"bar"},
+		d.GetNextStepNames("foo", []string{"foo"}),
+	)
+	assert.True(t, d.IsComplete([]string{"foo", "bar"}))
+}
+
+func TestDeprovisionerBuilderRejectsDuplicateLegacySteps(t *testing.T) {
+	_, err := NewDeprovisionerBuilder().
+		AddStep(NewDeprovisioningStep("foo", nil)).
+		AddLegacyStep(NewDeprovisioningStep("foo", nil)).
+		Build()
+	assert.NotNil(t, err)
+	_, err = NewDeprovisionerBuilder().
+		AddLegacyStep(NewDeprovisioningStep("foo", nil)).
+		AddStep(NewDeprovisioningStep("foo", nil)).
+		Build()
+	assert.NotNil(t, err)
+}
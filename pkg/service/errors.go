@@ -22,3 +22,46 @@ func NewValidationError(field, issue string) *ValidationError {
 func (e *ValidationError) Error() string {
 	return fmt.Sprintf("Error validating field '%s': %s", e.Field, e.Issue)
 }
+
+// RetryableError represents a transient failure executing a provisioning,
+// updating, or deprovisioning step; for instance, a throttled or timed out
+// request to Azure. Steps should return this specific error type to allow the
+// broker's framework to retry the step later instead of immediately failing
+// the operation. Any other error returned by a step is treated as a permanent
+// failure.
+type RetryableError struct {
+	Err error
+}
+
+// NewRetryableError returns a new RetryableError that wraps the given error
+func NewRetryableError(err error) *RetryableError {
+	return &RetryableError{
+		Err: err,
+	}
+}
+
+func (e *RetryableError) Error() string {
+	return fmt.Sprintf("retryable error: %s", e.Err)
+}
+
+// IsRetryable always returns true. It permits the async engine to identify
+// retryable errors without depending on this package.
+func (e *RetryableError) IsRetryable() bool {
+	return true
+}
+
+// WrapError returns an error whose message prefixes the given error's message
+// with the formatted message. If the given error is a *RetryableError, so is
+// the returned error, so that adding context to an error doesn't prevent the
+// step that encountered it from being retried.
+func WrapError(err error, format string, a ...interface{}) error {
+	retryableErr, retryable := err.(*RetryableError)
+	if retryable {
+		err = retryableErr.Err
+	}
+	err = fmt.Errorf("%s: %s", fmt.Sprintf(format, a...), err)
+	if retryable {
+		return NewRetryableError(err)
+	}
+	return err
+}
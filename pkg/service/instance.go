@@ -20,6 +20,26 @@ type Instance struct {
 	StandardProvisioningContext     StandardProvisioningContext    `json:"standardProvisioningContext"`    // nolint: lll
 	EncryptedProvisioningContext    []byte                         `json:"provisioningContext"`            // nolint: lll
 	Created                         time.Time                      `json:"created"`                        // nolint: lll
+	// Revision is incremented by the storage layer each time the instance is
+	// persisted. It is used to detect (and reject) concurrent modifications.
+	Revision int `json:"revision"`
+	// SchemaVersion is the schema version of the module-specific provisioning
+	// context. See MigrationRegistry.
+	SchemaVersion int `json:"schemaVersion"`
+	// OperationID uniquely identifies the operation in progress (or most
+	// recently attempted). It distinguishes the tasks of one attempt at an
+	// operation from those left over from an earlier attempt.
+	OperationID string `json:"operationId"`
+	// CompletedSteps are the names of the steps of the operation in progress (or
+	// most recently attempted) that have completed. Since steps without
+	// dependencies on one another may execute concurrently, this is what
+	// determines when a step's dependencies have all been satisfied.
+	CompletedSteps []string `json:"completedSteps"`
+	// SkippedSteps are the names of the steps of the operation in progress (or
+	// most recently attempted) that were skipped because their skip conditions
+	// were met. Skipped steps also count among CompletedSteps so that the steps
+	// that depend on them aren't held up.
+	SkippedSteps []string `json:"skippedSteps"`
 }
 
 // NewInstanceFromJSON returns a new Instance unmarshalled from the provided
@@ -49,7 +69,10 @@ func (i *Instance) SetProvisioningParameters(
 	if err != nil {
 		return err
 	}
-	ciphertext, err := codec.Encrypt(jsonBytes)
+	ciphertext, err := codec.Encrypt(
+		jsonBytes,
+		GetAssociatedData(i.InstanceID, FieldProvisioningParameters),
+	)
 	if err != nil {
 		return err
 	}
@@ -68,7 +91,10 @@ func (i *Instance) SetUpdatingParameters(
 	if err != nil {
 		return err
 	}
-	ciphertext, err := codec.Encrypt(jsonBytes)
+	ciphertext, err := codec.Encrypt(
+		jsonBytes,
+		GetAssociatedData(i.InstanceID, FieldUpdatingParameters),
+	)
 	if err != nil {
 		return err
 	}
@@ -85,7 +111,10 @@ func (i *Instance) GetProvisioningParameters(
 	if len(i.EncryptedProvisioningParameters) == 0 {
 		return nil
 	}
-	plaintext, err := codec.Decrypt(i.EncryptedProvisioningParameters)
+	plaintext, err := codec.Decrypt(
+		i.EncryptedProvisioningParameters,
+		GetAssociatedData(i.InstanceID, FieldProvisioningParameters),
+	)
 	if err != nil {
 		return err
 	}
@@ -101,7 +130,10 @@ func (i *Instance) GetUpdatingParameters(
 	if len(i.EncryptedUpdatingParameters) == 0 {
 		return nil
 	}
-	plaintext, err := codec.Decrypt(i.EncryptedUpdatingParameters)
+	plaintext, err := codec.Decrypt(
+		i.EncryptedUpdatingParameters,
+		GetAssociatedData(i.InstanceID, FieldUpdatingParameters),
+	)
 	if err != nil {
 		return err
 	}
@@ -118,7 +150,10 @@ func (i *Instance) SetProvisioningContext(
 	if err != nil {
 		return err
 	}
-	ciphertext, err := codec.Encrypt(jsonBytes)
+	ciphertext, err := codec.Encrypt(
+		jsonBytes,
+		GetAssociatedData(i.InstanceID, FieldProvisioningContext),
+	)
 	if err != nil {
 		return err
 	}
@@ -135,7 +170,10 @@ func (i *Instance) GetProvisioningContext(
 	if len(i.EncryptedProvisioningContext) == 0 {
 		return nil
 	}
-	plaintext, err := codec.Decrypt(i.EncryptedProvisioningContext)
+	plaintext, err := codec.Decrypt(
+		i.EncryptedProvisioningContext,
+		GetAssociatedData(i.InstanceID, FieldProvisioningContext),
+	)
 	if err != nil {
 		return err
 	}
@@ -31,6 +31,11 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	revision := 3
+	schemaVersion := 2
+	operationID := "test-operation-id"
+	completedSteps := []string{"foo", "bar"}
+	skippedSteps := []string{"bar"}
 
 	testInstance = &Instance{
 		InstanceID: instanceID,
@@ -51,7 +56,12 @@ func init() {
 			Tags:          map[string]string{tagKey: tagVal},
 		},
 		EncryptedProvisioningContext: encryptedProvisiongingContext,
-		Created: created,
+		Created:  created,
+		Revision: revision,
+		SchemaVersion: schemaVersion,
+		OperationID: operationID,
+		CompletedSteps: completedSteps,
+		SkippedSteps: skippedSteps,
 	}
 
 	b64EncryptedProvisioningParameters := base64.StdEncoding.EncodeToString(
@@ -84,7 +94,12 @@ func init() {
 				"tags":{"%s":"%s"}
 			},
 			"provisioningContext":"%s",
-			"created":"%s"
+			"created":"%s",
+			"revision":%d,
+			"schemaVersion":%d,
+			"operationId":"%s",
+			"completedSteps":["%s","%s"],
+			"skippedSteps":["%s"]
 		}`,
 		instanceID,
 		serviceID,
@@ -103,6 +118,12 @@ func init() {
 		tagVal,
 		b64EncryptedProvisioningContext,
 		created.Format(time.RFC3339),
+		revision,
+		schemaVersion,
+		operationID,
+		completedSteps[0],
+		completedSteps[1],
+		skippedSteps[0],
 	)
 	testInstanceJSONStr = strings.Replace(testInstanceJSONStr, " ", "", -1)
 	testInstanceJSONStr = strings.Replace(testInstanceJSONStr, "\n", "", -1)
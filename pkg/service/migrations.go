@@ -0,0 +1,222 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto"
+)
+
+// ContextMigration is the signature for functions that upgrade, in place, the
+// (generic) JSON representation of a module-specific ProvisioningContext or
+// BindingContext from one schema version to the next
+type ContextMigration func(context map[string]interface{}) error
+
+// SchemaMigrator is an interface that may optionally be implemented by modules
+// that need to evolve the shape of their ProvisioningContext or BindingContext
+// types without breaking the decoding of existing instances and bindings
+type SchemaMigrator interface {
+	// RegisterMigrations registers, with the given registry, all of the
+	// module's upgrade functions
+	RegisterMigrations(MigrationRegistry)
+}
+
+// MigrationRegistry is an interface to be implemented by components that
+// track the schema versions of module-specific contexts and can upgrade
+// instances and bindings from older schema versions to the current ones
+type MigrationRegistry interface {
+	// RegisterProvisioningContextMigration registers a function that upgrades
+	// the ProvisioningContext of the service having the given ID from its
+	// current schema version to the next. Migrations for a given service must be
+	// registered in order and existing migrations must NEVER be removed or
+	// reordered.
+	RegisterProvisioningContextMigration(
+		serviceID string,
+		migration ContextMigration,
+	)
+	// RegisterBindingContextMigration registers a function that upgrades the
+	// BindingContext of bindings to the service having the given ID from its
+	// current schema version to the next. Migrations for a given service must be
+	// registered in order and existing migrations must NEVER be removed or
+	// reordered.
+	RegisterBindingContextMigration(serviceID string, migration ContextMigration)
+	// GetProvisioningContextSchemaVersion returns the current schema version of
+	// the ProvisioningContext of the service having the given ID
+	GetProvisioningContextSchemaVersion(serviceID string) int
+	// GetBindingContextSchemaVersion returns the current schema version of the
+	// BindingContext of bindings to the service having the given ID
+	GetBindingContextSchemaVersion(serviceID string) int
+	// MigrateInstance upgrades the given instance's ProvisioningContext to the
+	// current schema version. It returns a bool indicating whether any upgrade
+	// was applied.
+	MigrateInstance(instance *Instance, codec crypto.Codec) (bool, error)
+	// MigrateBinding upgrades the given binding's BindingContext to the current
+	// schema version. Since bindings don't record the ID of the service they are
+	// bound to, that must be provided. It returns a bool indicating whether any
+	// upgrade was applied.
+	MigrateBinding(
+		binding *Binding,
+		serviceID string,
+		codec crypto.Codec,
+	) (bool, error)
+}
+
+type migrationRegistry struct {
+	provisioningContextMigrations map[string][]ContextMigration
+	bindingContextMigrations      map[string][]ContextMigration
+	mutex                         sync.RWMutex
+}
+
+// NewMigrationRegistry returns a new, empty MigrationRegistry
+func NewMigrationRegistry() MigrationRegistry {
+	return &migrationRegistry{
+		provisioningContextMigrations: map[string][]ContextMigration{},
+		bindingContextMigrations:      map[string][]ContextMigration{},
+	}
+}
+
+func (m *migrationRegistry) RegisterProvisioningContextMigration(
+	serviceID string,
+	migration ContextMigration,
+) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.provisioningContextMigrations[serviceID] = append(
+		m.provisioningContextMigrations[serviceID],
+		migration,
+	)
+}
+
+func (m *migrationRegistry) RegisterBindingContextMigration(
+	serviceID string,
+	migration ContextMigration,
+) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bindingContextMigrations[serviceID] = append(
+		m.bindingContextMigrations[serviceID],
+		migration,
+	)
+}
+
+func (m *migrationRegistry) GetProvisioningContextSchemaVersion(
+	serviceID string,
+) int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.provisioningContextMigrations[serviceID])
+}
+
+func (m *migrationRegistry) GetBindingContextSchemaVersion(
+	serviceID string,
+) int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.bindingContextMigrations[serviceID])
+}
+
+func (m *migrationRegistry) MigrateInstance(
+	instance *Instance,
+	codec crypto.Codec,
+) (bool, error) {
+	m.mutex.RLock()
+	migrations := m.provisioningContextMigrations[instance.ServiceID]
+	m.mutex.RUnlock()
+	ciphertext, err := migrate(
+		instance.EncryptedProvisioningContext,
+		GetAssociatedData(instance.InstanceID, FieldProvisioningContext),
+		instance.SchemaVersion,
+		migrations,
+		codec,
+	)
+	if err != nil {
+		return false, fmt.Errorf(
+			`error migrating provisioning context of instance "%s": %s`,
+			instance.InstanceID,
+			err,
+		)
+	}
+	if instance.SchemaVersion == len(migrations) {
+		return false, nil
+	}
+	instance.EncryptedProvisioningContext = ciphertext
+	instance.SchemaVersion = len(migrations)
+	return true, nil
+}
+
+func (m *migrationRegistry) MigrateBinding(
+	binding *Binding,
+	serviceID string,
+	codec crypto.Codec,
+) (bool, error) {
+	m.mutex.RLock()
+	migrations := m.bindingContextMigrations[serviceID]
+	m.mutex.RUnlock()
+	ciphertext, err := migrate(
+		binding.EncryptedBindingContext,
+		GetAssociatedData(binding.BindingID, FieldBindingContext),
+		binding.SchemaVersion,
+		migrations,
+		codec,
+	)
+	if err != nil {
+		return false, fmt.Errorf(
+			`error migrating binding context of binding "%s": %s`,
+			binding.BindingID,
+			err,
+		)
+	}
+	if binding.SchemaVersion == len(migrations) {
+		return false, nil
+	}
+	binding.EncryptedBindingContext = ciphertext
+	binding.SchemaVersion = len(migrations)
+	return true, nil
+}
+
+// migrate decrypts the given ciphertext, applies every migration from the
+// given schema version onward to the resulting JSON, and returns the result,
+// re-encrypted and bound to the same associated data. If no migrations apply,
+// the ciphertext is returned unmodified.
+func migrate(
+	ciphertext []byte,
+	associatedData []byte,
+	schemaVersion int,
+	migrations []ContextMigration,
+	codec crypto.Codec,
+) ([]byte, error) {
+	if schemaVersion > len(migrations) {
+		return nil, fmt.Errorf(
+			"schema version %d is newer than the current schema version %d; was "+
+				"it written by a newer version of the broker?",
+			schemaVersion,
+			len(migrations),
+		)
+	}
+	if schemaVersion == len(migrations) || len(ciphertext) == 0 {
+		return ciphertext, nil
+	}
+	plaintext, err := codec.Decrypt(ciphertext, associatedData)
+	if err != nil {
+		return nil, err
+	}
+	context := map[string]interface{}{}
+	if err = json.Unmarshal(plaintext, &context); err != nil {
+		return nil, err
+	}
+	for version := schemaVersion; version < len(migrations); version++ {
+		if err = migrations[version](context); err != nil {
+			return nil, fmt.Errorf(
+				"error upgrading from schema version %d to %d: %s",
+				version,
+				version+1,
+				err,
+			)
+		}
+	}
+	if plaintext, err = json.Marshal(context); err != nil {
+		return nil, err
+	}
+	return codec.Encrypt(plaintext, associatedData)
+}
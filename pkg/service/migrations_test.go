@@ -0,0 +1,108 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testMigrationServiceID = "test-service-id"
+
+func getTestMigrationRegistry() MigrationRegistry {
+	registry := NewMigrationRegistry()
+	// Version 0 -> 1 renames "foo" to "bar"
+	registry.RegisterProvisioningContextMigration(
+		testMigrationServiceID,
+		func(context map[string]interface{}) error {
+			context["bar"] = context["foo"]
+			delete(context, "foo")
+			return nil
+		},
+	)
+	// Version 1 -> 2 adds "baz"
+	registry.RegisterProvisioningContextMigration(
+		testMigrationServiceID,
+		func(context map[string]interface{}) error {
+			context["baz"] = "bat"
+			return nil
+		},
+	)
+	return registry
+}
+
+func TestMigrateInstance(t *testing.T) {
+	registry := getTestMigrationRegistry()
+	assert.Equal(
+		t,
+		2,
+		registry.GetProvisioningContextSchemaVersion(testMigrationServiceID),
+	)
+	instance := &Instance{
+		ServiceID: testMigrationServiceID,
+	}
+	err := instance.SetProvisioningContext(testArbitraryObject, noopCodec)
+	assert.Nil(t, err)
+	migrated, err := registry.MigrateInstance(instance, noopCodec)
+	assert.Nil(t, err)
+	assert.True(t, migrated)
+	assert.Equal(t, 2, instance.SchemaVersion)
+	context := map[string]interface{}{}
+	err = instance.GetProvisioningContext(&context, noopCodec)
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		map[string]interface{}{"bar": fooValue, "baz": "bat"},
+		context,
+	)
+	// Migrating again should be a no-op
+	migrated, err = registry.MigrateInstance(instance, noopCodec)
+	assert.Nil(t, err)
+	assert.False(t, migrated)
+}
+
+func TestMigrateInstanceFromIntermediateVersion(t *testing.T) {
+	registry := getTestMigrationRegistry()
+	instance := &Instance{
+		ServiceID:     testMigrationServiceID,
+		SchemaVersion: 1,
+	}
+	err := instance.SetProvisioningContext(testArbitraryObject, noopCodec)
+	assert.Nil(t, err)
+	migrated, err := registry.MigrateInstance(instance, noopCodec)
+	assert.Nil(t, err)
+	assert.True(t, migrated)
+	context := map[string]interface{}{}
+	err = instance.GetProvisioningContext(&context, noopCodec)
+	assert.Nil(t, err)
+	// Only the second migration should have been applied
+	assert.Equal(
+		t,
+		map[string]interface{}{"foo": fooValue, "baz": "bat"},
+		context,
+	)
+}
+
+func TestMigrateInstanceFromNewerVersion(t *testing.T) {
+	registry := getTestMigrationRegistry()
+	instance := &Instance{
+		ServiceID:     testMigrationServiceID,
+		SchemaVersion: 3,
+	}
+	_, err := registry.MigrateInstance(instance, noopCodec)
+	assert.NotNil(t, err)
+}
+
+func TestMigrateBindingWithNoMigrations(t *testing.T) {
+	registry := getTestMigrationRegistry()
+	binding := &Binding{}
+	err := binding.SetBindingContext(testArbitraryObject, noopCodec)
+	assert.Nil(t, err)
+	migrated, err := registry.MigrateBinding(
+		binding,
+		testMigrationServiceID,
+		noopCodec,
+	)
+	assert.Nil(t, err)
+	assert.False(t, migrated)
+	assert.Equal(t, 0, binding.SchemaVersion)
+}
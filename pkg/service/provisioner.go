@@ -1,9 +1,6 @@
 package service
 
-import (
-	"context"
-	"fmt"
-)
+import "context"
 
 // ProvisioningStepFunction is the signature for functions that implement a
 // provisioning step
@@ -16,10 +13,40 @@ type ProvisioningStepFunction func(
 	params ProvisioningParameters,
 ) (ProvisioningContext, error)
 
+// ProvisioningCompensationFunction is the signature for functions that undo
+// the effects of a provisioning step that completed successfully
+type ProvisioningCompensationFunction func(
+	ctx context.Context,
+	instanceID string,
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	params ProvisioningParameters,
+) error
+
+// ProvisioningStepPredicate is the signature for functions that determine,
+// from the plan, parameters and context of a provisioning process, whether a
+// provisioning step should be skipped. They return true if the step should be
+// skipped.
+type ProvisioningStepPredicate func(
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	params ProvisioningParameters,
+) (bool, error)
+
 // ProvisioningStep is an interface to be implemented by types that represent
 // a single step in a chain of steps that defines a provisioning process
 type ProvisioningStep interface {
 	GetName() string
+	// IsSkipped returns a bool indicating whether, given the plan, parameters
+	// and context of the provisioning process, the step should be skipped
+	IsSkipped(
+		plan Plan,
+		standardProvisioningContext StandardProvisioningContext,
+		provisioningContext ProvisioningContext,
+		params ProvisioningParameters,
+	) (bool, error)
 	Execute(
 		ctx context.Context,
 		instanceID string,
@@ -28,25 +55,58 @@ type ProvisioningStep interface {
 		provisioningContext ProvisioningContext,
 		params ProvisioningParameters,
 	) (ProvisioningContext, error)
+	// HasCompensation returns a bool indicating whether the step declares a
+	// compensation
+	HasCompensation() bool
+	// Compensate undoes the effects of the step's successful execution. It is
+	// invoked when a later step in the chain fails permanently. For steps that
+	// declare no compensation, it does nothing.
+	Compensate(
+		ctx context.Context,
+		instanceID string,
+		plan Plan,
+		standardProvisioningContext StandardProvisioningContext,
+		provisioningContext ProvisioningContext,
+		params ProvisioningParameters,
+	) error
 }
 
 type provisioningStep struct {
-	name string
-	fn   ProvisioningStepFunction
+	name           string
+	fn             ProvisioningStepFunction
+	compensationFn ProvisioningCompensationFunction
+}
+
+type conditionalProvisioningStep struct {
+	ProvisioningStep
+	skipFn ProvisioningStepPredicate
 }
 
 // Provisioner is an interface to be implemented by types that model a declared
-// chain of tasks used to asynchronously provision a service
+// graph of tasks used to asynchronously provision a service
 type Provisioner interface {
-	GetFirstStepName() (string, bool)
+	StepGraph
 	GetStep(name string) (ProvisioningStep, bool)
-	GetNextStepName(name string) (string, bool)
 }
 
 type provisioner struct {
-	firstStepName string
-	steps         map[string]ProvisioningStep
-	nextSteps     map[string]string
+	*stepGraph
+	steps map[string]ProvisioningStep
+}
+
+// ProvisionerBuilder is an interface to be implemented by types that declare
+// the steps of a provisioning process and the dependencies among them
+type ProvisionerBuilder interface {
+	// AddStep declares a step that may be executed once all of the named steps
+	// have completed. Steps must be declared after the steps they depend on.
+	AddStep(step ProvisioningStep, dependencies ...string) ProvisionerBuilder
+	// Build returns a Provisioner that executes the declared steps
+	Build() (Provisioner, error)
+}
+
+type provisionerBuilder struct {
+	provisioner *provisioner
+	err         error
 }
 
 // NewProvisioningStep returns a new ProvisioningStep
@@ -60,11 +120,49 @@ func NewProvisioningStep(
 	}
 }
 
+// NewProvisioningStepWithCompensation returns a new ProvisioningStep that
+// declares a compensation. If a later step fails permanently, the
+// compensation is invoked to undo the effects of this step.
+func NewProvisioningStepWithCompensation(
+	name string,
+	fn ProvisioningStepFunction,
+	compensationFn ProvisioningCompensationFunction,
+) ProvisioningStep {
+	return &provisioningStep{
+		name:           name,
+		fn:             fn,
+		compensationFn: compensationFn,
+	}
+}
+
+// NewConditionalProvisioningStep returns a new ProvisioningStep that wraps the
+// given one and is skipped whenever the given predicate returns true
+func NewConditionalProvisioningStep(
+	step ProvisioningStep,
+	skipFn ProvisioningStepPredicate,
+) ProvisioningStep {
+	return &conditionalProvisioningStep{
+		ProvisioningStep: step,
+		skipFn:           skipFn,
+	}
+}
+
 // GetName returns a provisioning step's name
 func (p *provisioningStep) GetName() string {
 	return p.name
 }
 
+// IsSkipped returns a bool indicating whether the step should be skipped.
+// Unconditional steps are never skipped.
+func (p *provisioningStep) IsSkipped(
+	Plan,
+	StandardProvisioningContext,
+	ProvisioningContext,
+	ProvisioningParameters,
+) (bool, error) {
+	return false, nil
+}
+
 // Execute executes a step
 func (p *provisioningStep) Execute(
 	ctx context.Context,
@@ -86,38 +184,99 @@ func (p *provisioningStep) Execute(
 	)
 }
 
-// NewProvisioner returns a new provisioner
-func NewProvisioner(steps ...ProvisioningStep) (Provisioner, error) {
-	p := &provisioner{
-		steps:     make(map[string]ProvisioningStep),
-		nextSteps: make(map[string]string),
+// HasCompensation returns a bool indicating whether the step declares a
+// compensation
+func (p *provisioningStep) HasCompensation() bool {
+	return p.compensationFn != nil
+}
+
+// Compensate undoes the effects of the step's successful execution. For steps
+// that declare no compensation, it does nothing.
+func (p *provisioningStep) Compensate(
+	ctx context.Context,
+	instanceID string,
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	params ProvisioningParameters,
+) error {
+	if p.compensationFn == nil {
+		return nil
 	}
-	if len(steps) > 0 {
-		p.firstStepName = steps[0].GetName()
-		var lastStep ProvisioningStep
-		for _, step := range steps {
-			_, ok := p.steps[step.GetName()]
-			if ok {
-				// This means a duplicate step name has been detected. This is a serious
-				// problem.
-				return nil, fmt.Errorf(
-					`duplicate step name "%s" detected`,
-					step.GetName(),
-				)
-			}
-			p.steps[step.GetName()] = step
-			if lastStep != nil {
-				p.nextSteps[lastStep.GetName()] = step.GetName()
-			}
-			lastStep = step
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return p.compensationFn(
+		ctx,
+		instanceID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+		params,
+	)
+}
+
+// IsSkipped returns a bool indicating whether, given the plan, parameters and
+// context of the provisioning process, the step should be skipped
+func (c *conditionalProvisioningStep) IsSkipped(
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	params ProvisioningParameters,
+) (bool, error) {
+	return c.skipFn(
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+		params,
+	)
+}
+
+// NewProvisioner returns a new provisioner that executes the given steps one
+// at a time, in order
+func NewProvisioner(steps ...ProvisioningStep) (Provisioner, error) {
+	builder := NewProvisionerBuilder()
+	for i, step := range steps {
+		if i == 0 {
+			builder.AddStep(step)
+		} else {
+			builder.AddStep(step, steps[i-1].GetName())
 		}
 	}
-	return p, nil
+	return builder.Build()
 }
 
-// GetFirstStepName retrieves the name of the first step in the chain
-func (p *provisioner) GetFirstStepName() (string, bool) {
-	return p.firstStepName, (p.firstStepName != "")
+// NewProvisionerBuilder returns a new ProvisionerBuilder
+func NewProvisionerBuilder() ProvisionerBuilder {
+	return &provisionerBuilder{
+		provisioner: &provisioner{
+			stepGraph: newStepGraph(),
+			steps:     make(map[string]ProvisioningStep),
+		},
+	}
+}
+
+// AddStep declares a step that may be executed once all of the named steps
+// have completed. Steps must be declared after the steps they depend on.
+func (p *provisionerBuilder) AddStep(
+	step ProvisioningStep,
+	dependencies ...string,
+) ProvisionerBuilder {
+	if p.err != nil {
+		return p
+	}
+	p.err = p.provisioner.addStep(step.GetName(), dependencies)
+	if p.err == nil {
+		p.provisioner.steps[step.GetName()] = step
+	}
+	return p
+}
+
+// Build returns a Provisioner that executes the declared steps
+func (p *provisionerBuilder) Build() (Provisioner, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.provisioner, nil
 }
 
 // GetStep retrieves a step by name
@@ -125,10 +284,3 @@ func (p *provisioner) GetStep(name string) (ProvisioningStep, bool) {
 	step, ok := p.steps[name]
 	return step, ok
 }
-
-// GetNextStepName, given the name of one step, returns the name of the next
-// step and a boolean indicating whether a next step actually exists
-func (p *provisioner) GetNextStepName(name string) (string, bool) {
-	nextStepName, ok := p.nextSteps[name]
-	return nextStepName, ok
-}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisionerBuilderRejectsUndeclaredDependencies(t *testing.T) {
+	_, err := NewProvisionerBuilder().
+		AddStep(NewProvisioningStep("foo", nil), "bar").
+		AddStep(NewProvisioningStep("bar", nil)).
+		Build()
+	assert.NotNil(t, err)
+}
+
+func TestProvisionerBuilderRejectsDuplicateSteps(t *testing.T) {
+	_, err := NewProvisionerBuilder().
+		AddStep(NewProvisioningStep("foo", nil)).
+		AddStep(NewProvisioningStep("foo", nil)).
+		Build()
+	assert.NotNil(t, err)
+}
+
+func TestNewProvisionerExecutesStepsInOrder(t *testing.T) {
+	p, err := NewProvisioner(
+		NewProvisioningStep("foo", nil),
+		NewProvisioningStep("bar", nil),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, p.GetFirstStepNames())
+	assert.Equal(
+		t,
+		[]string{"bar"},
+		p.GetNextStepNames("foo", []string{"foo"}),
+	)
+	assert.Empty(t, p.GetNextStepNames("bar", []string{"foo", "bar"}))
+	_, ok := p.GetStep("bar")
+	assert.True(t, ok)
+}
+
+func TestProvisioningStepWithoutCompensation(t *testing.T) {
+	step := NewProvisioningStep("foo", nil)
+	assert.False(t, step.HasCompensation())
+	err := step.Compensate(
+		context.Background(),
+		"",
+		nil,
+		StandardProvisioningContext{},
+		nil,
+		nil,
+	)
+	assert.Nil(t, err)
+}
+
+func TestProvisioningStepWithCompensation(t *testing.T) {
+	var compensated bool
+	step := NewProvisioningStepWithCompensation(
+		"foo",
+		nil,
+		func(
+			context.Context,
+			string,
+			Plan,
+			StandardProvisioningContext,
+			ProvisioningContext,
+			ProvisioningParameters,
+		) error {
+			compensated = true
+			return nil
+		},
+	)
+	assert.True(t, step.HasCompensation())
+	err := step.Compensate(
+		context.Background(),
+		"",
+		nil,
+		StandardProvisioningContext{},
+		nil,
+		nil,
+	)
+	assert.Nil(t, err)
+	assert.True(t, compensated)
+}
+
+func TestUnconditionalProvisioningStepIsNotSkipped(t *testing.T) {
+	step := NewProvisioningStep("foo", nil)
+	skipped, err := step.IsSkipped(nil, StandardProvisioningContext{}, nil, nil)
+	assert.Nil(t, err)
+	assert.False(t, skipped)
+}
+
+func TestConditionalProvisioningStep(t *testing.T) {
+	testCases := []struct {
+		name            string
+		skip            bool
+		expectedSkipped bool
+	}{
+		{
+			name:            "predicate returns true",
+			skip:            true,
+			expectedSkipped: true,
+		},
+		{
+			name:            "predicate returns false",
+			skip:            false,
+			expectedSkipped: false,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			skip := testCase.skip
+			step := NewConditionalProvisioningStep(
+				NewProvisioningStepWithCompensation("foo", nil, nil),
+				func(
+					Plan,
+					StandardProvisioningContext,
+					ProvisioningContext,
+					ProvisioningParameters,
+				) (bool, error) {
+					return skip, nil
+				},
+			)
+			// The wrapped step's name is preserved
+			assert.Equal(t, "foo", step.GetName())
+			skipped, err := step.IsSkipped(
+				nil,
+				StandardProvisioningContext{},
+				nil,
+				nil,
+			)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expectedSkipped, skipped)
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// schemaVersion is the version of JSON Schema that generated schemas conform
+// to. The OSB spec requires it to be declared in each parameters schema.
+const schemaVersion = "http://json-schema.org/draft-04/schema#"
+
+// PlanSchemas represents the schemas for the parameters that a plan accepts
+// when provisioning, updating, or binding
+type PlanSchemas struct {
+	ServiceInstance ServiceInstanceSchemas `json:"service_instance"`
+	ServiceBinding  ServiceBindingSchemas  `json:"service_binding"`
+}
+
+// ServiceInstanceSchemas represents the schemas for the parameters that a plan
+// accepts when provisioning or updating a service instance
+type ServiceInstanceSchemas struct {
+	Create *InputParametersSchema `json:"create,omitempty"`
+	Update *InputParametersSchema `json:"update,omitempty"`
+}
+
+// ServiceBindingSchemas represents the schema for the parameters that a plan
+// accepts when binding to a service instance
+type ServiceBindingSchemas struct {
+	Create *InputParametersSchema `json:"create,omitempty"`
+}
+
+// InputParametersSchema wraps the schema for the parameters of a single
+// operation
+type InputParametersSchema struct {
+	Parameters *Schema `json:"parameters"`
+}
+
+// Schema represents the subset of JSON Schema that can be derived from a
+// module's parameter types. Schemas are derived from the json struct tags of
+// those types, supplemented by directives specified in jsonschema struct tags.
+// Supported directives are:
+//
+//	required            The field must be specified
+//	enum=a|b|c          The field's value must be one of those listed; string
+//	                    values are matched case-insensitively
+//	minimum=n           The field's (numeric) value must be n or more
+//	maximum=n           The field's (numeric) value must be n or less
+//
+// Directives are comma-delimited; e.g. `jsonschema:"required,minimum=1"`.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// GetPlanSchemas derives schemas for the provisioning, updating, and binding
+// parameters accepted by the given service manager. Standard provisioning
+// parameters are included in the schema for provisioning parameters.
+func GetPlanSchemas(serviceManager ServiceManager) (*PlanSchemas, error) {
+	standardProvisioningSchema, err := NewSchema(
+		StandardProvisioningParameters{},
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error deriving schema for standard provisioning parameters: %s",
+			err,
+		)
+	}
+	provisioningSchema, err := NewSchema(
+		serviceManager.GetEmptyProvisioningParameters(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error deriving schema for provisioning parameters: %s",
+			err,
+		)
+	}
+	for name, property := range standardProvisioningSchema.Properties {
+		if _, ok := provisioningSchema.Properties[name]; ok {
+			return nil, fmt.Errorf(
+				`provisioning parameter "%s" conflicts with the standard `+
+					`provisioning parameter of the same name`,
+				name,
+			)
+		}
+		provisioningSchema.Properties[name] = property
+	}
+	provisioningSchema.Required = append(
+		provisioningSchema.Required,
+		standardProvisioningSchema.Required...,
+	)
+	updatingSchema, err := NewSchema(
+		serviceManager.GetEmptyUpdatingParameters(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error deriving schema for updating parameters: %s",
+			err,
+		)
+	}
+	bindingSchema, err := NewSchema(
+		serviceManager.GetEmptyBindingParameters(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error deriving schema for binding parameters: %s",
+			err,
+		)
+	}
+	return &PlanSchemas{
+		ServiceInstance: ServiceInstanceSchemas{
+			Create: &InputParametersSchema{Parameters: provisioningSchema},
+			Update: &InputParametersSchema{Parameters: updatingSchema},
+		},
+		ServiceBinding: ServiceBindingSchemas{
+			Create: &InputParametersSchema{Parameters: bindingSchema},
+		},
+	}, nil
+}
+
+// NewSchema derives a schema from the type of the given struct (or pointer to
+// a struct)
+func NewSchema(obj interface{}) (*Schema, error) {
+	t := reflect.TypeOf(obj)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf(
+			"can't derive a parameters schema from non-struct type %v",
+			t,
+		)
+	}
+	schema, err := newSchemaForType(t)
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = schemaVersion
+	return schema, nil
+}
+
+func newSchemaForType(t reflect.Type) (*Schema, error) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return newSchemaForType(t.Elem())
+	case reflect.Interface:
+		// Any value is acceptable
+		return &Schema{}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := newSchemaForType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %v", t.Key())
+		}
+		additionalProperties, err := newSchemaForType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{
+			Type:                 "object",
+			AdditionalProperties: additionalProperties,
+		}, nil
+	case reflect.Struct:
+		return newSchemaForStruct(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %v", t)
+	}
+}
+
+func newSchemaForStruct(t reflect.Type) (*Schema, error) {
+	schema := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // Unexported
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			// Like encoding/json, promote the fields of embedded structs
+			embeddedSchema, err := newSchemaForStruct(fieldType)
+			if err != nil {
+				return nil, err
+			}
+			for propertyName, property := range embeddedSchema.Properties {
+				schema.Properties[propertyName] = property
+			}
+			schema.Required = append(schema.Required, embeddedSchema.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property, err := newSchemaForType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf(
+				`error deriving schema for field "%s": %s`,
+				name,
+				err,
+			)
+		}
+		required, err := property.applyDirectives(field.Tag.Get("jsonschema"))
+		if err != nil {
+			return nil, fmt.Errorf(
+				`error deriving schema for field "%s": %s`,
+				name,
+				err,
+			)
+		}
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema, nil
+}
+
+// applyDirectives applies the directives in the given jsonschema struct tag to
+// the schema and returns a bool indicating whether the field is required
+func (s *Schema) applyDirectives(tag string) (bool, error) {
+	var required bool
+	for _, directive := range strings.Split(tag, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		tokens := strings.SplitN(directive, "=", 2)
+		key := tokens[0]
+		var value string
+		if len(tokens) == 2 {
+			value = tokens[1]
+		}
+		switch key {
+		case "required":
+			required = true
+		case "enum":
+			for _, enumValue := range strings.Split(value, "|") {
+				parsedValue, err := s.parseValue(enumValue)
+				if err != nil {
+					return false, err
+				}
+				s.Enum = append(s.Enum, parsedValue)
+			}
+		case "minimum", "maximum":
+			if s.Type != "integer" && s.Type != "number" {
+				return false, fmt.Errorf(
+					`directive "%s" is not applicable to type "%s"`,
+					key,
+					s.Type,
+				)
+			}
+			limit, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf(`invalid %s "%s"`, key, value)
+			}
+			if key == "minimum" {
+				s.Minimum = &limit
+			} else {
+				s.Maximum = &limit
+			}
+		default:
+			return false, fmt.Errorf(`unknown directive "%s"`, key)
+		}
+	}
+	return required, nil
+}
+
+// parseValue parses the string representation of a value (from a struct tag)
+// of the schema's type
+func (s *Schema) parseValue(value string) (interface{}, error) {
+	switch s.Type {
+	case "string":
+		return value, nil
+	case "integer":
+		parsedValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid integer "%s"`, value)
+		}
+		return parsedValue, nil
+	case "number":
+		parsedValue, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid number "%s"`, value)
+		}
+		return parsedValue, nil
+	default:
+		return nil, fmt.Errorf(
+			`enumerated values are not applicable to type "%s"`,
+			s.Type,
+		)
+	}
+}
+
+// Validate validates the given value (typically a parameter map unmarshaled
+// from JSON) against the schema. A *ValidationError identifying the first
+// invalid field is returned if the value doesn't conform. Null values are
+// treated as though they were unspecified, as are empty strings where only
+// enumerated values are permitted, since the parameter types that schemas are
+// derived from cannot tell those apart from an unspecified value either.
+func (s *Schema) Validate(value interface{}) error {
+	return s.validate("", value)
+}
+
+func (s *Schema) validate(field string, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	switch s.Type {
+	case "string":
+		if v.Kind() != reflect.String {
+			return NewValidationError(field, "must be a string")
+		}
+		if v.String() == "" {
+			return nil
+		}
+	case "boolean":
+		if v.Kind() != reflect.Bool {
+			return NewValidationError(field, "must be a boolean")
+		}
+	case "integer", "number":
+		number, ok := toFloat(v)
+		if !ok {
+			return NewValidationError(field, fmt.Sprintf("must be a %s", s.Type))
+		}
+		if s.Type == "integer" && number != math.Trunc(number) {
+			return NewValidationError(field, "must be an integer")
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			return NewValidationError(
+				field,
+				fmt.Sprintf("must be greater than or equal to %v", *s.Minimum),
+			)
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			return NewValidationError(
+				field,
+				fmt.Sprintf("must be less than or equal to %v", *s.Maximum),
+			)
+		}
+	case "array":
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return NewValidationError(field, "must be an array")
+		}
+		if s.Items != nil {
+			for i := 0; i < v.Len(); i++ {
+				if err := s.Items.validate(
+					fmt.Sprintf("%s[%d]", field, i),
+					v.Index(i).Interface(),
+				); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return NewValidationError(field, "must be an object")
+		}
+		for _, name := range s.Required {
+			propertyValue := v.MapIndex(reflect.ValueOf(name))
+			if !propertyValue.IsValid() || propertyValue.Interface() == nil {
+				return NewValidationError(
+					getPropertyPath(field, name),
+					"must be specified",
+				)
+			}
+		}
+		for _, key := range v.MapKeys() {
+			name := key.String()
+			property, ok := s.Properties[name]
+			if !ok {
+				property = s.AdditionalProperties
+			}
+			if property == nil {
+				continue
+			}
+			if err := property.validate(
+				getPropertyPath(field, name),
+				v.MapIndex(key).Interface(),
+			); err != nil {
+				return err
+			}
+		}
+	}
+	if len(s.Enum) > 0 && !s.isEnumerated(v) {
+		enumValues := make([]string, len(s.Enum))
+		for i, enumValue := range s.Enum {
+			enumValues[i] = fmt.Sprintf(`"%v"`, enumValue)
+		}
+		return NewValidationError(
+			field,
+			fmt.Sprintf("must be one of %s", strings.Join(enumValues, ", ")),
+		)
+	}
+	return nil
+}
+
+// isEnumerated returns a bool indicating whether the given value is one of the
+// schema's enumerated values. Strings are compared case-insensitively.
+func (s *Schema) isEnumerated(v reflect.Value) bool {
+	number, isNumber := toFloat(v)
+	for _, enumValue := range s.Enum {
+		if isNumber {
+			if enumNumber, ok := toFloat(reflect.ValueOf(enumValue)); ok &&
+				enumNumber == number {
+				return true
+			}
+		} else if v.Kind() == reflect.String {
+			if enumString, ok := enumValue.(string); ok &&
+				strings.EqualFold(v.String(), enumString) {
+				return true
+			}
+		} else if v.Interface() == enumValue {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+func getPropertyPath(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return fmt.Sprintf("%s.%s", field, name)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testParameters struct {
+	Foo string            `json:"foo" jsonschema:"required,enum=a|b"`
+	Bar int               `json:"bar,omitempty" jsonschema:"minimum=1,maximum=4"`
+	Baz []float64         `json:"baz"`
+	Bat map[string]string `json:"bat"`
+	Qux bool              `json:"-"`
+}
+
+func TestNewSchema(t *testing.T) {
+	schema, err := NewSchema(&testParameters{})
+	assert.Nil(t, err)
+	minimum := 1.0
+	maximum := 4.0
+	assert.Equal(
+		t,
+		&Schema{
+			Schema: schemaVersion,
+			Type:   "object",
+			Properties: map[string]*Schema{
+				"foo": {
+					Type: "string",
+					Enum: []interface{}{"a", "b"},
+				},
+				"bar": {
+					Type:    "integer",
+					Minimum: &minimum,
+					Maximum: &maximum,
+				},
+				"baz": {
+					Type:  "array",
+					Items: &Schema{Type: "number"},
+				},
+				"bat": {
+					Type:                 "object",
+					AdditionalProperties: &Schema{Type: "string"},
+				},
+			},
+			Required: []string{"foo"},
+		},
+		schema,
+	)
+}
+
+func TestNewSchemaWithInvalidDirective(t *testing.T) {
+	_, err := NewSchema(&struct {
+		Foo string `json:"foo" jsonschema:"minimum=1"`
+	}{})
+	assert.NotNil(t, err)
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := NewSchema(&testParameters{})
+	assert.Nil(t, err)
+	testCases := []struct {
+		name          string
+		params        map[string]interface{}
+		expectedField string
+	}{
+		{
+			name: "valid",
+			params: map[string]interface{}{
+				"foo":     "a",
+				"bar":     float64(2),
+				"baz":     []interface{}{1.5},
+				"bat":     map[string]interface{}{"x": "y"},
+				"unknown": "whatever",
+			},
+		},
+		{
+			name:          "missing required field",
+			params:        map[string]interface{}{"bar": float64(2)},
+			expectedField: "foo",
+		},
+		{
+			name:   "enumerated value in a different case",
+			params: map[string]interface{}{"foo": "A"},
+		},
+		{
+			name:   "empty string in place of an enumerated value",
+			params: map[string]interface{}{"foo": ""},
+		},
+		{
+			name:          "value not enumerated",
+			params:        map[string]interface{}{"foo": "c"},
+			expectedField: "foo",
+		},
+		{
+			name: "value of wrong type",
+			params: map[string]interface{}{
+				"foo": "a",
+				"bar": "2",
+			},
+			expectedField: "bar",
+		},
+		{
+			name: "value not an integer",
+			params: map[string]interface{}{
+				"foo": "a",
+				"bar": 1.5,
+			},
+			expectedField: "bar",
+		},
+		{
+			name: "value out of range",
+			params: map[string]interface{}{
+				"foo": "a",
+				"bar": float64(5),
+			},
+			expectedField: "bar",
+		},
+		{
+			name: "invalid array item",
+			params: map[string]interface{}{
+				"foo": "a",
+				"baz": []interface{}{1.5, "2.5"},
+			},
+			expectedField: "baz[1]",
+		},
+		{
+			name: "invalid additional property",
+			params: map[string]interface{}{
+				"foo": "a",
+				"bat": map[string]interface{}{"x": 1},
+			},
+			expectedField: "bat.x",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := schema.Validate(testCase.params)
+			if testCase.expectedField == "" {
+				assert.Nil(t, err)
+				return
+			}
+			validationErr, ok := err.(*ValidationError)
+			assert.True(t, ok)
+			if ok {
+				assert.Equal(t, testCase.expectedField, validationErr.Field)
+			}
+		})
+	}
+}
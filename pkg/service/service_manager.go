@@ -30,20 +30,18 @@ type ServiceManager interface { // nolint: golint
 	// ValidateBindingParameters validates the provided bindingParameters and
 	// returns an error if there is any problem
 	ValidateBindingParameters(BindingParameters) error
-	// Bind synchronously binds to a service
-	Bind(
-		StandardProvisioningContext,
-		ProvisioningContext,
-		BindingParameters,
-	) (BindingContext, Credentials, error)
+	// GetBinder returns a binder that defines the steps a module must execute
+	// to bind to a service
+	GetBinder(Plan) (Binder, error)
 	// GetEmptyBindingContext returns an empty instance of a module-specific
 	// bindingContext
 	GetEmptyBindingContext() BindingContext
 	// GetEmptyCredentials returns an empty instance of module-specific
 	// credentials
 	GetEmptyCredentials() Credentials
-	// Unbind synchronously unbinds from a service
-	Unbind(StandardProvisioningContext, ProvisioningContext, BindingContext) error
+	// GetUnbinder returns an unbinder that defines the steps a module must
+	// execute to unbind from a service
+	GetUnbinder(Plan) (Unbinder, error)
 	// GetDeprovisioner returns a deprovisioner that defines the steps a module
 	// must execute asynchronously to deprovision a service
 	GetDeprovisioner(Plan) (Deprovisioner, error)
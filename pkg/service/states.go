@@ -27,12 +27,18 @@ const (
 	// InstanceStateDeprovisioningFailed represents the state where service
 	// instance deprovisioning has failed
 	InstanceStateDeprovisioningFailed = "DEPROVISIONING_FAILED"
+	// BindingStateBinding represents the state where service binding is in
+	// progress
+	BindingStateBinding = "BINDING"
 	// BindingStateBound represents the state where service binding has completed
 	// successfully
 	BindingStateBound = "BOUND"
 	// BindingStateBindingFailed represents the state where service binding has
 	// failed
 	BindingStateBindingFailed = "BINDING_FAILED"
+	// BindingStateUnbinding represents the state where service unbinding is in
+	// progress
+	BindingStateUnbinding = "UNBINDING"
 	// BindingStateUnbindingFailed represents the state where service unbinding
 	// has failed
 	BindingStateUnbindingFailed = "UNBINDING_FAILED"
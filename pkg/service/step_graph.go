@@ -0,0 +1,125 @@
+package service
+
+import "fmt"
+
+// StepGraph is an interface to be implemented by types that model the steps
+// of an asynchronous process and the dependencies among them. A step may be
+// executed once all the steps it depends on have completed, so steps that
+// don't depend on one another may be executed concurrently.
+type StepGraph interface {
+	// GetStepNames returns the names of all steps, ordered such that every step
+	// follows all the steps it depends on
+	GetStepNames() []string
+	// GetFirstStepNames returns the names of the steps that depend on no other
+	// steps
+	GetFirstStepNames() []string
+	// GetNextStepNames, given the name of a step that has just completed and the
+	// names of all steps that have completed thus far, returns the names of the
+	// steps that depend on the given step and have become ready for execution
+	GetNextStepNames(name string, completedStepNames []string) []string
+	// IsComplete returns a bool indicating whether, given the names of all steps
+	// that have completed thus far, every step has completed
+	IsComplete(completedStepNames []string) bool
+}
+
+type stepGraph struct {
+	stepNames    []string
+	dependencies map[string][]string
+	dependents   map[string][]string
+}
+
+func newStepGraph() *stepGraph {
+	return &stepGraph{
+		stepNames:    []string{},
+		dependencies: make(map[string][]string),
+		dependents:   make(map[string][]string),
+	}
+}
+
+// addStep adds the named step to the graph. All of the step's dependencies
+// must already have been added, which guarantees the graph is acyclic.
+func (s *stepGraph) addStep(name string, dependencies []string) error {
+	if _, ok := s.dependencies[name]; ok {
+		// This means a duplicate step name has been detected. This is a serious
+		// problem.
+		return fmt.Errorf(`duplicate step name "%s" detected`, name)
+	}
+	for _, dependency := range dependencies {
+		if _, ok := s.dependencies[dependency]; !ok {
+			return fmt.Errorf(
+				`step "%s" depends on undeclared step "%s"`,
+				name,
+				dependency,
+			)
+		}
+	}
+	s.stepNames = append(s.stepNames, name)
+	s.dependencies[name] = dependencies
+	for _, dependency := range dependencies {
+		s.dependents[dependency] = append(s.dependents[dependency], name)
+	}
+	return nil
+}
+
+// GetStepNames returns the names of all steps, ordered such that every step
+// follows all the steps it depends on
+func (s *stepGraph) GetStepNames() []string {
+	return append([]string{}, s.stepNames...)
+}
+
+// GetFirstStepNames returns the names of the steps that depend on no other
+// steps
+func (s *stepGraph) GetFirstStepNames() []string {
+	firstStepNames := []string{}
+	for _, stepName := range s.stepNames {
+		if len(s.dependencies[stepName]) == 0 {
+			firstStepNames = append(firstStepNames, stepName)
+		}
+	}
+	return firstStepNames
+}
+
+// GetNextStepNames, given the name of a step that has just completed and the
+// names of all steps that have completed thus far, returns the names of the
+// steps that depend on the given step and have become ready for execution
+func (s *stepGraph) GetNextStepNames(
+	name string,
+	completedStepNames []string,
+) []string {
+	completed := make(map[string]bool, len(completedStepNames))
+	for _, completedStepName := range completedStepNames {
+		completed[completedStepName] = true
+	}
+	nextStepNames := []string{}
+	for _, dependent := range s.dependents[name] {
+		if completed[dependent] {
+			continue
+		}
+		ready := true
+		for _, dependency := range s.dependencies[dependent] {
+			if !completed[dependency] {
+				ready = false
+				break
+			}
+		}
+		if ready {
+			nextStepNames = append(nextStepNames, dependent)
+		}
+	}
+	return nextStepNames
+}
+
+// IsComplete returns a bool indicating whether, given the names of all steps
+// that have completed thus far, every step has completed
+func (s *stepGraph) IsComplete(completedStepNames []string) bool {
+	completed := make(map[string]bool, len(completedStepNames))
+	for _, completedStepName := range completedStepNames {
+		completed[completedStepName] = true
+	}
+	for _, stepName := range s.stepNames {
+		if !completed[stepName] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestStepGraph(t *testing.T) *stepGraph {
+	// foo --> bar --> bat
+	//     \-> baz -/
+	s := newStepGraph()
+	assert.Nil(t, s.addStep("foo", nil))
+	assert.Nil(t, s.addStep("bar", []string{"foo"}))
+	assert.Nil(t, s.addStep("baz", []string{"foo"}))
+	assert.Nil(t, s.addStep("bat", []string{"bar", "baz"}))
+	return s
+}
+
+func TestStepGraphGetStepNames(t *testing.T) {
+	s := getTestStepGraph(t)
+	assert.Equal(t, []string{"foo", "bar", "baz", "bat"}, s.GetStepNames())
+}
+
+func TestStepGraphGetFirstStepNames(t *testing.T) {
+	s := getTestStepGraph(t)
+	assert.Equal(t, []string{"foo"}, s.GetFirstStepNames())
+}
+
+func TestStepGraphGetNextStepNamesForksBranches(t *testing.T) {
+	s := getTestStepGraph(t)
+	assert.Equal(
+		t,
+		[]string{"bar", "baz"},
+		s.GetNextStepNames("foo", []string{"foo"}),
+	)
+}
+
+func TestStepGraphGetNextStepNamesJoinsBranches(t *testing.T) {
+	s := getTestStepGraph(t)
+	// Until both branches have completed, the step that joins them isn't ready
+	assert.Empty(t, s.GetNextStepNames("bar", []string{"foo", "bar"}))
+	assert.Equal(
+		t,
+		[]string{"bat"},
+		s.GetNextStepNames("baz", []string{"foo", "bar", "baz"}),
+	)
+}
+
+func TestStepGraphIsComplete(t *testing.T) {
+	s := getTestStepGraph(t)
+	assert.False(t, s.IsComplete([]string{"foo", "bar", "baz"}))
+	assert.True(t, s.IsComplete([]string{"foo", "baz", "bar", "bat"}))
+}
+
+func TestStepGraphAddStepRejectsUndeclaredDependencies(t *testing.T) {
+	s := newStepGraph()
+	assert.NotNil(t, s.addStep("foo", []string{"bar"}))
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// UnbindingStepFunction is the signature for functions that implement an
+// unbinding step
+type UnbindingStepFunction func(
+	ctx context.Context,
+	bindingID string,
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	bindingContext BindingContext,
+) (BindingContext, error)
+
+// UnbindingStep is an interface to be implemented by types that represent
+// a single step in a chain of steps that defines an unbinding process
+type UnbindingStep interface {
+	GetName() string
+	Execute(
+		ctx context.Context,
+		bindingID string,
+		plan Plan,
+		standardProvisioningContext StandardProvisioningContext,
+		provisioningContext ProvisioningContext,
+		bindingContext BindingContext,
+	) (BindingContext, error)
+}
+
+type unbindingStep struct {
+	name string
+	fn   UnbindingStepFunction
+}
+
+// Unbinder is an interface to be implemented by types that model a declared
+// graph of tasks used to asynchronously unbind from a service
+type Unbinder interface {
+	StepGraph
+	GetStep(name string) (UnbindingStep, bool)
+}
+
+type unbinder struct {
+	*stepGraph
+	steps map[string]UnbindingStep
+}
+
+// UnbinderBuilder is an interface to be implemented by types that declare the
+// steps of an unbinding process and the dependencies among them
+type UnbinderBuilder interface {
+	// AddStep declares a step that may be executed once all of the named steps
+	// have completed. Steps must be declared after the steps they depend on.
+	AddStep(step UnbindingStep, dependencies ...string) UnbinderBuilder
+	// Build returns an Unbinder that executes the declared steps
+	Build() (Unbinder, error)
+}
+
+type unbinderBuilder struct {
+	unbinder *unbinder
+	err      error
+}
+
+// NewUnbindingStep returns a new UnbindingStep
+func NewUnbindingStep(
+	name string,
+	fn UnbindingStepFunction,
+) UnbindingStep {
+	return &unbindingStep{
+		name: name,
+		fn:   fn,
+	}
+}
+
+// GetName returns an unbinding step's name
+func (u *unbindingStep) GetName() string {
+	return u.name
+}
+
+// Execute executes a step
+func (u *unbindingStep) Execute(
+	ctx context.Context,
+	bindingID string,
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	bindingContext BindingContext,
+) (BindingContext, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return u.fn(
+		ctx,
+		bindingID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+		bindingContext,
+	)
+}
+
+// NewUnbinder returns a new unbinder that executes the given steps one at a
+// time, in order
+func NewUnbinder(steps ...UnbindingStep) (Unbinder, error) {
+	builder := NewUnbinderBuilder()
+	for i, step := range steps {
+		if i == 0 {
+			builder.AddStep(step)
+		} else {
+			builder.AddStep(step, steps[i-1].GetName())
+		}
+	}
+	return builder.Build()
+}
+
+// NewUnbinderBuilder returns a new UnbinderBuilder
+func NewUnbinderBuilder() UnbinderBuilder {
+	return &unbinderBuilder{
+		unbinder: &unbinder{
+			stepGraph: newStepGraph(),
+			steps:     make(map[string]UnbindingStep),
+		},
+	}
+}
+
+// AddStep declares a step that may be executed once all of the named steps
+// have completed. Steps must be declared after the steps they depend on.
+func (u *unbinderBuilder) AddStep(
+	step UnbindingStep,
+	dependencies ...string,
+) UnbinderBuilder {
+	if u.err != nil {
+		return u
+	}
+	u.err = u.unbinder.addStep(step.GetName(), dependencies)
+	if u.err == nil {
+		u.unbinder.steps[step.GetName()] = step
+	}
+	return u
+}
+
+// Build returns an Unbinder that executes the declared steps
+func (u *unbinderBuilder) Build() (Unbinder, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	return u.unbinder, nil
+}
+
+// GetStep retrieves a step by name
+func (u *unbinder) GetStep(name string) (UnbindingStep, bool) {
+	step, ok := u.steps[name]
+	return step, ok
+}
+
+// UnbindSynchronously executes all of the given unbinder's steps, one at a
+// time, in an order that respects their dependencies. It is used to unbind on
+// behalf of clients that don't support asynchronous unbinding.
+func UnbindSynchronously(
+	ctx context.Context,
+	unbinder Unbinder,
+	bindingID string,
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	bindingContext BindingContext,
+) error {
+	for _, stepName := range unbinder.GetStepNames() {
+		step, ok := unbinder.GetStep(stepName)
+		if !ok {
+			return fmt.Errorf(
+				`unbinder does not know how to process step "%s"`,
+				stepName,
+			)
+		}
+		updatedBindingContext, err := step.Execute(
+			ctx,
+			bindingID,
+			plan,
+			standardProvisioningContext,
+			provisioningContext,
+			bindingContext,
+		)
+		if err != nil {
+			return fmt.Errorf(
+				`error executing unbinding step "%s": %s`,
+				stepName,
+				err,
+			)
+		}
+		bindingContext = updatedBindingContext
+	}
+	return nil
+}
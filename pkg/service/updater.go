@@ -1,9 +1,6 @@
 package service
 
-import (
-	"context"
-	"fmt"
-)
+import "context"
 
 // UpdatingStepFunction is the signature for functions that implement a
 // updating step
@@ -16,10 +13,28 @@ type UpdatingStepFunction func(
 	params UpdatingParameters,
 ) (ProvisioningContext, error)
 
+// UpdatingStepPredicate is the signature for functions that determine, from the
+// plan, parameters and context of an updating process, whether an updating
+// step should be skipped. They return true if the step should be skipped.
+type UpdatingStepPredicate func(
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	params UpdatingParameters,
+) (bool, error)
+
 // UpdatingStep is an interface to be implemented by types that represent
 // a single step in a chain of steps that defines a updating process
 type UpdatingStep interface {
 	GetName() string
+	// IsSkipped returns a bool indicating whether, given the plan, parameters
+	// and context of the updating process, the step should be skipped
+	IsSkipped(
+		plan Plan,
+		standardProvisioningContext StandardProvisioningContext,
+		provisioningContext ProvisioningContext,
+		params UpdatingParameters,
+	) (bool, error)
 	Execute(
 		ctx context.Context,
 		instanceID string,
@@ -35,18 +50,36 @@ type updatingStep struct {
 	fn   UpdatingStepFunction
 }
 
+type conditionalUpdatingStep struct {
+	UpdatingStep
+	skipFn UpdatingStepPredicate
+}
+
 // Updater is an interface to be implemented by types that model a declared
-// chain of tasks used to asynchronously update a service
+// graph of tasks used to asynchronously update a service
 type Updater interface {
-	GetFirstStepName() (string, bool)
+	StepGraph
 	GetStep(name string) (UpdatingStep, bool)
-	GetNextStepName(name string) (string, bool)
 }
 
 type updater struct {
-	firstStepName string
-	steps         map[string]UpdatingStep
-	nextSteps     map[string]string
+	*stepGraph
+	steps map[string]UpdatingStep
+}
+
+// UpdaterBuilder is an interface to be implemented by types that declare
+// the steps of an updating process and the dependencies among them
+type UpdaterBuilder interface {
+	// AddStep declares a step that may be executed once all of the named steps
+	// have completed. Steps must be declared after the steps they depend on.
+	AddStep(step UpdatingStep, dependencies ...string) UpdaterBuilder
+	// Build returns an Updater that executes the declared steps
+	Build() (Updater, error)
+}
+
+type updaterBuilder struct {
+	updater *updater
+	err     error
 }
 
 // NewUpdatingStep returns a new UpdatingStep
@@ -60,11 +93,34 @@ func NewUpdatingStep(
 	}
 }
 
+// NewConditionalUpdatingStep returns a new UpdatingStep that wraps the given
+// one and is skipped whenever the given predicate returns true
+func NewConditionalUpdatingStep(
+	step UpdatingStep,
+	skipFn UpdatingStepPredicate,
+) UpdatingStep {
+	return &conditionalUpdatingStep{
+		UpdatingStep: step,
+		skipFn:       skipFn,
+	}
+}
+
 // GetName returns a updating step's name
 func (u *updatingStep) GetName() string {
 	return u.name
 }
 
+// IsSkipped returns a bool indicating whether the step should be skipped.
+// Unconditional steps are never skipped.
+func (u *updatingStep) IsSkipped(
+	Plan,
+	StandardProvisioningContext,
+	ProvisioningContext,
+	UpdatingParameters,
+) (bool, error) {
+	return false, nil
+}
+
 // Execute executes a step
 func (u *updatingStep) Execute(
 	ctx context.Context,
@@ -86,38 +142,68 @@ func (u *updatingStep) Execute(
 	)
 }
 
-// NewUpdater returns a new updater
+// IsSkipped returns a bool indicating whether, given the plan, parameters and
+// context of the updating process, the step should be skipped
+func (c *conditionalUpdatingStep) IsSkipped(
+	plan Plan,
+	standardProvisioningContext StandardProvisioningContext,
+	provisioningContext ProvisioningContext,
+	params UpdatingParameters,
+) (bool, error) {
+	return c.skipFn(
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+		params,
+	)
+}
+
+// NewUpdater returns a new updater that executes the given steps one at a
+// time, in order
 func NewUpdater(steps ...UpdatingStep) (Updater, error) {
-	u := &updater{
-		steps:     make(map[string]UpdatingStep),
-		nextSteps: make(map[string]string),
-	}
-	if len(steps) > 0 {
-		u.firstStepName = steps[0].GetName()
-		var lastStep UpdatingStep
-		for _, step := range steps {
-			_, ok := u.steps[step.GetName()]
-			if ok {
-				// This means a duplicate step name has been detected. This is a serious
-				// problem.
-				return nil, fmt.Errorf(
-					`duplicate step name "%s" detected`,
-					step.GetName(),
-				)
-			}
-			u.steps[step.GetName()] = step
-			if lastStep != nil {
-				u.nextSteps[lastStep.GetName()] = step.GetName()
-			}
-			lastStep = step
+	builder := NewUpdaterBuilder()
+	for i, step := range steps {
+		if i == 0 {
+			builder.AddStep(step)
+		} else {
+			builder.AddStep(step, steps[i-1].GetName())
 		}
 	}
-	return u, nil
+	return builder.Build()
 }
 
-// GetFirstStepName retrieves the name of the first step in the chain
-func (u *updater) GetFirstStepName() (string, bool) {
-	return u.firstStepName, (u.firstStepName != "")
+// NewUpdaterBuilder returns a new UpdaterBuilder
+func NewUpdaterBuilder() UpdaterBuilder {
+	return &updaterBuilder{
+		updater: &updater{
+			stepGraph: newStepGraph(),
+			steps:     make(map[string]UpdatingStep),
+		},
+	}
+}
+
+// AddStep declares a step that may be executed once all of the named steps
+// have completed. Steps must be declared after the steps they depend on.
+func (u *updaterBuilder) AddStep(
+	step UpdatingStep,
+	dependencies ...string,
+) UpdaterBuilder {
+	if u.err != nil {
+		return u
+	}
+	u.err = u.updater.addStep(step.GetName(), dependencies)
+	if u.err == nil {
+		u.updater.steps[step.GetName()] = step
+	}
+	return u
+}
+
+// Build returns an Updater that executes the declared steps
+func (u *updaterBuilder) Build() (Updater, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	return u.updater, nil
 }
 
 // GetStep retrieves a step by name
@@ -125,10 +211,3 @@ func (u *updater) GetStep(name string) (UpdatingStep, bool) {
 	step, ok := u.steps[name]
 	return step, ok
 }
-
-// GetNextStepName, given the name of one step, returns the name of the next
-// step and a boolean indicating whether a next step actually exists
-func (u *updater) GetNextStepName(name string) (string, bool) {
-	nextStepName, ok := u.nextSteps[name]
-	return nextStepName, ok
-}
@@ -20,5 +20,5 @@ func (m *module) GetCatalog() (service.Catalog, error) {
 				Free:        false,
 			}),
 		),
-	}), nil
+	})
 }
@@ -93,7 +93,7 @@ func (s *serviceManager) deployARMTemplate(
 		standardProvisioningContext.Tags,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error deploying ARM template: %s", err)
+		return nil, service.WrapError(err, "error deploying ARM template")
 	}
 
 	// We don't check if this is ok, because "no public IP" is a legitimate
@@ -4,9 +4,9 @@ import "github.com/Azure/open-service-broker-azure/pkg/service"
 
 // ProvisioningParameters encapsulates aci-specific provisioning options
 type ProvisioningParameters struct {
-	ImageName   string  `json:"image"`
-	NumberCores int     `json:"cpuCores"`
-	Memory      float64 `json:"memoryInGb"`
+	ImageName   string  `json:"image" jsonschema:"required"`
+	NumberCores int     `json:"cpuCores" jsonschema:"minimum=1,maximum=4"`
+	Memory      float64 `json:"memoryInGb" jsonschema:"minimum=0.1"`
 	Ports       []int   `json:"ports"`
 }
 
@@ -34,5 +34,5 @@ func (m *module) GetCatalog() (service.Catalog, error) {
 				},
 			}),
 		),
-	}), nil
+	})
 }
@@ -29,5 +29,5 @@ func (m *Module) GetCatalog() (service.Catalog, error) {
 				Free:        false,
 			}),
 		),
-	}), nil
+	})
 }
@@ -141,10 +141,21 @@ func (s *ServiceManager) ValidateBindingParameters(
 	return s.BindingValidationBehavior(bindingParameters)
 }
 
-// Bind synchronously binds to a service
-func (s *ServiceManager) Bind(
+// GetBinder returns a binder that defines the steps a module must execute to
+// bind to a service
+func (s *ServiceManager) GetBinder(service.Plan) (service.Binder, error) {
+	return service.NewBinder(
+		service.NewBindingStep("run", s.bind),
+	)
+}
+
+func (s *ServiceManager) bind(
+	_ context.Context,
+	_ string, // bindingID
+	_ service.Plan,
 	standardProvisioningContext service.StandardProvisioningContext,
 	provisioningContext service.ProvisioningContext,
+	_ service.BindingContext,
 	bindingParameters service.BindingParameters,
 ) (service.BindingContext, service.Credentials, error) {
 	return s.BindBehavior(
@@ -154,13 +165,23 @@ func (s *ServiceManager) Bind(
 	)
 }
 
-// Unbind synchronously unbinds from a service
-func (s *ServiceManager) Unbind(
+// GetUnbinder returns an unbinder that defines the steps a module must execute
+// to unbind from a service
+func (s *ServiceManager) GetUnbinder(service.Plan) (service.Unbinder, error) {
+	return service.NewUnbinder(
+		service.NewUnbindingStep("run", s.unbind),
+	)
+}
+
+func (s *ServiceManager) unbind(
+	_ context.Context,
+	_ string, // bindingID
+	_ service.Plan,
 	standardProvisioningContext service.StandardProvisioningContext,
 	provisioningContext service.ProvisioningContext,
 	bindingContext service.BindingContext,
-) error {
-	return s.UnbindBehavior(
+) (service.BindingContext, error) {
+	return bindingContext, s.UnbindBehavior(
 		standardProvisioningContext,
 		provisioningContext,
 		bindingContext,
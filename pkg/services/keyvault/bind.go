@@ -1,6 +1,7 @@
 package keyvault
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/Azure/open-service-broker-azure/pkg/service"
@@ -14,9 +15,19 @@ func (s *serviceManager) ValidateBindingParameters(
 	return nil
 }
 
-func (s *serviceManager) Bind(
+func (s *serviceManager) GetBinder(service.Plan) (service.Binder, error) {
+	return service.NewBinder(
+		service.NewBindingStep("bind", s.bind),
+	)
+}
+
+func (s *serviceManager) bind(
+	_ context.Context,
+	_ string, // bindingID
+	_ service.Plan,
 	_ service.StandardProvisioningContext,
 	provisioningContext service.ProvisioningContext,
+	_ service.BindingContext,
 	bindingParameters service.BindingParameters,
 ) (service.BindingContext, service.Credentials, error) {
 	pc, ok := provisioningContext.(*keyvaultProvisioningContext)
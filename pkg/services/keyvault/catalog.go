@@ -32,5 +32,5 @@ func (m *module) GetCatalog() (service.Catalog, error) {
 				},
 			}),
 		),
-	}), nil
+	})
 }
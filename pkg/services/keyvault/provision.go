@@ -105,7 +105,7 @@ func (s *serviceManager) deployARMTemplate(
 		standardProvisioningContext.Tags,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error deploying ARM template: %s", err)
+		return nil, service.WrapError(err, "error deploying ARM template")
 	}
 
 	vaultURI, ok := outputs["vaultUri"].(string)
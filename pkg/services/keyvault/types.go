@@ -4,9 +4,9 @@ import "github.com/Azure/open-service-broker-azure/pkg/service"
 
 // ProvisioningParameters encapsulates keyvault-specific provisioning options
 type ProvisioningParameters struct {
-	ObjectID     string `json:"objectId"`
-	ClientID     string `json:"clientId"`
-	ClientSecret string `json:"clientSecret"`
+	ObjectID     string `json:"objectId" jsonschema:"required"`
+	ClientID     string `json:"clientId" jsonschema:"required"`
+	ClientSecret string `json:"clientSecret" jsonschema:"required"`
 }
 
 type keyvaultProvisioningContext struct {
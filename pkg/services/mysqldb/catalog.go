@@ -86,5 +86,5 @@ func (m *module) GetCatalog() (service.Catalog, error) {
 				},
 			}),
 		),
-	}), nil
+	})
 }
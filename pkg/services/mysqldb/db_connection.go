@@ -7,6 +7,7 @@ import (
 
 	"github.com/Azure/go-autorest/autorest/azure"
 	az "github.com/Azure/open-service-broker-azure/pkg/azure"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
 	log "github.com/Sirupsen/logrus"
 	"github.com/go-sql-driver/mysql"
 )
@@ -53,5 +54,12 @@ func getDBConnection(pc *mysqlProvisioningContext) (*sql.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to the database: %s", err)
 	}
-	return db, err
+	if err = db.Ping(); err != nil {
+		db.Close() // nolint: errcheck
+		if az.IsTransientError(err) {
+			err = service.NewRetryableError(err)
+		}
+		return nil, service.WrapError(err, "error connecting to the database")
+	}
+	return db, nil
 }
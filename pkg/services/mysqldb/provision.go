@@ -38,7 +38,11 @@ func (s *serviceManager) GetProvisioner(
 ) (service.Provisioner, error) {
 	return service.NewProvisioner(
 		service.NewProvisioningStep("preProvision", s.preProvision),
-		service.NewProvisioningStep("deployARMTemplate", s.deployARMTemplate),
+		service.NewProvisioningStepWithCompensation(
+			"deployARMTemplate",
+			s.deployARMTemplate,
+			s.undeployARMTemplate,
+		),
 	)
 }
 
@@ -119,7 +123,7 @@ func (s *serviceManager) deployARMTemplate(
 		standardProvisioningContext.Tags,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error deploying ARM template: %s", err)
+		return nil, service.WrapError(err, "error deploying ARM template")
 	}
 
 	fullyQualifiedDomainName, ok := outputs["fullyQualifiedDomainName"].(string)
@@ -133,3 +137,33 @@ func (s *serviceManager) deployARMTemplate(
 
 	return pc, nil
 }
+
+// undeployARMTemplate compensates for deployARMTemplate by deleting the ARM
+// deployment and the MySQL server it created, so that the server isn't left
+// running if a subsequent provisioning step fails
+func (s *serviceManager) undeployARMTemplate(
+	ctx context.Context,
+	instanceID string,
+	plan service.Plan,
+	standardProvisioningContext service.StandardProvisioningContext,
+	provisioningContext service.ProvisioningContext,
+	_ service.ProvisioningParameters,
+) error {
+	if _, err := s.deleteARMDeployment(
+		ctx,
+		instanceID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+	); err != nil {
+		return err
+	}
+	_, err := s.deleteMySQLServer(
+		ctx,
+		instanceID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+	)
+	return err
+}
@@ -0,0 +1,47 @@
+package mysqldb
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateProvisioningParametersSSLEnforcement(t *testing.T) {
+	schema, err := service.NewSchema(&ProvisioningParameters{})
+	assert.Nil(t, err)
+	m := &module{}
+	testCases := []struct {
+		name           string
+		sslEnforcement interface{}
+		valid          bool
+	}{
+		{name: "omitted", valid: true},
+		{name: "empty", sslEnforcement: "", valid: true},
+		{name: "enabled", sslEnforcement: "enabled", valid: true},
+		{name: "Enabled", sslEnforcement: "Enabled", valid: true},
+		{name: "DISABLED", sslEnforcement: "DISABLED", valid: true},
+		{name: "invalid", sslEnforcement: "bogus", valid: false},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			params := map[string]interface{}{}
+			pp := &ProvisioningParameters{}
+			if testCase.sslEnforcement != nil {
+				params["sslEnforcement"] = testCase.sslEnforcement
+				pp.SSLEnforcement = testCase.sslEnforcement.(string)
+			}
+			// The schema advertised in the catalog and the module's own validation
+			// must agree on what is acceptable
+			schemaErr := schema.Validate(params)
+			moduleErr := m.serviceManager.ValidateProvisioningParameters(pp)
+			if testCase.valid {
+				assert.Nil(t, schemaErr)
+				assert.Nil(t, moduleErr)
+			} else {
+				assert.NotNil(t, schemaErr)
+				assert.NotNil(t, moduleErr)
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ import "github.com/Azure/open-service-broker-azure/pkg/service"
 
 // ProvisioningParameters encapsulates MySQL-specific provisioning options
 type ProvisioningParameters struct {
-	SSLEnforcement string `json:"sslEnforcement"`
+	SSLEnforcement string `json:"sslEnforcement" jsonschema:"enum=enabled|disabled"` // nolint: lll
 }
 
 type mysqlProvisioningContext struct {
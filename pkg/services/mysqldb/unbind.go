@@ -1,51 +1,61 @@
 package mysqldb
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 )
 
-func (s *serviceManager) Unbind(
+func (s *serviceManager) GetUnbinder(service.Plan) (service.Unbinder, error) {
+	return service.NewUnbinder(
+		service.NewUnbindingStep("dropUser", s.dropUser),
+	)
+}
+
+func (s *serviceManager) dropUser(
+	_ context.Context,
+	_ string, // bindingID
+	_ service.Plan,
 	_ service.StandardProvisioningContext,
 	provisioningContext service.ProvisioningContext,
 	bindingContext service.BindingContext,
-) error {
+) (service.BindingContext, error) {
 	pc, ok := provisioningContext.(*mysqlProvisioningContext)
 	if !ok {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"error casting provisioningContext as *mysqlProvisioningContext",
 		)
 	}
 	bc, ok := bindingContext.(*mysqlBindingContext)
 	if !ok {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"error casting bindingContext as *mysqlBindingContext",
 		)
 	}
 
 	db, err := getDBConnection(pc)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer db.Close() // nolint: errcheck
 
 	// Open doesn't open a connection. Validate DSN data:
 	err = db.Ping()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	_, err = db.Exec(
 		fmt.Sprintf("DROP USER '%s'@'%%'", bc.LoginName),
 	)
 	if err != nil {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			`error dropping user "%s": %s`,
 			bc.LoginName,
 			err,
 		)
 	}
 
-	return nil
+	return bc, nil
 }
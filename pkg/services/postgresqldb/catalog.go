@@ -36,5 +36,5 @@ func (m *module) GetCatalog() (service.Catalog, error) {
 				},
 			}),
 		),
-	}), nil
+	})
 }
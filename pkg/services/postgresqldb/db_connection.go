@@ -3,6 +3,9 @@ package postgresqldb
 import (
 	"database/sql"
 	"fmt"
+
+	az "github.com/Azure/open-service-broker-azure/pkg/azure"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
 )
 
 func getDBConnection(
@@ -26,5 +29,12 @@ func getDBConnection(
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to the database: %s", err)
 	}
-	return db, err
+	if err = db.Ping(); err != nil {
+		db.Close() // nolint: errcheck
+		if az.IsTransientError(err) {
+			err = service.NewRetryableError(err)
+		}
+		return nil, service.WrapError(err, "error connecting to the database")
+	}
+	return db, nil
 }
@@ -37,12 +37,27 @@ func (s *serviceManager) ValidateProvisioningParameters(
 func (s *serviceManager) GetProvisioner(
 	service.Plan,
 ) (service.Provisioner, error) {
-	return service.NewProvisioner(
-		service.NewProvisioningStep("preProvision", s.preProvision),
-		service.NewProvisioningStep("deployARMTemplate", s.deployARMTemplate),
-		service.NewProvisioningStep("setupDatabase", s.setupDatabase),
-		service.NewProvisioningStep("createExtensions", s.createExtensions),
-	)
+	// Setting up the database and creating extensions are independent of one
+	// another, so they proceed concurrently once the server has been deployed
+	return service.NewProvisionerBuilder().
+		AddStep(service.NewProvisioningStep("preProvision", s.preProvision)).
+		AddStep(
+			service.NewProvisioningStepWithCompensation(
+				"deployARMTemplate",
+				s.deployARMTemplate,
+				s.undeployARMTemplate,
+			),
+			"preProvision",
+		).
+		AddStep(
+			service.NewProvisioningStep("setupDatabase", s.setupDatabase),
+			"deployARMTemplate",
+		).
+		AddStep(
+			service.NewProvisioningStep("createExtensions", s.createExtensions),
+			"deployARMTemplate",
+		).
+		Build()
 }
 
 func (s *serviceManager) preProvision(
@@ -121,7 +136,7 @@ func (s *serviceManager) deployARMTemplate(
 		standardProvisioningContext.Tags,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error deploying ARM template: %s", err)
+		return nil, service.WrapError(err, "error deploying ARM template")
 	}
 
 	fullyQualifiedDomainName, ok := outputs["fullyQualifiedDomainName"].(string)
@@ -136,6 +151,36 @@ func (s *serviceManager) deployARMTemplate(
 	return pc, nil
 }
 
+// undeployARMTemplate compensates for deployARMTemplate by deleting the ARM
+// deployment and the PostgreSQL server it created, so that the server isn't
+// left running if a subsequent provisioning step fails
+func (s *serviceManager) undeployARMTemplate(
+	ctx context.Context,
+	instanceID string,
+	plan service.Plan,
+	standardProvisioningContext service.StandardProvisioningContext,
+	provisioningContext service.ProvisioningContext,
+	_ service.ProvisioningParameters,
+) error {
+	if _, err := s.deleteARMDeployment(
+		ctx,
+		instanceID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+	); err != nil {
+		return err
+	}
+	_, err := s.deletePostgreSQLServer(
+		ctx,
+		instanceID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+	)
+	return err
+}
+
 func (s *serviceManager) setupDatabase(
 	_ context.Context,
 	_ string, // instanceID
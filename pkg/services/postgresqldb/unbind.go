@@ -1,32 +1,42 @@
 package postgresqldb
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 )
 
-func (s *serviceManager) Unbind(
+func (s *serviceManager) GetUnbinder(service.Plan) (service.Unbinder, error) {
+	return service.NewUnbinder(
+		service.NewUnbindingStep("dropRole", s.dropRole),
+	)
+}
+
+func (s *serviceManager) dropRole(
+	_ context.Context,
+	_ string, // bindingID
+	_ service.Plan,
 	_ service.StandardProvisioningContext,
 	provisioningContext service.ProvisioningContext,
 	bindingContext service.BindingContext,
-) error {
+) (service.BindingContext, error) {
 	pc, ok := provisioningContext.(*postgresqlProvisioningContext)
 	if !ok {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"error casting provisioningContext as *postgresqlProvisioningContext",
 		)
 	}
 	bc, ok := bindingContext.(*postgresqlBindingContext)
 	if !ok {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"error casting bindingContext as *postgresqlBindingContext",
 		)
 	}
 
 	db, err := getDBConnection(pc, primaryDB)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer db.Close() // nolint: errcheck
 
@@ -34,8 +44,8 @@ func (s *serviceManager) Unbind(
 		fmt.Sprintf("drop role %s", bc.LoginName),
 	)
 	if err != nil {
-		return fmt.Errorf(`error dropping role "%s": %s`, bc.LoginName, err)
+		return nil, fmt.Errorf(`error dropping role "%s": %s`, bc.LoginName, err)
 	}
 
-	return nil
+	return bc, nil
 }
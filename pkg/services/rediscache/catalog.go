@@ -47,5 +47,5 @@ func (m *module) GetCatalog() (service.Catalog, error) {
 				},
 			}),
 		),
-	}), nil
+	})
 }
@@ -33,7 +33,7 @@ func (s *serviceManager) deleteARMDeployment(
 		pc.ARMDeploymentName,
 		standardProvisioningContext.ResourceGroup,
 	); err != nil {
-		return nil, fmt.Errorf("error deleting ARM deployment: %s", err)
+		return nil, service.WrapError(err, "error deleting ARM deployment")
 	}
 	return pc, nil
 }
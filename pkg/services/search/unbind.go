@@ -2,10 +2,7 @@ package search
 
 import "github.com/Azure/open-service-broker-azure/pkg/service"
 
-func (s *serviceManager) Unbind(
-	_ service.StandardProvisioningContext,
-	_ service.ProvisioningContext,
-	_ service.BindingContext,
-) error {
-	return nil
+func (s *serviceManager) GetUnbinder(service.Plan) (service.Unbinder, error) {
+	// There is nothing to clean up when unbinding, so no steps are required
+	return service.NewUnbinder()
 }
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/url"
 
+	az "github.com/Azure/open-service-broker-azure/pkg/azure"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
 	_ "github.com/denisenkom/go-mssqldb" // MS SQL Driver
 )
 
@@ -33,9 +35,12 @@ func getDBConnection(
 		return nil, fmt.Errorf("error validating the database arguments: %s", err)
 	}
 
-	err = db.Ping()
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to the database: %s", err)
+	if err = db.Ping(); err != nil {
+		db.Close() // nolint: errcheck
+		if az.IsTransientError(err) {
+			err = service.NewRetryableError(err)
+		}
+		return nil, service.WrapError(err, "error connecting to the database")
 	}
 
 	return db, nil
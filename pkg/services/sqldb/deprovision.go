@@ -10,16 +10,94 @@ import (
 func (s *serviceManager) GetDeprovisioner(
 	service.Plan,
 ) (service.Deprovisioner, error) {
-	return service.NewDeprovisioner(
-		service.NewDeprovisioningStep(
+	// Only one of the last two steps applies to any given instance, depending on
+	// whether a new server was created for it or an existing server was used
+	return service.NewDeprovisionerBuilder().
+		AddStep(
+			service.NewDeprovisioningStep(
+				"deleteARMDeployment",
+				s.deleteARMDeployment,
+			),
+		).
+		AddStep(
+			service.NewConditionalDeprovisioningStep(
+				service.NewDeprovisioningStep(
+					"deleteMsSQLServer",
+					s.deleteMsSQLServer,
+				),
+				isExistingServer,
+			),
 			"deleteARMDeployment",
-			s.deleteARMDeployment,
-		),
-		service.NewDeprovisioningStep(
-			"deleteMsSQLServerOrDatabase",
-			s.deleteMsSQLServerOrDatabase,
-		),
+		).
+		AddStep(
+			service.NewConditionalDeprovisioningStep(
+				service.NewDeprovisioningStep(
+					"deleteMsSQLDatabase",
+					s.deleteMsSQLDatabase,
+				),
+				isNewServer,
+			),
+			"deleteARMDeployment",
+		).
+		// Deprovisioning that was begun by older versions of the broker may still
+		// have a task for this step, which preceded the two above, enqueued
+		AddLegacyStep(
+			service.NewDeprovisioningStep(
+				"deleteMsSQLServerOrDatabase",
+				s.deleteMsSQLServerOrDatabase,
+			),
+		).
+		Build()
+}
+
+// isNewServer is a predicate that indicates whether a new server was created
+// for the instance
+func isNewServer(
+	_ service.Plan,
+	_ service.StandardProvisioningContext,
+	provisioningContext service.ProvisioningContext,
+) (bool, error) {
+	pc, ok := provisioningContext.(*mssqlProvisioningContext)
+	if !ok {
+		return false, fmt.Errorf(
+			"error casting provisioningContext as *mssqlProvisioningContext",
+		)
+	}
+	return pc.IsNewServer, nil
+}
+
+// isExistingServer is a predicate that indicates whether an existing server was
+// used for the instance
+func isExistingServer(
+	plan service.Plan,
+	standardProvisioningContext service.StandardProvisioningContext,
+	provisioningContext service.ProvisioningContext,
+) (bool, error) {
+	newServer, err := isNewServer(
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
 	)
+	return !newServer, err
+}
+
+// getResourceGroupName returns the name of the resource group that contains
+// the instance's server
+func (s *serviceManager) getResourceGroupName(
+	standardProvisioningContext service.StandardProvisioningContext,
+	pc *mssqlProvisioningContext,
+) (string, error) {
+	if pc.IsNewServer {
+		return standardProvisioningContext.ResourceGroup, nil
+	}
+	server, ok := s.mssqlConfig.Servers[pc.ServerName]
+	if !ok {
+		return "", fmt.Errorf(
+			`can't find serverName "%s" in Azure SQL Server configuration`,
+			pc.ServerName,
+		)
+	}
+	return server.ResourceGroupName, nil
 }
 
 func (s *serviceManager) deleteARMDeployment(
@@ -35,36 +113,80 @@ func (s *serviceManager) deleteARMDeployment(
 			"error casting provisioningContext as *mssqlProvisioningContext",
 		)
 	}
-	var err error
-	if pc.IsNewServer {
-		// new server scenario
-		err = s.armDeployer.Delete(
-			pc.ARMDeploymentName,
-			standardProvisioningContext.ResourceGroup,
-		)
-	} else {
-		// exisiting server scenario
-		servers := s.mssqlConfig.Servers
-		server, ok := servers[pc.ServerName]
-		if !ok {
-			return nil, fmt.Errorf(
-				`can't find serverName "%s" in Azure SQL Server configuration`,
-				pc.ServerName,
-			)
-		}
+	resourceGroupName, err := s.getResourceGroupName(
+		standardProvisioningContext,
+		pc,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.armDeployer.Delete(
+		pc.ARMDeploymentName,
+		resourceGroupName,
+	); err != nil {
+		return nil, service.WrapError(err, "error deleting ARM deployment")
+	}
+	return pc, nil
+}
 
-		err = s.armDeployer.Delete(
-			pc.ARMDeploymentName,
-			server.ResourceGroupName,
+func (s *serviceManager) deleteMsSQLServer(
+	_ context.Context,
+	_ string, // instanceID
+	_ service.Plan,
+	standardProvisioningContext service.StandardProvisioningContext,
+	provisioningContext service.ProvisioningContext,
+) (service.ProvisioningContext, error) {
+	pc, ok := provisioningContext.(*mssqlProvisioningContext)
+	if !ok {
+		return nil, fmt.Errorf(
+			"error casting provisioningContext as *mssqlProvisioningContext",
 		)
 	}
-	if err != nil {
-		return nil, fmt.Errorf("error deleting ARM deployment: %s", err)
+	if err := s.mssqlManager.DeleteServer(
+		pc.ServerName,
+		standardProvisioningContext.ResourceGroup,
+	); err != nil {
+		return pc, fmt.Errorf("error deleting mssql server: %s", err)
 	}
 	return pc, nil
 }
 
+// deleteMsSQLServerOrDatabase deletes the instance's server if it was created
+// for the instance or, otherwise, the instance's database
 func (s *serviceManager) deleteMsSQLServerOrDatabase(
+	ctx context.Context,
+	instanceID string,
+	plan service.Plan,
+	standardProvisioningContext service.StandardProvisioningContext,
+	provisioningContext service.ProvisioningContext,
+) (service.ProvisioningContext, error) {
+	newServer, err := isNewServer(
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if newServer {
+		return s.deleteMsSQLServer(
+			ctx,
+			instanceID,
+			plan,
+			standardProvisioningContext,
+			provisioningContext,
+		)
+	}
+	return s.deleteMsSQLDatabase(
+		ctx,
+		instanceID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+	)
+}
+
+func (s *serviceManager) deleteMsSQLDatabase(
 	_ context.Context,
 	_ string, // instanceID
 	_ service.Plan,
@@ -77,33 +199,19 @@ func (s *serviceManager) deleteMsSQLServerOrDatabase(
 			"error casting provisioningContext as *mssqlProvisioningContext",
 		)
 	}
-
-	if pc.IsNewServer {
-		// new server scenario
-		if err := s.mssqlManager.DeleteServer(
-			pc.ServerName,
-			standardProvisioningContext.ResourceGroup,
-		); err != nil {
-			return pc, fmt.Errorf("error deleting mssql server: %s", err)
-		}
-	} else {
-		// exisiting server scenario
-		servers := s.mssqlConfig.Servers
-		server, ok := servers[pc.ServerName]
-		if !ok {
-			return nil, fmt.Errorf(
-				`can't find serverName "%s" in Azure SQL Server configuration`,
-				pc.ServerName,
-			)
-		}
-
-		if err := s.mssqlManager.DeleteDatabase(
-			pc.ServerName,
-			pc.DatabaseName,
-			server.ResourceGroupName,
-		); err != nil {
-			return pc, fmt.Errorf("error deleting mssql database: %s", err)
-		}
+	resourceGroupName, err := s.getResourceGroupName(
+		standardProvisioningContext,
+		pc,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.mssqlManager.DeleteDatabase(
+		pc.ServerName,
+		pc.DatabaseName,
+		resourceGroupName,
+	); err != nil {
+		return pc, fmt.Errorf("error deleting mssql database: %s", err)
 	}
 	return pc, nil
 }
@@ -41,7 +41,11 @@ func (s *serviceManager) GetProvisioner(
 ) (service.Provisioner, error) {
 	return service.NewProvisioner(
 		service.NewProvisioningStep("preProvision", s.preProvision),
-		service.NewProvisioningStep("deployARMTemplate", s.deployARMTemplate),
+		service.NewProvisioningStepWithCompensation(
+			"deployARMTemplate",
+			s.deployARMTemplate,
+			s.undeployARMTemplate,
+		),
 	)
 }
 
@@ -155,7 +159,7 @@ func (s *serviceManager) deployARMTemplate(
 			standardProvisioningContext.Tags,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("error deploying ARM template: %s", err)
+			return nil, service.WrapError(err, "error deploying ARM template")
 		}
 		fullyQualifiedDomainName, ok := outputs["fullyQualifiedDomainName"].(string)
 		if !ok {
@@ -194,9 +198,40 @@ func (s *serviceManager) deployARMTemplate(
 			standardProvisioningContext.Tags,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("error deploying ARM template: %s", err)
+			return nil, service.WrapError(err, "error deploying ARM template")
 		}
 	}
 
 	return pc, nil
 }
+
+// undeployARMTemplate compensates for deployARMTemplate by deleting the ARM
+// deployment and either the server it created or, if an existing server was
+// used, the database it created, so that they aren't left running if a
+// subsequent provisioning step fails
+func (s *serviceManager) undeployARMTemplate(
+	ctx context.Context,
+	instanceID string,
+	plan service.Plan,
+	standardProvisioningContext service.StandardProvisioningContext,
+	provisioningContext service.ProvisioningContext,
+	_ service.ProvisioningParameters,
+) error {
+	if _, err := s.deleteARMDeployment(
+		ctx,
+		instanceID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+	); err != nil {
+		return err
+	}
+	_, err := s.deleteMsSQLServerOrDatabase(
+		ctx,
+		instanceID,
+		plan,
+		standardProvisioningContext,
+		provisioningContext,
+	)
+	return err
+}
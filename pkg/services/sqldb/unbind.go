@@ -1,25 +1,35 @@
 package sqldb
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 )
 
-func (s *serviceManager) Unbind(
+func (s *serviceManager) GetUnbinder(service.Plan) (service.Unbinder, error) {
+	return service.NewUnbinder(
+		service.NewUnbindingStep("dropLogin", s.dropLogin),
+	)
+}
+
+func (s *serviceManager) dropLogin(
+	_ context.Context,
+	_ string, // bindingID
+	_ service.Plan,
 	_ service.StandardProvisioningContext,
 	provisioningContext service.ProvisioningContext,
 	bindingContext service.BindingContext,
-) error {
+) (service.BindingContext, error) {
 	pc, ok := provisioningContext.(*mssqlProvisioningContext)
 	if !ok {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"error casting provisioningContext as *mssqlProvisioningContext",
 		)
 	}
 	bc, ok := bindingContext.(*mssqlBindingContext)
 	if !ok {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"error casting bindingContext as *mssqlBindingContext",
 		)
 	}
@@ -27,14 +37,14 @@ func (s *serviceManager) Unbind(
 	// connect to new database to drop user for the login
 	db, err := getDBConnection(pc, pc.DatabaseName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer db.Close() // nolint: errcheck
 
 	if _, err = db.Exec(
 		fmt.Sprintf("DROP USER \"%s\"", bc.LoginName),
 	); err != nil {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			`error dropping user "%s": %s`,
 			bc.LoginName,
 			err,
@@ -44,19 +54,19 @@ func (s *serviceManager) Unbind(
 	// connect to master database to drop login
 	masterDb, err := getDBConnection(pc, "master")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer masterDb.Close() // nolint: errcheck
 
 	if _, err = masterDb.Exec(
 		fmt.Sprintf("DROP LOGIN \"%s\"", bc.LoginName),
 	); err != nil {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			`error dropping login "%s": %s`,
 			bc.LoginName,
 			err,
 		)
 	}
 
-	return nil
+	return bc, nil
 }
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+)
+
+const (
+	archiveRecordKindInstance = "instance"
+	archiveRecordKindBinding  = "binding"
+)
+
+// archiveRecord is the JSON representation of a single line of an archive.
+// Instances and bindings are archived exactly as they are persisted, so all
+// fields that are encrypted in storage remain encrypted in the archive.
+type archiveRecord struct {
+	Kind     string            `json:"kind"`
+	Instance *service.Instance `json:"instance,omitempty"`
+	Binding  *service.Binding  `json:"binding,omitempty"`
+}
+
+// Export writes every instance and binding in the given Store to the given
+// writer as JSON lines-- one record per line. Instances are written before
+// bindings so that archives can be imported in a single pass. The numbers of
+// instances and bindings exported are returned.
+func Export(store Store, w io.Writer) (int, int, error) {
+	encoder := json.NewEncoder(w)
+	instances, err := store.ListInstances(InstanceFilter{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("error listing instances: %s", err)
+	}
+	for i, instance := range instances {
+		if err = encoder.Encode(archiveRecord{
+			Kind:     archiveRecordKindInstance,
+			Instance: instance,
+		}); err != nil {
+			return i, 0, fmt.Errorf(
+				`error exporting instance "%s": %s`,
+				instance.InstanceID,
+				err,
+			)
+		}
+	}
+	bindings, err := store.ListBindings(BindingFilter{})
+	if err != nil {
+		return len(instances), 0, fmt.Errorf("error listing bindings: %s", err)
+	}
+	for i, binding := range bindings {
+		if err = encoder.Encode(archiveRecord{
+			Kind:    archiveRecordKindBinding,
+			Binding: binding,
+		}); err != nil {
+			return len(instances), i, fmt.Errorf(
+				`error exporting binding "%s": %s`,
+				binding.BindingID,
+				err,
+			)
+		}
+	}
+	return len(instances), len(bindings), nil
+}
+
+// Import reads instances and bindings from an archive produced by Export and
+// writes them to the given Store. Since encrypted fields are imported as is,
+// the broker using the given Store must be configured with the same
+// crypto.Codec as the broker the archive was exported from. Import does not
+// overwrite existing records; if an instance or binding already exists in the
+// given Store, importing stops and an error is returned. The numbers of
+// instances and bindings imported are returned.
+func Import(store Store, r io.Reader) (int, int, error) {
+	decoder := json.NewDecoder(r)
+	var instances, bindings int
+	for {
+		record := archiveRecord{}
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return instances, bindings, nil
+		}
+		if err != nil {
+			return instances, bindings, fmt.Errorf(
+				"error decoding archive record: %s",
+				err,
+			)
+		}
+		switch {
+		case record.Kind == archiveRecordKindInstance && record.Instance != nil:
+			// Revisions are specific to the store a record was exported from
+			record.Instance.Revision = 0
+			if err = store.WriteInstance(record.Instance); err != nil {
+				return instances, bindings, fmt.Errorf(
+					`error importing instance "%s": %s`,
+					record.Instance.InstanceID,
+					err,
+				)
+			}
+			instances++
+		case record.Kind == archiveRecordKindBinding && record.Binding != nil:
+			record.Binding.Revision = 0
+			if err = store.WriteBinding(record.Binding); err != nil {
+				return instances, bindings, fmt.Errorf(
+					`error importing binding "%s": %s`,
+					record.Binding.BindingID,
+					err,
+				)
+			}
+			bindings++
+		default:
+			return instances, bindings, fmt.Errorf(
+				`invalid archive record of kind "%s"`,
+				record.Kind,
+			)
+		}
+	}
+}
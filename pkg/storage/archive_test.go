@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	instance := &service.Instance{
+		InstanceID:                   getDisposableInstanceID(),
+		EncryptedProvisioningContext: []byte("ciphertext"),
+	}
+	err := testStore.WriteInstance(instance)
+	assert.Nil(t, err)
+	binding := &service.Binding{
+		BindingID:  getDisposableBindingID(),
+		InstanceID: instance.InstanceID,
+	}
+	err = testStore.WriteBinding(binding)
+	assert.Nil(t, err)
+	buf := &bytes.Buffer{}
+	instances, bindings, err := Export(testStore, buf)
+	assert.Nil(t, err)
+	var foundInstance, foundBinding bool
+	var exportedInstances, exportedBindings int
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		record := archiveRecord{}
+		err = decoder.Decode(&record)
+		assert.Nil(t, err)
+		switch record.Kind {
+		case archiveRecordKindInstance:
+			exportedInstances++
+			if record.Instance.InstanceID == instance.InstanceID {
+				foundInstance = true
+				assert.Equal(t, instance, record.Instance)
+			}
+		case archiveRecordKindBinding:
+			// All bindings must follow all instances
+			assert.Equal(t, instances, exportedInstances)
+			exportedBindings++
+			if record.Binding.BindingID == binding.BindingID {
+				foundBinding = true
+				assert.Equal(t, binding, record.Binding)
+			}
+		}
+	}
+	assert.Equal(t, instances, exportedInstances)
+	assert.Equal(t, bindings, exportedBindings)
+	assert.True(t, foundInstance)
+	assert.True(t, foundBinding)
+}
+
+func TestImport(t *testing.T) {
+	instanceID := getDisposableInstanceID()
+	bindingID := getDisposableBindingID()
+	archive := bytes.NewBufferString(fmt.Sprintf(
+		`{"kind":"instance","instance":{"instanceId":"%s","revision":7}}
+{"kind":"binding","binding":{"bindingId":"%s","instanceId":"%s"}}
+`,
+		instanceID,
+		bindingID,
+		instanceID,
+	))
+	instances, bindings, err := Import(testStore, archive)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, instances)
+	assert.Equal(t, 1, bindings)
+	instance, ok, err := testStore.GetInstance(instanceID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	if ok {
+		// The revision is relative to the store imported into
+		assert.Equal(t, 1, instance.Revision)
+	}
+	_, ok, err = testStore.GetBinding(bindingID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestImportDoesNotOverwrite(t *testing.T) {
+	instance := &service.Instance{
+		InstanceID: getDisposableInstanceID(),
+	}
+	err := testStore.WriteInstance(instance)
+	assert.Nil(t, err)
+	archive := bytes.NewBufferString(fmt.Sprintf(
+		`{"kind":"instance","instance":{"instanceId":"%s"}}`,
+		instance.InstanceID,
+	))
+	instances, _, err := Import(testStore, archive)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, instances)
+}
+
+func TestImportInvalidRecord(t *testing.T) {
+	archive := bytes.NewBufferString(`{"kind":"bogus"}`)
+	_, _, err := Import(testStore, archive)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,215 @@
+package boltdb
+
+import (
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
+	"github.com/boltdb/bolt"
+)
+
+var (
+	instancesBucketName = []byte("instances")
+	bindingsBucketName  = []byte("bindings")
+)
+
+type store struct {
+	db *bolt.DB
+}
+
+// NewStore returns a new BoltDB-based implementation of the storage.Store
+// interface. This permits a single broker process to run using only a local
+// file for storage. Any buckets the store requires are created, as needed,
+// before the store is returned.
+func NewStore(db *bolt.DB) (storage.Store, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucketName := range [][]byte{
+			instancesBucketName,
+			bindingsBucketName,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(bucketName); err != nil {
+				return fmt.Errorf(`error creating bucket "%s": %s`, bucketName, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &store{
+		db: db,
+	}, nil
+}
+
+func (s *store) WriteInstance(instance *service.Instance) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(instancesBucketName)
+		var oldRevision int
+		if json := bucket.Get([]byte(instance.InstanceID)); json != nil {
+			oldInstance, err := service.NewInstanceFromJSON(json)
+			if err != nil {
+				return err
+			}
+			oldRevision = oldInstance.Revision
+		}
+		if oldRevision != instance.Revision {
+			return storage.NewConflictError(
+				"instance",
+				instance.InstanceID,
+				instance.Revision,
+			)
+		}
+		instance.Revision++
+		json, err := instance.ToJSON()
+		if err == nil {
+			err = bucket.Put([]byte(instance.InstanceID), json)
+		}
+		if err != nil {
+			instance.Revision--
+		}
+		return err
+	})
+}
+
+func (s *store) GetInstance(
+	instanceID string,
+) (*service.Instance, bool, error) {
+	var instance *service.Instance
+	err := s.db.View(func(tx *bolt.Tx) error {
+		json := tx.Bucket(instancesBucketName).Get([]byte(instanceID))
+		if json == nil {
+			return nil
+		}
+		var err error
+		instance, err = service.NewInstanceFromJSON(json)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return instance, instance != nil, nil
+}
+
+func (s *store) ListInstances(
+	filter storage.InstanceFilter,
+) ([]*service.Instance, error) {
+	instances := []*service.Instance{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(instancesBucketName).ForEach(func(_, json []byte) error {
+			instance, err := service.NewInstanceFromJSON(json)
+			if err != nil {
+				return err
+			}
+			if filter.Matches(instance) {
+				instances = append(instances, instance)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+func (s *store) DeleteInstance(instanceID string) (bool, error) {
+	return s.delete(instancesBucketName, instanceID)
+}
+
+func (s *store) WriteBinding(binding *service.Binding) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bindingsBucketName)
+		var oldRevision int
+		if json := bucket.Get([]byte(binding.BindingID)); json != nil {
+			oldBinding, err := service.NewBindingFromJSON(json)
+			if err != nil {
+				return err
+			}
+			oldRevision = oldBinding.Revision
+		}
+		if oldRevision != binding.Revision {
+			return storage.NewConflictError(
+				"binding",
+				binding.BindingID,
+				binding.Revision,
+			)
+		}
+		binding.Revision++
+		json, err := binding.ToJSON()
+		if err == nil {
+			err = bucket.Put([]byte(binding.BindingID), json)
+		}
+		if err != nil {
+			binding.Revision--
+		}
+		return err
+	})
+}
+
+func (s *store) GetBinding(bindingID string) (*service.Binding, bool, error) {
+	var binding *service.Binding
+	err := s.db.View(func(tx *bolt.Tx) error {
+		json := tx.Bucket(bindingsBucketName).Get([]byte(bindingID))
+		if json == nil {
+			return nil
+		}
+		var err error
+		binding, err = service.NewBindingFromJSON(json)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return binding, binding != nil, nil
+}
+
+func (s *store) ListBindings(
+	filter storage.BindingFilter,
+) ([]*service.Binding, error) {
+	bindings := []*service.Binding{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bindingsBucketName).ForEach(func(_, json []byte) error {
+			binding, err := service.NewBindingFromJSON(json)
+			if err != nil {
+				return err
+			}
+			if filter.Matches(binding) {
+				bindings = append(bindings, binding)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+func (s *store) DeleteBinding(bindingID string) (bool, error) {
+	return s.delete(bindingsBucketName, bindingID)
+}
+
+func (s *store) TestConnection() error {
+	// There's nothing to connect to, but verify the file is still usable
+	return s.db.View(func(*bolt.Tx) error {
+		return nil
+	})
+}
+
+// delete deletes the record having the given key from the given bucket and
+// indicates whether such a record existed
+func (s *store) delete(bucketName []byte, id string) (bool, error) {
+	var deleted bool
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+		if bucket.Get([]byte(id)) == nil {
+			return nil
+		}
+		if err := bucket.Delete([]byte(id)); err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
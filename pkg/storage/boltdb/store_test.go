@@ -0,0 +1,149 @@
+package boltdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListInstances(t *testing.T) {
+	s, cleanup := getTestStore(t)
+	defer cleanup()
+	err := s.WriteInstance(&service.Instance{
+		InstanceID: "foo",
+		ServiceID:  "svc",
+		PlanID:     "plan-a",
+		Status:     service.InstanceStateProvisioned,
+	})
+	assert.Nil(t, err)
+	err = s.WriteInstance(&service.Instance{
+		InstanceID: "bar",
+		ServiceID:  "svc",
+		PlanID:     "plan-b",
+		Status:     service.InstanceStateProvisioningFailed,
+	})
+	assert.Nil(t, err)
+	instances, err := s.ListInstances(storage.InstanceFilter{})
+	assert.Nil(t, err)
+	assert.Len(t, instances, 2)
+	instances, err = s.ListInstances(storage.InstanceFilter{
+		Status: service.InstanceStateProvisioningFailed,
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, "bar", instances[0].InstanceID)
+	}
+	instances, err = s.ListInstances(storage.InstanceFilter{
+		ServiceID: "svc",
+		PlanID:    "plan-c",
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, instances)
+}
+
+func TestListBindings(t *testing.T) {
+	s, cleanup := getTestStore(t)
+	defer cleanup()
+	err := s.WriteBinding(&service.Binding{
+		BindingID:  "foo",
+		InstanceID: "instance-a",
+	})
+	assert.Nil(t, err)
+	err = s.WriteBinding(&service.Binding{
+		BindingID:  "bar",
+		InstanceID: "instance-b",
+	})
+	assert.Nil(t, err)
+	bindings, err := s.ListBindings(storage.BindingFilter{
+		InstanceID: "instance-b",
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, bindings, 1) {
+		assert.Equal(t, "bar", bindings[0].BindingID)
+	}
+}
+
+func TestWriteInstanceDetectsConflict(t *testing.T) {
+	s, cleanup := getTestStore(t)
+	defer cleanup()
+	instance := &service.Instance{
+		InstanceID: "foo",
+	}
+	err := s.WriteInstance(instance)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, instance.Revision)
+	staleInstance := *instance
+	err = s.WriteInstance(instance)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, instance.Revision)
+	err = s.WriteInstance(&staleInstance)
+	assert.IsType(t, &storage.ConflictError{}, err)
+	assert.Equal(t, 1, staleInstance.Revision)
+}
+
+func TestWriteBindingDetectsConflict(t *testing.T) {
+	s, cleanup := getTestStore(t)
+	defer cleanup()
+	err := s.WriteBinding(&service.Binding{
+		BindingID: "foo",
+	})
+	assert.Nil(t, err)
+	// Writing another "new" binding with the same ID is a conflict
+	err = s.WriteBinding(&service.Binding{
+		BindingID: "foo",
+	})
+	assert.IsType(t, &storage.ConflictError{}, err)
+}
+
+func TestGetAndDeleteInstance(t *testing.T) {
+	s, cleanup := getTestStore(t)
+	defer cleanup()
+	_, ok, err := s.GetInstance("foo")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	err = s.WriteInstance(&service.Instance{
+		InstanceID: "foo",
+	})
+	assert.Nil(t, err)
+	instance, ok, err := s.GetInstance("foo")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	if assert.NotNil(t, instance, "instance should not be nil") {
+		assert.Equal(t, "foo", instance.InstanceID)
+	}
+	ok, err = s.DeleteInstance("foo")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = s.DeleteInstance("foo")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+// getTestStore returns a store backed by a new database file, along with a
+// function that closes and removes that file
+func getTestStore(t *testing.T) (storage.Store, func()) {
+	dir, err := ioutil.TempDir("", "osba-boltdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup := func() {
+		db.Close()        // nolint: errcheck
+		os.RemoveAll(dir) // nolint: errcheck
+	}
+	s, err := NewStore(db)
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return s, cleanup
+}
@@ -0,0 +1,33 @@
+package storage
+
+import "fmt"
+
+// ConflictError represents an error persisting a record that has been
+// modified (by another party) since the revision being written was read. This
+// specific error type should be used to allow callers to differentiate between
+// concurrent modifications, which can often be resolved by reloading the record
+// and retrying, and other common, unexpected errors.
+type ConflictError struct {
+	Kind             string
+	ID               string
+	ExpectedRevision int
+}
+
+// NewConflictError returns a new ConflictError for the given kind of record,
+// record id, and the revision the writer expected to find in storage
+func NewConflictError(kind, id string, expectedRevision int) *ConflictError {
+	return &ConflictError{
+		Kind:             kind,
+		ID:               id,
+		ExpectedRevision: expectedRevision,
+	}
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf(
+		`%s "%s" was modified concurrently; expected revision %d is out of date`,
+		e.Kind,
+		e.ID,
+		e.ExpectedRevision,
+	)
+}
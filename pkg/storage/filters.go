@@ -0,0 +1,33 @@
+package storage
+
+import "github.com/Azure/open-service-broker-azure/pkg/service"
+
+// InstanceFilter represents criteria for selecting instances. Empty fields
+// are ignored-- i.e. they match any value.
+type InstanceFilter struct {
+	ServiceID string
+	PlanID    string
+	Status    string
+}
+
+// Matches returns a boolean indicating whether the given instance satisfies
+// all of the filter's criteria
+func (f InstanceFilter) Matches(instance *service.Instance) bool {
+	return (f.ServiceID == "" || f.ServiceID == instance.ServiceID) &&
+		(f.PlanID == "" || f.PlanID == instance.PlanID) &&
+		(f.Status == "" || f.Status == instance.Status)
+}
+
+// BindingFilter represents criteria for selecting bindings. Empty fields are
+// ignored-- i.e. they match any value.
+type BindingFilter struct {
+	InstanceID string
+	Status     string
+}
+
+// Matches returns a boolean indicating whether the given binding satisfies
+// all of the filter's criteria
+func (f BindingFilter) Matches(binding *service.Binding) bool {
+	return (f.InstanceID == "" || f.InstanceID == binding.InstanceID) &&
+		(f.Status == "" || f.Status == binding.Status)
+}
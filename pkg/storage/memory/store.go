@@ -20,6 +20,15 @@ func NewStore() storage.Store {
 }
 
 func (s *store) WriteInstance(instance *service.Instance) error {
+	// Note that a zero-valued instance is found if the instance doesn't exist
+	if s.instances[instance.InstanceID].Revision != instance.Revision {
+		return storage.NewConflictError(
+			"instance",
+			instance.InstanceID,
+			instance.Revision,
+		)
+	}
+	instance.Revision++
 	s.instances[instance.InstanceID] = *instance
 	return nil
 }
@@ -33,6 +42,19 @@ func (s *store) GetInstance(instanceID string) (
 	return &instance, ok, nil
 }
 
+func (s *store) ListInstances(
+	filter storage.InstanceFilter,
+) ([]*service.Instance, error) {
+	instances := []*service.Instance{}
+	for _, instance := range s.instances {
+		instance := instance
+		if filter.Matches(&instance) {
+			instances = append(instances, &instance)
+		}
+	}
+	return instances, nil
+}
+
 func (s *store) DeleteInstance(instanceID string) (bool, error) {
 	_, ok := s.instances[instanceID]
 	if !ok {
@@ -43,6 +65,15 @@ func (s *store) DeleteInstance(instanceID string) (bool, error) {
 }
 
 func (s *store) WriteBinding(binding *service.Binding) error {
+	// Note that a zero-valued binding is found if the binding doesn't exist
+	if s.bindings[binding.BindingID].Revision != binding.Revision {
+		return storage.NewConflictError(
+			"binding",
+			binding.BindingID,
+			binding.Revision,
+		)
+	}
+	binding.Revision++
 	s.bindings[binding.BindingID] = *binding
 	return nil
 }
@@ -52,6 +83,19 @@ func (s *store) GetBinding(bindingID string) (*service.Binding, bool, error) {
 	return &binding, ok, nil
 }
 
+func (s *store) ListBindings(
+	filter storage.BindingFilter,
+) ([]*service.Binding, error) {
+	bindings := []*service.Binding{}
+	for _, binding := range s.bindings {
+		binding := binding
+		if filter.Matches(&binding) {
+			bindings = append(bindings, &binding)
+		}
+	}
+	return bindings, nil
+}
+
 func (s *store) DeleteBinding(bindingID string) (bool, error) {
 	_, ok := s.bindings[bindingID]
 	if !ok {
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListInstances(t *testing.T) {
+	s := NewStore()
+	err := s.WriteInstance(&service.Instance{
+		InstanceID: "foo",
+		ServiceID:  "svc",
+		PlanID:     "plan-a",
+		Status:     service.InstanceStateProvisioned,
+	})
+	assert.Nil(t, err)
+	err = s.WriteInstance(&service.Instance{
+		InstanceID: "bar",
+		ServiceID:  "svc",
+		PlanID:     "plan-b",
+		Status:     service.InstanceStateProvisioningFailed,
+	})
+	assert.Nil(t, err)
+	instances, err := s.ListInstances(storage.InstanceFilter{})
+	assert.Nil(t, err)
+	assert.Len(t, instances, 2)
+	instances, err = s.ListInstances(storage.InstanceFilter{
+		Status: service.InstanceStateProvisioningFailed,
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, "bar", instances[0].InstanceID)
+	}
+	instances, err = s.ListInstances(storage.InstanceFilter{
+		ServiceID: "svc",
+		PlanID:    "plan-c",
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, instances)
+}
+
+func TestListBindings(t *testing.T) {
+	s := NewStore()
+	err := s.WriteBinding(&service.Binding{
+		BindingID:  "foo",
+		InstanceID: "instance-a",
+	})
+	assert.Nil(t, err)
+	err = s.WriteBinding(&service.Binding{
+		BindingID:  "bar",
+		InstanceID: "instance-b",
+	})
+	assert.Nil(t, err)
+	bindings, err := s.ListBindings(storage.BindingFilter{
+		InstanceID: "instance-b",
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, bindings, 1) {
+		assert.Equal(t, "bar", bindings[0].BindingID)
+	}
+}
+
+func TestWriteInstanceDetectsConflict(t *testing.T) {
+	s := NewStore()
+	instance := &service.Instance{
+		InstanceID: "foo",
+	}
+	err := s.WriteInstance(instance)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, instance.Revision)
+	staleInstance := *instance
+	err = s.WriteInstance(instance)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, instance.Revision)
+	err = s.WriteInstance(&staleInstance)
+	assert.IsType(t, &storage.ConflictError{}, err)
+	assert.Equal(t, 1, staleInstance.Revision)
+}
+
+func TestWriteBindingDetectsConflict(t *testing.T) {
+	s := NewStore()
+	err := s.WriteBinding(&service.Binding{
+		BindingID: "foo",
+	})
+	assert.Nil(t, err)
+	// Writing another "new" binding with the same ID is a conflict
+	err = s.WriteBinding(&service.Binding{
+		BindingID: "foo",
+	})
+	assert.IsType(t, &storage.ConflictError{}, err)
+}
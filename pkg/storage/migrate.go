@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-redis/redis"
+)
+
+// legacyRecord is used to sniff the type of a record stored under an
+// unprefixed key
+type legacyRecord struct {
+	InstanceID string `json:"instanceId"`
+	BindingID  string `json:"bindingId"`
+}
+
+// MigrateLegacyKeys moves instances and bindings that were stored under their
+// raw ids (as they were by versions of the broker that predate namespaced
+// keys) into the keyspace used by a Redis-based Store having the given key
+// prefix. Keys that do not hold an instance or binding whose id matches the
+// key itself are left untouched. The migration is safe to run more than once.
+// The number of records migrated is returned.
+func MigrateLegacyKeys(
+	redisClient *redis.Client,
+	keyPrefix string,
+) (int, error) {
+	s := &store{
+		redisClient: redisClient,
+		keyPrefix:   keyPrefix,
+	}
+	var migrated int
+	var cursor uint64
+	for {
+		keys, nextCursor, err := redisClient.Scan(cursor, "*", 100).Result()
+		if err != nil {
+			return migrated, fmt.Errorf("error scanning keys: %s", err)
+		}
+		for _, key := range keys {
+			// All namespaced keys contain at least one delimiter
+			if strings.Contains(key, ":") {
+				continue
+			}
+			ok, err := s.migrateLegacyKey(key)
+			if err != nil {
+				return migrated, fmt.Errorf(
+					`error migrating legacy key "%s": %s`,
+					key,
+					err,
+				)
+			}
+			if ok {
+				migrated++
+			}
+		}
+		if nextCursor == 0 {
+			return migrated, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+// HasLegacyKeys returns a bool indicating whether any instances or bindings are
+// stored under the raw ids used by versions of the broker that predate
+// namespaced keys without also being present in the keyspace used by a
+// Redis-based Store having the given key prefix. A broker that is started
+// without migrating such records would not find them.
+func HasLegacyKeys(redisClient *redis.Client, keyPrefix string) (bool, error) {
+	s := &store{
+		redisClient: redisClient,
+		keyPrefix:   keyPrefix,
+	}
+	var cursor uint64
+	for {
+		keys, nextCursor, err := redisClient.Scan(cursor, "*", 100).Result()
+		if err != nil {
+			return false, fmt.Errorf("error scanning keys: %s", err)
+		}
+		for _, key := range keys {
+			if strings.Contains(key, ":") {
+				continue
+			}
+			keyType, err := redisClient.Type(key).Result()
+			if err != nil {
+				return false, fmt.Errorf(
+					`error retrieving type of key "%s": %s`,
+					key,
+					err,
+				)
+			}
+			if keyType != "string" {
+				continue
+			}
+			jsonBytes, err := redisClient.Get(key).Bytes()
+			if err == redis.Nil {
+				continue
+			} else if err != nil {
+				return false, fmt.Errorf(`error retrieving key "%s": %s`, key, err)
+			}
+			var newKey string
+			switch getLegacyKeyspace(key, jsonBytes) {
+			case instancesKeyspace:
+				newKey = s.getInstanceKey(key)
+			case bindingsKeyspace:
+				newKey = s.getBindingKey(key)
+			default:
+				continue
+			}
+			exists, err := redisClient.Exists(newKey).Result()
+			if err != nil {
+				return false, fmt.Errorf(`error checking key "%s": %s`, newKey, err)
+			}
+			if exists == 0 {
+				return true, nil
+			}
+		}
+		if nextCursor == 0 {
+			return false, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+// getLegacyKeyspace sniffs the value of an unprefixed key and returns the
+// keyspace that the instance or binding it holds belongs in. If the value is
+// not an instance or binding whose id matches the key itself, an empty string
+// is returned.
+func getLegacyKeyspace(key string, jsonBytes []byte) string {
+	record := legacyRecord{}
+	if json.Unmarshal(jsonBytes, &record) != nil {
+		// Not JSON; not ours
+		return ""
+	}
+	switch {
+	case record.BindingID == key:
+		return bindingsKeyspace
+	case record.BindingID == "" && record.InstanceID == key:
+		return instancesKeyspace
+	}
+	return ""
+}
+
+func (s *store) migrateLegacyKey(key string) (bool, error) {
+	var migrated bool
+	err := s.redisClient.Watch(func(tx *redis.Tx) error {
+		keyType, err := tx.Type(key).Result()
+		if err != nil || keyType != "string" {
+			return err
+		}
+		jsonBytes, err := tx.Get(key).Bytes()
+		if err == redis.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var newKey string
+		var indexNames []string
+		switch getLegacyKeyspace(key, jsonBytes) {
+		case bindingsKeyspace:
+			var binding *service.Binding
+			if binding, err = service.NewBindingFromJSON(jsonBytes); err != nil {
+				return err
+			}
+			newKey = s.getBindingKey(key)
+			indexNames = s.getBindingIndexNames(binding)
+		case instancesKeyspace:
+			var instance *service.Instance
+			if instance, err = service.NewInstanceFromJSON(jsonBytes); err != nil {
+				return err
+			}
+			newKey = s.getInstanceKey(key)
+			indexNames = s.getInstanceIndexNames(instance)
+		default:
+			return nil
+		}
+		exists, err := tx.Exists(newKey).Result()
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			// Never clobber a record that has already been written to the new
+			// keyspace; it is necessarily more current than the legacy one.
+			log.WithFields(log.Fields{
+				"legacyKey": key,
+				"key":       newKey,
+			}).Warn("not migrating legacy key; key already exists in keyspace")
+			return nil
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			pipeline.Set(newKey, jsonBytes, 0)
+			for _, indexName := range indexNames {
+				pipeline.SAdd(indexName, key)
+			}
+			pipeline.Del(key)
+			return nil
+		})
+		migrated = err == nil
+		return err
+	}, key, s.getInstanceKey(key), s.getBindingKey(key))
+	return migrated, err
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateLegacyKeys(t *testing.T) {
+	instanceID := getDisposableInstanceID()
+	bindingID := getDisposableBindingID()
+	unrelatedKey := getDisposableInstanceID()
+	// Write an instance, a binding, and something else under unprefixed keys
+	statCmd := redisClient.Set(instanceID, getInstanceJSON(instanceID), 0)
+	assert.Nil(t, statCmd.Err())
+	statCmd = redisClient.Set(bindingID, getBindingJSON(bindingID), 0)
+	assert.Nil(t, statCmd.Err())
+	statCmd = redisClient.Set(unrelatedKey, "alive", 0)
+	assert.Nil(t, statCmd.Err())
+	migrated, err := MigrateLegacyKeys(redisClient, testStore.keyPrefix)
+	assert.Nil(t, err)
+	assert.True(t, migrated >= 2)
+	// Assert that the instance and binding were moved and indexed
+	_, ok, err := testStore.GetInstance(instanceID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	strCmd := redisClient.Get(instanceID)
+	assert.Equal(t, redis.Nil, strCmd.Err())
+	bindings, err := testStore.ListBindings(BindingFilter{})
+	assert.Nil(t, err)
+	var found bool
+	for _, binding := range bindings {
+		if binding.BindingID == bindingID {
+			found = true
+		}
+	}
+	assert.True(t, found)
+	strCmd = redisClient.Get(bindingID)
+	assert.Equal(t, redis.Nil, strCmd.Err())
+	// Assert that the unrelated key was left alone
+	strCmd = redisClient.Get(unrelatedKey)
+	assert.Nil(t, strCmd.Err())
+}
+
+func TestMigrateLegacyKeysDoesNotOverwrite(t *testing.T) {
+	instanceID := getDisposableInstanceID()
+	instance := &service.Instance{
+		InstanceID: instanceID,
+		Status:     service.InstanceStateProvisioned,
+	}
+	err := testStore.WriteInstance(instance)
+	assert.Nil(t, err)
+	statCmd := redisClient.Set(instanceID, getInstanceJSON(instanceID), 0)
+	assert.Nil(t, statCmd.Err())
+	_, err = MigrateLegacyKeys(redisClient, testStore.keyPrefix)
+	assert.Nil(t, err)
+	migratedInstance, ok, err := testStore.GetInstance(instanceID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, instance, migratedInstance)
+}
+
+func TestHasLegacyKeys(t *testing.T) {
+	instanceID := getDisposableInstanceID()
+	statCmd := redisClient.Set(instanceID, getInstanceJSON(instanceID), 0)
+	assert.Nil(t, statCmd.Err())
+	found, err := HasLegacyKeys(redisClient, testStore.keyPrefix)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	_, err = MigrateLegacyKeys(redisClient, testStore.keyPrefix)
+	assert.Nil(t, err)
+	found, err = HasLegacyKeys(redisClient, testStore.keyPrefix)
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
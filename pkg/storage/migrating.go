@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"github.com/Azure/open-service-broker-azure/pkg/crypto"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	log "github.com/Sirupsen/logrus"
+)
+
+// migratingStore is an implementation of the Store interface that decorates
+// another Store. New instances and bindings are stamped with the current
+// schema versions of their module-specific contexts as they are written and
+// existing instances and bindings are upgraded to the current schema versions,
+// in memory, as they are read. Upgraded records are persisted the next time
+// they are written.
+type migratingStore struct {
+	Store
+	registry service.MigrationRegistry
+	codec    crypto.Codec
+}
+
+// NewMigratingStore returns an implementation of the Store interface that
+// applies migrations from the given registry to instances and bindings read
+// from the given Store
+func NewMigratingStore(
+	store Store,
+	registry service.MigrationRegistry,
+	codec crypto.Codec,
+) Store {
+	return &migratingStore{
+		Store:    store,
+		registry: registry,
+		codec:    codec,
+	}
+}
+
+func (m *migratingStore) WriteInstance(instance *service.Instance) error {
+	if instance.Revision == 0 {
+		instance.SchemaVersion =
+			m.registry.GetProvisioningContextSchemaVersion(instance.ServiceID)
+	}
+	return m.Store.WriteInstance(instance)
+}
+
+func (m *migratingStore) GetInstance(
+	instanceID string,
+) (*service.Instance, bool, error) {
+	instance, ok, err := m.Store.GetInstance(instanceID)
+	if err != nil || !ok {
+		return instance, ok, err
+	}
+	if _, err = m.registry.MigrateInstance(instance, m.codec); err != nil {
+		return nil, false, err
+	}
+	return instance, true, nil
+}
+
+func (m *migratingStore) ListInstances(
+	filter InstanceFilter,
+) ([]*service.Instance, error) {
+	instances, err := m.Store.ListInstances(filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		if _, err = m.registry.MigrateInstance(instance, m.codec); err != nil {
+			return nil, err
+		}
+	}
+	return instances, nil
+}
+
+func (m *migratingStore) WriteBinding(binding *service.Binding) error {
+	if binding.Revision == 0 {
+		serviceID, err := m.getServiceID(binding)
+		if err != nil {
+			return err
+		}
+		binding.SchemaVersion = m.registry.GetBindingContextSchemaVersion(serviceID)
+	}
+	return m.Store.WriteBinding(binding)
+}
+
+func (m *migratingStore) GetBinding(
+	bindingID string,
+) (*service.Binding, bool, error) {
+	binding, ok, err := m.Store.GetBinding(bindingID)
+	if err != nil || !ok {
+		return binding, ok, err
+	}
+	if err = m.migrateBinding(binding); err != nil {
+		return nil, false, err
+	}
+	return binding, true, nil
+}
+
+func (m *migratingStore) ListBindings(
+	filter BindingFilter,
+) ([]*service.Binding, error) {
+	bindings, err := m.Store.ListBindings(filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range bindings {
+		if err = m.migrateBinding(binding); err != nil {
+			return nil, err
+		}
+	}
+	return bindings, nil
+}
+
+// migrateBinding upgrades the given binding in memory. If the binding's
+// instance no longer exists, the service the binding is bound to, and
+// therefore the current schema version, cannot be determined, so the binding is
+// left as is.
+func (m *migratingStore) migrateBinding(binding *service.Binding) error {
+	serviceID, err := m.getServiceID(binding)
+	if err != nil || serviceID == "" {
+		return err
+	}
+	_, err = m.registry.MigrateBinding(binding, serviceID, m.codec)
+	return err
+}
+
+// getServiceID returns the ID of the service that the given binding is bound
+// to. If the binding's instance no longer exists, an empty string is returned.
+func (m *migratingStore) getServiceID(
+	binding *service.Binding,
+) (string, error) {
+	instance, ok, err := m.Store.GetInstance(binding.InstanceID)
+	if err != nil || !ok {
+		return "", err
+	}
+	return instance.ServiceID, nil
+}
+
+// MigrateAll applies migrations from the given registry to every instance and
+// binding in the given Store and persists those that were upgraded. The
+// numbers of instances and bindings that were upgraded are returned.
+// Instances and bindings that are concurrently modified are skipped, since
+// they will be upgraded lazily when next read by a broker.
+func MigrateAll(
+	store Store,
+	registry service.MigrationRegistry,
+	codec crypto.Codec,
+) (int, int, error) {
+	instances, err := store.ListInstances(InstanceFilter{})
+	if err != nil {
+		return 0, 0, err
+	}
+	serviceIDs := map[string]string{}
+	var migratedInstances int
+	for _, instance := range instances {
+		serviceIDs[instance.InstanceID] = instance.ServiceID
+		var migrated bool
+		if migrated, err = registry.MigrateInstance(instance, codec); err != nil {
+			return migratedInstances, 0, err
+		}
+		if !migrated {
+			continue
+		}
+		if err = store.WriteInstance(instance); err != nil {
+			if _, ok := err.(*ConflictError); !ok {
+				return migratedInstances, 0, err
+			}
+			log.WithField("instanceID", instance.InstanceID).Warn(
+				"skipping migration of concurrently modified instance",
+			)
+			continue
+		}
+		migratedInstances++
+	}
+	bindings, err := store.ListBindings(BindingFilter{})
+	if err != nil {
+		return migratedInstances, 0, err
+	}
+	var migratedBindings int
+	for _, binding := range bindings {
+		serviceID, ok := serviceIDs[binding.InstanceID]
+		if !ok {
+			// The binding's instance no longer exists, so there's no way to know
+			// what migrations apply
+			continue
+		}
+		var migrated bool
+		if migrated, err = registry.MigrateBinding(
+			binding,
+			serviceID,
+			codec,
+		); err != nil {
+			return migratedInstances, migratedBindings, err
+		}
+		if !migrated {
+			continue
+		}
+		if err = store.WriteBinding(binding); err != nil {
+			if _, ok := err.(*ConflictError); !ok {
+				return migratedInstances, migratedBindings, err
+			}
+			log.WithField("bindingID", binding.BindingID).Warn(
+				"skipping migration of concurrently modified binding",
+			)
+			continue
+		}
+		migratedBindings++
+	}
+	return migratedInstances, migratedBindings, nil
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/noop"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestMigratingStore() (Store, string) {
+	serviceID := uuid.NewV4().String()
+	registry := service.NewMigrationRegistry()
+	registry.RegisterProvisioningContextMigration(
+		serviceID,
+		func(context map[string]interface{}) error {
+			context["migrated"] = true
+			return nil
+		},
+	)
+	return NewMigratingStore(testStore, registry, noop.NewCodec()), serviceID
+}
+
+func TestMigratingStoreStampsNewInstances(t *testing.T) {
+	store, serviceID := getTestMigratingStore()
+	instance := &service.Instance{
+		InstanceID: getDisposableInstanceID(),
+		ServiceID:  serviceID,
+	}
+	err := store.WriteInstance(instance)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, instance.SchemaVersion)
+}
+
+func TestMigratingStoreMigratesInstancesOnRead(t *testing.T) {
+	store, serviceID := getTestMigratingStore()
+	codec := noop.NewCodec()
+	instance := &service.Instance{
+		InstanceID: getDisposableInstanceID(),
+		ServiceID:  serviceID,
+	}
+	err := instance.SetProvisioningContext(map[string]interface{}{}, codec)
+	assert.Nil(t, err)
+	// Write through the underlying store so the instance isn't stamped
+	err = testStore.WriteInstance(instance)
+	assert.Nil(t, err)
+	retrievedInstance, ok, err := store.GetInstance(instance.InstanceID)
+	assert.Nil(t, err)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, 1, retrievedInstance.SchemaVersion)
+	context := map[string]interface{}{}
+	err = retrievedInstance.GetProvisioningContext(&context, codec)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"migrated": true}, context)
+}
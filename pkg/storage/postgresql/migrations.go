@@ -0,0 +1,96 @@
+package postgresql
+
+import (
+	"database/sql"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// migrationsLockID is an arbitrary, application-specific key used to obtain a
+// PostgreSQL advisory lock so that multiple broker replicas starting at the
+// same time don't attempt to migrate the schema concurrently.
+const migrationsLockID = 5374028
+
+// migrations is an ordered list of DDL statements. The version of a migration
+// is its (1-based) index in this list. Existing entries must NEVER be modified
+// or reordered-- schema changes must always be appended as new migrations.
+var migrations = []string{
+	// 1
+	`create table instances (
+		instance_id text primary key,
+		service_id text not null,
+		plan_id text not null,
+		status text not null,
+		created timestamp with time zone not null,
+		data bytea not null
+	)`,
+	// 2
+	`create table bindings (
+		binding_id text primary key,
+		instance_id text not null,
+		status text not null,
+		created timestamp with time zone not null,
+		data bytea not null
+	)`,
+	// 3
+	`create index bindings_instance_id_idx on bindings (instance_id)`,
+	// 4
+	`create index instances_service_id_plan_id_idx
+		on instances (service_id, plan_id)`,
+	// 5
+	`create index instances_status_idx on instances (status)`,
+	// 6
+	`alter table instances add column revision integer not null default 0`,
+	// 7
+	`alter table bindings add column revision integer not null default 0`,
+}
+
+// migrate applies, in order and within a single transaction, any migrations
+// that have not already been applied to the database
+func migrate(db *sql.DB) error {
+	return inTx(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(
+			"select pg_advisory_xact_lock($1)",
+			migrationsLockID,
+		); err != nil {
+			return fmt.Errorf("error obtaining migrations lock: %s", err)
+		}
+		if _, err := tx.Exec(
+			`create table if not exists schema_migrations (
+				version integer primary key,
+				applied timestamp with time zone not null default now()
+			)`,
+		); err != nil {
+			return fmt.Errorf("error creating schema_migrations table: %s", err)
+		}
+		var currentVersion int
+		if err := tx.QueryRow(
+			"select coalesce(max(version), 0) from schema_migrations",
+		).Scan(&currentVersion); err != nil {
+			return fmt.Errorf("error determining current schema version: %s", err)
+		}
+		for i := currentVersion; i < len(migrations); i++ {
+			version := i + 1
+			log.WithField("version", version).Info("applying schema migration")
+			if _, err := tx.Exec(migrations[i]); err != nil {
+				return fmt.Errorf(
+					"error applying schema migration %d: %s",
+					version,
+					err,
+				)
+			}
+			if _, err := tx.Exec(
+				"insert into schema_migrations (version) values ($1)",
+				version,
+			); err != nil {
+				return fmt.Errorf(
+					"error recording schema migration %d: %s",
+					version,
+					err,
+				)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,377 @@
+package postgresql
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
+	log "github.com/Sirupsen/logrus"
+	_ "github.com/lib/pq" // Postgres SQL driver
+)
+
+type store struct {
+	db *sql.DB
+}
+
+// NewStore returns a new PostgreSQL-based implementation of the storage.Store
+// interface. The database schema is created or upgraded, as needed, before the
+// store is returned.
+func NewStore(db *sql.DB) (storage.Store, error) {
+	if err := migrate(db); err != nil {
+		return nil, fmt.Errorf("error migrating database schema: %s", err)
+	}
+	return &store{
+		db: db,
+	}, nil
+}
+
+func (s *store) WriteInstance(instance *service.Instance) error {
+	instance.Revision++
+	json, err := instance.ToJSON()
+	instance.Revision--
+	if err != nil {
+		return err
+	}
+	err = s.inTx(func(tx *sql.Tx) error {
+		var result sql.Result
+		var txErr error
+		if instance.Revision == 0 {
+			// This is a new instance. The on conflict clause only permits
+			// overwriting an existing row if it, too, has never been revised.
+			result, txErr = tx.Exec(
+				`insert into instances
+					(instance_id, service_id, plan_id, status, created, revision, data)
+					values ($1, $2, $3, $4, $5, $6, $7)
+					on conflict (instance_id) do update set
+						service_id = excluded.service_id,
+						plan_id = excluded.plan_id,
+						status = excluded.status,
+						created = excluded.created,
+						revision = excluded.revision,
+						data = excluded.data
+					where instances.revision = 0`,
+				instance.InstanceID,
+				instance.ServiceID,
+				instance.PlanID,
+				instance.Status,
+				instance.Created,
+				instance.Revision+1,
+				json,
+			)
+		} else {
+			result, txErr = tx.Exec(
+				`update instances set
+					service_id = $2,
+					plan_id = $3,
+					status = $4,
+					created = $5,
+					revision = $6,
+					data = $7
+					where instance_id = $1 and revision = $8`,
+				instance.InstanceID,
+				instance.ServiceID,
+				instance.PlanID,
+				instance.Status,
+				instance.Created,
+				instance.Revision+1,
+				json,
+				instance.Revision,
+			)
+		}
+		if txErr != nil {
+			return txErr
+		}
+		return checkWritten(
+			result,
+			"instance",
+			instance.InstanceID,
+			instance.Revision,
+		)
+	})
+	if err == nil {
+		instance.Revision++
+	}
+	return err
+}
+
+func (s *store) GetInstance(
+	instanceID string,
+) (*service.Instance, bool, error) {
+	var json []byte
+	err := s.db.QueryRow(
+		"select data from instances where instance_id = $1",
+		instanceID,
+	).Scan(&json)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	instance, err := service.NewInstanceFromJSON(json)
+	if err != nil {
+		return nil, false, err
+	}
+	return instance, true, nil
+}
+
+func (s *store) ListInstances(
+	filter storage.InstanceFilter,
+) ([]*service.Instance, error) {
+	query, args := buildSelect(
+		"instances",
+		map[string]string{
+			"service_id": filter.ServiceID,
+			"plan_id":    filter.PlanID,
+			"status":     filter.Status,
+		},
+	)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying instances: %s", err)
+	}
+	defer rows.Close() // nolint: errcheck
+	instances := []*service.Instance{}
+	for rows.Next() {
+		var json []byte
+		if err := rows.Scan(&json); err != nil {
+			return nil, err
+		}
+		instance, err := service.NewInstanceFromJSON(json)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+	return instances, rows.Err()
+}
+
+func (s *store) DeleteInstance(instanceID string) (bool, error) {
+	var deleted bool
+	err := s.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			"delete from instances where instance_id = $1",
+			instanceID,
+		)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deleted = rowsAffected > 0
+		return nil
+	})
+	return deleted, err
+}
+
+func (s *store) WriteBinding(binding *service.Binding) error {
+	binding.Revision++
+	json, err := binding.ToJSON()
+	binding.Revision--
+	if err != nil {
+		return err
+	}
+	err = s.inTx(func(tx *sql.Tx) error {
+		var result sql.Result
+		var txErr error
+		if binding.Revision == 0 {
+			// This is a new binding. The on conflict clause only permits
+			// overwriting an existing row if it, too, has never been revised.
+			result, txErr = tx.Exec(
+				`insert into bindings
+					(binding_id, instance_id, status, created, revision, data)
+					values ($1, $2, $3, $4, $5, $6)
+					on conflict (binding_id) do update set
+						instance_id = excluded.instance_id,
+						status = excluded.status,
+						created = excluded.created,
+						revision = excluded.revision,
+						data = excluded.data
+					where bindings.revision = 0`,
+				binding.BindingID,
+				binding.InstanceID,
+				binding.Status,
+				binding.Created,
+				binding.Revision+1,
+				json,
+			)
+		} else {
+			result, txErr = tx.Exec(
+				`update bindings set
+					instance_id = $2,
+					status = $3,
+					created = $4,
+					revision = $5,
+					data = $6
+					where binding_id = $1 and revision = $7`,
+				binding.BindingID,
+				binding.InstanceID,
+				binding.Status,
+				binding.Created,
+				binding.Revision+1,
+				json,
+				binding.Revision,
+			)
+		}
+		if txErr != nil {
+			return txErr
+		}
+		return checkWritten(
+			result,
+			"binding",
+			binding.BindingID,
+			binding.Revision,
+		)
+	})
+	if err == nil {
+		binding.Revision++
+	}
+	return err
+}
+
+func (s *store) GetBinding(bindingID string) (*service.Binding, bool, error) {
+	var json []byte
+	err := s.db.QueryRow(
+		"select data from bindings where binding_id = $1",
+		bindingID,
+	).Scan(&json)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	binding, err := service.NewBindingFromJSON(json)
+	if err != nil {
+		return nil, false, err
+	}
+	return binding, true, nil
+}
+
+func (s *store) ListBindings(
+	filter storage.BindingFilter,
+) ([]*service.Binding, error) {
+	query, args := buildSelect(
+		"bindings",
+		map[string]string{
+			"instance_id": filter.InstanceID,
+			"status":      filter.Status,
+		},
+	)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying bindings: %s", err)
+	}
+	defer rows.Close() // nolint: errcheck
+	bindings := []*service.Binding{}
+	for rows.Next() {
+		var json []byte
+		if err := rows.Scan(&json); err != nil {
+			return nil, err
+		}
+		binding, err := service.NewBindingFromJSON(json)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings, rows.Err()
+}
+
+func (s *store) DeleteBinding(bindingID string) (bool, error) {
+	var deleted bool
+	err := s.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			"delete from bindings where binding_id = $1",
+			bindingID,
+		)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deleted = rowsAffected > 0
+		return nil
+	})
+	return deleted, err
+}
+
+func (s *store) TestConnection() error {
+	return s.db.Ping()
+}
+
+// checkWritten returns a *storage.ConflictError if the given result indicates
+// that no row was written
+func checkWritten(
+	result sql.Result,
+	kind string,
+	id string,
+	expectedRevision int,
+) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return storage.NewConflictError(kind, id, expectedRevision)
+	}
+	return nil
+}
+
+// buildSelect builds a query that selects the data column from the given
+// table, constrained by equality on every column in the criteria map that has a
+// non-empty value. Column names are never derived from user input.
+func buildSelect(
+	table string,
+	criteria map[string]string,
+) (string, []interface{}) {
+	columns := make([]string, 0, len(criteria))
+	for column, value := range criteria {
+		if value != "" {
+			columns = append(columns, column)
+		}
+	}
+	// Sort for a deterministic query string
+	sort.Strings(columns)
+	query := fmt.Sprintf("select data from %s", table)
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		if i == 0 {
+			query += " where "
+		} else {
+			query += " and "
+		}
+		query += fmt.Sprintf("%s = $%d", column, i+1)
+		args[i] = criteria[column]
+	}
+	return query, args
+}
+
+// inTx executes the provided function within a transaction. The transaction is
+// committed if the function returns without error and rolled back otherwise.
+func (s *store) inTx(fn func(*sql.Tx) error) error {
+	return inTx(s.db, fn)
+}
+
+func inTx(db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %s", err)
+	}
+	if err = fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.WithField("error", rollbackErr).Error(
+				"error rolling back transaction",
+			)
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %s", err)
+	}
+	return nil
+}
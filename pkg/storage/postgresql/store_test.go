@@ -0,0 +1,154 @@
+package postgresql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/Azure/open-service-broker-azure/pkg/storage"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var db, _ = sql.Open(
+	"postgres",
+	"postgres://postgres@postgres:5432/postgres?sslmode=disable",
+)
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	assert.Nil(t, migrate(db))
+	assert.Nil(t, migrate(db))
+	var version int
+	err := db.QueryRow(
+		"select max(version) from schema_migrations",
+	).Scan(&version)
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations), version)
+}
+
+func TestWriteAndGetInstance(t *testing.T) {
+	testStore := getTestStore(t)
+	instanceID := getDisposableInstanceID()
+	// First assert that the instance doesn't exist
+	_, ok, err := testStore.GetInstance(instanceID)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	// Store the instance
+	err = testStore.WriteInstance(&service.Instance{
+		InstanceID: instanceID,
+		Status:     service.InstanceStateProvisioning,
+	})
+	assert.Nil(t, err)
+	// Overwrite the instance
+	err = testStore.WriteInstance(&service.Instance{
+		InstanceID: instanceID,
+		Status:     service.InstanceStateProvisioned,
+	})
+	assert.Nil(t, err)
+	// Retrieve the instance
+	instance, ok, err := testStore.GetInstance(instanceID)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	if assert.NotNil(t, instance, "instance should not be nil") {
+		assert.Equal(t, instanceID, instance.InstanceID)
+		assert.Equal(t, service.InstanceStateProvisioned, instance.Status)
+	}
+}
+
+func TestDeleteInstance(t *testing.T) {
+	testStore := getTestStore(t)
+	instanceID := getDisposableInstanceID()
+	// Try to delete the non-existing instance
+	ok, err := testStore.DeleteInstance(instanceID)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	// Store, then delete the instance
+	err = testStore.WriteInstance(&service.Instance{
+		InstanceID: instanceID,
+	})
+	assert.Nil(t, err)
+	ok, err = testStore.DeleteInstance(instanceID)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	_, ok, err = testStore.GetInstance(instanceID)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+}
+
+func TestWriteAndGetBinding(t *testing.T) {
+	testStore := getTestStore(t)
+	bindingID := getDisposableBindingID()
+	// First assert that the binding doesn't exist
+	_, ok, err := testStore.GetBinding(bindingID)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	// Store the binding
+	err = testStore.WriteBinding(&service.Binding{
+		BindingID:  bindingID,
+		InstanceID: getDisposableInstanceID(),
+	})
+	assert.Nil(t, err)
+	// Retrieve the binding
+	binding, ok, err := testStore.GetBinding(bindingID)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	if assert.NotNil(t, binding, "binding should not be nil") {
+		assert.Equal(t, bindingID, binding.BindingID)
+	}
+}
+
+func TestDeleteBinding(t *testing.T) {
+	testStore := getTestStore(t)
+	bindingID := getDisposableBindingID()
+	// Try to delete the non-existing binding
+	ok, err := testStore.DeleteBinding(bindingID)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	// Store, then delete the binding
+	err = testStore.WriteBinding(&service.Binding{
+		BindingID: bindingID,
+	})
+	assert.Nil(t, err)
+	ok, err = testStore.DeleteBinding(bindingID)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	_, ok, err = testStore.GetBinding(bindingID)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+}
+
+func getTestStore(t *testing.T) storage.Store {
+	testStore, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testStore
+}
+
+func getDisposableInstanceID() string {
+	return uuid.NewV4().String()
+}
+
+func getDisposableBindingID() string {
+	return uuid.NewV4().String()
+}
+
+func TestBuildSelect(t *testing.T) {
+	query, args := buildSelect(
+		"instances",
+		map[string]string{
+			"status":     "PROVISIONED",
+			"service_id": "foo",
+			"plan_id":    "",
+		},
+	)
+	assert.Equal(
+		t,
+		"select data from instances where service_id = $1 and status = $2",
+		query,
+	)
+	assert.Equal(t, []interface{}{"foo", "PROVISIONED"}, args)
+	query, args = buildSelect("bindings", map[string]string{})
+	assert.Equal(t, "select data from bindings", query)
+	assert.Empty(t, args)
+}
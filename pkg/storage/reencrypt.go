@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	log "github.com/Sirupsen/logrus"
+)
+
+// Reencrypt re-encrypts, using the given Codec's currently active key, every
+// encrypted field of every instance and binding in the given Store and
+// persists those that were modified. Fields whose ciphertexts are not yet bound
+// to their associated data (see service.GetAssociatedData) are bound as they
+// are re-encrypted. If the given Codec implements crypto.Rotator, fields that
+// are already bound and encrypted using the active key are left alone.
+// Otherwise, every encrypted field is re-encrypted. The numbers of
+// instances and bindings that were re-encrypted are returned. Instances and
+// bindings that are concurrently modified are skipped, since re-encryption is
+// safe to run again.
+func Reencrypt(store Store, codec crypto.Codec) (int, int, error) {
+	instances, err := store.ListInstances(InstanceFilter{})
+	if err != nil {
+		return 0, 0, err
+	}
+	var reencryptedInstances int
+	for _, instance := range instances {
+		var reencrypted bool
+		if reencrypted, err = reencryptFields(
+			codec,
+			instance.InstanceID,
+			map[string]*[]byte{
+				service.FieldProvisioningParameters: &instance.EncryptedProvisioningParameters, // nolint: lll
+				service.FieldUpdatingParameters:     &instance.EncryptedUpdatingParameters,
+				service.FieldProvisioningContext:    &instance.EncryptedProvisioningContext,
+			},
+		); err != nil {
+			return reencryptedInstances, 0, fmt.Errorf(
+				`error re-encrypting instance "%s": %s`,
+				instance.InstanceID,
+				err,
+			)
+		}
+		if !reencrypted {
+			continue
+		}
+		if err = store.WriteInstance(instance); err != nil {
+			if _, ok := err.(*ConflictError); !ok {
+				return reencryptedInstances, 0, err
+			}
+			log.WithField("instanceID", instance.InstanceID).Warn(
+				"skipping re-encryption of concurrently modified instance",
+			)
+			continue
+		}
+		reencryptedInstances++
+	}
+	bindings, err := store.ListBindings(BindingFilter{})
+	if err != nil {
+		return reencryptedInstances, 0, err
+	}
+	var reencryptedBindings int
+	for _, binding := range bindings {
+		var reencrypted bool
+		if reencrypted, err = reencryptFields(
+			codec,
+			binding.BindingID,
+			map[string]*[]byte{
+				service.FieldBindingParameters: &binding.EncryptedBindingParameters,
+				service.FieldBindingContext:    &binding.EncryptedBindingContext,
+				service.FieldCredentials:       &binding.EncryptedCredentials,
+			},
+		); err != nil {
+			return reencryptedInstances, reencryptedBindings, fmt.Errorf(
+				`error re-encrypting binding "%s": %s`,
+				binding.BindingID,
+				err,
+			)
+		}
+		if !reencrypted {
+			continue
+		}
+		if err = store.WriteBinding(binding); err != nil {
+			if _, ok := err.(*ConflictError); !ok {
+				return reencryptedInstances, reencryptedBindings, err
+			}
+			log.WithField("bindingID", binding.BindingID).Warn(
+				"skipping re-encryption of concurrently modified binding",
+			)
+			continue
+		}
+		reencryptedBindings++
+	}
+	return reencryptedInstances, reencryptedBindings, nil
+}
+
+// reencryptFields re-encrypts, in place, each of the given (non-empty)
+// ciphertexts, keyed by field name, that either isn't bound to its associated
+// data or isn't already encrypted using the given Codec's active key. It
+// returns a bool indicating whether any ciphertext was re-encrypted.
+func reencryptFields(
+	codec crypto.Codec,
+	id string,
+	ciphertexts map[string]*[]byte,
+) (bool, error) {
+	rotator, isRotator := codec.(crypto.Rotator)
+	var reencrypted bool
+	for field, ciphertext := range ciphertexts {
+		if len(*ciphertext) == 0 {
+			continue
+		}
+		associatedData := service.GetAssociatedData(id, field)
+		plaintext, err := codec.Decrypt(*ciphertext, associatedData)
+		if err == nil {
+			if isRotator && rotator.IsCurrent(*ciphertext) {
+				continue
+			}
+		} else if plaintext, err = codec.Decrypt(*ciphertext, nil); err != nil {
+			return false, fmt.Errorf(`error decrypting field "%s": %s`, field, err)
+		}
+		if *ciphertext, err = codec.Encrypt(plaintext, associatedData); err != nil {
+			return false, err
+		}
+		reencrypted = true
+	}
+	return reencrypted, nil
+}
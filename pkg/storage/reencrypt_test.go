@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/crypto"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/keyring"
+	"github.com/Azure/open-service-broker-azure/pkg/crypto/noop"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReencrypt(t *testing.T) {
+	oldCodec, err := keyring.NewCodec(
+		"old",
+		map[string]crypto.Codec{"old": noop.NewCodec()},
+		nil,
+	)
+	assert.Nil(t, err)
+	instance := &service.Instance{
+		InstanceID: getDisposableInstanceID(),
+	}
+	err = instance.SetProvisioningContext(
+		map[string]interface{}{"foo": "bar"},
+		oldCodec,
+	)
+	assert.Nil(t, err)
+	err = testStore.WriteInstance(instance)
+	assert.Nil(t, err)
+	newCodec, err := keyring.NewCodec(
+		"new",
+		map[string]crypto.Codec{
+			"old": noop.NewCodec(),
+			"new": noop.NewCodec(),
+		},
+		// Other tests leave untagged ciphertexts in the store
+		noop.NewCodec(),
+	)
+	assert.Nil(t, err)
+	instances, _, err := Reencrypt(testStore, newCodec)
+	assert.Nil(t, err)
+	assert.True(t, instances > 0)
+	retrievedInstance, ok, err := testStore.GetInstance(instance.InstanceID)
+	assert.Nil(t, err)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.True(
+		t,
+		newCodec.(crypto.Rotator).IsCurrent(
+			retrievedInstance.EncryptedProvisioningContext,
+		),
+	)
+	context := map[string]interface{}{}
+	err = retrievedInstance.GetProvisioningContext(&context, newCodec)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"foo": "bar"}, context)
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	"github.com/Azure/open-service-broker-azure/pkg/service"
+	"github.com/go-redis/redis"
+)
+
+// RebuildIndices adds every instance and binding in the keyspace used by a
+// Redis-based Store having the given key prefix to the indices that the Store
+// uses for listing them. Records written by versions of the broker that
+// predate those indices are otherwise invisible to ListInstances and
+// ListBindings. Index entries are only ever added, so the rebuild is safe to
+// run more than once and concurrently with a running broker. Once it succeeds,
+// a marker is recorded so that AreIndicesBuilt can report that a rebuild is no
+// longer needed. The numbers of instances and bindings indexed are returned.
+func RebuildIndices(
+	redisClient *redis.Client,
+	keyPrefix string,
+) (int, int, error) {
+	s := &store{
+		redisClient: redisClient,
+		keyPrefix:   keyPrefix,
+	}
+	instances, err := s.rebuildIndices(instancesKeyspace)
+	if err != nil {
+		return instances, 0, err
+	}
+	bindings, err := s.rebuildIndices(bindingsKeyspace)
+	if err != nil {
+		return instances, bindings, err
+	}
+	if err = redisClient.Set(
+		rediskey.Join(keyPrefix, indicesBuiltKey),
+		"true",
+		0,
+	).Err(); err != nil {
+		return instances, bindings, fmt.Errorf(
+			"error recording that indices were rebuilt: %s",
+			err,
+		)
+	}
+	return instances, bindings, nil
+}
+
+// AreIndicesBuilt returns a bool indicating whether RebuildIndices has already
+// completed successfully for the keyspace used by a Redis-based Store having
+// the given key prefix. Every record written since then has been indexed as it
+// was written, so there's no need to rebuild the indices again.
+func AreIndicesBuilt(
+	redisClient *redis.Client,
+	keyPrefix string,
+) (bool, error) {
+	exists, err := redisClient.Exists(
+		rediskey.Join(keyPrefix, indicesBuiltKey),
+	).Result()
+	if err != nil {
+		return false, fmt.Errorf(
+			"error checking whether indices have been built: %s",
+			err,
+		)
+	}
+	return exists > 0, nil
+}
+
+func (s *store) rebuildIndices(keyspace string) (int, error) {
+	pattern := rediskey.Join(s.keyPrefix, keyspace, "*")
+	keyspacePrefix := strings.TrimSuffix(pattern, "*")
+	var indexed int
+	var cursor uint64
+	for {
+		keys, nextCursor, err := s.redisClient.Scan(cursor, pattern, 100).Result()
+		if err != nil {
+			return indexed, fmt.Errorf("error scanning %s keys: %s", keyspace, err)
+		}
+		for _, key := range keys {
+			id := strings.TrimPrefix(key, keyspacePrefix)
+			ok, err := s.rebuildIndicesForKey(keyspace, key, id)
+			if err != nil {
+				return indexed, fmt.Errorf(
+					`error rebuilding indices for key "%s": %s`,
+					key,
+					err,
+				)
+			}
+			if ok {
+				indexed++
+			}
+		}
+		if nextCursor == 0 {
+			return indexed, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+func (s *store) rebuildIndicesForKey(
+	keyspace string,
+	key string,
+	id string,
+) (bool, error) {
+	var indexed bool
+	err := s.redisClient.Watch(func(tx *redis.Tx) error {
+		jsonBytes, err := tx.Get(key).Bytes()
+		if err == redis.Nil {
+			// Deleted since the scan; nothing to index
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var indexNames []string
+		switch keyspace {
+		case instancesKeyspace:
+			var instance *service.Instance
+			if instance, err = service.NewInstanceFromJSON(jsonBytes); err != nil {
+				return err
+			}
+			indexNames = s.getInstanceIndexNames(instance)
+		case bindingsKeyspace:
+			var binding *service.Binding
+			if binding, err = service.NewBindingFromJSON(jsonBytes); err != nil {
+				return err
+			}
+			indexNames = s.getBindingIndexNames(binding)
+		}
+		// The record is watched, so a concurrent write that moves it to
+		// different indices aborts this transaction instead of leaving a stale
+		// index entry behind
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			for _, indexName := range indexNames {
+				pipeline.SAdd(indexName, id)
+			}
+			return nil
+		})
+		indexed = err == nil
+		return err
+	}, key)
+	if err == redis.TxFailedErr {
+		// The record was written concurrently, which indexes it anyway
+		return false, nil
+	}
+	return indexed, err
+}
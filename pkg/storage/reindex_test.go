@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebuildIndices(t *testing.T) {
+	serviceID := uuid.NewV4().String()
+	instanceID := getDisposableInstanceID()
+	bindingID := getDisposableBindingID()
+	// Write an instance and a binding directly, without indexing them, as older
+	// versions of the broker did
+	statCmd := redisClient.Set(
+		testStore.getInstanceKey(instanceID),
+		fmt.Sprintf(
+			`{"instanceId":"%s","serviceId":"%s"}`,
+			instanceID,
+			serviceID,
+		),
+		0,
+	)
+	assert.Nil(t, statCmd.Err())
+	statCmd = redisClient.Set(
+		testStore.getBindingKey(bindingID),
+		fmt.Sprintf(
+			`{"bindingId":"%s","instanceId":"%s"}`,
+			bindingID,
+			instanceID,
+		),
+		0,
+	)
+	assert.Nil(t, statCmd.Err())
+	// Assert that neither can be listed yet
+	instances, err := testStore.ListInstances(InstanceFilter{
+		ServiceID: serviceID,
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, instances)
+	bindings, err := testStore.ListBindings(BindingFilter{
+		InstanceID: instanceID,
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, bindings)
+	err = redisClient.Del(
+		rediskey.Join(testStore.keyPrefix, indicesBuiltKey),
+	).Err()
+	assert.Nil(t, err)
+	built, err := AreIndicesBuilt(redisClient, testStore.keyPrefix)
+	assert.Nil(t, err)
+	assert.False(t, built)
+	instancesIndexed, bindingsIndexed, err := RebuildIndices(
+		redisClient,
+		testStore.keyPrefix,
+	)
+	assert.Nil(t, err)
+	assert.True(t, instancesIndexed >= 1)
+	assert.True(t, bindingsIndexed >= 1)
+	built, err = AreIndicesBuilt(redisClient, testStore.keyPrefix)
+	assert.Nil(t, err)
+	assert.True(t, built)
+	// Assert that both can now be listed
+	instances, err = testStore.ListInstances(InstanceFilter{
+		ServiceID: serviceID,
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, instanceID, instances[0].InstanceID)
+	}
+	bindings, err = testStore.ListBindings(BindingFilter{
+		InstanceID: instanceID,
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, bindings, 1) {
+		assert.Equal(t, bindingID, bindings[0].BindingID)
+	}
+	// Assert that rebuilding again is harmless
+	_, _, err = RebuildIndices(redisClient, testStore.keyPrefix)
+	assert.Nil(t, err)
+	instances, err = testStore.ListInstances(InstanceFilter{
+		ServiceID: serviceID,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, instances, 1)
+}
@@ -1,26 +1,50 @@
 package storage
 
 import (
+	"fmt"
+
+	"github.com/Azure/open-service-broker-azure/pkg/internal/rediskey"
 	"github.com/Azure/open-service-broker-azure/pkg/service"
 	"github.com/go-redis/redis"
 )
 
+const (
+	instancesKeyspace = "instances"
+	bindingsKeyspace  = "bindings"
+	indicesKeyspace   = "indices"
+	// indicesBuiltKey marks a keyspace whose pre-existing records have all been
+	// indexed
+	indicesBuiltKey = "indicesBuilt"
+)
+
 // Store is an interface to be implemented by types capable of handling
 // persistence for other broker-related types
 type Store interface {
-	// WriteInstance persists the given instance to the underlying storage
+	// WriteInstance persists the given instance to the underlying storage. If
+	// the revision of the instance found in the underlying storage does not
+	// match the given instance's revision, a *ConflictError is returned.
+	// Otherwise, the given instance's revision is incremented as it is written.
 	WriteInstance(instance *service.Instance) error
 	// GetInstance retrieves a persisted instance from the underlying storage by
 	// instance id
 	GetInstance(instanceID string) (*service.Instance, bool, error)
+	// ListInstances retrieves all persisted instances that satisfy the given
+	// filter from the underlying storage
+	ListInstances(filter InstanceFilter) ([]*service.Instance, error)
 	// DeleteInstance deletes a persisted instance from the underlying storage by
 	// instance id
 	DeleteInstance(instanceID string) (bool, error)
-	// WriteBinding persists the given binding to the underlying storage
+	// WriteBinding persists the given binding to the underlying storage. If the
+	// revision of the binding found in the underlying storage does not match
+	// the given binding's revision, a *ConflictError is returned. Otherwise, the
+	// given binding's revision is incremented as it is written.
 	WriteBinding(binding *service.Binding) error
 	// GetBinding retrieves a persisted instance from the underlying storage by
 	// binding id
 	GetBinding(bindingID string) (*service.Binding, bool, error)
+	// ListBindings retrieves all persisted bindings that satisfy the given
+	// filter from the underlying storage
+	ListBindings(filter BindingFilter) ([]*service.Binding, error)
 	// DeleteBinding deletes a persisted binding from the underlying storage by
 	// binding id
 	DeleteBinding(bindingID string) (bool, error)
@@ -31,27 +55,77 @@ type Store interface {
 
 type store struct {
 	redisClient *redis.Client
+	keyPrefix   string
 }
 
-// NewStore returns a new Redis-based implementation of the Store interface
-func NewStore(redisClient *redis.Client) Store {
+// NewStore returns a new Redis-based implementation of the Store interface.
+// All keys written by the store are namespaced by the given key prefix so that
+// multiple brokers may safely share a single Redis database.
+func NewStore(redisClient *redis.Client, keyPrefix string) Store {
 	return &store{
 		redisClient: redisClient,
+		keyPrefix:   keyPrefix,
 	}
 }
 
 func (s *store) WriteInstance(instance *service.Instance) error {
-	json, err := instance.ToJSON()
-	if err != nil {
+	instanceKey := s.getInstanceKey(instance.InstanceID)
+	err := s.redisClient.Watch(func(tx *redis.Tx) error {
+		oldInstance, ok, err := getInstance(tx, instanceKey)
+		if err != nil {
+			return err
+		}
+		var oldRevision int
+		if ok {
+			oldRevision = oldInstance.Revision
+		}
+		if oldRevision != instance.Revision {
+			return NewConflictError(
+				"instance",
+				instance.InstanceID,
+				instance.Revision,
+			)
+		}
+		instance.Revision++
+		json, err := instance.ToJSON()
+		if err != nil {
+			instance.Revision--
+			return err
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			if ok {
+				for _, indexName := range s.getInstanceIndexNames(oldInstance) {
+					pipeline.SRem(indexName, oldInstance.InstanceID)
+				}
+			}
+			pipeline.Set(instanceKey, json, 0)
+			for _, indexName := range s.getInstanceIndexNames(instance) {
+				pipeline.SAdd(indexName, instance.InstanceID)
+			}
+			return nil
+		})
+		if err != nil {
+			instance.Revision--
+		}
 		return err
+	}, instanceKey)
+	if err == redis.TxFailedErr {
+		return NewConflictError("instance", instance.InstanceID, instance.Revision)
 	}
-	return s.redisClient.Set(instance.InstanceID, json, 0).Err()
+	return err
 }
 
 func (s *store) GetInstance(
 	instanceID string,
 ) (*service.Instance, bool, error) {
-	strCmd := s.redisClient.Get(instanceID)
+	return getInstance(s.redisClient, s.getInstanceKey(instanceID))
+}
+
+func getInstance(
+	redisClient redis.Cmdable,
+	instanceKey string,
+) (*service.Instance, bool, error) {
+	strCmd := redisClient.Get(instanceKey)
 	if err := strCmd.Err(); err == redis.Nil {
 		return nil, false, nil
 	} else if err != nil {
@@ -68,29 +142,131 @@ func (s *store) GetInstance(
 	return instance, true, nil
 }
 
-func (s *store) DeleteInstance(instanceID string) (bool, error) {
-	strCmd := s.redisClient.Get(instanceID)
-	if err := strCmd.Err(); err == redis.Nil {
-		return false, nil
-	} else if err != nil {
-		return false, err
+func (s *store) ListInstances(
+	filter InstanceFilter,
+) ([]*service.Instance, error) {
+	indexNames := []string{s.getIndexName(instancesKeyspace)}
+	if filter.ServiceID != "" {
+		indexNames = append(
+			indexNames,
+			s.getIndexName(instancesKeyspace, "serviceID", filter.ServiceID),
+		)
 	}
-	if err := s.redisClient.Del(instanceID).Err(); err != nil {
-		return false, err
+	if filter.PlanID != "" {
+		indexNames = append(
+			indexNames,
+			s.getIndexName(instancesKeyspace, "planID", filter.PlanID),
+		)
+	}
+	if filter.Status != "" {
+		indexNames = append(
+			indexNames,
+			s.getIndexName(instancesKeyspace, "status", filter.Status),
+		)
+	}
+	instanceIDs, err := s.redisClient.SInter(indexNames...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving instance ids: %s", err)
 	}
-	return true, nil
+	instances := []*service.Instance{}
+	for _, instanceID := range instanceIDs {
+		instance, ok, err := s.GetInstance(instanceID)
+		if err != nil {
+			return nil, err
+		}
+		// The indices and the instance itself are always updated together
+		// transactionally, but check anyway that the instance was found and still
+		// matches the filter.
+		if ok && filter.Matches(instance) {
+			instances = append(instances, instance)
+		}
+	}
+	return instances, nil
+}
+
+func (s *store) DeleteInstance(instanceID string) (bool, error) {
+	for {
+		deleted, err := s.tryDeleteInstance(instanceID)
+		// If the instance was modified while we were deleting it, just try again
+		if err != redis.TxFailedErr {
+			return deleted, err
+		}
+	}
+}
+
+func (s *store) tryDeleteInstance(instanceID string) (bool, error) {
+	var deleted bool
+	instanceKey := s.getInstanceKey(instanceID)
+	err := s.redisClient.Watch(func(tx *redis.Tx) error {
+		instance, ok, err := getInstance(tx, instanceKey)
+		if err != nil || !ok {
+			return err
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			pipeline.Del(instanceKey)
+			for _, indexName := range s.getInstanceIndexNames(instance) {
+				pipeline.SRem(indexName, instanceID)
+			}
+			return nil
+		})
+		deleted = err == nil
+		return err
+	}, instanceKey)
+	return deleted, err
 }
 
 func (s *store) WriteBinding(binding *service.Binding) error {
-	json, err := binding.ToJSON()
-	if err != nil {
+	bindingKey := s.getBindingKey(binding.BindingID)
+	err := s.redisClient.Watch(func(tx *redis.Tx) error {
+		oldBinding, ok, err := getBinding(tx, bindingKey)
+		if err != nil {
+			return err
+		}
+		var oldRevision int
+		if ok {
+			oldRevision = oldBinding.Revision
+		}
+		if oldRevision != binding.Revision {
+			return NewConflictError("binding", binding.BindingID, binding.Revision)
+		}
+		binding.Revision++
+		json, err := binding.ToJSON()
+		if err != nil {
+			binding.Revision--
+			return err
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			if ok {
+				for _, indexName := range s.getBindingIndexNames(oldBinding) {
+					pipeline.SRem(indexName, oldBinding.BindingID)
+				}
+			}
+			pipeline.Set(bindingKey, json, 0)
+			for _, indexName := range s.getBindingIndexNames(binding) {
+				pipeline.SAdd(indexName, binding.BindingID)
+			}
+			return nil
+		})
+		if err != nil {
+			binding.Revision--
+		}
 		return err
+	}, bindingKey)
+	if err == redis.TxFailedErr {
+		return NewConflictError("binding", binding.BindingID, binding.Revision)
 	}
-	return s.redisClient.Set(binding.BindingID, json, 0).Err()
+	return err
 }
 
 func (s *store) GetBinding(bindingID string) (*service.Binding, bool, error) {
-	strCmd := s.redisClient.Get(bindingID)
+	return getBinding(s.redisClient, s.getBindingKey(bindingID))
+}
+
+func getBinding(
+	redisClient redis.Cmdable,
+	bindingKey string,
+) (*service.Binding, bool, error) {
+	strCmd := redisClient.Get(bindingKey)
 	if err := strCmd.Err(); err == redis.Nil {
 		return nil, false, nil
 	} else if err != nil {
@@ -107,19 +283,111 @@ func (s *store) GetBinding(bindingID string) (*service.Binding, bool, error) {
 	return binding, true, nil
 }
 
-func (s *store) DeleteBinding(bindingID string) (bool, error) {
-	strCmd := s.redisClient.Get(bindingID)
-	if err := strCmd.Err(); err == redis.Nil {
-		return false, nil
-	} else if err != nil {
-		return false, err
+func (s *store) ListBindings(filter BindingFilter) ([]*service.Binding, error) {
+	indexNames := []string{s.getIndexName(bindingsKeyspace)}
+	if filter.InstanceID != "" {
+		indexNames = append(
+			indexNames,
+			s.getIndexName(bindingsKeyspace, "instanceID", filter.InstanceID),
+		)
+	}
+	if filter.Status != "" {
+		indexNames = append(
+			indexNames,
+			s.getIndexName(bindingsKeyspace, "status", filter.Status),
+		)
+	}
+	bindingIDs, err := s.redisClient.SInter(indexNames...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving binding ids: %s", err)
+	}
+	bindings := []*service.Binding{}
+	for _, bindingID := range bindingIDs {
+		binding, ok, err := s.GetBinding(bindingID)
+		if err != nil {
+			return nil, err
+		}
+		// The indices and the binding itself are always updated together
+		// transactionally, but check anyway that the binding was found and still
+		// matches the filter.
+		if ok && filter.Matches(binding) {
+			bindings = append(bindings, binding)
+		}
 	}
-	if err := s.redisClient.Del(bindingID).Err(); err != nil {
-		return false, err
+	return bindings, nil
+}
+
+func (s *store) DeleteBinding(bindingID string) (bool, error) {
+	for {
+		deleted, err := s.tryDeleteBinding(bindingID)
+		// If the binding was modified while we were deleting it, just try again
+		if err != redis.TxFailedErr {
+			return deleted, err
+		}
 	}
-	return true, nil
+}
+
+func (s *store) tryDeleteBinding(bindingID string) (bool, error) {
+	var deleted bool
+	bindingKey := s.getBindingKey(bindingID)
+	err := s.redisClient.Watch(func(tx *redis.Tx) error {
+		binding, ok, err := getBinding(tx, bindingKey)
+		if err != nil || !ok {
+			return err
+		}
+		_, err = tx.Pipelined(func(pipeline redis.Pipeliner) error {
+			pipeline.Del(bindingKey)
+			for _, indexName := range s.getBindingIndexNames(binding) {
+				pipeline.SRem(indexName, bindingID)
+			}
+			return nil
+		})
+		deleted = err == nil
+		return err
+	}, bindingKey)
+	return deleted, err
 }
 
 func (s *store) TestConnection() error {
 	return s.redisClient.Ping().Err()
 }
+
+func (s *store) getInstanceKey(instanceID string) string {
+	return rediskey.Join(s.keyPrefix, instancesKeyspace, instanceID)
+}
+
+func (s *store) getBindingKey(bindingID string) string {
+	return rediskey.Join(s.keyPrefix, bindingsKeyspace, bindingID)
+}
+
+// getInstanceIndexNames returns the names of all the secondary-index sets that
+// the given instance's id is a member of
+func (s *store) getInstanceIndexNames(instance *service.Instance) []string {
+	return []string{
+		s.getIndexName(instancesKeyspace),
+		s.getIndexName(instancesKeyspace, "serviceID", instance.ServiceID),
+		s.getIndexName(instancesKeyspace, "planID", instance.PlanID),
+		s.getIndexName(instancesKeyspace, "status", instance.Status),
+	}
+}
+
+// getBindingIndexNames returns the names of all the secondary-index sets that
+// the given binding's id is a member of
+func (s *store) getBindingIndexNames(binding *service.Binding) []string {
+	return []string{
+		s.getIndexName(bindingsKeyspace),
+		s.getIndexName(bindingsKeyspace, "instanceID", binding.InstanceID),
+		s.getIndexName(bindingsKeyspace, "status", binding.Status),
+	}
+}
+
+// getIndexName returns the name of the secondary-index set for the given
+// keyspace. If a field and value are given, the name returned is that of the
+// set of ids having that value for that field; otherwise it is the name of the
+// set of all ids in the keyspace.
+func (s *store) getIndexName(keyspace string, fieldAndValue ...string) string {
+	return rediskey.Join(
+		s.keyPrefix,
+		append([]string{indicesKeyspace, keyspace}, fieldAndValue...)...,
+	)
+}
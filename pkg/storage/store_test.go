@@ -14,13 +14,13 @@ var (
 	redisClient = redis.NewClient(&redis.Options{
 		Addr: "redis:6379",
 	})
-	testStore = NewStore(redisClient)
+	testStore = NewStore(redisClient, "osba-test").(*store)
 )
 
 func TestWriteInstance(t *testing.T) {
 	instanceID := getDisposableInstanceID()
 	// First assert that the instance doesn't exist in Redis
-	strCmd := redisClient.Get(instanceID)
+	strCmd := redisClient.Get(testStore.getInstanceKey(instanceID))
 	assert.Equal(t, redis.Nil, strCmd.Err())
 	// Store the instance
 	err := testStore.WriteInstance(&service.Instance{
@@ -28,14 +28,32 @@ func TestWriteInstance(t *testing.T) {
 	})
 	assert.Nil(t, err)
 	// Assert that the instance is now in Redis
-	strCmd = redisClient.Get(instanceID)
+	strCmd = redisClient.Get(testStore.getInstanceKey(instanceID))
 	assert.Nil(t, strCmd.Err())
 }
 
+func TestWriteStaleInstance(t *testing.T) {
+	instanceID := getDisposableInstanceID()
+	instance := &service.Instance{
+		InstanceID: instanceID,
+	}
+	err := testStore.WriteInstance(instance)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, instance.Revision)
+	staleInstance := *instance
+	err = testStore.WriteInstance(instance)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, instance.Revision)
+	// Writing the stale copy must fail without modifying its revision
+	err = testStore.WriteInstance(&staleInstance)
+	assert.IsType(t, &ConflictError{}, err)
+	assert.Equal(t, 1, staleInstance.Revision)
+}
+
 func TestGetNonExistingInstance(t *testing.T) {
 	instanceID := getDisposableInstanceID()
 	// First assert that the instance doesn't exist in Redis
-	strCmd := redisClient.Get(instanceID)
+	strCmd := redisClient.Get(testStore.getInstanceKey(instanceID))
 	assert.Equal(t, redis.Nil, strCmd.Err())
 	// Try to retrieve the non-existing instance
 	_, ok, err := testStore.GetInstance(instanceID)
@@ -47,7 +65,11 @@ func TestGetNonExistingInstance(t *testing.T) {
 func TestGetExistingInstance(t *testing.T) {
 	instanceID := getDisposableInstanceID()
 	// First ensure the instance exists in Redis
-	statCmd := redisClient.Set(instanceID, getInstanceJSON(instanceID), 0)
+	statCmd := redisClient.Set(
+		testStore.getInstanceKey(instanceID),
+		getInstanceJSON(instanceID),
+		0,
+	)
 	assert.Nil(t, statCmd.Err())
 	// Retrieve the instance
 	instance, ok, err := testStore.GetInstance(instanceID)
@@ -63,7 +85,7 @@ func TestGetExistingInstance(t *testing.T) {
 func TestDeleteNonExistingInstance(t *testing.T) {
 	instanceID := getDisposableInstanceID()
 	// First assert that the instance doesn't exist in Redis
-	strCmd := redisClient.Get(instanceID)
+	strCmd := redisClient.Get(testStore.getInstanceKey(instanceID))
 	assert.Equal(t, redis.Nil, strCmd.Err())
 	// Try to delete the non-existing instance
 	ok, err := testStore.DeleteInstance(instanceID)
@@ -75,21 +97,25 @@ func TestDeleteNonExistingInstance(t *testing.T) {
 func TestDeleteExistingInstance(t *testing.T) {
 	instanceID := getDisposableInstanceID()
 	// First ensure the instance exists in Redis
-	statCmd := redisClient.Set(instanceID, getInstanceJSON(instanceID), 0)
+	statCmd := redisClient.Set(
+		testStore.getInstanceKey(instanceID),
+		getInstanceJSON(instanceID),
+		0,
+	)
 	assert.Nil(t, statCmd.Err())
 	// Delete the instance
 	ok, err := testStore.DeleteInstance(instanceID)
 	// Assert that the delete was successful
 	assert.True(t, ok)
 	assert.Nil(t, err)
-	strCmd := redisClient.Get(instanceID)
+	strCmd := redisClient.Get(testStore.getInstanceKey(instanceID))
 	assert.Equal(t, redis.Nil, strCmd.Err())
 }
 
 func TestWriteBinding(t *testing.T) {
 	bindingID := getDisposableBindingID()
 	// First assert that the binding doesn't exist in Redis
-	strCmd := redisClient.Get(bindingID)
+	strCmd := redisClient.Get(testStore.getBindingKey(bindingID))
 	assert.Equal(t, redis.Nil, strCmd.Err())
 	// Store the binding
 	err := testStore.WriteBinding(&service.Binding{
@@ -97,14 +123,27 @@ func TestWriteBinding(t *testing.T) {
 	})
 	assert.Nil(t, err)
 	// Assert that the binding is now in Redis
-	strCmd = redisClient.Get(bindingID)
+	strCmd = redisClient.Get(testStore.getBindingKey(bindingID))
 	assert.Nil(t, strCmd.Err())
 }
 
+func TestWriteStaleBinding(t *testing.T) {
+	bindingID := getDisposableBindingID()
+	err := testStore.WriteBinding(&service.Binding{
+		BindingID: bindingID,
+	})
+	assert.Nil(t, err)
+	// Writing another "new" binding with the same ID is a conflict
+	err = testStore.WriteBinding(&service.Binding{
+		BindingID: bindingID,
+	})
+	assert.IsType(t, &ConflictError{}, err)
+}
+
 func TestGetNonExistingBinding(t *testing.T) {
 	bindingID := getDisposableBindingID()
 	// First assert that the binding doesn't exist in Redis
-	strCmd := redisClient.Get(bindingID)
+	strCmd := redisClient.Get(testStore.getBindingKey(bindingID))
 	assert.Equal(t, redis.Nil, strCmd.Err())
 	// Try to retrieve the non-existing binding
 	_, ok, err := testStore.GetBinding(bindingID)
@@ -116,7 +155,11 @@ func TestGetNonExistingBinding(t *testing.T) {
 func TestGetExistingBinding(t *testing.T) {
 	bindingID := getDisposableBindingID()
 	// First ensure the binding exists in Redis
-	statCmd := redisClient.Set(bindingID, getBindingJSON(bindingID), 0)
+	statCmd := redisClient.Set(
+		testStore.getBindingKey(bindingID),
+		getBindingJSON(bindingID),
+		0,
+	)
 	assert.Nil(t, statCmd.Err())
 	// Retrieve the binding
 	binding, ok, err := testStore.GetBinding(bindingID)
@@ -132,7 +175,7 @@ func TestGetExistingBinding(t *testing.T) {
 func TestDeleteNonExistingBinding(t *testing.T) {
 	bindingID := getDisposableBindingID()
 	// First assert that the binding doesn't exist in Redis
-	strCmd := redisClient.Get(bindingID)
+	strCmd := redisClient.Get(testStore.getBindingKey(bindingID))
 	assert.Equal(t, redis.Nil, strCmd.Err())
 	// Try to delete the non-existing binding
 	ok, err := testStore.DeleteBinding(bindingID)
@@ -144,17 +187,110 @@ func TestDeleteNonExistingBinding(t *testing.T) {
 func TestDeleteExistingBinding(t *testing.T) {
 	bindingID := getDisposableBindingID()
 	// First ensure the binding exists in Redis
-	statCmd := redisClient.Set(bindingID, getBindingJSON(bindingID), 0)
+	statCmd := redisClient.Set(
+		testStore.getBindingKey(bindingID),
+		getBindingJSON(bindingID),
+		0,
+	)
 	assert.Nil(t, statCmd.Err())
 	// Delete the binding
 	ok, err := testStore.DeleteBinding(bindingID)
 	// Assert that the delete was successful
 	assert.True(t, ok)
 	assert.Nil(t, err)
-	strCmd := redisClient.Get(bindingID)
+	strCmd := redisClient.Get(testStore.getBindingKey(bindingID))
 	assert.Equal(t, redis.Nil, strCmd.Err())
 }
 
+func TestListInstances(t *testing.T) {
+	serviceID := uuid.NewV4().String()
+	instanceIDs := []string{
+		getDisposableInstanceID(),
+		getDisposableInstanceID(),
+	}
+	err := testStore.WriteInstance(&service.Instance{
+		InstanceID: instanceIDs[0],
+		ServiceID:  serviceID,
+		Status:     service.InstanceStateProvisioning,
+	})
+	assert.Nil(t, err)
+	err = testStore.WriteInstance(&service.Instance{
+		InstanceID: instanceIDs[1],
+		ServiceID:  serviceID,
+		Status:     service.InstanceStateProvisioningFailed,
+	})
+	assert.Nil(t, err)
+	// List all instances of the service
+	instances, err := testStore.ListInstances(InstanceFilter{
+		ServiceID: serviceID,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, instances, 2)
+	// List only failed instances of the service
+	instances, err = testStore.ListInstances(InstanceFilter{
+		ServiceID: serviceID,
+		Status:    service.InstanceStateProvisioningFailed,
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, instanceIDs[1], instances[0].InstanceID)
+	}
+	// Change the status of the first instance and assert that the status index
+	// has been updated
+	err = testStore.WriteInstance(&service.Instance{
+		InstanceID: instanceIDs[0],
+		ServiceID:  serviceID,
+		Status:     service.InstanceStateProvisioningFailed,
+	})
+	assert.Nil(t, err)
+	instances, err = testStore.ListInstances(InstanceFilter{
+		ServiceID: serviceID,
+		Status:    service.InstanceStateProvisioning,
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, instances)
+	// Delete an instance and assert it is no longer listed
+	_, err = testStore.DeleteInstance(instanceIDs[0])
+	assert.Nil(t, err)
+	instances, err = testStore.ListInstances(InstanceFilter{
+		ServiceID: serviceID,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, instances, 1)
+}
+
+func TestListBindings(t *testing.T) {
+	instanceID := getDisposableInstanceID()
+	bindingIDs := []string{
+		getDisposableBindingID(),
+		getDisposableBindingID(),
+	}
+	for _, bindingID := range bindingIDs {
+		err := testStore.WriteBinding(&service.Binding{
+			BindingID:  bindingID,
+			InstanceID: instanceID,
+			Status:     service.BindingStateBound,
+		})
+		assert.Nil(t, err)
+	}
+	// List all bindings of the instance
+	bindings, err := testStore.ListBindings(BindingFilter{
+		InstanceID: instanceID,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, bindings, 2)
+	// Delete a binding and assert it is no longer listed
+	_, err = testStore.DeleteBinding(bindingIDs[0])
+	assert.Nil(t, err)
+	bindings, err = testStore.ListBindings(BindingFilter{
+		InstanceID: instanceID,
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, bindings, 1) {
+		assert.Equal(t, bindingIDs[1], bindings[0].BindingID)
+	}
+}
+
 func getInstanceJSON(instanceID string) string {
 	return fmt.Sprintf(`{"instanceId":"%s"}`, instanceID)
 }
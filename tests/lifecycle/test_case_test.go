@@ -110,18 +110,18 @@ func (m *moduleLifecycleTestCase) execute(resourceGroup string) error {
 	if err != nil {
 		return err
 	}
-	stepName, ok := provisioner.GetFirstStepName()
-	// There MUST be a first step
-	if !ok {
+	stepNames := provisioner.GetStepNames()
+	// There MUST be at least one step
+	if len(stepNames) == 0 {
 		return fmt.Errorf(
 			`Module "%s" provisioner has no steps`,
 			m.module.GetName(),
 		)
 	}
-	// Execute provisioning steps until there are none left
-	for {
-		var step service.ProvisioningStep
-		step, ok = provisioner.GetStep(stepName)
+	// Execute provisioning steps one at a time, in an order that respects their
+	// dependencies
+	for _, stepName := range stepNames {
+		step, ok := provisioner.GetStep(stepName)
 		if !ok {
 			return fmt.Errorf(
 				`Module "%s" provisioning step "%s" not found`,
@@ -129,6 +129,19 @@ func (m *moduleLifecycleTestCase) execute(resourceGroup string) error {
 				stepName,
 			)
 		}
+		var skipped bool
+		skipped, err = step.IsSkipped(
+			plan,
+			m.standardProvisioningContext,
+			pc,
+			m.provisioningParameters,
+		)
+		if err != nil {
+			return err
+		}
+		if skipped {
+			continue
+		}
 		// Assign results to temp variable in case they're nil. We don't want
 		// pc to ever be nil, or we risk a nil pointer dereference in the
 		// cleanup logic.
@@ -144,17 +157,22 @@ func (m *moduleLifecycleTestCase) execute(resourceGroup string) error {
 			return err
 		}
 		pc = tempPC
-		stepName, ok = provisioner.GetNextStepName(stepName)
-		// If there is no next step, we're done with provisioning
-		if !ok {
-			break
-		}
 	}
 
 	// Bind
-	bc, credentials, err := serviceManager.Bind(
+	bid := uuid.NewV4().String()
+	binder, err := serviceManager.GetBinder(plan)
+	if err != nil {
+		return err
+	}
+	bc, credentials, err := service.BindSynchronously(
+		ctx,
+		binder,
+		bid,
+		plan,
 		m.standardProvisioningContext,
 		pc,
+		serviceManager.GetEmptyBindingContext(),
 		m.bindingParameters,
 	)
 	if err != nil {
@@ -170,7 +188,19 @@ func (m *moduleLifecycleTestCase) execute(resourceGroup string) error {
 	}
 
 	// Unbind
-	err = serviceManager.Unbind(m.standardProvisioningContext, pc, bc)
+	unbinder, err := serviceManager.GetUnbinder(plan)
+	if err != nil {
+		return err
+	}
+	err = service.UnbindSynchronously(
+		ctx,
+		unbinder,
+		bid,
+		plan,
+		m.standardProvisioningContext,
+		pc,
+		bc,
+	)
 	if err != nil {
 		return err
 	}
@@ -180,16 +210,17 @@ func (m *moduleLifecycleTestCase) execute(resourceGroup string) error {
 	if err != nil {
 		return nil
 	}
-	stepName, ok = deprovisioner.GetFirstStepName()
-	// There MUST be a first step
-	if !ok {
+	stepNames = deprovisioner.GetStepNames()
+	// There MUST be at least one step
+	if len(stepNames) == 0 {
 		return fmt.Errorf(
 			`Module "%s" deprovisioner has no steps`,
 			m.module.GetName(),
 		)
 	}
-	// Execute deprovisioning steps until there are none left
-	for {
+	// Execute deprovisioning steps one at a time, in an order that respects
+	// their dependencies
+	for _, stepName := range stepNames {
 		step, ok := deprovisioner.GetStep(stepName)
 		if !ok {
 			return fmt.Errorf(
@@ -198,6 +229,18 @@ func (m *moduleLifecycleTestCase) execute(resourceGroup string) error {
 				stepName,
 			)
 		}
+		var skipped bool
+		skipped, err = step.IsSkipped(
+			nil, // Plan
+			m.standardProvisioningContext,
+			pc,
+		)
+		if err != nil {
+			return err
+		}
+		if skipped {
+			continue
+		}
 		// Assign results to temp variable in case they're nil. We don't want
 		// pc to ever be nil, or we risk a nil pointer dereference in the
 		// cleanup logic.
@@ -212,11 +255,6 @@ func (m *moduleLifecycleTestCase) execute(resourceGroup string) error {
 			return err
 		}
 		pc = tempPC
-		stepName, ok = deprovisioner.GetNextStepName(stepName)
-		// If there is no next step, we're done with deprovisioning
-		if !ok {
-			break
-		}
 	}
 
 	return nil